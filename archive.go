@@ -0,0 +1,171 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"nostr-exif-scan/pkg/exifscan"
+)
+
+type archiveEntry struct {
+	EventID    string    `json:"event_id"`
+	URL        string    `json:"url"`
+	SHA256     string    `json:"sha256"`
+	Categories []string  `json:"categories"`
+	Image      string    `json:"image"`
+	Metadata   string    `json:"metadata"`
+	Event      string    `json:"event"`
+	ArchivedAt time.Time `json:"archived_at"`
+}
+
+type archiveIndex struct {
+	Npub      string         `json:"npub"`
+	StartedAt time.Time      `json:"started_at"`
+	Since     string         `json:"since,omitempty"`
+	Until     string         `json:"until,omitempty"`
+	Entries   []archiveEntry `json:"entries"`
+}
+
+// archive preserves flagged images as evidence. Each run gets its own
+// timestamped directory holding one subdirectory per event plus index.json.
+type archive struct {
+	dir   string
+	mu    sync.Mutex
+	index archiveIndex
+}
+
+func newArchive(root, npub string) (*archive, error) {
+	now := time.Now().UTC()
+	dir := filepath.Join(root, now.Format("20060102T150405Z"))
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return nil, err
+	}
+	a := &archive{
+		dir: dir,
+		index: archiveIndex{
+			Npub:      npub,
+			StartedAt: now,
+			Since:     *sinceFlag,
+			Until:     *untilFlag,
+			Entries:   []archiveEntry{},
+		},
+	}
+	return a, a.writeIndex()
+}
+
+func (a *archive) add(r *exifscan.ImageResult) error {
+	// Blossom uploads no note links have no event to group them under.
+	sub := r.EventID
+	if sub == "" {
+		sub = filepath.Join("unlinked", r.SHA256)
+	}
+	if err := os.MkdirAll(filepath.Join(a.dir, sub), 0o700); err != nil {
+		return err
+	}
+
+	entry := archiveEntry{
+		EventID:    r.EventID,
+		URL:        r.URL,
+		SHA256:     r.SHA256,
+		Categories: r.Categories(),
+		Image:      filepath.Join(sub, r.SHA256+imageExt(r.URL)),
+		Metadata:   filepath.Join(sub, r.SHA256+".exif.json"),
+		Event:      filepath.Join(sub, "event.json"),
+		ArchivedAt: time.Now().UTC(),
+	}
+
+	if err := writeImage(filepath.Join(a.dir, entry.Image), r); err != nil {
+		return err
+	}
+	meta, err := json.MarshalIndent(archiveMetadata(r), "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.WriteFile(filepath.Join(a.dir, entry.Metadata), meta, 0o600); err != nil {
+		return err
+	}
+	evt, err := json.MarshalIndent(r.Event, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.WriteFile(filepath.Join(a.dir, entry.Event), evt, 0o600); err != nil {
+		return err
+	}
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.index.Entries = append(a.index.Entries, entry)
+	return a.writeIndex()
+}
+
+// writeIndex rewrites index.json after every entry so an interrupted scan
+// still leaves a usable archive behind. Callers must hold a.mu.
+func (a *archive) writeIndex() error {
+	data, err := json.MarshalIndent(a.index, "", "  ")
+	if err != nil {
+		return err
+	}
+	tmp := filepath.Join(a.dir, "index.json.tmp")
+	if err := os.WriteFile(tmp, data, 0o600); err != nil {
+		return err
+	}
+	return os.Rename(tmp, filepath.Join(a.dir, "index.json"))
+}
+
+// archiveMetadata is the raw EXIF, or for the audio files, PDFs, SVGs
+// and GIFs that keep their metadata elsewhere, the fields read from them.
+func archiveMetadata(r *exifscan.ImageResult) any {
+	switch {
+	case r.Exif != nil:
+		return r.Exif
+	case r.AllTags != nil:
+		return r.AllTags
+	}
+	return r.Tags
+}
+
+func imageExt(rawURL string) string {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return ".bin"
+	}
+	ext := strings.ToLower(path.Ext(u.Path))
+	if ext == "" {
+		return ".bin"
+	}
+	return ext
+}
+
+func (a *archive) String() string {
+	return fmt.Sprintf("%s (%d entries)", a.dir, len(a.index.Entries))
+}
+
+// writeImage copies the image bytes, from memory or from the file they
+// were spooled to.
+func writeImage(path string, r *exifscan.ImageResult) error {
+	if r.DataFile == "" {
+		return os.WriteFile(path, r.Data, 0o600)
+	}
+	src, err := os.Open(r.DataFile)
+	if err != nil {
+		return err
+	}
+	defer src.Close()
+	dst, err := os.OpenFile(path, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, 0o600)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(dst, src); err != nil {
+		dst.Close()
+		return err
+	}
+	return dst.Close()
+}
@@ -0,0 +1,134 @@
+package main
+
+import (
+	"bufio"
+	"errors"
+	"flag"
+	"fmt"
+	"io/fs"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/nbd-wtf/go-nostr"
+	"github.com/nbd-wtf/go-nostr/nip19"
+
+	"nostr-exif-scan/pkg/exifscan"
+)
+
+func baselineFlag(fs *flag.FlagSet) *string {
+	return fs.String("baseline", "exifscan-baseline.txt", "File of accepted findings to suppress: event IDs, fingerprint:<hex>, sha256:<image hash> or category:<name> lines")
+}
+
+// baseline is a plain text file of accepted findings, one per line: an
+// event ID (hex, note1 or nevent1) suppresses a post, fingerprint:<hex> one
+// leak, sha256:<hex> an image wherever it is posted and category:<name> a
+// leak category everywhere. Blank lines and everything after '#' are ignored, so
+// entries can carry a note.
+type baseline struct {
+	path         string
+	events       map[string]bool
+	fingerprints map[string]bool
+	hashes       map[string]bool
+	categories   map[string]bool
+}
+
+// loadBaseline reads path; a missing file is an empty baseline.
+func loadBaseline(path string) (*baseline, error) {
+	b := &baseline{path: path, events: map[string]bool{}, fingerprints: map[string]bool{}, hashes: map[string]bool{}, categories: map[string]bool{}}
+	f, err := os.Open(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return b, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	sc := bufio.NewScanner(f)
+	for n := 1; sc.Scan(); n++ {
+		line, _, _ := strings.Cut(sc.Text(), "#")
+		if line = strings.TrimSpace(line); line == "" {
+			continue
+		}
+		if err := b.add(line); err != nil {
+			return nil, fmt.Errorf("%s:%d: %w", path, n, err)
+		}
+	}
+	return b, sc.Err()
+}
+
+func (b *baseline) add(entry string) error {
+	if hash, ok := strings.CutPrefix(entry, "sha256:"); ok {
+		if len(hash) != 64 {
+			return fmt.Errorf("invalid image hash %q", hash)
+		}
+		b.hashes[strings.ToLower(hash)] = true
+		return nil
+	}
+	if fp, ok := strings.CutPrefix(entry, "fingerprint:"); ok {
+		b.fingerprints[strings.ToLower(fp)] = true
+		return nil
+	}
+	if cat, ok := strings.CutPrefix(entry, "category:"); ok {
+		b.categories[strings.ToLower(strings.TrimSpace(cat))] = true
+		return nil
+	}
+	if strings.HasPrefix(entry, "note1") || strings.HasPrefix(entry, "nevent1") {
+		prefix, data, err := nip19.Decode(entry)
+		if err != nil {
+			return err
+		}
+		switch prefix {
+		case "note":
+			entry = data.(string)
+		case "nevent":
+			entry = data.(nostr.EventPointer).ID
+		}
+	}
+	if !nostr.IsValid32ByteHex(entry) {
+		return fmt.Errorf("invalid entry %q", entry)
+	}
+	b.events[entry] = true
+	return nil
+}
+
+// apply drops the tags of r the baseline accepts and reports whether it
+// dropped any.
+func (b *baseline) apply(r *exifscan.ImageResult) bool {
+	if !r.Sensitive() {
+		return false
+	}
+	if b.events[r.EventID] || b.fingerprints[r.Fingerprint] || b.hashes[r.SHA256] {
+		r.SetTags(nil)
+		r.GPS = nil
+		return true
+	}
+	if len(b.categories) == 0 {
+		return false
+	}
+	return keepTags(r, func(t exifscan.Tag) bool {
+		return !b.categories[strings.ToLower(t.Category)]
+	})
+}
+
+func (b *baseline) ignores(eventID string) bool {
+	return b.events[eventID]
+}
+
+// ignore adds eventID to the file right away, so an interrupted review
+// keeps what was already decided.
+func (b *baseline) ignore(eventID, note string) error {
+	if b.events[eventID] {
+		return nil
+	}
+	f, err := os.OpenFile(b.path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0o644)
+	if err != nil {
+		return err
+	}
+	if _, err := fmt.Fprintf(f, "%s  # %s %s\n", eventID, time.Now().UTC().Format(time.DateOnly), note); err != nil {
+		f.Close()
+		return err
+	}
+	b.events[eventID] = true
+	return f.Close()
+}
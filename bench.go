@@ -0,0 +1,233 @@
+package main
+
+import (
+	"bufio"
+	"cmp"
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io/fs"
+	"net"
+	"net/http"
+	"net/http/pprof"
+	"net/url"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"runtime"
+	"slices"
+	"strings"
+	"time"
+
+	"nostr-exif-scan/pkg/exifscan"
+)
+
+func pprofFlag(fs *flag.FlagSet) *string {
+	return fs.String("pprof", "", "Serve Go profiling endpoints (/debug/pprof/) on this address (e.g. :6060)")
+}
+
+// servePprof serves the profiler on its own mux, so it never ends up on a
+// public API listener.
+func servePprof(addr string) {
+	if addr == "" {
+		return
+	}
+	mux := http.NewServeMux()
+	mux.HandleFunc("/debug/pprof/", pprof.Index)
+	mux.HandleFunc("/debug/pprof/cmdline", pprof.Cmdline)
+	mux.HandleFunc("/debug/pprof/profile", pprof.Profile)
+	mux.HandleFunc("/debug/pprof/symbol", pprof.Symbol)
+	mux.HandleFunc("/debug/pprof/trace", pprof.Trace)
+	fmt.Printf("🩺 Profiling on \033[36mhttp://%s/debug/pprof/\033[0m\n", addr)
+	go func() {
+		if err := http.ListenAndServe(addr, mux); err != nil {
+			fmt.Println("\033[31m❌ Profiling server failed:\033[0m", err)
+		}
+	}()
+}
+
+// benchRound is the outcome of replaying the whole set once.
+type benchRound struct {
+	Images     int     `json:"images"`
+	Failed     int     `json:"failed"`
+	Bytes      int64   `json:"bytes"`
+	Seconds    float64 `json:"seconds"`
+	ImagesPerS float64 `json:"images_per_second"`
+	MBPerS     float64 `json:"mb_per_second"`
+	// AllocBytes and Mallocs are per image.
+	AllocBytes uint64 `json:"alloc_bytes_per_image"`
+	Mallocs    uint64 `json:"allocs_per_image"`
+	GCs        uint32 `json:"gc_cycles"`
+}
+
+// runBench replays a recorded set of image URLs, or a directory of
+// fixture images served over loopback HTTP, through the download and
+// decode pipeline and reports its throughput.
+func runBench(args []string) int {
+	fs := newFlagSet("bench")
+	threads := threadsFlag(fs, "Number of parallel workers")
+	rounds := fs.Int("rounds", 3, "Number of times to replay the set")
+	record := fs.String("record", "", "Record the image URLs of --npub's notes to this file instead of benchmarking")
+	npub := fs.String("npub", "", "Account to record with --record")
+	limit := fs.Int("limit", 10000, "Maximum number of events to fetch with --record")
+	jsonOut := fs.Bool("json", false, "Print the rounds as JSON lines, for comparing runs")
+	pprofListen := pprofFlag(fs)
+	parseFlags(fs, args)
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+
+	if *record != "" {
+		return recordBench(ctx, *record, *npub, *limit)
+	}
+	if fs.NArg() != 1 || *rounds < 1 {
+		fmt.Println("\033[31m❌ Please provide a URL list or a fixture directory, and --rounds of at least 1\033[0m")
+		return 1
+	}
+	images, cleanup, err := benchImages(fs.Arg(0))
+	if err != nil {
+		fmt.Println("\033[31m❌ Cannot load the benchmark set:\033[0m", err)
+		return 1
+	}
+	defer cleanup()
+	if len(images) == 0 {
+		fmt.Println("\033[31m❌ The benchmark set is empty\033[0m")
+		return 1
+	}
+	servePprof(*pprofListen)
+
+	scanner := exifscan.New(exifscan.Options{Threads: *threads})
+	if !*jsonOut {
+		fmt.Printf("⏱️  Replaying \033[36m%d\033[0m images %d times with %s threads\n", len(images), *rounds, fs.Lookup("threads").Value)
+	}
+	var results []benchRound
+	for i := range *rounds {
+		r := benchOnce(ctx, scanner, images)
+		if ctx.Err() != nil {
+			break
+		}
+		results = append(results, r)
+		if *jsonOut {
+			json.NewEncoder(os.Stdout).Encode(r)
+			continue
+		}
+		fmt.Printf("[%d/%d] %6.1f images/s %8.1f MB/s %9d B/image %6d allocs/image %4d GCs (%d failed)\n",
+			i+1, *rounds, r.ImagesPerS, r.MBPerS, r.AllocBytes, r.Mallocs, r.GCs, r.Failed)
+	}
+	if len(results) > 1 && !*jsonOut {
+		// The median round is the least affected by a cold cache or a
+		// noisy neighbour.
+		slices.SortFunc(results, func(a, b benchRound) int {
+			return cmp.Compare(a.ImagesPerS, b.ImagesPerS)
+		})
+		m := results[len(results)/2]
+		fmt.Printf("📊 Median: \033[36m%.1f\033[0m images/s, \033[36m%.1f\033[0m MB/s, %d B and %d allocs per image\n", m.ImagesPerS, m.MBPerS, m.AllocBytes, m.Mallocs)
+	}
+	return 0
+}
+
+func benchOnce(ctx context.Context, s *exifscan.Scanner, images []exifscan.Image) benchRound {
+	var before, after runtime.MemStats
+	runtime.GC()
+	runtime.ReadMemStats(&before)
+	start := time.Now()
+	var r benchRound
+	for res := range s.ScanImages(ctx, images) {
+		r.Images++
+		r.Bytes += int64(res.Size)
+		if res.Err != nil {
+			r.Failed++
+		}
+	}
+	elapsed := time.Since(start)
+	runtime.ReadMemStats(&after)
+	r.Seconds = elapsed.Seconds()
+	r.ImagesPerS = float64(r.Images) / r.Seconds
+	r.MBPerS = float64(r.Bytes) / 1e6 / r.Seconds
+	if r.Images > 0 {
+		r.AllocBytes = (after.TotalAlloc - before.TotalAlloc) / uint64(r.Images)
+		r.Mallocs = (after.Mallocs - before.Mallocs) / uint64(r.Images)
+	}
+	r.GCs = after.NumGC - before.NumGC
+	return r
+}
+
+// benchImages reads a URL list, one per line with # comments, or serves
+// the images of a directory from a loopback server so the downloader is
+// measured without the network.
+func benchImages(path string) ([]exifscan.Image, func(), error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, nil, err
+	}
+	if !info.IsDir() {
+		f, err := os.Open(path)
+		if err != nil {
+			return nil, nil, err
+		}
+		defer f.Close()
+		var images []exifscan.Image
+		sc := bufio.NewScanner(f)
+		for sc.Scan() {
+			line, _, _ := strings.Cut(sc.Text(), "#")
+			if line = strings.TrimSpace(line); line != "" {
+				images = append(images, exifscan.Image{URL: line})
+			}
+		}
+		return images, func() {}, sc.Err()
+	}
+
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		return nil, nil, err
+	}
+	srv := &http.Server{Handler: http.FileServer(http.Dir(path))}
+	go srv.Serve(ln)
+	var images []exifscan.Image
+	err = filepath.WalkDir(path, func(p string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() || !imageExts[strings.ToLower(filepath.Ext(p))] {
+			return err
+		}
+		rel, err := filepath.Rel(path, p)
+		if err != nil {
+			return err
+		}
+		u := url.URL{Scheme: "http", Host: ln.Addr().String(), Path: "/" + filepath.ToSlash(rel)}
+		images = append(images, exifscan.Image{URL: u.String()})
+		return nil
+	})
+	if err != nil {
+		srv.Close()
+		return nil, nil, err
+	}
+	return images, func() { srv.Close() }, nil
+}
+
+// recordBench writes the image URLs of npub's notes to path, so later
+// benchmarks replay the same set.
+func recordBench(ctx context.Context, path, npub string, limit int) int {
+	pubkey, err := exifscan.DecodePubkey(npub)
+	if err != nil {
+		fmt.Println("\033[31m❌ Invalid npub:\033[0m", err)
+		return 1
+	}
+	scanner := exifscan.New(exifscan.Options{Relays: loadRelays("relays.txt"), Limit: limit})
+	events, err := scanner.FetchEvents(ctx, pubkey)
+	if err != nil {
+		fmt.Println("\033[31m❌ Fetching posts failed:\033[0m", err)
+		return 1
+	}
+	var b strings.Builder
+	fmt.Fprintf(&b, "# Image URLs of %s, recorded %s\n", npub, time.Now().UTC().Format(time.RFC3339))
+	images := exifscan.ExtractImages(events)
+	for _, img := range images {
+		b.WriteString(img.URL + "\n")
+	}
+	if err := os.WriteFile(path, []byte(b.String()), 0o644); err != nil {
+		fmt.Println("\033[31m❌ Writing the URL list failed:\033[0m", err)
+		return 1
+	}
+	fmt.Printf("📼 Recorded \033[36m%d\033[0m image URLs to \033[36m%s\033[0m\n", len(images), path)
+	return 0
+}
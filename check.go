@@ -0,0 +1,263 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"nostr-exif-scan/pkg/exifscan"
+)
+
+// Exit codes for the check subcommand, so hooks can branch on the verdict.
+const (
+	checkSafe  = 0
+	checkLeaks = 1
+	checkError = 2
+)
+
+// imageExts selects the files checked when walking a directory.
+var imageExts = map[string]bool{
+	".jpg": true, ".jpeg": true, ".png": true, ".gif": true, ".webp": true, ".svg": true,
+	".tif": true, ".tiff": true, ".heic": true, ".heif": true,
+	".mp3": true, ".m4a": true, ".opus": true, ".ogg": true,
+	".pdf": true, ".zip": true,
+}
+
+type checked struct {
+	path string
+	r    *exifscan.ImageResult
+	err  error
+}
+
+func runCheck(args []string) int {
+	fs := newFlagSet("check")
+	verbose := fs.Bool("v", false, "Verbose output: show the leaking EXIF values")
+	dumpAllTags := fs.Bool("dump-all-tags", false, "List every decoded EXIF tag of every image with metadata, not just the sensitive ones")
+	format := fs.String("format", "", "Output format: text, json for one verdict object per line, or github for workflow annotations and a step summary (default github when $GITHUB_ACTIONS is set)")
+	tz := tzFlag(fs)
+	coordFmt := coordFormatFlag(fs)
+	fs.Usage = func() {
+		fmt.Fprintf(os.Stderr, "Usage of %s check:\n", os.Args[0])
+		fmt.Fprintf(os.Stderr, "  %s check [-v] [--format github] image.jpg|directory...\n", os.Args[0])
+		fmt.Fprintf(os.Stderr, "  %s check --format json - < image.jpg\n\n", os.Args[0])
+		fs.PrintDefaults()
+		fmt.Fprintln(os.Stderr, "\nExit status is 0 when every image is safe, 1 when one leaks metadata and 2 when one can't be read.")
+	}
+	fs.Parse(args)
+	if fs.NArg() == 0 {
+		fs.Usage()
+		return checkError
+	}
+	if *format == "" {
+		*format = "text"
+		if os.Getenv("GITHUB_ACTIONS") == "true" {
+			*format = "github"
+		}
+	}
+	// JSON verdicts and workflow commands are for machines; --plain would
+	// mangle them.
+	if plainRequested && *format == "text" {
+		startPlain()
+	}
+	if *format != "text" && *format != "json" && *format != "github" {
+		fmt.Println("\033[31m❌ --format must be text, json or github\033[0m")
+		return checkError
+	}
+	if !setTimezone(*tz) || !setCoordFormat(*coordFmt) {
+		return checkError
+	}
+
+	var results []checked
+	for _, arg := range fs.Args() {
+		for _, path := range checkPaths(arg) {
+			c := checked{path: path}
+			buf, err := readImage(path)
+			if err != nil {
+				c.err = err
+			} else {
+				c.r = exifscan.ScanBytes(buf)
+				// Only the file name: the directories are the checker's.
+				c.r.AddTags(exifscan.URLLocation(filepath.Base(path))...)
+				if *dumpAllTags && c.r.Exif != nil {
+					c.r.AllTags = exifscan.AllTags(c.r.Exif)
+				}
+			}
+			results = append(results, c)
+		}
+	}
+
+	if *format == "json" {
+		return jsonVerdicts(results)
+	}
+	code := checkSafe
+	for _, c := range results {
+		switch {
+		case c.err != nil:
+			fmt.Println("\033[31m❌ Cannot read image:\033[0m", c.err)
+			code = checkError
+		case !c.r.Sensitive():
+			note := ""
+			if !c.r.HasMetadata {
+				note = " (no EXIF metadata)"
+				if c.r.Provenance != nil {
+					note = " (no EXIF metadata, " + c.r.Provenance.Class + ")"
+				}
+			}
+			fmt.Printf("✅ \033[32mSAFE\033[0m: %s%s\n", c.path, note)
+			if *verbose && c.r.Provenance != nil {
+				fmt.Printf("    🧪 %s\n", c.r.Provenance)
+			}
+			printAllTags(os.Stdout, c.r.AllTags)
+		default:
+			fmt.Printf("🚨 \033[31mLEAKS: %s\033[0m: %s\n", strings.Join(c.r.Categories(), ", "), c.path)
+			if c.r.Archive != nil {
+				fmt.Printf("    🗜️  Archive of %d files: %s\n", len(c.r.Archive), archiveFiles(c.r.Archive, 10))
+			}
+			if c.r.AllTags != nil {
+				printAllTags(os.Stdout, c.r.AllTags)
+			} else if *verbose {
+				printTags(os.Stdout, c.r.Tags)
+				if c.r.GPS != nil {
+					fmt.Printf("    🌍 GPS: %s (accurate to %s) %s\n", c.r.GPS, c.r.GPS.Accuracy(), c.r.GPS.MapsURL())
+				}
+			}
+			if code == checkSafe {
+				code = checkLeaks
+			}
+		}
+		if c.err == nil && c.r.ExternalRefs != nil {
+			fmt.Printf("    ⚠️  Loads external content when rendered: \033[33m%s\033[0m\n", strings.Join(c.r.ExternalRefs, ", "))
+		}
+	}
+	if *format == "github" {
+		if err := githubOutput(results); err != nil {
+			fmt.Println("\033[31m❌ Writing the step summary failed:\033[0m", err)
+			return checkError
+		}
+	}
+	return code
+}
+
+// readImage reads a file, or stdin for "-".
+func readImage(path string) ([]byte, error) {
+	if path == "-" {
+		buf, err := io.ReadAll(os.Stdin)
+		if err == nil && len(buf) == 0 {
+			err = errors.New("no image data on stdin")
+		}
+		return buf, err
+	}
+	return os.ReadFile(path)
+}
+
+// checkVerdict is the json output for one file. File is empty for stdin.
+type checkVerdict struct {
+	SchemaVersion int      `json:"schema_version"`
+	File          string   `json:"file,omitempty"`
+	Safe          bool     `json:"safe"`
+	Categories    []string `json:"categories"`
+	Error         string   `json:"error,omitempty"`
+	*exifscan.ImageResult
+}
+
+// jsonVerdicts writes one verdict per line to stdout and returns the exit
+// status. Nothing else is printed, so clients can parse stdout as is.
+func jsonVerdicts(results []checked) int {
+	code := checkSafe
+	enc := json.NewEncoder(os.Stdout)
+	for _, c := range results {
+		v := checkVerdict{SchemaVersion: exifscan.SchemaVersion, Categories: []string{}}
+		if c.path != "-" {
+			v.File = c.path
+		}
+		switch {
+		case c.err != nil:
+			v.Error = c.err.Error()
+			code = checkError
+		default:
+			v.ImageResult = c.r
+			v.Safe = !c.r.Sensitive()
+			v.Categories = append(v.Categories, c.r.Categories()...)
+			if !v.Safe && code == checkSafe {
+				code = checkLeaks
+			}
+		}
+		enc.Encode(v)
+	}
+	return code
+}
+
+// checkPaths expands a directory into the images below it; anything else
+// is checked as given.
+func checkPaths(arg string) []string {
+	info, err := os.Stat(arg)
+	if err != nil || !info.IsDir() {
+		return []string{arg}
+	}
+	var paths []string
+	filepath.WalkDir(arg, func(path string, d fs.DirEntry, err error) error {
+		if err == nil && !d.IsDir() && imageExts[strings.ToLower(filepath.Ext(path))] {
+			paths = append(paths, path)
+		}
+		return nil
+	})
+	return paths
+}
+
+// githubOutput prints a workflow annotation per leaking or unreadable file
+// and appends a Markdown table to the job's step summary.
+func githubOutput(results []checked) error {
+	var summary strings.Builder
+	leaks := 0
+	summary.WriteString("### 🛡️ EXIF check\n\n| File | Verdict | Leaked fields |\n| --- | --- | --- |\n")
+	for _, c := range results {
+		switch {
+		case c.err != nil:
+			fmt.Printf("::error file=%s,title=EXIF check::%s\n", ghProperty(c.path), ghData(c.err.Error()))
+			fmt.Fprintf(&summary, "| `%s` | ❌ unreadable | %s |\n", c.path, c.err)
+		case c.r.Sensitive():
+			leaks++
+			msg := "Image leaks " + strings.Join(c.r.Categories(), ", ")
+			var fields []string
+			for _, t := range c.r.Tags {
+				fields = append(fields, t.Field)
+			}
+			if c.r.GPS != nil {
+				msg += " – GPS " + c.r.GPS.String()
+			}
+			fmt.Printf("::error file=%s,title=EXIF leak::%s\n", ghProperty(c.path), ghData(msg))
+			fmt.Fprintf(&summary, "| `%s` | 🚨 leaks %s | %s |\n", c.path, strings.Join(c.r.Categories(), ", "), strings.Join(fields, ", "))
+		default:
+			fmt.Fprintf(&summary, "| `%s` | ✅ safe | |\n", c.path)
+		}
+	}
+	fmt.Fprintf(&summary, "\n%d of %d images leak metadata.\n", leaks, len(results))
+
+	path := os.Getenv("GITHUB_STEP_SUMMARY")
+	if path == "" {
+		return nil
+	}
+	f, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o644)
+	if err != nil {
+		return err
+	}
+	if _, err := f.WriteString(summary.String()); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// ghData and ghProperty escape workflow command messages and properties.
+func ghData(s string) string {
+	return strings.NewReplacer("%", "%25", "\r", "%0D", "\n", "%0A").Replace(s)
+}
+
+func ghProperty(s string) string {
+	return strings.NewReplacer("%", "%25", "\r", "%0D", "\n", "%0A", ":", "%3A", ",", "%2C").Replace(s)
+}
@@ -0,0 +1,113 @@
+//go:build js && wasm
+
+// Command wasm exposes the scanner to browsers as a global nostrExifScan
+// object. Images are downloaded with the browser's fetch and relays are
+// reached over its WebSocket implementation, so nothing leaves the client.
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"syscall/js"
+	"time"
+
+	"nostr-exif-scan/pkg/exifscan"
+)
+
+func main() {
+	js.Global().Set("nostrExifScan", js.ValueOf(map[string]any{
+		"scanNpub":  js.FuncOf(scanNpub),
+		"scanBytes": js.FuncOf(scanBytes),
+	}))
+	select {}
+}
+
+// scanNpub(npub, options) returns a Promise of the findings. options may
+// carry relays, limit, threads, since/until (RFC3339) and onImage/onFinding
+// callbacks receiving each image result as it completes.
+func scanNpub(this js.Value, args []js.Value) any {
+	return newPromise(func() (any, error) {
+		if len(args) < 1 {
+			return nil, errors.New("scanNpub: npub argument required")
+		}
+		pubkey, err := exifscan.DecodePubkey(args[0].String())
+		if err != nil {
+			return nil, err
+		}
+
+		// There's no temp dir to spool big downloads to in the browser.
+		opts := exifscan.Options{SpoolBytes: -1}
+		if len(args) > 1 && args[1].Type() == js.TypeObject {
+			o := args[1]
+			if v := o.Get("relays"); v.Type() == js.TypeObject {
+				for i := 0; i < v.Length(); i++ {
+					opts.Relays = append(opts.Relays, v.Index(i).String())
+				}
+			}
+			if v := o.Get("limit"); v.Type() == js.TypeNumber {
+				opts.Limit = v.Int()
+			}
+			if v := o.Get("threads"); v.Type() == js.TypeNumber {
+				opts.Threads = v.Int()
+			}
+			if v := o.Get("since"); v.Type() == js.TypeString {
+				if opts.Since, err = exifscan.ParseTime(v.String(), time.Now()); err != nil {
+					return nil, err
+				}
+			}
+			if v := o.Get("until"); v.Type() == js.TypeString {
+				if opts.Until, err = exifscan.ParseTime(v.String(), time.Now()); err != nil {
+					return nil, err
+				}
+			}
+			if fn := o.Get("onImage"); fn.Type() == js.TypeFunction {
+				opts.Hooks.OnImageScanned = func(r *exifscan.ImageResult) { fn.Invoke(toJS(r)) }
+			}
+			if fn := o.Get("onFinding"); fn.Type() == js.TypeFunction {
+				opts.Hooks.OnFinding = func(r *exifscan.ImageResult) { fn.Invoke(toJS(r)) }
+			}
+		}
+
+		findings, err := exifscan.New(opts).Scan(context.Background(), pubkey)
+		if err != nil {
+			return nil, err
+		}
+		return toJS(findings), nil
+	})
+}
+
+// scanBytes(Uint8Array) inspects an image picked by the user, e.g. right
+// before upload, and returns the result synchronously.
+func scanBytes(this js.Value, args []js.Value) any {
+	if len(args) < 1 {
+		return nil
+	}
+	buf := make([]byte, args[0].Get("length").Int())
+	js.CopyBytesToGo(buf, args[0])
+	return toJS(exifscan.ScanBytes(buf))
+}
+
+func newPromise(fn func() (any, error)) any {
+	handler := js.FuncOf(func(this js.Value, args []js.Value) any {
+		resolve, reject := args[0], args[1]
+		go func() {
+			v, err := fn()
+			if err != nil {
+				reject.Invoke(js.Global().Get("Error").New(err.Error()))
+				return
+			}
+			resolve.Invoke(v)
+		}()
+		return nil
+	})
+	return js.Global().Get("Promise").New(handler)
+}
+
+func toJS(v any) js.Value {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return js.Null()
+	}
+	return js.Global().Get("JSON").Call("parse", string(data))
+}
@@ -0,0 +1,190 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/signal"
+	"strings"
+
+	"github.com/nbd-wtf/go-nostr"
+	"github.com/nbd-wtf/go-nostr/nip19"
+
+	"nostr-exif-scan/internal/export"
+	"nostr-exif-scan/internal/publish"
+	"nostr-exif-scan/internal/report"
+	"nostr-exif-scan/pkg/exifscan"
+)
+
+// runCompare scans several accounts, or everyone an account follows, and
+// ranks them by privacy score.
+func runCompare(args []string) int {
+	fs := newFlagSet("compare")
+	npubs := fs.String("npub", "", "Comma separated npubs to compare")
+	follows := fs.String("follows", "", "Compare the accounts this npub follows (NIP-02 follow list)")
+	threads := threadsFlag(fs, "Number of parallel workers per account")
+	rps := rpsFlag(fs)
+	limit := fs.Int("limit", 1000, "Maximum number of events to fetch per account")
+	sinceFlag := sinceFlagFor(fs)
+	untilFlag := untilFlagFor(fs)
+	tz := tzFlag(fs)
+	reportPath := fs.String("report", "", "Write an HTML (.html) or Markdown (.md) comparison to this file")
+	top := fs.Int("top", 10, "Length of the leaderboards of accounts with the most GPS leaks and the highest share of leaking images; 0 leaves them out")
+	parquetDir := fs.String("parquet", "", "Write images.parquet and findings.parquet for all accounts to this directory")
+	correlateDB := correlateFlag(fs)
+	parseFlags(fs, args)
+
+	if (*npubs == "") == (*follows == "") {
+		fmt.Println("\033[31m❌ Please provide either --npub or --follows\033[0m")
+		return 1
+	}
+	photos, err := openPhotoIndex(*correlateDB)
+	if err != nil {
+		fmt.Println("\033[31m❌ Cannot open results database:\033[0m", err)
+		return 1
+	}
+	opts := exifscan.Options{Relays: loadRelays("relays.txt"), Limit: *limit, Threads: *threads, RateLimit: exifscan.NewRateLimit(*rps), PerceptualHash: photos != nil}
+	if opts.Since, opts.Until, err = timeRange(*sinceFlag, *untilFlag); err != nil {
+		fmt.Println("\033[31m❌", err, "\033[0m")
+		return 1
+	}
+	if !setTimezone(*tz) {
+		return 1
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+
+	var authors []string
+	if *follows != "" {
+		pubkey, err := exifscan.DecodePubkey(*follows)
+		if err != nil {
+			fmt.Println("\033[31m❌ Invalid npub:\033[0m", err)
+			return 1
+		}
+		if authors, err = publish.Follows(ctx, nostr.NewSimplePool(ctx), opts.Relays, pubkey); err != nil {
+			fmt.Println("\033[31m❌ Cannot load the follow list:\033[0m", err)
+			return 1
+		}
+	} else {
+		for _, npub := range strings.Split(*npubs, ",") {
+			pubkey, err := exifscan.DecodePubkey(strings.TrimSpace(npub))
+			if err != nil {
+				fmt.Println("\033[31m❌ Invalid npub:\033[0m", npub, err)
+				return 1
+			}
+			authors = append(authors, pubkey)
+		}
+	}
+
+	var pq *export.Parquet
+	if *parquetDir != "" {
+		var err error
+		if pq, err = export.NewParquet(*parquetDir); err != nil {
+			fmt.Println("\033[31m❌ Cannot create Parquet files:\033[0m", err)
+			return 1
+		}
+	}
+
+	scanner := exifscan.New(opts)
+	fmt.Printf("👥 Comparing \033[36m%d\033[0m accounts on \033[36m%d\033[0m relays\n", len(authors), len(opts.Relays))
+	var accounts []report.Account
+	var linked []report.LinkedPhoto
+	for i, pubkey := range authors {
+		npub, _ := nip19.EncodePublicKey(pubkey)
+		a := report.Account{Npub: npub}
+		findings, err := scanner.Scan(ctx, pubkey)
+		if ctx.Err() != nil {
+			break
+		}
+		if err != nil {
+			a.Err = err.Error()
+			fmt.Printf("[%d/%d] ❌ %s: %v\n", i+1, len(authors), npub, err)
+		} else {
+			a.Findings = findings
+			if err := addParquet(pq, findings); err != nil {
+				fmt.Println("\033[31m❌ Writing Parquet failed:\033[0m", err)
+				return 1
+			}
+			fmt.Printf("[%d/%d] 🔎 %s: %d of %d images leak\n", i+1, len(authors), npub, len(findings.Flagged()), len(findings.Images))
+			if photos != nil {
+				links, err := photos.add(pubkey, findings.Images)
+				if err != nil {
+					fmt.Println("\033[31m❌ Cannot record the photo hashes:\033[0m", err)
+					return 1
+				}
+				linked = addLinks(linked, links)
+			}
+		}
+		accounts = append(accounts, a)
+	}
+
+	rows := report.Compare(accounts)
+	fmt.Printf("\n%-65s %5s %6s %7s %4s %4s\n", "Account", "Score", "Images", "Leaking", "High", "GPS")
+	for _, r := range rows {
+		if r.Err != "" {
+			fmt.Printf("%-65s ❌ %s\n", r.Npub, r.Err)
+			continue
+		}
+		line := fmt.Sprintf("%-65s %5d %6d %7d %4d %4d", r.Npub, r.Score, r.Images, r.Leaking, r.High, r.GPS)
+		if r.Worst {
+			line = "\033[31m" + line + "\033[0m 🚨"
+		}
+		fmt.Println(line)
+	}
+
+	byGPS, byRate := report.Leaderboard(rows, *top)
+	if len(byGPS) > 0 {
+		fmt.Printf("\n🌍 Most images with GPS positions:\n")
+		for i, r := range byGPS {
+			fmt.Printf("%3d. %s  \033[31m%d\033[0m with GPS, %d of %d leaking (%s)\n", i+1, r.Npub, r.GPS, r.Leaking, r.Scanned, r.Percent())
+		}
+	}
+	if len(byRate) > 0 {
+		fmt.Printf("\n📊 Highest share of leaking images:\n")
+		for i, r := range byRate {
+			fmt.Printf("%3d. %s  \033[31m%s\033[0m, %d of %d images\n", i+1, r.Npub, r.Percent(), r.Leaking, r.Scanned)
+		}
+	}
+	if len(linked) > 0 {
+		fmt.Printf("\n🔗 Accounts linked by the same photo:\n")
+		for _, l := range linked {
+			fmt.Printf("  %s and %s: %s is a copy of %s (hash distance %d)\n", l.Npub, l.OtherNpub, l.URL, l.OtherURL, l.Distance)
+		}
+	}
+
+	if pq != nil {
+		if err := pq.Close(); err != nil {
+			fmt.Println("\033[31m❌ Writing Parquet failed:\033[0m", err)
+			return 1
+		}
+		fmt.Printf("🧮 Parquet files written to \033[36m%s\033[0m\n", *parquetDir)
+	}
+	if *reportPath != "" {
+		f, err := os.Create(*reportPath)
+		if err == nil {
+			err = report.WriteComparison(f, *reportPath, report.Comparison{Since: opts.Since, Until: opts.Until, Rows: rows, ByGPS: byGPS, ByRate: byRate, Linked: linked})
+			if cerr := f.Close(); err == nil {
+				err = cerr
+			}
+		}
+		if err != nil {
+			fmt.Println("\033[31m❌ Writing report failed:\033[0m", err)
+			return 1
+		}
+		fmt.Printf("📝 Report written to \033[36m%s\033[0m\n", *reportPath)
+	}
+	return 0
+}
+
+func addParquet(pq *export.Parquet, f *exifscan.Findings) error {
+	if pq == nil {
+		return nil
+	}
+	for _, r := range f.Images {
+		if err := pq.Add(f.Pubkey, r); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,58 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+
+	"nostr-exif-scan/internal/dm"
+	"nostr-exif-scan/internal/dvm"
+	"nostr-exif-scan/internal/mailer"
+	"nostr-exif-scan/internal/notify"
+	"nostr-exif-scan/internal/server"
+)
+
+const defaultConfigPath = "config.json"
+
+// config is read from config.json in the working directory (or --config)
+// and holds integration settings that don't belong on the command line.
+type config struct {
+	Notify notify.Config `json:"notify"`
+	SMTP   mailer.Config `json:"smtp"`
+	DM     dm.Config     `json:"dm"`
+	DVM    dvmConfig     `json:"dvm"`
+	Signer signerConfig  `json:"signer"`
+	Serve  serveConfig   `json:"serve"`
+}
+
+type serveConfig struct {
+	Tenants []server.Tenant `json:"tenants"`
+}
+
+type dvmConfig struct {
+	dvm.Profile
+	Pricing dvm.Pricing `json:"pricing"`
+}
+
+func configFlag(fs *flag.FlagSet) *string {
+	return fs.String("config", defaultConfigPath, "Path to the JSON config file")
+}
+
+// loadConfig returns an empty config when the default file is absent, but
+// fails if an explicitly named file can't be read.
+func loadConfig(path string) (*config, error) {
+	cfg := &config{}
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) && path == defaultConfigPath {
+		return cfg, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(data, cfg); err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return cfg, nil
+}
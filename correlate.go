@@ -0,0 +1,164 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/nbd-wtf/go-nostr/nip19"
+
+	"nostr-exif-scan/internal/report"
+	"nostr-exif-scan/internal/store"
+	"nostr-exif-scan/pkg/exifscan"
+)
+
+// correlateDistance is the most bits two perceptual hashes may differ in
+// for the photos to count as copies of one another.
+const correlateDistance = 6
+
+// maxLinkedAccounts is how many accounts a photo may be found under
+// before it counts as widely shared, a meme or a news picture, rather
+// than a link between them.
+const maxLinkedAccounts = 5
+
+func correlateFlag(fs *flag.FlagSet) *string {
+	return fs.String("correlate-db", "", "Results database directory of perceptual photo hashes; report photos also posted under other accounts scanned into it")
+}
+
+// photoRecord is the hashed photos of one account, stored as
+// "photos-<pubkey>".
+type photoRecord struct {
+	Pubkey string          `json:"pubkey"`
+	Photos []photoSighting `json:"photos"`
+}
+
+type photoSighting struct {
+	PHash   string    `json:"phash"`
+	EventID string    `json:"event_id,omitempty"`
+	URL     string    `json:"url"`
+	Seen    time.Time `json:"seen"`
+}
+
+// photoIndex finds the same photo under several accounts by its
+// perceptual hash, which links pseudonymous accounts to each other.
+type photoIndex struct {
+	db *store.Store
+	mu sync.Mutex
+}
+
+// openPhotoIndex opens the --correlate-db directory; it returns nil when
+// dir is empty.
+func openPhotoIndex(dir string) (*photoIndex, error) {
+	if dir == "" {
+		return nil, nil
+	}
+	db, err := store.Open(dir)
+	if err != nil {
+		return nil, err
+	}
+	return &photoIndex{db: db}, nil
+}
+
+// add records the hashed images pubkey posted and returns those found
+// under other accounts. The other accounts' records are read
+// afresh each time, so scans sharing the directory see each other.
+func (ix *photoIndex) add(pubkey string, images []*exifscan.ImageResult) ([]report.LinkedPhoto, error) {
+	ix.mu.Lock()
+	defer ix.mu.Unlock()
+	ids, err := ix.db.IDs()
+	if err != nil {
+		return nil, err
+	}
+	var others []photoRecord
+	for _, id := range ids {
+		if !strings.HasPrefix(id, "photos-") || id == "photos-"+pubkey {
+			continue
+		}
+		var rec photoRecord
+		if err := ix.db.Get(id, &rec); err != nil {
+			return nil, err
+		}
+		others = append(others, rec)
+	}
+	own := photoRecord{Pubkey: pubkey}
+	if err := ix.db.Get("photos-"+pubkey, &own); err != nil && !errors.Is(err, store.ErrNotFound) {
+		return nil, err
+	}
+	known := map[string]bool{}
+	for _, p := range own.Photos {
+		known[p.PHash+" "+p.URL] = true
+	}
+
+	npub, _ := nip19.EncodePublicKey(pubkey)
+	var links []report.LinkedPhoto
+	changed := false
+	done := map[string]bool{}
+	for _, r := range images {
+		if !distinctive(r.PHash) || done[r.URL] {
+			continue
+		}
+		done[r.URL] = true
+		if !known[r.PHash+" "+r.URL] {
+			known[r.PHash+" "+r.URL] = true
+			own.Photos = append(own.Photos, photoSighting{PHash: r.PHash, EventID: r.EventID, URL: r.URL, Seen: time.Now()})
+			changed = true
+		}
+		// The closest copy under each other account.
+		var found []report.LinkedPhoto
+		for _, rec := range others {
+			best := -1
+			var link report.LinkedPhoto
+			for _, p := range rec.Photos {
+				d := exifscan.HashDistance(r.PHash, p.PHash)
+				if d < 0 || d > correlateDistance || best >= 0 && d >= best {
+					continue
+				}
+				best = d
+				other, _ := nip19.EncodePublicKey(rec.Pubkey)
+				link = report.LinkedPhoto{Npub: npub, EventID: r.EventID, URL: r.URL, OtherNpub: other, OtherEventID: p.EventID, OtherURL: p.URL, Distance: d}
+			}
+			if best >= 0 {
+				found = append(found, link)
+			}
+		}
+		if len(found) < maxLinkedAccounts {
+			links = append(links, found...)
+		}
+	}
+	if changed {
+		if err := ix.db.Put("photos-"+pubkey, own); err != nil {
+			return nil, err
+		}
+	}
+	return links, nil
+}
+
+// distinctive rejects the hashes of flat or evenly shaded pictures, which
+// blank images and gradients share without being copies.
+func distinctive(hash string) bool {
+	bits := exifscan.HashDistance(hash, "0000000000000000")
+	return bits >= 8 && bits <= 56
+}
+
+// addLinks appends the links new to list, taking a link from either end
+// as the same.
+func addLinks(list, links []report.LinkedPhoto) []report.LinkedPhoto {
+	for _, l := range links {
+		if !slices.ContainsFunc(list, func(o report.LinkedPhoto) bool {
+			return o.URL == l.URL && o.OtherURL == l.OtherURL || o.URL == l.OtherURL && o.OtherURL == l.URL
+		}) {
+			list = append(list, l)
+		}
+	}
+	return list
+}
+
+func printLinkedPhotos(links []report.LinkedPhoto) {
+	for _, l := range links {
+		fmt.Printf("    🔗 \033[31m%s is a copy of a photo posted by %s\033[0m, linking the accounts: %s (hash distance %d)\n", l.URL, l.OtherNpub, l.OtherURL, l.Distance)
+	}
+}
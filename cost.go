@@ -0,0 +1,55 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"nostr-exif-scan/pkg/exifscan"
+)
+
+// costSample is how many URLs are sized to estimate a scan's cost.
+const costSample = 20
+
+// costLimits are the estimated scan sizes above which to ask before
+// downloading.
+type costLimits struct {
+	mb  *int64
+	dur *time.Duration
+	yes *bool
+}
+
+func costFlags(fs *flag.FlagSet) costLimits {
+	return costLimits{
+		mb:  fs.Int64("confirm-over-mb", 1000, "Ask before scans estimated to download more than this many MB; 0 never asks"),
+		dur: fs.Duration("confirm-over", 15*time.Minute, "Ask before scans estimated to take longer than this; 0 never asks"),
+		yes: fs.Bool("yes", false, "Don't estimate the scan's cost or ask before downloading"),
+	}
+}
+
+// confirm estimates the bandwidth and time scanning images takes from a
+// sample of HEAD requests and, above the limits, asks whether to go on.
+// Without a terminal to ask on the scan goes on with a warning.
+func (c costLimits) confirm(ctx context.Context, scanner *exifscan.Scanner, images []exifscan.Image) bool {
+	if *c.yes || len(images) == 0 || *c.mb <= 0 && *c.dur <= 0 {
+		return true
+	}
+	p := scanner.Plan(ctx, images, costSample)
+	mb := float64(p.TotalBytes()) / 1e6
+	fmt.Printf("⏱️  Estimated \033[36m%.1f MB\033[0m in \033[36m%s\033[0m for %d images (from %d HEAD requests)\n", mb, p.Duration.Round(time.Second), len(p.URLs), p.Sampled)
+	over := *c.mb > 0 && mb > float64(*c.mb) || *c.dur > 0 && p.Duration > *c.dur
+	if !over {
+		return true
+	}
+	if fi, err := os.Stdin.Stat(); err != nil || fi.Mode()&os.ModeCharDevice == 0 {
+		fmt.Println("⚠️  Above --confirm-over-mb or --confirm-over; going on since there is no terminal to ask")
+		return true
+	}
+	fmt.Print("That is above --confirm-over-mb or --confirm-over. Scan anyway? [y/N] ")
+	answer, _ := bufio.NewReader(os.Stdin).ReadString('\n')
+	return strings.EqualFold(strings.TrimSpace(answer), "y")
+}
@@ -0,0 +1,321 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"os/signal"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/nbd-wtf/go-nostr/nip19"
+
+	"nostr-exif-scan/internal/cron"
+	"nostr-exif-scan/internal/health"
+	"nostr-exif-scan/internal/notify"
+	"nostr-exif-scan/internal/report"
+	"nostr-exif-scan/internal/store"
+	"nostr-exif-scan/pkg/exifscan"
+)
+
+// daemonState is what the daemon remembers about one author between runs.
+type daemonState struct {
+	Pubkey  string    `json:"pubkey"`
+	LastRun time.Time `json:"last_run"`
+	// Newest is the creation time of the newest note scanned so far; the
+	// next run only fetches notes from then on.
+	Newest time.Time `json:"newest"`
+	// Seen holds the leaks already notified about, by fingerprint.
+	Seen map[string]time.Time `json:"seen"`
+}
+
+// daemonRun is the stored outcome of one scheduled scan.
+type daemonRun struct {
+	Pubkey   string             `json:"pubkey"`
+	Started  time.Time          `json:"started"`
+	Finished time.Time          `json:"finished"`
+	Since    time.Time          `json:"since,omitzero"`
+	New      int                `json:"new"`
+	Findings *exifscan.Findings `json:"findings"`
+	// Linked are the new photos found under other accounts, with
+	// --correlate-db.
+	Linked []report.LinkedPhoto `json:"linked,omitempty"`
+}
+
+func runDaemon(args []string) int {
+	fs := newFlagSet("daemon")
+	npubs := fs.String("npub", "", "Comma separated npubs to audit (required)")
+	schedule := fs.String("schedule", "0 3 * * *", "Cron expression (minute hour day month weekday, local time) or @daily, @hourly...")
+	dbDir := fs.String("db", "daemon-db", "Results database directory keeping every run and what was already reported")
+	threads := threadsFlag(fs, "Number of parallel workers")
+	rps := rpsFlag(fs)
+	limit := fs.Int("limit", 10000, "Maximum number of events to fetch per run")
+	now := fs.Bool("now", false, "Also run a scan immediately on start")
+	emailFlag := fs.Bool("email", false, "Email a report of the new findings using the smtp config section")
+	baselinePath := baselineFlag(fs)
+	filters := filterFlags(fs)
+	healthListen := healthFlag(fs)
+	pprofListen := pprofFlag(fs)
+	configPath := configFlag(fs)
+	linkTmpl := linkTemplateFlag(fs)
+	tz := tzFlag(fs)
+	coordFmt := coordFormatFlag(fs)
+	correlateDB := correlateFlag(fs)
+	parseFlags(fs, args)
+	if !setLinkTemplate(*linkTmpl) {
+		return 1
+	}
+	if !setTimezone(*tz) || !setCoordFormat(*coordFmt) {
+		return 1
+	}
+
+	if *npubs == "" {
+		fmt.Println("\033[31m❌ Please provide --npub\033[0m")
+		return 1
+	}
+	sched, err := cron.Parse(*schedule)
+	if err != nil {
+		fmt.Println("\033[31m❌ Invalid --schedule:\033[0m", err)
+		return 1
+	}
+	var authors []string
+	for _, npub := range strings.Split(*npubs, ",") {
+		pubkey, err := exifscan.DecodePubkey(strings.TrimSpace(npub))
+		if err != nil {
+			fmt.Println("\033[31m❌ Invalid npub:\033[0m", npub, err)
+			return 1
+		}
+		authors = append(authors, pubkey)
+	}
+	cfg, err := loadConfig(*configPath)
+	if err != nil {
+		fmt.Println("\033[31m❌ Cannot load config:\033[0m", err)
+		return 1
+	}
+	base, err := loadBaseline(*baselinePath)
+	if err != nil {
+		fmt.Println("\033[31m❌ Cannot load baseline:\033[0m", err)
+		return 1
+	}
+	filter, err := filters.parse()
+	if err != nil {
+		fmt.Println("\033[31m❌", err, "\033[0m")
+		return 1
+	}
+	db, err := store.Open(*dbDir)
+	if err != nil {
+		fmt.Println("\033[31m❌ Cannot open results database:\033[0m", err)
+		return 1
+	}
+	photos, err := openPhotoIndex(*correlateDB)
+	if err != nil {
+		fmt.Println("\033[31m❌ Cannot open results database:\033[0m", err)
+		return 1
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+	servePprof(*pprofListen)
+
+	d := &daemon{
+		db:        db,
+		photos:    photos,
+		base:      base,
+		filter:    filter,
+		notifiers: notify.FromConfig(cfg.Notify),
+		opts: exifscan.Options{
+			Relays:         loadRelays("relays.txt"),
+			Limit:          *limit,
+			Threads:        *threads,
+			RateLimit:      exifscan.NewRateLimit(*rps),
+			PerceptualHash: photos != nil,
+		},
+	}
+	if *emailFlag {
+		d.smtp = cfg
+	}
+	if *healthListen != "" {
+		d.mon = health.New("daemon", d.opts.Relays)
+		d.opts.Hooks = d.mon.Hooks(d.opts.Hooks)
+		d.mon.SetJobs(d.counts)
+		d.mon.SetDetail("schedule", sched.String())
+		mux := http.NewServeMux()
+		d.mon.Register(mux)
+		d.mon.SetReady(true)
+		go func() {
+			if err := http.ListenAndServe(*healthListen, mux); err != nil {
+				fmt.Println("\033[31m❌ HTTP server failed:\033[0m", err)
+			}
+		}()
+	}
+	fmt.Printf("🕒 Auditing \033[36m%d\033[0m authors on schedule \033[36m%s\033[0m (%d notifiers)\n", len(authors), sched, len(d.notifiers))
+	if *now {
+		d.runAll(ctx, authors)
+	}
+	for {
+		next := sched.Next(time.Now())
+		if next.IsZero() {
+			fmt.Println("\033[31m❌ The schedule never fires\033[0m")
+			return 1
+		}
+		fmt.Printf("⏰ Next scan at \033[36m%s\033[0m\n", next.Format(time.RFC3339))
+		d.setDetail("next_run", next)
+		select {
+		case <-time.After(time.Until(next)):
+		case <-ctx.Done():
+			return 0
+		}
+		d.runAll(ctx, authors)
+	}
+}
+
+type daemon struct {
+	db        *store.Store
+	photos    *photoIndex
+	notifiers []notify.Notifier
+	opts      exifscan.Options
+	// smtp is set when new findings are emailed as a report.
+	smtp   *config
+	mon    *health.Monitor
+	base   *baseline
+	filter *tagFilter
+
+	mu               sync.Mutex
+	running, pending int
+}
+
+func (d *daemon) runAll(ctx context.Context, authors []string) {
+	d.mu.Lock()
+	d.pending = len(authors)
+	d.mu.Unlock()
+	defer func() {
+		d.mu.Lock()
+		d.running, d.pending = 0, 0
+		d.mu.Unlock()
+	}()
+	d.setDetail("last_run", time.Now())
+	for _, pubkey := range authors {
+		if ctx.Err() != nil {
+			return
+		}
+		d.mu.Lock()
+		d.running, d.pending = 1, d.pending-1
+		d.mu.Unlock()
+		npub, _ := nip19.EncodePublicKey(pubkey)
+		fresh, err := d.run(ctx, pubkey)
+		if err != nil {
+			fmt.Printf("\033[31m❌ Scan of %s failed:\033[0m %v\n", npub, err)
+			continue
+		}
+		d.report(ctx, npub, fresh)
+	}
+}
+
+// counts reports the scan in progress and the authors still waiting in
+// the current run.
+func (d *daemon) counts() (running, pending int) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return d.running, d.pending
+}
+
+func (d *daemon) setDetail(key string, value any) {
+	if d.mon != nil {
+		d.mon.SetDetail(key, value)
+	}
+}
+
+// run scans the notes pubkey published since the previous run, stores the
+// run and returns the leaks not reported before.
+func (d *daemon) run(ctx context.Context, pubkey string) (*exifscan.Findings, error) {
+	st := daemonState{Pubkey: pubkey, Seen: map[string]time.Time{}}
+	if err := d.db.Get("state-"+pubkey, &st); err != nil && !errors.Is(err, store.ErrNotFound) {
+		return nil, err
+	}
+	if st.Seen == nil {
+		st.Seen = map[string]time.Time{}
+	}
+	run := daemonRun{Pubkey: pubkey, Started: time.Now(), Since: st.Newest}
+
+	opts := d.opts
+	opts.Since = st.Newest
+	scanner := exifscan.New(opts)
+	events, err := scanner.FetchEvents(ctx, pubkey)
+	if err != nil {
+		return nil, err
+	}
+	run.Findings = &exifscan.Findings{Pubkey: pubkey, Events: len(events)}
+	for r := range scanner.ScanImages(ctx, exifscan.ExtractImages(events)) {
+		d.base.apply(r)
+		d.filter.apply(r)
+		run.Findings.Images = append(run.Findings.Images, r)
+	}
+	if ctx.Err() != nil {
+		return nil, ctx.Err()
+	}
+
+	fresh := &exifscan.Findings{Pubkey: pubkey, Events: len(events)}
+	for _, r := range run.Findings.Flagged() {
+		key := r.Fingerprint
+		if _, ok := st.Seen[key]; ok {
+			continue
+		}
+		if t, ok := st.Seen[legacyKey(r)]; ok {
+			// Recorded before fingerprints: keep it and switch its key.
+			delete(st.Seen, legacyKey(r))
+			st.Seen[key] = t
+			continue
+		}
+		st.Seen[key] = run.Started
+		fresh.Images = append(fresh.Images, r)
+	}
+	run.New = len(fresh.Images)
+	if d.photos != nil {
+		if run.Linked, err = d.photos.add(pubkey, run.Findings.Images); err != nil {
+			return nil, err
+		}
+	}
+	run.Finished = time.Now()
+	if len(events) > 0 {
+		// The relay filter's since is inclusive, so the newest note is
+		// fetched again next time; Seen keeps it from being reported twice.
+		st.Newest = time.Unix(int64(events[len(events)-1].CreatedAt), 0)
+	}
+	st.LastRun = run.Started
+
+	if err := d.db.Put(fmt.Sprintf("run-%s-%d", pubkey, run.Started.Unix()), run); err != nil {
+		return nil, err
+	}
+	if err := d.db.Put("state-"+pubkey, st); err != nil {
+		return nil, err
+	}
+	fmt.Printf("🔎 Scanned \033[36m%d\033[0m new posts, \033[36m%d\033[0m images: \033[36m%d\033[0m new leaks\n", len(events), len(run.Findings.Images), run.New)
+	printLinkedPhotos(run.Linked)
+	return fresh, nil
+}
+
+// report sends the notifiers and, if enabled, an email about new leaks.
+func (d *daemon) report(ctx context.Context, npub string, fresh *exifscan.Findings) {
+	if len(fresh.Images) == 0 {
+		return
+	}
+	for _, r := range fresh.Images {
+		alert := notify.Alert{Author: npub, EventURL: report.ResultURL(r), Image: r}
+		if err := notify.All(ctx, d.notifiers, alert); err != nil {
+			fmt.Println("    ❌ Notification failed:", err)
+		}
+	}
+	if d.smtp != nil {
+		if err := emailReport(d.smtp.SMTP, report.Data{Npub: npub, Findings: fresh}); err != nil {
+			fmt.Println("\033[31m❌ Emailing report failed:\033[0m", err)
+		}
+	}
+}
+
+// legacyKey is how Seen keyed leaks before fingerprints existed.
+func legacyKey(r *exifscan.ImageResult) string {
+	return r.EventID + " " + r.URL
+}
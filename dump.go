@@ -0,0 +1,33 @@
+package main
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+
+	exif "github.com/rwcarlsen/goexif/exif"
+
+	"nostr-exif-scan/pkg/exifscan"
+)
+
+type exifDump struct {
+	SHA256  string     `json:"sha256"`
+	URL     string     `json:"url"`
+	EventID string     `json:"event_id"`
+	Tags    *exif.Exif `json:"tags"`
+}
+
+// writeExifDump stores every decoded tag of an image in dir/<sha256>.json.
+// Identical images linked from several posts share one file.
+func writeExifDump(dir string, r *exifscan.ImageResult) error {
+	data, err := json.MarshalIndent(exifDump{
+		SHA256:  r.SHA256,
+		URL:     r.URL,
+		EventID: r.EventID,
+		Tags:    r.Exif,
+	}, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(filepath.Join(dir, r.SHA256+".json"), data, 0o600)
+}
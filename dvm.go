@@ -0,0 +1,79 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/signal"
+
+	"github.com/nbd-wtf/go-nostr"
+
+	"nostr-exif-scan/internal/dvm"
+	"nostr-exif-scan/internal/nwc"
+	"nostr-exif-scan/pkg/exifscan"
+)
+
+func runDVM(args []string) int {
+	fs := newFlagSet("dvm")
+	kind := fs.Int("kind", dvm.DefaultKind, "NIP-90 job request kind to serve (results use kind+1000)")
+	maxJobs := fs.Int("max-jobs", 4, "Number of jobs running concurrently; more are queued")
+	threads := threadsFlag(fs, "Number of parallel image workers per job")
+	rps := rpsFlag(fs)
+	limit := fs.Int("limit", 10000, "Maximum number of events to fetch per job")
+	announce := fs.Bool("announce", true, "Publish a NIP-89 handler event advertising the service on start")
+	signWith := signerFlag(fs)
+	configPath := configFlag(fs)
+	parseFlags(fs, args)
+
+	cfg, err := loadConfig(*configPath)
+	if err != nil {
+		fmt.Println("\033[31m❌ Cannot load config:\033[0m", err)
+		return 1
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+
+	pool := nostr.NewSimplePool(ctx)
+	kr, err := loadSigner(ctx, pool, *signWith, cfg.Signer)
+	if err != nil {
+		fmt.Println("\033[31m❌ Cannot load signer:\033[0m", err)
+		return 1
+	}
+	var wallet *nwc.Client
+	if cfg.DVM.Pricing.SatsPer1000 > 0 {
+		if wallet, err = nwc.New(cfg.DVM.Pricing.NWC, pool); err != nil {
+			fmt.Println("\033[31m❌ Cannot set up payments:\033[0m", err)
+			return 1
+		}
+	}
+	svc := dvm.New(dvm.Config{
+		Scan: exifscan.Options{
+			Relays:    loadRelays("relays.txt"),
+			Limit:     *limit,
+			Threads:   *threads,
+			RateLimit: exifscan.NewRateLimit(*rps),
+		},
+		Kind:    *kind,
+		MaxJobs: *maxJobs,
+		Profile: cfg.DVM.Profile,
+		Pricing: cfg.DVM.Pricing,
+		Wallet:  wallet,
+	}, kr, pool)
+
+	if *announce {
+		if err := svc.Announce(ctx); err != nil {
+			fmt.Println("\033[31m❌ Announcing the service failed:\033[0m", err)
+			return 1
+		}
+		fmt.Println("📢 Published NIP-89 handler announcement")
+	}
+
+	fmt.Printf("🤖 Serving kind \033[36m%d\033[0m job requests\n", svc.Kind())
+	if err := svc.Run(ctx); err != nil && !errors.Is(err, context.Canceled) {
+		fmt.Println("\033[31m❌ DVM failed:\033[0m", err)
+		return 1
+	}
+	return 0
+}
@@ -0,0 +1,179 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"unicode/utf8"
+
+	"filippo.io/age"
+	"github.com/nbd-wtf/go-nostr"
+	"github.com/nbd-wtf/go-nostr/keyer"
+
+	"nostr-exif-scan/pkg/exifscan"
+)
+
+// nip44MaxChunk is the most plaintext NIP-44 encrypts at once.
+const nip44MaxChunk = 65535
+
+// nip44Report is the file --encrypt-to npub writes: the report cut into
+// NIP-44 payloads from a one-off sender key to the recipient.
+type nip44Report struct {
+	Encryption string   `json:"encryption"`
+	File       string   `json:"file"`
+	Sender     string   `json:"sender"`
+	Recipient  string   `json:"recipient"`
+	Chunks     []string `json:"chunks"`
+}
+
+// reportCipher encrypts reports for --encrypt-to: a nostr pubkey, or age
+// recipients.
+type reportCipher struct {
+	pubkey string
+	age    []age.Recipient
+}
+
+func parseEncryptTo(s string) (*reportCipher, error) {
+	if s == "" {
+		return nil, nil
+	}
+	if strings.HasPrefix(s, "age1") {
+		rs, err := age.ParseRecipients(strings.NewReader(strings.ReplaceAll(s, ",", "\n")))
+		if err != nil {
+			return nil, err
+		}
+		return &reportCipher{age: rs}, nil
+	}
+	pk, err := exifscan.DecodePubkey(s)
+	if err != nil {
+		return nil, err
+	}
+	return &reportCipher{pubkey: pk}, nil
+}
+
+// path is where the encrypted copy of the report at path goes.
+func (c *reportCipher) path(path string) string {
+	if c.age != nil {
+		return path + ".age"
+	}
+	return path + ".nip44"
+}
+
+func (c *reportCipher) encrypt(ctx context.Context, name string, body []byte) ([]byte, error) {
+	if c.age != nil {
+		var buf bytes.Buffer
+		w, err := age.Encrypt(&buf, c.age...)
+		if err != nil {
+			return nil, err
+		}
+		w.Write(body)
+		if err := w.Close(); err != nil {
+			return nil, err
+		}
+		return buf.Bytes(), nil
+	}
+	sender, err := keyer.NewPlainKeySigner(nostr.GeneratePrivateKey())
+	if err != nil {
+		return nil, err
+	}
+	senderPub, _ := sender.GetPublicKey(ctx)
+	out := nip44Report{Encryption: "nip44", File: filepath.Base(name), Sender: senderPub, Recipient: c.pubkey}
+	for len(body) > 0 {
+		n := min(len(body), nip44MaxChunk)
+		// Cut between characters: bunkers take the plaintext as a JSON
+		// string.
+		for n < len(body) && !utf8.RuneStart(body[n]) {
+			n--
+		}
+		chunk, err := sender.Encrypt(ctx, string(body[:n]), c.pubkey)
+		if err != nil {
+			return nil, err
+		}
+		out.Chunks = append(out.Chunks, chunk)
+		body = body[n:]
+	}
+	return json.MarshalIndent(out, "", "  ")
+}
+
+// runDecrypt turns a report --encrypt-to npub wrote back into the report,
+// with the recipient's key.
+func runDecrypt(args []string) int {
+	fs := newFlagSet("decrypt")
+	signWith := signerFlag(fs)
+	out := fs.String("o", "", "File to write the report to (default: the encrypted file's name without .nip44)")
+	configPath := configFlag(fs)
+	fs.Usage = func() {
+		fmt.Fprintf(os.Stderr, "Usage of %s decrypt:\n", os.Args[0])
+		fmt.Fprintf(os.Stderr, "  %s decrypt [--sign-with nsec...] report.html.nip44\n\n", os.Args[0])
+		fs.PrintDefaults()
+		fmt.Fprintln(os.Stderr, "\nFiles ending in .age are decrypted with age -d instead.")
+	}
+	parseFlags(fs, args)
+	if fs.NArg() != 1 {
+		fs.Usage()
+		return 1
+	}
+	in := fs.Arg(0)
+	cfg, err := loadConfig(*configPath)
+	if err != nil {
+		fmt.Println("\033[31m❌ Cannot load config:\033[0m", err)
+		return 1
+	}
+	data, err := os.ReadFile(in)
+	if err != nil {
+		fmt.Println("\033[31m❌ Cannot read report:\033[0m", err)
+		return 1
+	}
+	var enc nip44Report
+	if err := json.Unmarshal(data, &enc); err != nil || enc.Encryption != "nip44" {
+		fmt.Printf("\033[31m❌ %s isn't a report encrypted with --encrypt-to npub\033[0m\n", in)
+		return 1
+	}
+	ctx := context.Background()
+	kr, err := loadSigner(ctx, nostr.NewSimplePool(ctx), *signWith, cfg.Signer)
+	if err != nil {
+		fmt.Println("\033[31m❌ Cannot load signer:\033[0m", err)
+		return 1
+	}
+	if pk, err := kr.GetPublicKey(ctx); err == nil && pk != enc.Recipient {
+		fmt.Println("\033[31m❌ The report is encrypted for another key\033[0m")
+		return 1
+	}
+	body, err := decryptChunks(ctx, kr, enc)
+	if err != nil {
+		fmt.Println("\033[31m❌ Decrypting failed:\033[0m", err)
+		return 1
+	}
+	if *out == "" {
+		*out = strings.TrimSuffix(in, ".nip44")
+		if *out == in {
+			*out = filepath.Join(filepath.Dir(in), filepath.Base(enc.File))
+		}
+	}
+	if err := os.WriteFile(*out, body, 0o600); err != nil {
+		fmt.Println("\033[31m❌ Writing report failed:\033[0m", err)
+		return 1
+	}
+	fmt.Printf("🔓 Report decrypted to \033[36m%s\033[0m\n", *out)
+	return 0
+}
+
+func decryptChunks(ctx context.Context, kr nostr.Keyer, enc nip44Report) ([]byte, error) {
+	if len(enc.Chunks) == 0 {
+		return nil, errors.New("no encrypted content")
+	}
+	var body []byte
+	for i, c := range enc.Chunks {
+		plain, err := kr.Decrypt(ctx, c, enc.Sender)
+		if err != nil {
+			return nil, fmt.Errorf("part %d: %w", i+1, err)
+		}
+		body = append(body, plain...)
+	}
+	return body, nil
+}
@@ -0,0 +1,101 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"nostr-exif-scan/internal/report"
+	"nostr-exif-scan/pkg/exifscan"
+)
+
+// --on-error policies.
+const (
+	onErrorContinue = "continue"
+	onErrorFail     = "fail"
+)
+
+// exitFailFast is the exit status of a scan --on-error fail stopped.
+const exitFailFast = 2
+
+// minFailureSample is how many downloads a --max-failures percentage
+// waits for, so the first failures of a scan don't end it.
+const minFailureSample = 20
+
+// failureLimit is --max-failures: a number of failed downloads or, with
+// share set, a share of the downloads done.
+type failureLimit struct {
+	count int
+	share float64
+}
+
+func parseMaxFailures(s string) (failureLimit, error) {
+	if s == "" {
+		return failureLimit{}, nil
+	}
+	if v, pct := strings.CutSuffix(s, "%"); pct {
+		f, err := strconv.ParseFloat(v, 64)
+		if err != nil || f <= 0 || f > 100 {
+			return failureLimit{}, fmt.Errorf("%q is neither a count nor a percentage above 0 and at most 100%%", s)
+		}
+		return failureLimit{share: f / 100}, nil
+	}
+	n, err := strconv.Atoi(s)
+	if err != nil || n <= 0 {
+		return failureLimit{}, fmt.Errorf("%q is neither a count nor a percentage above 0 and at most 100%%", s)
+	}
+	return failureLimit{count: n}, nil
+}
+
+// exceeded reports whether failed of done downloads, out of total, is
+// over the limit.
+func (l failureLimit) exceeded(failed, done, total int) bool {
+	switch {
+	case l.count > 0:
+		return failed >= l.count
+	case l.share > 0:
+		return done >= min(minFailureSample, total) && float64(failed) > l.share*float64(done)
+	}
+	return false
+}
+
+// systemicFailure reports whether a failed download points at the network
+// or a host rather than at the image: dead links and images over the
+// memory budget are the account's business, the rest would hit the next
+// download as well.
+func systemicFailure(r *exifscan.ImageResult) bool {
+	return r.Err != nil && r.ErrorClass != exifscan.FailNotFound && r.ErrorClass != exifscan.FailTooLarge
+}
+
+// diagnoseFailures guesses why downloads keep failing from their classes
+// and hosts.
+func diagnoseFailures(failed []*exifscan.ImageResult) string {
+	classes, hosts := map[string]int{}, map[string]int{}
+	for _, r := range failed {
+		classes[r.ErrorClass]++
+		hosts[r.Host()]++
+	}
+	network := classes[exifscan.FailDNS] + classes[exifscan.FailRefused] + classes[exifscan.FailTimeout] + classes[exifscan.FailConnection]
+	top := report.Ranked(hosts)[0]
+	switch {
+	case classes[exifscan.FailNotFound] == len(failed):
+		return "Every failure is a link answering 404 or 410: the media was deleted, the network is fine."
+	case len(hosts) > 1 && network == len(failed):
+		return fmt.Sprintf("Every failure is a network error (%s) across %d hosts: the network looks down, or a firewall or proxy blocks the media hosts.", classList(classes), len(hosts))
+	case top.Count*5 >= len(failed)*4 && classes[exifscan.FailThrottled] > 0:
+		return fmt.Sprintf("%s is rate limiting the scan (HTTP 429): retry later, or slow down with --rps or --max-images-per-host.", top.Key)
+	case top.Count*5 >= len(failed)*4 && classes[exifscan.FailClientError] > 0:
+		return fmt.Sprintf("Most failures come from %s answering with client errors (%s): it likely blocks this machine or needs authentication.", top.Key, classList(classes))
+	case top.Count*5 >= len(failed)*4:
+		return fmt.Sprintf("Most failures come from %s (%s): that host looks down.", top.Key, classList(classes))
+	}
+	return fmt.Sprintf("Failures are spread over %d hosts: %s.", len(hosts), classList(classes))
+}
+
+func classList(classes map[string]int) string {
+	var out []string
+	for _, c := range report.Ranked(classes) {
+		out = append(out, fmt.Sprintf("%s %d", exifscan.FailureLabel(c.Key), c.Count))
+	}
+	return strings.Join(out, ", ")
+}
@@ -0,0 +1,133 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"regexp"
+	"strings"
+
+	"nostr-exif-scan/pkg/exifscan"
+)
+
+type filterFlagValues struct {
+	only, exclude, minSeverity *string
+}
+
+func filterFlags(fs *flag.FlagSet) filterFlagValues {
+	return filterFlagValues{
+		only:        fs.String("only", "", "Only report these comma separated leak categories or EXIF fields (e.g. gps,serial)"),
+		exclude:     fs.String("exclude", "", "Don't report these comma separated leak categories or EXIF fields (e.g. software,model)"),
+		minSeverity: fs.String("min-severity", "", "Only report leaks of at least this severity: low, medium or high"),
+	}
+}
+
+// tagFilter narrows findings down to the tags the user asked about.
+type tagFilter struct {
+	only, exclude map[string]bool
+	minRank       int
+}
+
+func (v filterFlagValues) parse() (*tagFilter, error) {
+	f := &tagFilter{}
+	var err error
+	if f.only, err = tagNames("--only", *v.only); err != nil {
+		return nil, err
+	}
+	if f.exclude, err = tagNames("--exclude", *v.exclude); err != nil {
+		return nil, err
+	}
+	if *v.minSeverity != "" {
+		if f.minRank = exifscan.SeverityRank(strings.ToLower(*v.minSeverity)); f.minRank == 0 {
+			return nil, fmt.Errorf("--min-severity must be low, medium or high")
+		}
+	}
+	return f, nil
+}
+
+// tagNames parses a list of categories and field names, case-insensitively.
+func tagNames(flagName, list string) (map[string]bool, error) {
+	if list == "" {
+		return nil, nil
+	}
+	known := map[string]bool{}
+	for _, st := range exifscan.SensitiveTags {
+		known[strings.ToLower(string(st.Field))] = true
+		known[strings.ToLower(st.Category)] = true
+	}
+	names := map[string]bool{}
+	for _, name := range strings.Split(list, ",") {
+		name = strings.ToLower(strings.TrimSpace(name))
+		if !known[name] {
+			return nil, fmt.Errorf("%s: unknown category or field %q", flagName, name)
+		}
+		names[name] = true
+	}
+	return names, nil
+}
+
+func (f *tagFilter) keep(t exifscan.Tag) bool {
+	match := func(names map[string]bool) bool {
+		return names[strings.ToLower(t.Category)] || names[strings.ToLower(t.Field)]
+	}
+	if f.only != nil && !match(f.only) {
+		return false
+	}
+	if match(f.exclude) {
+		return false
+	}
+	return exifscan.SeverityRank(exifscan.CategorySeverity[t.Category]) >= f.minRank
+}
+
+// apply drops the tags of r the filter leaves out.
+func (f *tagFilter) apply(r *exifscan.ImageResult) {
+	keepTags(r, f.keep)
+}
+
+// keepTags drops the tags of r not accepted by keep, and the GPS position
+// with the last GPS tag, reporting whether any were dropped.
+func keepTags(r *exifscan.ImageResult, keep func(exifscan.Tag) bool) bool {
+	kept := r.Tags[:0]
+	gps := false
+	for _, t := range r.Tags {
+		if !keep(t) {
+			continue
+		}
+		kept = append(kept, t)
+		gps = gps || t.Category == exifscan.CategoryGPS
+	}
+	dropped := len(kept) < len(r.Tags)
+	// The fingerprint covers the leak categories, which may be fewer now.
+	r.SetTags(kept)
+	if !gps {
+		r.GPS = nil
+	}
+	return dropped
+}
+
+// parseGeohashes parses the --geohash cells, lowercased.
+func parseGeohashes(list string) ([]string, error) {
+	var cells []string
+	for _, gh := range strings.Split(list, ",") {
+		if gh = strings.ToLower(strings.TrimSpace(gh)); gh == "" {
+			continue
+		}
+		if _, ok := exifscan.DecodeGeohash(gh); !ok {
+			return nil, fmt.Errorf("%q is not a geohash", gh)
+		}
+		cells = append(cells, gh)
+	}
+	return cells, nil
+}
+
+// contentRegexp compiles a --content-match or --content-exclude pattern,
+// nil when empty.
+func contentRegexp(pattern string) (*regexp.Regexp, error) {
+	if pattern == "" {
+		return nil, nil
+	}
+	// Compiled bare first, so errors quote the pattern as given.
+	if _, err := regexp.Compile(pattern); err != nil {
+		return nil, err
+	}
+	return regexp.Compile("(?i)" + pattern)
+}
@@ -0,0 +1,92 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/nbd-wtf/go-nostr"
+
+	"nostr-exif-scan/internal/media"
+	"nostr-exif-scan/pkg/exifscan"
+)
+
+// hostedImages lists the images pubkey keeps on its Blossom servers (BUD-03
+// kind 10063 list) and, when signer is pubkey itself, on its NIP-96 servers
+// (kind 10096 list), skipping those the linked images already cover. The
+// Blossom listing is authenticated when signer is pubkey; NIP-96 listings
+// always need it.
+func hostedImages(ctx context.Context, pool *nostr.SimplePool, relays []string, pubkey string, linked []exifscan.Image, signer nostr.Signer) []exifscan.Image {
+	if signer != nil {
+		if pk, err := signer.GetPublicKey(ctx); err != nil || pk != pubkey {
+			signer = nil
+		}
+	}
+	seen := map[string]bool{}
+	for _, img := range linked {
+		seen[img.URL] = true
+	}
+	isLinked := func(b media.Blob) bool {
+		if seen[b.URL] {
+			return true
+		}
+		for _, img := range linked {
+			if b.SHA256 != "" && strings.Contains(img.URL, b.SHA256) {
+				return true
+			}
+		}
+		return false
+	}
+
+	var out []exifscan.Image
+	collect := func(server string, blobs []media.Blob, err error) {
+		if err != nil {
+			fmt.Printf("⚠️  Cannot list \033[33m%s\033[0m: %v\n", server, err)
+			return
+		}
+		n := 0
+		for _, b := range blobs {
+			if !strings.HasPrefix(b.Type, "image/") || isLinked(b) {
+				continue
+			}
+			seen[b.URL] = true
+			out = append(out, exifscan.Image{URL: b.URL, Source: server, SHA256: strings.ToLower(b.SHA256)})
+			n++
+		}
+		fmt.Printf("🌸 \033[36m%s\033[0m: %d file(s), %d unlinked image(s)\n", server, len(blobs), n)
+	}
+
+	client := &http.Client{Timeout: 30 * time.Second}
+	for _, server := range media.BlossomServers(ctx, pool, relays, pubkey) {
+		blobs, err := media.BlossomList(ctx, client, server, pubkey, signer)
+		collect(server, blobs, err)
+	}
+	servers := media.NIP96Servers(ctx, pool, relays, pubkey)
+	if len(servers) > 0 && signer == nil {
+		fmt.Println("ℹ️  Skipping the NIP-96 servers: listing them needs the account's own signer (--sign-with)")
+		return out
+	}
+	for _, server := range servers {
+		n := &media.NIP96{Server: server, Signer: signer, Client: client}
+		blobs, err := n.List(ctx)
+		collect(server, blobs, err)
+	}
+	return out
+}
+
+// optionalSigner loads the configured signer, or returns nil when none is
+// configured or it can't be loaded.
+func optionalSigner(ctx context.Context, pool *nostr.SimplePool, input string, cfg signerConfig) nostr.Keyer {
+	if input == "" && os.Getenv(signerEnv) == "" && cfg.URI == "" {
+		return nil
+	}
+	kr, err := loadSigner(ctx, pool, input, cfg)
+	if err != nil {
+		fmt.Println("⚠️  Cannot load signer:", err)
+		return nil
+	}
+	return kr
+}
@@ -0,0 +1,237 @@
+// Package dm tells authors about their leaking images with NIP-17 private
+// messages.
+package dm
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+	"text/template"
+	"time"
+
+	"github.com/nbd-wtf/go-nostr"
+	"github.com/nbd-wtf/go-nostr/nip17"
+	"github.com/nbd-wtf/go-nostr/nip19"
+	"github.com/nbd-wtf/go-nostr/nip59"
+
+	"nostr-exif-scan/internal/report"
+	"nostr-exif-scan/pkg/exifscan"
+)
+
+var (
+	// ErrOptedOut is returned for recipients who asked not to be messaged.
+	ErrOptedOut = errors.New("recipient opted out")
+	// ErrTooSoon is returned when the recipient was messaged within Interval.
+	ErrTooSoon = errors.New("recipient was messaged recently")
+)
+
+// Config is the dm section of the config file.
+type Config struct {
+	// Template is a text/template file overriding the built-in message.
+	Template string `json:"template,omitempty"`
+	// Interval is the minimum time between two messages to the same
+	// recipient (default 168h).
+	Interval string `json:"interval,omitempty"`
+	// PerHour caps how many messages are sent per hour (default 30).
+	PerHour int `json:"per_hour,omitempty"`
+	// OptOut lists npubs that must never be messaged.
+	OptOut []string `json:"opt_out,omitempty"`
+	// StateFile remembers who was messaged and who replied STOP
+	// (default dm-state.json).
+	StateFile string `json:"state_file,omitempty"`
+}
+
+// Message is the data the template is executed with.
+type Message struct {
+	Npub   string
+	Images []*exifscan.ImageResult
+}
+
+const defaultTemplate = `Hi! An automated privacy check found that {{len .Images}} image(s) you posted on nostr still carry embedded photo metadata (EXIF):
+{{range .Images}}
+- {{if .EventID}}{{resultURL .}}{{else}}{{.URL}} (stored on your media server, not linked in any note){{end}} ({{join .Categories ", "}}){{with .GPS}} – reveals where the photo was taken, to within {{.Accuracy}}{{end}}{{with .Post}}
+  {{.}}{{end}}
+{{- end}}
+
+Anyone can download these images and read this data. To fix it, delete the affected posts and re-upload the pictures after removing the metadata (for example with "exiftool -all= photo.jpg"), and check whether your client or media host can strip metadata on upload.
+
+Reply STOP and you won't get messages like this again.`
+
+var funcs = template.FuncMap{
+	"resultURL": report.ResultURL,
+	"join":      strings.Join,
+}
+
+type state struct {
+	Sent     map[string]time.Time `json:"sent"`
+	OptedOut map[string]time.Time `json:"opted_out"`
+}
+
+// Sender delivers messages and enforces the opt-out list and rate limits.
+type Sender struct {
+	kr       nostr.Keyer
+	pool     *nostr.SimplePool
+	relays   []string
+	tmpl     *template.Template
+	interval time.Duration
+	gap      time.Duration
+	optOut   map[string]bool
+	path     string
+
+	mu    sync.Mutex
+	state state
+	last  time.Time
+}
+
+// New loads the template and the state file. relays are used to look up
+// recipients' DM relays and to store our copy of each message.
+func New(cfg Config, kr nostr.Keyer, pool *nostr.SimplePool, relays []string) (*Sender, error) {
+	s := &Sender{
+		kr:       kr,
+		pool:     pool,
+		relays:   relays,
+		interval: 7 * 24 * time.Hour,
+		gap:      time.Hour / 30,
+		optOut:   map[string]bool{},
+		path:     cfg.StateFile,
+		state:    state{Sent: map[string]time.Time{}, OptedOut: map[string]time.Time{}},
+	}
+	src := defaultTemplate
+	if cfg.Template != "" {
+		data, err := os.ReadFile(cfg.Template)
+		if err != nil {
+			return nil, err
+		}
+		src = string(data)
+	}
+	tmpl, err := template.New("dm").Funcs(funcs).Parse(src)
+	if err != nil {
+		return nil, err
+	}
+	s.tmpl = tmpl
+	if cfg.Interval != "" {
+		if s.interval, err = time.ParseDuration(cfg.Interval); err != nil {
+			return nil, fmt.Errorf("dm interval: %w", err)
+		}
+	}
+	if cfg.PerHour > 0 {
+		s.gap = time.Hour / time.Duration(cfg.PerHour)
+	}
+	for _, npub := range cfg.OptOut {
+		pk, err := exifscan.DecodePubkey(npub)
+		if err != nil {
+			return nil, fmt.Errorf("dm opt_out %q: %w", npub, err)
+		}
+		s.optOut[pk] = true
+	}
+	if s.path == "" {
+		s.path = "dm-state.json"
+	}
+	data, err := os.ReadFile(s.path)
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return nil, err
+	}
+	if err == nil {
+		if err := json.Unmarshal(data, &s.state); err != nil {
+			return nil, fmt.Errorf("%s: %w", s.path, err)
+		}
+	}
+	return s, nil
+}
+
+// Send messages pubkey about its sensitive images. Nothing is sent when
+// none of images is sensitive. It blocks while the hourly limit is
+// exhausted.
+func (s *Sender) Send(ctx context.Context, pubkey string, images []*exifscan.ImageResult) error {
+	var flagged []*exifscan.ImageResult
+	for _, r := range images {
+		if r.Sensitive() {
+			flagged = append(flagged, r)
+		}
+	}
+	if len(flagged) == 0 {
+		return nil
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	_, stopped := s.state.OptedOut[pubkey]
+	if s.optOut[pubkey] || stopped {
+		return ErrOptedOut
+	}
+	if time.Since(s.state.Sent[pubkey]) < s.interval {
+		return ErrTooSoon
+	}
+	if s.repliedStop(ctx, pubkey) {
+		s.state.OptedOut[pubkey] = time.Now()
+		if err := s.save(); err != nil {
+			return err
+		}
+		return ErrOptedOut
+	}
+
+	npub, _ := nip19.EncodePublicKey(pubkey)
+	var buf bytes.Buffer
+	if err := s.tmpl.Execute(&buf, Message{Npub: npub, Images: flagged}); err != nil {
+		return err
+	}
+
+	if wait := s.gap - time.Since(s.last); wait > 0 {
+		select {
+		case <-time.After(wait):
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+	theirs := nip17.GetDMRelays(ctx, pubkey, s.pool, s.relays)
+	if len(theirs) == 0 {
+		theirs = s.relays
+	}
+	if err := nip17.PublishMessage(ctx, buf.String(), nil, s.pool, s.relays, theirs, s.kr, pubkey, nil); err != nil {
+		return err
+	}
+	s.last = time.Now()
+	s.state.Sent[pubkey] = s.last
+	return s.save()
+}
+
+// repliedStop looks through messages sent to us for a STOP from pubkey.
+func (s *Sender) repliedStop(ctx context.Context, pubkey string) bool {
+	ours, err := s.kr.GetPublicKey(ctx)
+	if err != nil {
+		return false
+	}
+	ctx, cancel := context.WithTimeout(ctx, 15*time.Second)
+	defer cancel()
+	filter := nostr.Filter{Kinds: []int{nostr.KindGiftWrap}, Tags: nostr.TagMap{"p": {ours}}}
+	for ie := range s.pool.FetchMany(ctx, s.relays, filter) {
+		rumor, err := nip59.GiftUnwrap(*ie.Event, func(other, ciphertext string) (string, error) {
+			return s.kr.Decrypt(ctx, ciphertext, other)
+		})
+		if err != nil || rumor.PubKey != pubkey {
+			continue
+		}
+		if strings.EqualFold(strings.TrimSpace(rumor.Content), "stop") {
+			return true
+		}
+	}
+	return false
+}
+
+func (s *Sender) save() error {
+	data, err := json.MarshalIndent(s.state, "", "  ")
+	if err != nil {
+		return err
+	}
+	tmp := s.path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0o600); err != nil {
+		return err
+	}
+	return os.Rename(tmp, s.path)
+}
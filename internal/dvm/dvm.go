@@ -0,0 +1,369 @@
+// Package dvm runs the scanner as a NIP-90 Data Vending Machine: it
+// answers job requests published on relays with scan results.
+package dvm
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"strconv"
+	"sync/atomic"
+	"time"
+
+	"github.com/nbd-wtf/go-nostr"
+
+	"nostr-exif-scan/internal/nwc"
+	"nostr-exif-scan/internal/publish"
+	"nostr-exif-scan/pkg/exifscan"
+)
+
+// DefaultKind is the job request kind served when Config.Kind is zero. No
+// kind is registered for metadata scans, so it is picked from the unused
+// part of the 5000-5999 range.
+const DefaultKind = 5960
+
+// Feedback states (NIP-90 kind 7000 "status" tags).
+const (
+	StatusPayment    = "payment-required"
+	StatusProcessing = "processing"
+	StatusError      = "error"
+	StatusSuccess    = "success"
+)
+
+// Config tunes the service.
+type Config struct {
+	// Scan is the template for every job; its Limit caps the "limit" param.
+	Scan    exifscan.Options
+	Kind    int
+	MaxJobs int
+	// ProgressEvery is how often processing feedback is sent (default 15s).
+	ProgressEvery time.Duration
+	Profile       Profile
+	Pricing       Pricing
+	// Wallet issues invoices; jobs are free when it is nil.
+	Wallet *nwc.Client
+}
+
+// Pricing charges for jobs above a free tier.
+type Pricing struct {
+	SatsPer1000 int64 `json:"sats_per_1000"`
+	// FreeImages is the largest job, in images, run without payment.
+	FreeImages int `json:"free_images"`
+	// NWC is the nostr+walletconnect:// URI of the wallet issuing invoices.
+	NWC string `json:"nwc"`
+	// PaymentTimeout is how long an invoice stays valid (default 10m).
+	PaymentTimeout string `json:"payment_timeout,omitempty"`
+}
+
+// price returns the cost of scanning n images in millisatoshis, rounding
+// up to the next thousand images.
+func (p Pricing) price(n int) int64 {
+	if p.SatsPer1000 <= 0 || n <= p.FreeImages {
+		return 0
+	}
+	return int64((n+999)/1000) * p.SatsPer1000 * 1000
+}
+
+func (p Pricing) describe() string {
+	if p.SatsPer1000 <= 0 {
+		return "Free."
+	}
+	return fmt.Sprintf("Free up to %d images, then %d sats per 1000 images.", p.FreeImages, p.SatsPer1000)
+}
+
+// Profile describes the service in its NIP-89 announcement.
+type Profile struct {
+	Name    string `json:"name,omitempty"`
+	About   string `json:"about,omitempty"`
+	Picture string `json:"picture,omitempty"`
+}
+
+// Result is the content of a job result event.
+type Result struct {
+	Pubkey  string                  `json:"pubkey"`
+	Events  int                     `json:"events"`
+	Images  int                     `json:"images"`
+	Flagged []*exifscan.ImageResult `json:"flagged"`
+}
+
+// Service listens for job requests and publishes results.
+type Service struct {
+	cfg   Config
+	kr    nostr.Keyer
+	pool  *nostr.SimplePool
+	pub   *publish.Publisher
+	slots chan struct{}
+}
+
+// New returns a Service signing with kr and talking to cfg.Scan.Relays.
+func New(cfg Config, kr nostr.Keyer, pool *nostr.SimplePool) *Service {
+	if cfg.Kind == 0 {
+		cfg.Kind = DefaultKind
+	}
+	if cfg.MaxJobs < 1 {
+		cfg.MaxJobs = 4
+	}
+	if cfg.ProgressEvery <= 0 {
+		cfg.ProgressEvery = 15 * time.Second
+	}
+	if cfg.Scan.Limit <= 0 {
+		cfg.Scan.Limit = 10000
+	}
+	if cfg.Wallet == nil {
+		cfg.Pricing = Pricing{}
+	}
+	return &Service{
+		cfg:   cfg,
+		kr:    kr,
+		pool:  pool,
+		pub:   publish.New(kr, pool, cfg.Scan.Relays),
+		slots: make(chan struct{}, cfg.MaxJobs),
+	}
+}
+
+// Kind returns the job request kind served.
+func (s *Service) Kind() int { return s.cfg.Kind }
+
+// Announce publishes (or replaces) the NIP-89 kind 31990 handler event
+// advertising the job kind and its params, so clients can list the service.
+func (s *Service) Announce(ctx context.Context) error {
+	p := s.cfg.Profile
+	if p.Name == "" {
+		p.Name = "nostr-exif-scan"
+	}
+	if p.About == "" {
+		p.About = "Scans the images posted by an npub for leaking EXIF metadata (GPS position, camera serials, ...)."
+	}
+	if s.cfg.Wallet != nil {
+		p.About += " " + s.cfg.Pricing.describe()
+	}
+	content, err := json.Marshal(struct {
+		Profile
+		Params map[string]param `json:"nip90Params"`
+	}{p, map[string]param{
+		"limit": {Required: false, Values: []string{}},
+		"since": {Required: false, Values: []string{}},
+		"until": {Required: false, Values: []string{}},
+	}})
+	if err != nil {
+		return err
+	}
+	kind := strconv.Itoa(s.cfg.Kind)
+	return s.pub.Publish(ctx, &nostr.Event{
+		Kind:    nostr.KindHandlerInformation,
+		Tags:    nostr.Tags{{"d", "nostr-exif-scan-" + kind}, {"k", kind}},
+		Content: string(content),
+	})
+}
+
+type param struct {
+	Required bool     `json:"required"`
+	Values   []string `json:"values"`
+}
+
+// Run handles job requests created from now on until ctx is done.
+func (s *Service) Run(ctx context.Context) error {
+	ours, err := s.kr.GetPublicKey(ctx)
+	if err != nil {
+		return err
+	}
+	since := nostr.Now()
+	filter := nostr.Filter{Kinds: []int{s.cfg.Kind}, Since: &since}
+	for ie := range s.pool.SubscribeMany(ctx, s.cfg.Scan.Relays, filter) {
+		req := ie.Event
+		if p := req.Tags.Find("p"); p != nil && p[1] != ours {
+			continue // addressed to another service provider
+		}
+		go s.handle(ctx, req)
+	}
+	return ctx.Err()
+}
+
+func (s *Service) handle(ctx context.Context, req *nostr.Event) {
+	pubkey, opts, err := s.parse(req)
+	if err != nil {
+		log.Printf("dvm: job %s from %s: %v", req.ID, req.PubKey, err)
+		s.feedback(ctx, req, StatusError, err.Error())
+		return
+	}
+
+	log.Printf("dvm: job %s from %s: scanning %s", req.ID, req.PubKey, pubkey)
+	s.feedback(ctx, req, StatusProcessing, "fetching notes")
+
+	var scanned, total atomic.Int64
+	next := opts.Hooks.OnImageScanned
+	opts.Hooks.OnImageScanned = func(r *exifscan.ImageResult) {
+		scanned.Add(1)
+		if next != nil {
+			next(r)
+		}
+	}
+	scanner := exifscan.New(opts)
+	events, err := scanner.FetchEvents(ctx, pubkey)
+	if err != nil {
+		s.feedback(ctx, req, StatusError, "fetching notes failed: "+err.Error())
+		return
+	}
+	images := exifscan.ExtractImages(events)
+	total.Store(int64(len(images)))
+
+	if err := s.charge(ctx, req, len(images)); err != nil {
+		log.Printf("dvm: job %s: %v", req.ID, err)
+		s.feedback(ctx, req, StatusError, err.Error())
+		return
+	}
+
+	select {
+	case s.slots <- struct{}{}:
+	case <-ctx.Done():
+		return
+	}
+	defer func() { <-s.slots }()
+
+	done := make(chan struct{})
+	go func() {
+		tick := time.NewTicker(s.cfg.ProgressEvery)
+		defer tick.Stop()
+		for {
+			select {
+			case <-tick.C:
+				s.feedback(ctx, req, StatusProcessing, fmt.Sprintf("scanned %d/%d images", scanned.Load(), total.Load()))
+			case <-done:
+				return
+			}
+		}
+	}()
+	res := Result{Pubkey: pubkey, Events: len(events), Images: len(images), Flagged: []*exifscan.ImageResult{}}
+	for r := range scanner.ScanImages(ctx, images) {
+		if r.Sensitive() {
+			res.Flagged = append(res.Flagged, r)
+		}
+	}
+	close(done)
+
+	if err := s.result(ctx, req, res); err != nil {
+		log.Printf("dvm: job %s: publishing result: %v", req.ID, err)
+		return
+	}
+	s.feedback(ctx, req, StatusSuccess, fmt.Sprintf("%d of %d images leak metadata", len(res.Flagged), len(images)))
+	log.Printf("dvm: job %s done: %d/%d images flagged", req.ID, len(res.Flagged), len(images))
+}
+
+// charge asks for payment of jobs above the free tier and waits until the
+// invoice is paid. A ["bid", msats] tag lower than the price fails the job.
+func (s *Service) charge(ctx context.Context, req *nostr.Event, images int) error {
+	msats := s.cfg.Pricing.price(images)
+	if msats == 0 {
+		return nil
+	}
+	if bid := req.Tags.Find("bid"); bid != nil {
+		if n, err := strconv.ParseInt(bid[1], 10, 64); err == nil && n < msats {
+			return fmt.Errorf("scanning %d images costs %d msats, above the bid of %d", images, msats, n)
+		}
+	}
+	timeout := 10 * time.Minute
+	if s.cfg.Pricing.PaymentTimeout != "" {
+		if d, err := time.ParseDuration(s.cfg.Pricing.PaymentTimeout); err == nil {
+			timeout = d
+		}
+	}
+	inv, err := s.cfg.Wallet.MakeInvoice(ctx, msats, "nostr-exif-scan job "+req.ID, timeout)
+	if err != nil {
+		return fmt.Errorf("creating invoice failed: %w", err)
+	}
+	s.feedbackTags(ctx, req, StatusPayment, fmt.Sprintf("scanning %d images costs %d sats", images, msats/1000),
+		nostr.Tag{"amount", strconv.FormatInt(msats, 10), inv.Invoice})
+	log.Printf("dvm: job %s: waiting for payment of %d msats", req.ID, msats)
+
+	deadline := time.After(timeout)
+	tick := time.NewTicker(5 * time.Second)
+	defer tick.Stop()
+	for {
+		select {
+		case <-tick.C:
+			inv, err := s.cfg.Wallet.LookupInvoice(ctx, inv.PaymentHash)
+			if err != nil {
+				log.Printf("dvm: job %s: %v", req.ID, err)
+				continue
+			}
+			if inv.Paid() {
+				return nil
+			}
+		case <-deadline:
+			return errors.New("payment not received in time")
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+}
+
+// parse reads the "i" input (an npub, nprofile or hex key) and the limit,
+// since and until params of req.
+func (s *Service) parse(req *nostr.Event) (string, exifscan.Options, error) {
+	opts := s.cfg.Scan
+	input := req.Tags.Find("i")
+	if input == nil {
+		return "", opts, errors.New("missing input: expected [\"i\", \"<npub>\", \"text\"]")
+	}
+	pubkey, err := exifscan.DecodePubkey(input[1])
+	if err != nil {
+		return "", opts, fmt.Errorf("invalid input: %w", err)
+	}
+	for _, tag := range req.Tags {
+		if len(tag) < 3 || tag[0] != "param" {
+			continue
+		}
+		n, err := strconv.ParseInt(tag[2], 10, 64)
+		if err != nil {
+			return "", opts, fmt.Errorf("param %s: %w", tag[1], err)
+		}
+		switch tag[1] {
+		case "limit":
+			if int(n) < opts.Limit {
+				opts.Limit = int(n)
+			}
+		case "since":
+			opts.Since = time.Unix(n, 0)
+		case "until":
+			opts.Until = time.Unix(n, 0)
+		}
+	}
+	return pubkey, opts, nil
+}
+
+func (s *Service) feedback(ctx context.Context, req *nostr.Event, status, info string) {
+	s.feedbackTags(ctx, req, status, info)
+}
+
+func (s *Service) feedbackTags(ctx context.Context, req *nostr.Event, status, info string, extra ...nostr.Tag) {
+	evt := &nostr.Event{
+		Kind: nostr.KindJobFeedback,
+		Tags: append(nostr.Tags{
+			{"status", status, info},
+			{"e", req.ID},
+			{"p", req.PubKey},
+		}, extra...),
+	}
+	if err := s.pub.Publish(ctx, evt); err != nil {
+		log.Printf("dvm: job %s: publishing %s feedback: %v", req.ID, status, err)
+	}
+}
+
+func (s *Service) result(ctx context.Context, req *nostr.Event, res Result) error {
+	content, err := json.Marshal(res)
+	if err != nil {
+		return err
+	}
+	request, _ := json.Marshal(req)
+	tags := nostr.Tags{
+		{"request", string(request)},
+		{"e", req.ID},
+		{"p", req.PubKey},
+	}
+	if input := req.Tags.Find("i"); input != nil {
+		tags = append(tags, input)
+	}
+	return s.pub.Publish(ctx, &nostr.Event{Kind: s.cfg.Kind + 1000, Tags: tags, Content: string(content)})
+}
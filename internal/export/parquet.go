@@ -0,0 +1,191 @@
+// Package export writes scan results in formats meant for bulk analysis.
+package export
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/parquet-go/parquet-go"
+
+	"nostr-exif-scan/pkg/exifscan"
+)
+
+// ImageRow is one scanned image in images.parquet.
+type ImageRow struct {
+	Pubkey      string    `parquet:"pubkey,dict"`
+	EventID     string    `parquet:"event_id,optional"`
+	PostedAt    time.Time `parquet:"posted_at,optional,timestamp(millisecond)"`
+	PostKind    int       `parquet:"post_kind,optional"`
+	Reply       bool      `parquet:"reply"`
+	Warning     bool      `parquet:"content_warning"`
+	Excerpt     string    `parquet:"post_excerpt,optional"`
+	URL         string    `parquet:"url"`
+	Host        string    `parquet:"host,dict"`
+	Source      string    `parquet:"source,optional,dict"`
+	SHA256      string    `parquet:"sha256,optional"`
+	Declared    string    `parquet:"declared_sha256,optional"`
+	Mismatch    bool      `parquet:"hash_mismatch"`
+	ContentType string    `parquet:"content_type,optional,dict"`
+	Mistyped    bool      `parquet:"type_mismatch"`
+	Size        int64     `parquet:"size"`
+	HasMetadata bool      `parquet:"has_metadata"`
+	Provenance  string    `parquet:"provenance,optional,dict"`
+	Quality     int       `parquet:"jpeg_quality,optional"`
+	Sensitive   bool      `parquet:"sensitive"`
+	Severity    string    `parquet:"severity,optional,dict"`
+	Categories  []string  `parquet:"categories,list"`
+	Lat         *float64  `parquet:"lat,optional"`
+	Lon         *float64  `parquet:"lon,optional"`
+	GPSRadius   *float64  `parquet:"gps_radius_m,optional"`
+	Coordinates string    `parquet:"coordinates,optional"`
+	Fingerprint string    `parquet:"fingerprint,optional"`
+	Error       string    `parquet:"error,optional"`
+	Status      int       `parquet:"status,optional"`
+	ErrorClass  string    `parquet:"error_class,optional,dict"`
+	Retries     int       `parquet:"retries"`
+	Dead        string    `parquet:"dead,optional,dict"`
+	Archived    string    `parquet:"archived,optional"`
+	Origin      string    `parquet:"origin_url,optional"`
+	OriginLeaks bool      `parquet:"origin_leaks"`
+	DurationMS  int64     `parquet:"duration_ms"`
+}
+
+// FindingRow is one sensitive tag of an image in findings.parquet.
+type FindingRow struct {
+	Pubkey      string `parquet:"pubkey,dict"`
+	EventID     string `parquet:"event_id,optional"`
+	URL         string `parquet:"url"`
+	SHA256      string `parquet:"sha256"`
+	Fingerprint string `parquet:"fingerprint"`
+	Field       string `parquet:"field,dict"`
+	Category    string `parquet:"category,dict"`
+	Severity    string `parquet:"severity,dict"`
+	Value       string `parquet:"value,optional"`
+}
+
+// rowGroupSize bounds the rows buffered in memory before a row group is
+// flushed, so relay-wide audits don't hold every row at once.
+const rowGroupSize = 100_000
+
+// Parquet writes dir/images.parquet and dir/findings.parquet.
+type Parquet struct {
+	images, findings               *os.File
+	imageRows                      *parquet.GenericWriter[ImageRow]
+	findingRows                    *parquet.GenericWriter[FindingRow]
+	pendingImages, pendingFindings int
+}
+
+// NewParquet creates dir and both files in it, replacing earlier ones.
+func NewParquet(dir string) (*Parquet, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, err
+	}
+	images, err := os.Create(filepath.Join(dir, "images.parquet"))
+	if err != nil {
+		return nil, err
+	}
+	findings, err := os.Create(filepath.Join(dir, "findings.parquet"))
+	if err != nil {
+		images.Close()
+		return nil, err
+	}
+	codec := parquet.Compression(&parquet.Zstd)
+	return &Parquet{
+		images:      images,
+		findings:    findings,
+		imageRows:   parquet.NewGenericWriter[ImageRow](images, codec),
+		findingRows: parquet.NewGenericWriter[FindingRow](findings, codec),
+	}, nil
+}
+
+// Add writes the rows of one scanned image of pubkey.
+func (p *Parquet) Add(pubkey string, r *exifscan.ImageResult) error {
+	row := ImageRow{
+		Pubkey:      pubkey,
+		EventID:     r.EventID,
+		URL:         r.URL,
+		Host:        r.Host(),
+		Source:      r.Source,
+		SHA256:      r.SHA256,
+		Declared:    r.DeclaredSHA256,
+		Mismatch:    r.HashMismatch,
+		ContentType: r.ContentType,
+		Mistyped:    r.TypeMismatch,
+		Size:        int64(r.Size),
+		HasMetadata: r.HasMetadata,
+		Sensitive:   r.Sensitive(),
+		Severity:    r.Severity(),
+		Categories:  r.Categories(),
+		Fingerprint: r.Fingerprint,
+		Error:       r.Error,
+		Status:      r.Status,
+		ErrorClass:  r.ErrorClass,
+		Retries:     r.Retries,
+		Dead:        r.Dead,
+		Archived:    r.Archived,
+		Origin:      r.Origin,
+		OriginLeaks: r.OriginLeaks,
+		DurationMS:  r.Duration.Milliseconds(),
+	}
+	if r.Post != nil {
+		row.PostedAt, row.PostKind = r.Post.CreatedAt, r.Post.Kind
+		row.Reply, row.Excerpt = r.Post.Reply, strings.ToValidUTF8(r.Post.Excerpt, "�")
+		row.Warning = r.Post.ContentWarning
+	}
+	if r.Provenance != nil {
+		row.Provenance, row.Quality = r.Provenance.Class, r.Provenance.Quality
+	}
+	if r.GPS != nil {
+		radius := r.GPS.Radius()
+		row.Lat, row.Lon, row.GPSRadius = &r.GPS.Lat, &r.GPS.Lon, &radius
+		row.Coordinates = r.GPS.String()
+	}
+	if _, err := p.imageRows.Write([]ImageRow{row}); err != nil {
+		return err
+	}
+	if p.pendingImages++; p.pendingImages == rowGroupSize {
+		p.pendingImages = 0
+		if err := p.imageRows.Flush(); err != nil {
+			return err
+		}
+	}
+
+	if len(r.Tags) == 0 {
+		return nil
+	}
+	rows := make([]FindingRow, len(r.Tags))
+	for i, t := range r.Tags {
+		rows[i] = FindingRow{
+			Pubkey:      pubkey,
+			EventID:     r.EventID,
+			URL:         r.URL,
+			SHA256:      r.SHA256,
+			Fingerprint: r.Fingerprint,
+			Field:       t.Field,
+			Category:    t.Category,
+			Severity:    exifscan.CategorySeverity[t.Category],
+			Value:       strings.ToValidUTF8(t.Value, "�"),
+		}
+	}
+	if _, err := p.findingRows.Write(rows); err != nil {
+		return err
+	}
+	if p.pendingFindings += len(rows); p.pendingFindings >= rowGroupSize {
+		p.pendingFindings = 0
+		return p.findingRows.Flush()
+	}
+	return nil
+}
+
+// Close writes the file footers.
+func (p *Parquet) Close() error {
+	return errors.Join(
+		p.imageRows.Close(),
+		p.findingRows.Close(),
+		p.images.Close(),
+		p.findings.Close(),
+	)
+}
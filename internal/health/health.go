@@ -0,0 +1,181 @@
+// Package health serves the liveness, readiness and status endpoints of
+// the long-running modes.
+package health
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+
+	"nostr-exif-scan/pkg/exifscan"
+)
+
+// Relay connection states.
+const (
+	RelayUnknown     = "unknown"
+	RelayConnected   = "connected"
+	RelayUnreachable = "unreachable"
+)
+
+// RelayState is the outcome of the last connection attempt to a relay.
+type RelayState struct {
+	URL   string    `json:"url"`
+	State string    `json:"state"`
+	Error string    `json:"error,omitempty"`
+	Since time.Time `json:"since,omitzero"`
+}
+
+// Status is served at /status.
+type Status struct {
+	Mode       string       `json:"mode"`
+	Ready      bool         `json:"ready"`
+	StartedAt  time.Time    `json:"started_at"`
+	ActiveJobs int          `json:"active_jobs"`
+	QueueDepth int          `json:"queue_depth"`
+	Relays     []RelayState `json:"relays"`
+	// Details holds mode specific fields, e.g. the daemon's next run.
+	Details map[string]any `json:"details,omitempty"`
+}
+
+// Monitor tracks the state of one process.
+type Monitor struct {
+	mode    string
+	started time.Time
+
+	mu      sync.Mutex
+	ready   bool
+	relays  map[string]*RelayState
+	jobs    func() (active, queued int)
+	details map[string]any
+}
+
+// New returns a Monitor for mode that reports relays as unknown until a
+// scan connects to them. It is not ready until SetReady is called.
+func New(mode string, relays []string) *Monitor {
+	m := &Monitor{
+		mode:    mode,
+		started: time.Now().UTC(),
+		relays:  map[string]*RelayState{},
+		details: map[string]any{},
+	}
+	for _, url := range relays {
+		m.relays[url] = &RelayState{URL: url, State: RelayUnknown}
+	}
+	return m
+}
+
+// SetReady marks the process as able (or no longer able) to do work.
+func (m *Monitor) SetReady(ready bool) {
+	m.mu.Lock()
+	m.ready = ready
+	m.mu.Unlock()
+}
+
+// SetJobs registers the function reporting running and queued work.
+func (m *Monitor) SetJobs(fn func() (active, queued int)) {
+	m.mu.Lock()
+	m.jobs = fn
+	m.mu.Unlock()
+}
+
+// SetDetail sets a mode specific status field; a nil value removes it.
+func (m *Monitor) SetDetail(key string, value any) {
+	m.mu.Lock()
+	if value == nil {
+		delete(m.details, key)
+	} else {
+		m.details[key] = value
+	}
+	m.mu.Unlock()
+}
+
+// Hooks returns hooks that record relay connection states and then call
+// next.
+func (m *Monitor) Hooks(next exifscan.Hooks) exifscan.Hooks {
+	hooks := next
+	hooks.OnRelayConnected = func(url string) {
+		m.setRelay(url, RelayConnected, "")
+		if next.OnRelayConnected != nil {
+			next.OnRelayConnected(url)
+		}
+	}
+	hooks.OnError = func(err error) {
+		var se *exifscan.ScanError
+		if errors.As(err, &se) && se.Stage == exifscan.StageRelay {
+			m.setRelay(se.URL, RelayUnreachable, se.Err.Error())
+		}
+		if next.OnError != nil {
+			next.OnError(err)
+		}
+	}
+	return hooks
+}
+
+func (m *Monitor) setRelay(url, state, errMsg string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	rs, ok := m.relays[url]
+	if !ok {
+		rs = &RelayState{URL: url}
+		m.relays[url] = rs
+	}
+	if rs.State != state {
+		rs.Since = time.Now().UTC()
+	}
+	rs.State, rs.Error = state, errMsg
+}
+
+// Status returns a snapshot of the process state.
+func (m *Monitor) Status() Status {
+	m.mu.Lock()
+	jobs := m.jobs
+	st := Status{
+		Mode:      m.mode,
+		StartedAt: m.started,
+		Relays:    make([]RelayState, 0, len(m.relays)),
+	}
+	down := 0
+	for _, rs := range m.relays {
+		st.Relays = append(st.Relays, *rs)
+		if rs.State == RelayUnreachable {
+			down++
+		}
+	}
+	// Ready until every relay failed: a process that can reach none of
+	// them can't do any work.
+	st.Ready = m.ready && (len(m.relays) == 0 || down < len(m.relays))
+	if len(m.details) > 0 {
+		st.Details = make(map[string]any, len(m.details))
+		for k, v := range m.details {
+			st.Details[k] = v
+		}
+	}
+	m.mu.Unlock()
+
+	sort.Slice(st.Relays, func(i, j int) bool { return st.Relays[i].URL < st.Relays[j].URL })
+	if jobs != nil {
+		st.ActiveJobs, st.QueueDepth = jobs()
+	}
+	return st
+}
+
+// Register adds GET /healthz, /readyz and /status to mux.
+func (m *Monitor) Register(mux *http.ServeMux) {
+	mux.HandleFunc("GET /healthz", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("ok\n"))
+	})
+	mux.HandleFunc("GET /readyz", func(w http.ResponseWriter, r *http.Request) {
+		if !m.Status().Ready {
+			http.Error(w, "not ready", http.StatusServiceUnavailable)
+			return
+		}
+		w.Write([]byte("ok\n"))
+	})
+	mux.HandleFunc("GET /status", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(m.Status())
+	})
+}
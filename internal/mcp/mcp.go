@@ -0,0 +1,208 @@
+// Package mcp is a minimal Model Context Protocol server over stdio,
+// exposing tools to AI assistants.
+package mcp
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"io"
+	"sync"
+)
+
+// protocolVersion is the newest MCP revision spoken here; clients asking
+// for an older one get theirs echoed back.
+const protocolVersion = "2025-06-18"
+
+// Tool is one callable tool.
+type Tool struct {
+	Name        string          `json:"name"`
+	Description string          `json:"description"`
+	InputSchema json.RawMessage `json:"inputSchema"`
+	// Call runs the tool with the raw arguments object. The returned text
+	// is shown to the model; structured, when not nil, is sent alongside as
+	// structuredContent.
+	Call func(ctx context.Context, args json.RawMessage) (text string, structured any, err error) `json:"-"`
+}
+
+// Server answers MCP requests for a fixed set of tools.
+type Server struct {
+	Name    string
+	Version string
+	Tools   []Tool
+
+	mu      sync.Mutex
+	out     *json.Encoder
+	cancels map[string]context.CancelFunc
+}
+
+type request struct {
+	JSONRPC string          `json:"jsonrpc"`
+	ID      json.RawMessage `json:"id,omitempty"`
+	Method  string          `json:"method"`
+	Params  json.RawMessage `json:"params,omitempty"`
+}
+
+type response struct {
+	JSONRPC string          `json:"jsonrpc"`
+	ID      json.RawMessage `json:"id"`
+	Result  any             `json:"result,omitempty"`
+	Error   *rpcError       `json:"error,omitempty"`
+}
+
+type rpcError struct {
+	Code    int    `json:"code"`
+	Message string `json:"message"`
+}
+
+// JSON-RPC error codes.
+const (
+	codeParse          = -32700
+	codeMethodNotFound = -32601
+	codeInvalidParams  = -32602
+)
+
+// Serve reads newline delimited requests from r and writes responses to w
+// until r is exhausted or ctx is done. Tool calls run concurrently.
+func (s *Server) Serve(ctx context.Context, r io.Reader, w io.Writer) error {
+	s.out = json.NewEncoder(w)
+	s.cancels = map[string]context.CancelFunc{}
+	var wg sync.WaitGroup
+	defer wg.Wait()
+
+	sc := bufio.NewScanner(r)
+	sc.Buffer(make([]byte, 64*1024), 16<<20)
+	for sc.Scan() {
+		line := sc.Bytes()
+		if len(line) == 0 {
+			continue
+		}
+		var req request
+		if err := json.Unmarshal(line, &req); err != nil {
+			s.send(response{ID: json.RawMessage("null"), Error: &rpcError{codeParse, err.Error()}})
+			continue
+		}
+		if req.Method == "" {
+			continue // a response to a request we never send
+		}
+		if len(req.ID) == 0 {
+			s.notification(req)
+			continue
+		}
+		if req.Method != "tools/call" {
+			s.reply(req.ID, s.handle(req))
+			continue
+		}
+		callCtx, cancel := context.WithCancel(ctx)
+		s.mu.Lock()
+		s.cancels[string(req.ID)] = cancel
+		s.mu.Unlock()
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			defer func() {
+				s.mu.Lock()
+				delete(s.cancels, string(req.ID))
+				s.mu.Unlock()
+				cancel()
+			}()
+			res, err := s.callTool(callCtx, req.Params)
+			if err != nil {
+				s.send(response{ID: req.ID, Error: err})
+				return
+			}
+			s.reply(req.ID, res)
+		}()
+	}
+	return sc.Err()
+}
+
+func (s *Server) handle(req request) any {
+	switch req.Method {
+	case "initialize":
+		var p struct {
+			ProtocolVersion string `json:"protocolVersion"`
+		}
+		json.Unmarshal(req.Params, &p)
+		version := protocolVersion
+		if p.ProtocolVersion != "" && p.ProtocolVersion < protocolVersion {
+			version = p.ProtocolVersion
+		}
+		return map[string]any{
+			"protocolVersion": version,
+			"capabilities":    map[string]any{"tools": map[string]any{}},
+			"serverInfo":      map[string]string{"name": s.Name, "version": s.Version},
+		}
+	case "ping":
+		return map[string]any{}
+	case "tools/list":
+		return map[string]any{"tools": s.Tools}
+	}
+	return &rpcError{codeMethodNotFound, "method not found: " + req.Method}
+}
+
+func (s *Server) notification(req request) {
+	if req.Method != "notifications/cancelled" {
+		return
+	}
+	var p struct {
+		RequestID json.RawMessage `json:"requestId"`
+	}
+	if json.Unmarshal(req.Params, &p) != nil {
+		return
+	}
+	s.mu.Lock()
+	cancel := s.cancels[string(p.RequestID)]
+	s.mu.Unlock()
+	if cancel != nil {
+		cancel()
+	}
+}
+
+func (s *Server) callTool(ctx context.Context, params json.RawMessage) (any, *rpcError) {
+	var p struct {
+		Name      string          `json:"name"`
+		Arguments json.RawMessage `json:"arguments"`
+	}
+	if err := json.Unmarshal(params, &p); err != nil {
+		return nil, &rpcError{codeInvalidParams, err.Error()}
+	}
+	for _, t := range s.Tools {
+		if t.Name != p.Name {
+			continue
+		}
+		if len(p.Arguments) == 0 {
+			p.Arguments = json.RawMessage("{}")
+		}
+		text, structured, err := t.Call(ctx, p.Arguments)
+		if err != nil {
+			// Tool failures are results the model can read and react to,
+			// not protocol errors.
+			return map[string]any{
+				"content": []map[string]string{{"type": "text", "text": err.Error()}},
+				"isError": true,
+			}, nil
+		}
+		res := map[string]any{"content": []map[string]string{{"type": "text", "text": text}}}
+		if structured != nil {
+			res["structuredContent"] = structured
+		}
+		return res, nil
+	}
+	return nil, &rpcError{codeInvalidParams, "unknown tool: " + p.Name}
+}
+
+func (s *Server) reply(id json.RawMessage, result any) {
+	if e, ok := result.(*rpcError); ok {
+		s.send(response{ID: id, Error: e})
+		return
+	}
+	s.send(response{ID: id, Result: result})
+}
+
+func (s *Server) send(resp response) {
+	resp.JSONRPC = "2.0"
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.out.Encode(resp)
+}
@@ -0,0 +1,153 @@
+// Package media uploads files to nostr media servers.
+package media
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/nbd-wtf/go-nostr"
+)
+
+// KindBlossomServers is the BUD-03 user server list.
+const KindBlossomServers = 10063
+
+// Blob describes an uploaded file.
+type Blob struct {
+	URL    string `json:"url"`
+	SHA256 string `json:"sha256"`
+	Size   int    `json:"size"`
+	Type   string `json:"type"`
+}
+
+// Uploader stores a file on a media server.
+type Uploader interface {
+	Upload(ctx context.Context, data []byte, contentType string) (*Blob, error)
+}
+
+// Blossom uploads to a Blossom server (BUD-02) with kind 24242 auth.
+type Blossom struct {
+	Server string
+	Signer nostr.Signer
+	Client *http.Client
+}
+
+func (b *Blossom) Upload(ctx context.Context, data []byte, contentType string) (*Blob, error) {
+	sum := sha256.Sum256(data)
+	hash := hex.EncodeToString(sum[:])
+	auth, err := blossomAuth(ctx, b.Signer, "upload", nostr.Tag{"x", hash})
+	if err != nil {
+		return nil, err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPut, strings.TrimRight(b.Server, "/")+"/upload", bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Authorization", auth)
+	req.Header.Set("Content-Type", contentType)
+	req.Header.Set("X-SHA-256", hash)
+
+	blob := &Blob{}
+	if err := do(b.Client, req, blob); err != nil {
+		return nil, fmt.Errorf("blossom upload to %s: %w", b.Server, err)
+	}
+	if blob.SHA256 != "" && blob.SHA256 != hash {
+		return nil, fmt.Errorf("blossom upload to %s: server stored %s, expected %s", b.Server, blob.SHA256, hash)
+	}
+	return blob, nil
+}
+
+// blossomAuth builds the BUD-01 Authorization header for verb.
+func blossomAuth(ctx context.Context, signer nostr.Signer, verb string, tags ...nostr.Tag) (string, error) {
+	evt := nostr.Event{
+		Kind:      24242,
+		CreatedAt: nostr.Now(),
+		Content:   "nostr-exif-scan " + verb,
+		Tags: append(nostr.Tags{
+			{"t", verb},
+			{"expiration", strconv.FormatInt(time.Now().Add(5*time.Minute).Unix(), 10)},
+		}, tags...),
+	}
+	if err := signer.SignEvent(ctx, &evt); err != nil {
+		return "", fmt.Errorf("signing blossom auth: %w", err)
+	}
+	j, _ := json.Marshal(evt)
+	return "Nostr " + base64.StdEncoding.EncodeToString(j), nil
+}
+
+// BlossomServers returns the servers in pubkey's BUD-03 list, preferred
+// first.
+func BlossomServers(ctx context.Context, pool *nostr.SimplePool, relays []string, pubkey string) []string {
+	return serverList(ctx, pool, relays, pubkey, KindBlossomServers)
+}
+
+func serverList(ctx context.Context, pool *nostr.SimplePool, relays []string, pubkey string, kind int) []string {
+	ctx, cancel := context.WithTimeout(ctx, 10*time.Second)
+	defer cancel()
+	ie := pool.QuerySingle(ctx, relays, nostr.Filter{Kinds: []int{kind}, Authors: []string{pubkey}})
+	if ie == nil {
+		return nil
+	}
+	var out []string
+	for _, tag := range ie.Tags {
+		if len(tag) >= 2 && tag[0] == "server" {
+			out = append(out, strings.TrimRight(tag[1], "/"))
+		}
+	}
+	return out
+}
+
+// BlossomList returns the blobs pubkey stored on a Blossom server (BUD-02
+// /list). signer authenticates the request when set, which servers may
+// require to list private uploads.
+func BlossomList(ctx context.Context, client *http.Client, server, pubkey string, signer nostr.Signer) ([]Blob, error) {
+	server = strings.TrimRight(server, "/")
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, server+"/list/"+pubkey, nil)
+	if err != nil {
+		return nil, err
+	}
+	if signer != nil {
+		auth, err := blossomAuth(ctx, signer, "list")
+		if err != nil {
+			return nil, err
+		}
+		req.Header.Set("Authorization", auth)
+	}
+	var blobs []Blob
+	if err := do(client, req, &blobs); err != nil {
+		return nil, fmt.Errorf("blossom list on %s: %w", server, err)
+	}
+	return blobs, nil
+}
+
+func do(client *http.Client, req *http.Request, out any) error {
+	if client == nil {
+		client = http.DefaultClient
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(io.LimitReader(resp.Body, 16<<20))
+	if err != nil {
+		return err
+	}
+	if resp.StatusCode >= 300 {
+		reason := resp.Header.Get("X-Reason")
+		if reason == "" {
+			reason = strings.TrimSpace(string(body))
+		}
+		return fmt.Errorf("%s: %s", resp.Status, reason)
+	}
+	return json.Unmarshal(body, out)
+}
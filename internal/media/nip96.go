@@ -0,0 +1,274 @@
+package media
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"mime/multipart"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/nbd-wtf/go-nostr"
+)
+
+// KindNIP96Servers is the NIP-96 user server list.
+const KindNIP96Servers = 10096
+
+// NIP96 uploads to a NIP-96 HTTP file storage server, authenticating with
+// NIP-98 HTTP auth events.
+type NIP96 struct {
+	Server string
+	Signer nostr.Signer
+	Client *http.Client
+
+	apiURL string
+}
+
+type nip96Response struct {
+	Status        string `json:"status"`
+	Message       string `json:"message"`
+	ProcessingURL string `json:"processing_url"`
+	NIP94Event    struct {
+		Tags nostr.Tags `json:"tags"`
+	} `json:"nip94_event"`
+}
+
+// APIURL discovers the upload endpoint from the server's
+// /.well-known/nostr/nip96.json, following delegation once.
+func (n *NIP96) APIURL(ctx context.Context) (string, error) {
+	if n.apiURL != "" {
+		return n.apiURL, nil
+	}
+	server := strings.TrimRight(n.Server, "/")
+	for range 2 {
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, server+"/.well-known/nostr/nip96.json", nil)
+		if err != nil {
+			return "", err
+		}
+		var info struct {
+			APIURL      string `json:"api_url"`
+			DelegatedTo string `json:"delegated_to_url"`
+		}
+		if err := do(n.Client, req, &info); err != nil {
+			return "", fmt.Errorf("nip96 discovery on %s: %w", server, err)
+		}
+		if info.APIURL != "" {
+			n.apiURL = info.APIURL
+			return n.apiURL, nil
+		}
+		if info.DelegatedTo == "" {
+			break
+		}
+		server = strings.TrimRight(info.DelegatedTo, "/")
+	}
+	return "", fmt.Errorf("nip96 discovery on %s: no api_url", n.Server)
+}
+
+func (n *NIP96) Upload(ctx context.Context, data []byte, contentType string) (*Blob, error) {
+	api, err := n.APIURL(ctx)
+	if err != nil {
+		return nil, err
+	}
+	var body bytes.Buffer
+	mw := multipart.NewWriter(&body)
+	mw.WriteField("size", strconv.Itoa(len(data)))
+	mw.WriteField("content_type", contentType)
+	fw, err := mw.CreateFormFile("file", "upload"+extension(contentType))
+	if err != nil {
+		return nil, err
+	}
+	fw.Write(data)
+	if err := mw.Close(); err != nil {
+		return nil, err
+	}
+
+	auth, err := HTTPAuth(ctx, n.Signer, api, http.MethodPost, body.Bytes())
+	if err != nil {
+		return nil, err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, api, &body)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Authorization", auth)
+	req.Header.Set("Content-Type", mw.FormDataContentType())
+
+	resp := &nip96Response{}
+	if err := do(n.Client, req, resp); err != nil {
+		return nil, fmt.Errorf("nip96 upload to %s: %w", n.Server, err)
+	}
+	if resp.Status == "processing" && resp.ProcessingURL != "" {
+		if resp, err = n.wait(ctx, resp.ProcessingURL); err != nil {
+			return nil, err
+		}
+	}
+	if resp.Status == "error" {
+		return nil, fmt.Errorf("nip96 upload to %s: %s", n.Server, resp.Message)
+	}
+	blob := &Blob{Size: len(data), Type: contentType}
+	for _, tag := range resp.NIP94Event.Tags {
+		if len(tag) < 2 {
+			continue
+		}
+		switch tag[0] {
+		case "url":
+			blob.URL = tag[1]
+		case "x":
+			blob.SHA256 = tag[1]
+		case "m":
+			blob.Type = tag[1]
+		}
+	}
+	if blob.URL == "" {
+		return nil, fmt.Errorf("nip96 upload to %s: no url in response", n.Server)
+	}
+	return blob, nil
+}
+
+// wait polls a delayed upload until the server finished processing it.
+func (n *NIP96) wait(ctx context.Context, url string) (*nip96Response, error) {
+	ctx, cancel := context.WithTimeout(ctx, 2*time.Minute)
+	defer cancel()
+	for {
+		select {
+		case <-time.After(2 * time.Second):
+		case <-ctx.Done():
+			return nil, fmt.Errorf("nip96 upload to %s: still processing", n.Server)
+		}
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+		if err != nil {
+			return nil, err
+		}
+		resp := &nip96Response{}
+		if err := do(n.Client, req, resp); err != nil {
+			return nil, fmt.Errorf("nip96 upload to %s: %w", n.Server, err)
+		}
+		if resp.Status != "processing" {
+			return resp, nil
+		}
+	}
+}
+
+// List returns every file the signer stored on the server, following the
+// listing API's pages.
+func (n *NIP96) List(ctx context.Context) ([]Blob, error) {
+	api, err := n.APIURL(ctx)
+	if err != nil {
+		return nil, err
+	}
+	const perPage = 100
+	var out []Blob
+	for page := 0; ; page++ {
+		url := fmt.Sprintf("%s?page=%d&count=%d", api, page, perPage)
+		auth, err := HTTPAuth(ctx, n.Signer, url, http.MethodGet, nil)
+		if err != nil {
+			return nil, err
+		}
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+		if err != nil {
+			return nil, err
+		}
+		req.Header.Set("Authorization", auth)
+		var resp struct {
+			Total int `json:"total"`
+			Files []struct {
+				Tags nostr.Tags `json:"tags"`
+			} `json:"files"`
+		}
+		if err := do(n.Client, req, &resp); err != nil {
+			return nil, fmt.Errorf("nip96 list on %s: %w", n.Server, err)
+		}
+		for _, f := range resp.Files {
+			var blob Blob
+			for _, tag := range f.Tags {
+				if len(tag) < 2 {
+					continue
+				}
+				switch tag[0] {
+				case "url":
+					blob.URL = tag[1]
+				case "x":
+					blob.SHA256 = tag[1]
+				case "m":
+					blob.Type = tag[1]
+				case "size":
+					blob.Size, _ = strconv.Atoi(tag[1])
+				}
+			}
+			if blob.URL != "" {
+				out = append(out, blob)
+			}
+		}
+		if len(resp.Files) < perPage || (resp.Total > 0 && (page+1)*perPage >= resp.Total) {
+			return out, nil
+		}
+	}
+}
+
+// HTTPAuth builds a NIP-98 Authorization header for a request to url.
+func HTTPAuth(ctx context.Context, signer nostr.Signer, url, method string, body []byte) (string, error) {
+	evt := nostr.Event{
+		Kind:      27235,
+		CreatedAt: nostr.Now(),
+		Tags:      nostr.Tags{{"u", url}, {"method", method}},
+	}
+	if body != nil {
+		sum := sha256.Sum256(body)
+		evt.Tags = append(evt.Tags, nostr.Tag{"payload", hex.EncodeToString(sum[:])})
+	}
+	if err := signer.SignEvent(ctx, &evt); err != nil {
+		return "", fmt.Errorf("signing http auth: %w", err)
+	}
+	j, _ := json.Marshal(evt)
+	return "Nostr " + base64.StdEncoding.EncodeToString(j), nil
+}
+
+// NIP96Servers returns the servers in pubkey's kind 10096 list.
+func NIP96Servers(ctx context.Context, pool *nostr.SimplePool, relays []string, pubkey string) []string {
+	return serverList(ctx, pool, relays, pubkey, KindNIP96Servers)
+}
+
+// ErrNoServer is returned by Pick when the user has no media server.
+var ErrNoServer = errors.New("no media server: publish a kind 10063 or 10096 server list, or pass --blossom or --nip96")
+
+// Pick returns an uploader for the explicitly given server, or else for the
+// user's first Blossom server, or else their first NIP-96 server.
+func Pick(ctx context.Context, pool *nostr.SimplePool, relays []string, signer nostr.Signer, blossomURL, nip96URL string) (Uploader, string, error) {
+	client := &http.Client{Timeout: time.Minute}
+	switch {
+	case blossomURL != "":
+		return &Blossom{Server: blossomURL, Signer: signer, Client: client}, blossomURL, nil
+	case nip96URL != "":
+		return &NIP96{Server: nip96URL, Signer: signer, Client: client}, nip96URL, nil
+	}
+	pubkey, err := signer.GetPublicKey(ctx)
+	if err != nil {
+		return nil, "", err
+	}
+	if servers := BlossomServers(ctx, pool, relays, pubkey); len(servers) > 0 {
+		return &Blossom{Server: servers[0], Signer: signer, Client: client}, servers[0], nil
+	}
+	if servers := NIP96Servers(ctx, pool, relays, pubkey); len(servers) > 0 {
+		return &NIP96{Server: servers[0], Signer: signer, Client: client}, servers[0], nil
+	}
+	return nil, "", ErrNoServer
+}
+
+func extension(contentType string) string {
+	switch contentType {
+	case "image/jpeg":
+		return ".jpg"
+	case "image/png":
+		return ".png"
+	case "image/webp":
+		return ".webp"
+	}
+	return ""
+}
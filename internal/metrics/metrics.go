@@ -0,0 +1,125 @@
+// Package metrics exports scan activity in the Prometheus format.
+package metrics
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/nbd-wtf/go-nostr"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+
+	"nostr-exif-scan/pkg/exifscan"
+)
+
+// Metrics holds the collectors of one process.
+type Metrics struct {
+	registry *prometheus.Registry
+
+	eventsFetched   prometheus.Counter
+	imagesScanned   *prometheus.CounterVec
+	findings        *prometheus.CounterVec
+	bytesDownloaded prometheus.Counter
+	relayErrors     *prometheus.CounterVec
+	fetchLatency    *prometheus.HistogramVec
+}
+
+func New() *Metrics {
+	m := &Metrics{
+		registry: prometheus.NewRegistry(),
+		eventsFetched: prometheus.NewCounter(prometheus.CounterOpts{
+			Name: "exifscan_events_fetched_total",
+			Help: "Distinct notes received from relays.",
+		}),
+		imagesScanned: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "exifscan_images_scanned_total",
+			Help: "Images scanned, by outcome (clean, metadata, leaking, failed).",
+		}, []string{"outcome"}),
+		findings: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "exifscan_findings_total",
+			Help: "Images with sensitive metadata, by highest severity.",
+		}, []string{"severity"}),
+		bytesDownloaded: prometheus.NewCounter(prometheus.CounterOpts{
+			Name: "exifscan_downloaded_bytes_total",
+			Help: "Image bytes downloaded.",
+		}),
+		relayErrors: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "exifscan_relay_errors_total",
+			Help: "Relay connection failures, by relay.",
+		}, []string{"relay"}),
+		fetchLatency: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Name:    "exifscan_fetch_duration_seconds",
+			Help:    "Image fetch latency, by host.",
+			Buckets: []float64{.05, .1, .25, .5, 1, 2.5, 5, 10},
+		}, []string{"host"}),
+	}
+	m.registry.MustRegister(
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+		m.eventsFetched,
+		m.imagesScanned,
+		m.findings,
+		m.bytesDownloaded,
+		m.relayErrors,
+		m.fetchLatency,
+	)
+	return m
+}
+
+// Handler serves the /metrics endpoint.
+func (m *Metrics) Handler() http.Handler {
+	return promhttp.HandlerFor(m.registry, promhttp.HandlerOpts{})
+}
+
+// Registry lets long-running modes register their own collectors.
+func (m *Metrics) Registry() *prometheus.Registry {
+	return m.registry
+}
+
+// Hooks returns hooks that record metrics and then call next.
+func (m *Metrics) Hooks(next exifscan.Hooks) exifscan.Hooks {
+	return exifscan.Hooks{
+		OnEventFetched: func(evt *nostr.Event) {
+			m.eventsFetched.Inc()
+			if next.OnEventFetched != nil {
+				next.OnEventFetched(evt)
+			}
+		},
+		OnImageScanned: func(r *exifscan.ImageResult) {
+			m.observeImage(r)
+			if next.OnImageScanned != nil {
+				next.OnImageScanned(r)
+			}
+		},
+		OnFinding:        next.OnFinding,
+		OnRelayConnected: next.OnRelayConnected,
+		OnError: func(err error) {
+			var se *exifscan.ScanError
+			if errors.As(err, &se) && se.Stage == exifscan.StageRelay {
+				m.relayErrors.WithLabelValues(se.URL).Inc()
+			}
+			if next.OnError != nil {
+				next.OnError(err)
+			}
+		},
+	}
+}
+
+func (m *Metrics) observeImage(r *exifscan.ImageResult) {
+	outcome := "clean"
+	switch {
+	case r.Err != nil:
+		outcome = "failed"
+	case r.Sensitive():
+		outcome = "leaking"
+		m.findings.WithLabelValues(r.Severity()).Inc()
+	case r.HasMetadata:
+		outcome = "metadata"
+	}
+	m.imagesScanned.WithLabelValues(outcome).Inc()
+	m.bytesDownloaded.Add(float64(r.Size))
+	if r.Duration > 0 {
+		m.fetchLatency.WithLabelValues(r.Host()).Observe(r.Duration.Seconds())
+	}
+}
@@ -0,0 +1,187 @@
+// Package notify delivers findings to chat services.
+package notify
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"html"
+	"io"
+	"net/http"
+	neturl "net/url"
+	"strings"
+	"time"
+
+	"nostr-exif-scan/pkg/exifscan"
+)
+
+// Alert describes one leaking image.
+type Alert struct {
+	// Author is the npub of the note's author.
+	Author   string
+	EventURL string
+	Image    *exifscan.ImageResult
+}
+
+// Notifier delivers alerts to one destination.
+type Notifier interface {
+	Notify(ctx context.Context, a Alert) error
+}
+
+// Config selects the destinations; empty sections are disabled.
+type Config struct {
+	Slack    *WebhookConfig  `json:"slack,omitempty"`
+	Discord  *WebhookConfig  `json:"discord,omitempty"`
+	Telegram *TelegramConfig `json:"telegram,omitempty"`
+}
+
+type WebhookConfig struct {
+	WebhookURL string `json:"webhook_url"`
+}
+
+type TelegramConfig struct {
+	BotToken string `json:"bot_token"`
+	ChatID   string `json:"chat_id"`
+}
+
+// FromConfig builds the notifiers enabled in cfg.
+func FromConfig(cfg Config) []Notifier {
+	client := &http.Client{Timeout: 10 * time.Second}
+	var out []Notifier
+	if cfg.Slack != nil && cfg.Slack.WebhookURL != "" {
+		out = append(out, &Slack{WebhookURL: cfg.Slack.WebhookURL, Client: client})
+	}
+	if cfg.Discord != nil && cfg.Discord.WebhookURL != "" {
+		out = append(out, &Discord{WebhookURL: cfg.Discord.WebhookURL, Client: client})
+	}
+	if cfg.Telegram != nil && cfg.Telegram.BotToken != "" {
+		out = append(out, &Telegram{BotToken: cfg.Telegram.BotToken, ChatID: cfg.Telegram.ChatID, Client: client})
+	}
+	return out
+}
+
+// All sends a to every notifier and joins their errors.
+func All(ctx context.Context, notifiers []Notifier, a Alert) error {
+	var errs []error
+	for _, n := range notifiers {
+		if err := n.Notify(ctx, a); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// Slack posts to an incoming webhook using mrkdwn.
+type Slack struct {
+	WebhookURL string
+	Client     *http.Client
+}
+
+func (s *Slack) Notify(ctx context.Context, a Alert) error {
+	var b strings.Builder
+	fmt.Fprintf(&b, ":rotating_light: *Sensitive EXIF (%s)* in a post by `%s`\n", strings.Join(a.Image.Categories(), ", "), a.Author)
+	if a.Image.Post != nil {
+		fmt.Fprintf(&b, "> %s\n", a.Image.Post)
+	}
+	fmt.Fprintf(&b, "<%s|Open post> · <%s|Image>", a.EventURL, a.Image.URL)
+	if a.Image.GPS != nil {
+		fmt.Fprintf(&b, " · <%s|Location> (%s)", a.Image.GPS.MapsURL(), a.Image.GPS.Accuracy())
+	}
+	for _, t := range a.Image.Tags {
+		if t.Value != "" {
+			fmt.Fprintf(&b, "\n• %s: `%s`", t.Field, t.Value)
+		}
+	}
+	return postJSON(ctx, s.Client, s.WebhookURL, map[string]string{"text": b.String()})
+}
+
+// Discord posts an embed to a channel webhook.
+type Discord struct {
+	WebhookURL string
+	Client     *http.Client
+}
+
+func (d *Discord) Notify(ctx context.Context, a Alert) error {
+	var b strings.Builder
+	fmt.Fprintf(&b, "Author: `%s`\n", a.Author)
+	if a.Image.Post != nil {
+		fmt.Fprintf(&b, "> %s\n", a.Image.Post)
+	}
+	fmt.Fprintf(&b, "[Image](%s)", a.Image.URL)
+	if a.Image.GPS != nil {
+		fmt.Fprintf(&b, " · [Location](%s) (%s)", a.Image.GPS.MapsURL(), a.Image.GPS.Accuracy())
+	}
+	for _, t := range a.Image.Tags {
+		if t.Value != "" {
+			fmt.Fprintf(&b, "\n**%s**: `%s`", t.Field, t.Value)
+		}
+	}
+	return postJSON(ctx, d.Client, d.WebhookURL, map[string]any{
+		"embeds": []map[string]any{{
+			"title":       "🚨 Sensitive EXIF: " + strings.Join(a.Image.Categories(), ", "),
+			"url":         a.EventURL,
+			"description": b.String(),
+			"color":       0xB00020,
+		}},
+	})
+}
+
+// Telegram sends a message through the Bot API.
+type Telegram struct {
+	BotToken string
+	ChatID   string
+	Client   *http.Client
+}
+
+func (t *Telegram) Notify(ctx context.Context, a Alert) error {
+	var b strings.Builder
+	fmt.Fprintf(&b, "🚨 <b>Sensitive EXIF (%s)</b> in a post by <code>%s</code>\n", html.EscapeString(strings.Join(a.Image.Categories(), ", ")), html.EscapeString(a.Author))
+	if a.Image.Post != nil {
+		fmt.Fprintf(&b, "<i>%s</i>\n", html.EscapeString(a.Image.Post.String()))
+	}
+	fmt.Fprintf(&b, `<a href="%s">Open post</a> · <a href="%s">Image</a>`, html.EscapeString(a.EventURL), html.EscapeString(a.Image.URL))
+	if a.Image.GPS != nil {
+		fmt.Fprintf(&b, ` · <a href="%s">Location</a> (%s)`, html.EscapeString(a.Image.GPS.MapsURL()), a.Image.GPS.Accuracy())
+	}
+	for _, tag := range a.Image.Tags {
+		if tag.Value != "" {
+			fmt.Fprintf(&b, "\n• %s: <code>%s</code>", html.EscapeString(tag.Field), html.EscapeString(tag.Value))
+		}
+	}
+	url := "https://api.telegram.org/bot" + t.BotToken + "/sendMessage"
+	return postJSON(ctx, t.Client, url, map[string]any{
+		"chat_id":                  t.ChatID,
+		"text":                     b.String(),
+		"parse_mode":               "HTML",
+		"disable_web_page_preview": true,
+	})
+}
+
+func postJSON(ctx context.Context, client *http.Client, url string, payload any) error {
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := client.Do(req)
+	if err != nil {
+		// Drop the URL, which carries the Telegram bot token.
+		var uerr *neturl.Error
+		if errors.As(err, &uerr) {
+			return fmt.Errorf("notify: %w", uerr.Err)
+		}
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return fmt.Errorf("notify: %s: %s", resp.Status, strings.TrimSpace(string(msg)))
+	}
+	return nil
+}
@@ -0,0 +1,153 @@
+// Package nwc is a minimal Nostr Wallet Connect (NIP-47) client, enough to
+// issue invoices and check whether they were paid.
+package nwc
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/nbd-wtf/go-nostr"
+	"github.com/nbd-wtf/go-nostr/nip04"
+)
+
+const (
+	kindRequest  = 23194
+	kindResponse = 23195
+)
+
+// Client talks to one wallet service over its relay.
+type Client struct {
+	wallet string
+	relay  string
+	secret string
+	shared []byte
+	pool   *nostr.SimplePool
+}
+
+// Invoice is the subset of NIP-47 transaction fields we use.
+type Invoice struct {
+	Invoice     string `json:"invoice"`
+	PaymentHash string `json:"payment_hash"`
+	Amount      int64  `json:"amount"`
+	Preimage    string `json:"preimage,omitempty"`
+	SettledAt   int64  `json:"settled_at,omitempty"`
+}
+
+// Paid reports whether the invoice was settled.
+func (i Invoice) Paid() bool {
+	return i.SettledAt > 0 || i.Preimage != ""
+}
+
+// New parses a nostr+walletconnect:// URI.
+func New(uri string, pool *nostr.SimplePool) (*Client, error) {
+	u, err := url.Parse(uri)
+	if err != nil || u.Scheme != "nostr+walletconnect" {
+		// don't echo the URI, it holds the secret
+		return nil, errors.New("invalid NWC URI: want nostr+walletconnect://<pubkey>?relay=...&secret=...")
+	}
+	c := &Client{
+		wallet: u.Host,
+		relay:  u.Query().Get("relay"),
+		secret: u.Query().Get("secret"),
+		pool:   pool,
+	}
+	if c.wallet == "" {
+		c.wallet = strings.TrimPrefix(u.Opaque, "//")
+	}
+	if !nostr.IsValidPublicKey(c.wallet) || c.relay == "" || c.secret == "" {
+		return nil, errors.New("invalid NWC URI: missing wallet pubkey, relay or secret")
+	}
+	if c.shared, err = nip04.ComputeSharedSecret(c.wallet, c.secret); err != nil {
+		return nil, fmt.Errorf("invalid NWC URI: %w", err)
+	}
+	return c, nil
+}
+
+// MakeInvoice asks the wallet for a bolt11 invoice of msats.
+func (c *Client) MakeInvoice(ctx context.Context, msats int64, description string, expiry time.Duration) (Invoice, error) {
+	var inv Invoice
+	err := c.call(ctx, "make_invoice", map[string]any{
+		"amount":      msats,
+		"description": description,
+		"expiry":      int64(expiry.Seconds()),
+	}, &inv)
+	return inv, err
+}
+
+// LookupInvoice returns the current state of the invoice with paymentHash.
+func (c *Client) LookupInvoice(ctx context.Context, paymentHash string) (Invoice, error) {
+	var inv Invoice
+	err := c.call(ctx, "lookup_invoice", map[string]any{"payment_hash": paymentHash}, &inv)
+	return inv, err
+}
+
+func (c *Client) call(ctx context.Context, method string, params, out any) error {
+	payload, err := json.Marshal(map[string]any{"method": method, "params": params})
+	if err != nil {
+		return err
+	}
+	content, err := nip04.Encrypt(string(payload), c.shared)
+	if err != nil {
+		return err
+	}
+	req := nostr.Event{
+		Kind:      kindRequest,
+		CreatedAt: nostr.Now(),
+		Tags:      nostr.Tags{{"p", c.wallet}},
+		Content:   content,
+	}
+	if err := req.Sign(c.secret); err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, 30*time.Second)
+	defer cancel()
+	relay, err := c.pool.EnsureRelay(c.relay)
+	if err != nil {
+		return fmt.Errorf("nwc relay: %w", err)
+	}
+	sub, err := relay.Subscribe(ctx, nostr.Filters{{
+		Kinds:   []int{kindResponse},
+		Authors: []string{c.wallet},
+		Tags:    nostr.TagMap{"e": {req.ID}},
+	}})
+	if err != nil {
+		return fmt.Errorf("nwc relay: %w", err)
+	}
+	defer sub.Unsub()
+	if err := relay.Publish(ctx, req); err != nil {
+		return fmt.Errorf("nwc %s: %w", method, err)
+	}
+
+	select {
+	case evt, ok := <-sub.Events:
+		if !ok {
+			return fmt.Errorf("nwc %s: subscription closed", method)
+		}
+		plain, err := nip04.Decrypt(evt.Content, c.shared)
+		if err != nil {
+			return fmt.Errorf("nwc %s: %w", method, err)
+		}
+		var resp struct {
+			Error *struct {
+				Code    string `json:"code"`
+				Message string `json:"message"`
+			} `json:"error"`
+			Result json.RawMessage `json:"result"`
+		}
+		if err := json.Unmarshal([]byte(plain), &resp); err != nil {
+			return fmt.Errorf("nwc %s: %w", method, err)
+		}
+		if resp.Error != nil {
+			return fmt.Errorf("nwc %s: %s: %s", method, resp.Error.Code, resp.Error.Message)
+		}
+		return json.Unmarshal(resp.Result, out)
+	case <-ctx.Done():
+		return fmt.Errorf("nwc %s: no response from wallet: %w", method, ctx.Err())
+	}
+}
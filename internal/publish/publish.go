@@ -0,0 +1,198 @@
+// Package publish signs and broadcasts events describing findings.
+package publish
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/nbd-wtf/go-nostr"
+
+	"nostr-exif-scan/pkg/exifscan"
+)
+
+// Namespace is the NIP-32 label namespace used for our labels.
+const Namespace = "exif-scan"
+
+// Publisher signs events with one key and sends them to a set of relays.
+type Publisher struct {
+	kr     nostr.Keyer
+	pool   *nostr.SimplePool
+	relays []string
+}
+
+// New returns a Publisher signing with kr.
+func New(kr nostr.Keyer, pool *nostr.SimplePool, relays []string) *Publisher {
+	return &Publisher{kr: kr, pool: pool, relays: relays}
+}
+
+// Publish signs evt and sends it to every relay. It succeeds when at least
+// one relay accepted the event.
+func (p *Publisher) Publish(ctx context.Context, evt *nostr.Event) error {
+	if evt.CreatedAt == 0 {
+		evt.CreatedAt = nostr.Now()
+	}
+	if err := p.kr.SignEvent(ctx, evt); err != nil {
+		return fmt.Errorf("signing: %w", err)
+	}
+	ctx, cancel := context.WithTimeout(ctx, 15*time.Second)
+	defer cancel()
+	var errs []error
+	accepted := 0
+	for res := range p.pool.PublishMany(ctx, p.relays, *evt) {
+		if res.Error != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", res.RelayURL, res.Error))
+			continue
+		}
+		accepted++
+	}
+	if accepted == 0 {
+		return errors.Join(append([]error{errors.New("no relay accepted the event")}, errs...)...)
+	}
+	return nil
+}
+
+// Existing returns the ids among eventIDs that we already published an
+// event of the given kind about, so reruns don't repeat themselves.
+func (p *Publisher) Existing(ctx context.Context, kind int, eventIDs []string) map[string]bool {
+	seen := map[string]bool{}
+	ours, err := p.kr.GetPublicKey(ctx)
+	if err != nil || len(eventIDs) == 0 {
+		return seen
+	}
+	ctx, cancel := context.WithTimeout(ctx, 15*time.Second)
+	defer cancel()
+	filter := nostr.Filter{Kinds: []int{kind}, Authors: []string{ours}, Tags: nostr.TagMap{"e": eventIDs}}
+	for ie := range p.pool.FetchMany(ctx, p.relays, filter) {
+		for _, tag := range ie.Tags {
+			if len(tag) >= 2 && tag[0] == "e" {
+				seen[tag[1]] = true
+			}
+		}
+	}
+	return seen
+}
+
+// ByEvent groups the sensitive results by the note they were found in,
+// keeping the order of first appearance. Files no note links to are left
+// out.
+func ByEvent(images []*exifscan.ImageResult) [][]*exifscan.ImageResult {
+	var out [][]*exifscan.ImageResult
+	idx := map[string]int{}
+	for _, r := range images {
+		if !r.Sensitive() || r.EventID == "" {
+			continue
+		}
+		i, ok := idx[r.EventID]
+		if !ok {
+			i = len(out)
+			idx[r.EventID] = i
+			out = append(out, nil)
+		}
+		out[i] = append(out[i], r)
+	}
+	return out
+}
+
+// Report builds a NIP-56 report flagging the note the images were found in
+// for leaking private data. NIP-56 has no privacy type, so it is reported as
+// "other" with the reason in the content and a label.
+func Report(images []*exifscan.ImageResult) *nostr.Event {
+	r := images[0]
+	tags := nostr.Tags{{"e", r.EventID, "other"}}
+	if r.Event != nil {
+		tags = append(tags, nostr.Tag{"p", r.Event.PubKey, "other"})
+	}
+	tags = append(tags, nostr.Tag{"L", Namespace}, nostr.Tag{"l", "privacy", Namespace})
+	return &nostr.Event{
+		Kind:    nostr.KindReporting,
+		Tags:    tags,
+		Content: fmt.Sprintf("privacy: images in this note leak %s metadata", strings.Join(categories(images), ", ")),
+	}
+}
+
+// Label builds a NIP-32 label event tagging the note the images were found
+// in with one "<category>-leak" label per leaked category, e.g. gps-leak.
+func Label(images []*exifscan.ImageResult) *nostr.Event {
+	r := images[0]
+	tags := nostr.Tags{{"L", Namespace}}
+	for _, c := range categories(images) {
+		tags = append(tags, nostr.Tag{"l", c + "-leak", Namespace})
+	}
+	tags = append(tags, nostr.Tag{"e", r.EventID})
+	if r.Event != nil {
+		tags = append(tags, nostr.Tag{"p", r.Event.PubKey})
+	}
+	return &nostr.Event{Kind: nostr.KindLabel, Tags: tags}
+}
+
+// Deletion builds a NIP-09 deletion request for the given kind 1 notes.
+func Deletion(eventIDs []string, reason string) *nostr.Event {
+	tags := make(nostr.Tags, 0, len(eventIDs)+1)
+	for _, id := range eventIDs {
+		tags = append(tags, nostr.Tag{"e", id})
+	}
+	tags = append(tags, nostr.Tag{"k", "1"})
+	return &nostr.Event{Kind: nostr.KindDeletion, Tags: tags, Content: reason}
+}
+
+// OutboxRelays adds the write relays pubkey advertises in its NIP-65
+// relay list to relays.
+func OutboxRelays(ctx context.Context, pool *nostr.SimplePool, relays []string, pubkey string) []string {
+	out := append([]string(nil), relays...)
+	ctx, cancel := context.WithTimeout(ctx, 10*time.Second)
+	defer cancel()
+	ie := pool.QuerySingle(ctx, relays, nostr.Filter{Kinds: []int{nostr.KindRelayListMetadata}, Authors: []string{pubkey}})
+	if ie == nil {
+		return out
+	}
+	seen := map[string]bool{}
+	for _, r := range out {
+		seen[nostr.NormalizeURL(r)] = true
+	}
+	for _, tag := range ie.Tags {
+		if len(tag) < 2 || tag[0] != "r" || (len(tag) > 2 && tag[2] == "read") {
+			continue
+		}
+		if u := nostr.NormalizeURL(tag[1]); u != "" && !seen[u] {
+			seen[u] = true
+			out = append(out, u)
+		}
+	}
+	return out
+}
+
+// Follows returns the pubkeys in pubkey's NIP-02 follow list.
+func Follows(ctx context.Context, pool *nostr.SimplePool, relays []string, pubkey string) ([]string, error) {
+	ctx, cancel := context.WithTimeout(ctx, 15*time.Second)
+	defer cancel()
+	ie := pool.QuerySingle(ctx, relays, nostr.Filter{Kinds: []int{nostr.KindFollowList}, Authors: []string{pubkey}})
+	if ie == nil {
+		return nil, errors.New("no follow list found")
+	}
+	var out []string
+	seen := map[string]bool{}
+	for _, tag := range ie.Tags {
+		if len(tag) >= 2 && tag[0] == "p" && nostr.IsValid32ByteHex(tag[1]) && !seen[tag[1]] {
+			seen[tag[1]] = true
+			out = append(out, tag[1])
+		}
+	}
+	return out, nil
+}
+
+func categories(images []*exifscan.ImageResult) []string {
+	var out []string
+	seen := map[string]bool{}
+	for _, r := range images {
+		for _, c := range r.Categories() {
+			if !seen[c] {
+				seen[c] = true
+				out = append(out, c)
+			}
+		}
+	}
+	return out
+}
@@ -0,0 +1,101 @@
+package publish
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/nbd-wtf/go-nostr"
+
+	"nostr-exif-scan/pkg/exifscan"
+)
+
+// KindResult is the addressable kind scan results are published as, one
+// per scanned account: the d tag is its pubkey.
+const KindResult = 30985
+
+// maxResultPosts caps the leaking notes a result lists, so it stays well
+// under the 64 KiB NIP-44 encrypts.
+const maxResultPosts = 500
+
+// Result is the content of a result event before encryption.
+type Result struct {
+	Pubkey    string `json:"pubkey"`
+	ScannedAt int64  `json:"scanned_at"`
+	Events    int    `json:"events"`
+	Images    int    `json:"images"`
+	Leaking   int    `json:"leaking"`
+	Failed    int    `json:"failed"`
+	// Categories counts the leaking images per category; Profile lists
+	// the categories found in the profile text.
+	Categories map[string]int `json:"categories,omitempty"`
+	Profile    []string       `json:"profile,omitempty"`
+	// Posts are the leaking notes, at most maxResultPosts of them;
+	// Truncated tells when there were more.
+	Posts     []ResultPost `json:"posts,omitempty"`
+	Truncated bool         `json:"truncated,omitempty"`
+}
+
+// ResultPost is a leaking note of a Result.
+type ResultPost struct {
+	ID         string   `json:"id"`
+	Categories []string `json:"categories"`
+}
+
+// Summarize builds the Result of a scan finished at.
+func Summarize(f *exifscan.Findings, at time.Time) Result {
+	res := Result{
+		Pubkey:     f.Pubkey,
+		ScannedAt:  at.Unix(),
+		Events:     f.Events,
+		Images:     len(f.Images),
+		Leaking:    len(f.Flagged()),
+		Failed:     len(f.Failed()),
+		Categories: map[string]int{},
+	}
+	for _, r := range f.Flagged() {
+		for _, c := range r.Categories() {
+			res.Categories[c]++
+		}
+	}
+	seen := map[string]bool{}
+	for _, t := range f.Profile {
+		if !seen[t.Category] {
+			seen[t.Category] = true
+			res.Profile = append(res.Profile, t.Category)
+		}
+	}
+	for _, g := range ByEvent(f.Images) {
+		if len(res.Posts) == maxResultPosts {
+			res.Truncated = true
+			break
+		}
+		res.Posts = append(res.Posts, ResultPost{ID: g[0].EventID, Categories: categories(g)})
+	}
+	return res
+}
+
+// PublishResult publishes res as an event of kind, replacing the previous
+// result for the same account. The content is NIP-44 encrypted for the
+// scanned account, so only it and the publisher can read it.
+func (p *Publisher) PublishResult(ctx context.Context, kind int, res Result) error {
+	plain, err := json.Marshal(res)
+	if err != nil {
+		return err
+	}
+	content, err := p.kr.Encrypt(ctx, string(plain), res.Pubkey)
+	if err != nil {
+		return fmt.Errorf("encrypting: %w", err)
+	}
+	return p.Publish(ctx, &nostr.Event{
+		Kind:      kind,
+		CreatedAt: nostr.Timestamp(res.ScannedAt),
+		Tags: nostr.Tags{
+			{"d", res.Pubkey},
+			{"p", res.Pubkey},
+			{"alt", "Encrypted EXIF scan result"},
+		},
+		Content: content,
+	})
+}
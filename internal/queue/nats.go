@@ -0,0 +1,138 @@
+package queue
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/nats-io/nats.go"
+
+	"nostr-exif-scan/pkg/exifscan"
+)
+
+const workerGroup = "exifscan-workers"
+
+// natsBackend publishes tasks on a subject that workers share through a
+// queue group and results on a subject per job. Core NATS keeps nothing:
+// tasks published while no worker is subscribed are lost, which the
+// submitter notices as missing results.
+type natsBackend struct {
+	conn *nats.Conn
+
+	mu  sync.Mutex
+	sub *nats.Subscription
+}
+
+func openNATS(rawURL string) (*natsBackend, error) {
+	conn, err := nats.Connect(rawURL, nats.Name("nostr-exif-scan"), nats.MaxReconnects(-1))
+	if err != nil {
+		return nil, err
+	}
+	return &natsBackend{conn: conn}, nil
+}
+
+// subject turns a Redis style key into a NATS subject.
+func subject(key string) string {
+	return strings.ReplaceAll(key, ":", ".")
+}
+
+func (b *natsBackend) Push(ctx context.Context, tasks []Task) error {
+	for _, t := range tasks {
+		data, err := json.Marshal(t)
+		if err != nil {
+			return err
+		}
+		if err := b.conn.Publish(subject(tasksKey), data); err != nil {
+			return err
+		}
+	}
+	return b.flush(ctx)
+}
+
+// flush waits until the server processed everything sent so far.
+func (b *natsBackend) flush(ctx context.Context) error {
+	ctx, cancel := context.WithTimeout(ctx, 10*time.Second)
+	defer cancel()
+	return b.conn.FlushWithContext(ctx)
+}
+
+func (b *natsBackend) Pop(ctx context.Context) (Task, error) {
+	b.mu.Lock()
+	if b.sub == nil {
+		sub, err := b.conn.QueueSubscribeSync(subject(tasksKey), workerGroup)
+		if err != nil {
+			b.mu.Unlock()
+			return Task{}, err
+		}
+		// Hold queued tasks for the local workers instead of dropping
+		// them on slow consumers.
+		sub.SetPendingLimits(-1, -1)
+		b.sub = sub
+	}
+	sub := b.sub
+	b.mu.Unlock()
+
+	msg, err := sub.NextMsgWithContext(ctx)
+	if errors.Is(err, nats.ErrConnectionClosed) || errors.Is(err, nats.ErrBadSubscription) {
+		return Task{}, ErrClosed
+	}
+	if err != nil {
+		return Task{}, err
+	}
+	var t Task
+	if err := json.Unmarshal(msg.Data, &t); err != nil {
+		return Task{}, err
+	}
+	return t, nil
+}
+
+func (b *natsBackend) Reply(ctx context.Context, job string, r *exifscan.ImageResult) error {
+	data, err := json.Marshal(r)
+	if err != nil {
+		return err
+	}
+	return b.conn.Publish(subject(resultsPrefix+job), data)
+}
+
+func (b *natsBackend) Results(ctx context.Context, job string) (<-chan *exifscan.ImageResult, error) {
+	msgs := make(chan *nats.Msg, 256)
+	sub, err := b.conn.ChanSubscribe(subject(resultsPrefix+job), msgs)
+	if err != nil {
+		return nil, err
+	}
+	// The subscription must reach the server before tasks are pushed.
+	if err := b.flush(ctx); err != nil {
+		sub.Unsubscribe()
+		return nil, err
+	}
+	out := make(chan *exifscan.ImageResult)
+	go func() {
+		defer close(out)
+		defer sub.Unsubscribe()
+		for {
+			select {
+			case msg := <-msgs:
+				r := &exifscan.ImageResult{}
+				if json.Unmarshal(msg.Data, r) != nil {
+					continue
+				}
+				select {
+				case out <- r:
+				case <-ctx.Done():
+					return
+				}
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+	return out, nil
+}
+
+func (b *natsBackend) Close() error {
+	b.conn.Close()
+	return nil
+}
@@ -0,0 +1,62 @@
+// Package queue hands image scans to worker processes through a shared
+// Redis or NATS backend, so downloads scale out across machines.
+package queue
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/url"
+
+	"nostr-exif-scan/pkg/exifscan"
+)
+
+// Task is one image to scan on behalf of a job.
+type Task struct {
+	Job     string `json:"job"`
+	EventID string `json:"event_id,omitempty"`
+	URL     string `json:"url"`
+	Source  string `json:"source,omitempty"`
+}
+
+// Image returns the image the task is about.
+func (t Task) Image() exifscan.Image {
+	return exifscan.Image{EventID: t.EventID, URL: t.URL, Source: t.Source}
+}
+
+// Backend is a task queue shared by any number of submitters and workers.
+type Backend interface {
+	// Push queues tasks for the next free worker.
+	Push(ctx context.Context, tasks []Task) error
+	// Pop blocks until a task is available or ctx is done.
+	Pop(ctx context.Context) (Task, error)
+	// Reply sends the result of a task back to the job's submitter.
+	Reply(ctx context.Context, job string, r *exifscan.ImageResult) error
+	// Results streams the results for job until ctx is done. Call it
+	// before pushing the job's tasks so no result is missed.
+	Results(ctx context.Context, job string) (<-chan *exifscan.ImageResult, error)
+	Close() error
+}
+
+// ErrClosed is returned by Pop once the backend is closed.
+var ErrClosed = errors.New("queue: closed")
+
+// Open connects to the backend at rawURL: redis://, rediss:// or nats://.
+func Open(rawURL string) (Backend, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return nil, err
+	}
+	switch u.Scheme {
+	case "redis", "rediss":
+		return openRedis(rawURL)
+	case "nats", "tls":
+		return openNATS(rawURL)
+	}
+	return nil, fmt.Errorf("queue: unsupported backend %q (want redis:// or nats://)", u.Scheme)
+}
+
+const (
+	tasksKey      = "exifscan:tasks"
+	resultsPrefix = "exifscan:results:"
+)
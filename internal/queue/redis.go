@@ -0,0 +1,120 @@
+package queue
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+
+	"nostr-exif-scan/pkg/exifscan"
+)
+
+// resultTTL bounds how long unread results stay in Redis, e.g. after the
+// submitter crashed.
+const resultTTL = time.Hour
+
+// redisBackend keeps tasks in one list that workers pop from and each job's
+// results in a list of its own.
+type redisBackend struct {
+	client *redis.Client
+}
+
+func openRedis(rawURL string) (*redisBackend, error) {
+	opts, err := redis.ParseURL(rawURL)
+	if err != nil {
+		return nil, err
+	}
+	client := redis.NewClient(opts)
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	if err := client.Ping(ctx).Err(); err != nil {
+		client.Close()
+		return nil, err
+	}
+	return &redisBackend{client: client}, nil
+}
+
+func (b *redisBackend) Push(ctx context.Context, tasks []Task) error {
+	values := make([]any, len(tasks))
+	for i, t := range tasks {
+		data, err := json.Marshal(t)
+		if err != nil {
+			return err
+		}
+		values[i] = data
+	}
+	return b.client.LPush(ctx, tasksKey, values...).Err()
+}
+
+func (b *redisBackend) Pop(ctx context.Context) (Task, error) {
+	for {
+		// A bounded wait lets ctx cancel the pop.
+		res, err := b.client.BRPop(ctx, 5*time.Second, tasksKey).Result()
+		switch {
+		case errors.Is(err, redis.Nil):
+			continue
+		case errors.Is(err, redis.ErrClosed):
+			return Task{}, ErrClosed
+		case err != nil:
+			if ctx.Err() != nil {
+				return Task{}, ctx.Err()
+			}
+			return Task{}, err
+		}
+		var t Task
+		if err := json.Unmarshal([]byte(res[1]), &t); err != nil {
+			return Task{}, err
+		}
+		return t, nil
+	}
+}
+
+func (b *redisBackend) Reply(ctx context.Context, job string, r *exifscan.ImageResult) error {
+	data, err := json.Marshal(r)
+	if err != nil {
+		return err
+	}
+	key := resultsPrefix + job
+	_, err = b.client.TxPipelined(ctx, func(p redis.Pipeliner) error {
+		p.RPush(ctx, key, data)
+		p.Expire(ctx, key, resultTTL)
+		return nil
+	})
+	return err
+}
+
+func (b *redisBackend) Results(ctx context.Context, job string) (<-chan *exifscan.ImageResult, error) {
+	out := make(chan *exifscan.ImageResult)
+	key := resultsPrefix + job
+	go func() {
+		defer close(out)
+		defer b.client.Del(context.Background(), key)
+		for ctx.Err() == nil {
+			res, err := b.client.BLPop(ctx, 5*time.Second, key).Result()
+			if errors.Is(err, redis.Nil) {
+				continue
+			}
+			if err != nil {
+				if ctx.Err() == nil {
+					time.Sleep(time.Second)
+				}
+				continue
+			}
+			r := &exifscan.ImageResult{}
+			if json.Unmarshal([]byte(res[1]), r) != nil {
+				continue
+			}
+			select {
+			case out <- r:
+			case <-ctx.Done():
+			}
+		}
+	}()
+	return out, nil
+}
+
+func (b *redisBackend) Close() error {
+	return b.client.Close()
+}
@@ -0,0 +1,293 @@
+package report
+
+import (
+	"fmt"
+	"html/template"
+	"io"
+	"math"
+	"path/filepath"
+	"sort"
+	"strings"
+	texttemplate "text/template"
+	"time"
+
+	"nostr-exif-scan/pkg/exifscan"
+)
+
+// severityWeight is how much one leaking image of a severity costs an
+// account's score.
+var severityWeight = map[string]float64{
+	exifscan.SeverityHigh:   1,
+	exifscan.SeverityMedium: 0.5,
+	exifscan.SeverityLow:    0.2,
+}
+
+// Score rates an account from 0 (every image leaks high severity data) to
+// 100 (no image leaks), weighting each leaking image by its severity.
+func Score(f *exifscan.Findings) int {
+	scanned, cost := 0, 0.0
+	for _, r := range f.Images {
+		if r.Error != "" {
+			continue
+		}
+		scanned++
+		cost += severityWeight[r.Severity()]
+	}
+	if scanned == 0 {
+		return 100
+	}
+	return max(0, 100-int(math.Round(100*cost/float64(scanned))))
+}
+
+// Account is one scanned account of a comparison. Err is set when it
+// could not be scanned.
+type Account struct {
+	Npub     string
+	Findings *exifscan.Findings
+	Err      string
+}
+
+// Row is an account's line in a comparison.
+type Row struct {
+	Npub                        string
+	Posts, Images, Leaking, GPS int
+	High                        int
+	// Scanned counts the images that could be downloaded and read.
+	Scanned int
+	Score   int
+	Err     string
+	// Worst marks the accounts to look at first.
+	Worst bool
+}
+
+// worstCount is how many of the lowest scoring leaking accounts are
+// highlighted.
+const worstCount = 3
+
+// Compare turns scanned accounts into rows, lowest score first.
+func Compare(accounts []Account) []Row {
+	rows := make([]Row, 0, len(accounts))
+	for _, a := range accounts {
+		row := Row{Npub: a.Npub, Err: a.Err, Score: -1}
+		if f := a.Findings; f != nil {
+			row.Posts, row.Images, row.Score = f.Events, len(f.Images), Score(f)
+			row.Scanned = row.Images - len(f.Failed())
+			for _, r := range f.Flagged() {
+				row.Leaking++
+				if r.GPS != nil {
+					row.GPS++
+				}
+				if r.Severity() == exifscan.SeverityHigh {
+					row.High++
+				}
+			}
+		}
+		rows = append(rows, row)
+	}
+	// Failed scans (score -1) go last.
+	sort.SliceStable(rows, func(i, k int) bool {
+		si, sk := rows[i].Score, rows[k].Score
+		if (si < 0) != (sk < 0) {
+			return sk < 0
+		}
+		if si != sk {
+			return si < sk
+		}
+		return rows[i].Leaking > rows[k].Leaking
+	})
+	for i := 0; i < len(rows) && i < worstCount && rows[i].Leaking > 0; i++ {
+		rows[i].Worst = true
+	}
+	return rows
+}
+
+// HitRate is the share of the scanned images that leak.
+func (r Row) HitRate() float64 {
+	if r.Scanned == 0 {
+		return 0
+	}
+	return float64(r.Leaking) / float64(r.Scanned)
+}
+
+// Percent renders HitRate, e.g. "42%".
+func (r Row) Percent() string {
+	return fmt.Sprintf("%.0f%%", 100*r.HitRate())
+}
+
+// minRateImages is how many images an account needs to be ranked by hit
+// rate, so one leaking photo doesn't top the board at 100%.
+const minRateImages = 5
+
+// Leaderboard returns the top n accounts by images with GPS positions and
+// by hit rate, leaving out accounts without any.
+func Leaderboard(rows []Row, n int) (byGPS, byRate []Row) {
+	if n <= 0 {
+		return nil, nil
+	}
+	for _, r := range rows {
+		if r.Err != "" {
+			continue
+		}
+		if r.GPS > 0 {
+			byGPS = append(byGPS, r)
+		}
+		if r.Leaking > 0 && r.Scanned >= minRateImages {
+			byRate = append(byRate, r)
+		}
+	}
+	sort.SliceStable(byGPS, func(i, k int) bool {
+		if byGPS[i].GPS != byGPS[k].GPS {
+			return byGPS[i].GPS > byGPS[k].GPS
+		}
+		return byGPS[i].HitRate() > byGPS[k].HitRate()
+	})
+	sort.SliceStable(byRate, func(i, k int) bool {
+		if a, b := byRate[i].HitRate(), byRate[k].HitRate(); a != b {
+			return a > b
+		}
+		return byRate[i].Leaking > byRate[k].Leaking
+	})
+	return byGPS[:min(n, len(byGPS))], byRate[:min(n, len(byRate))]
+}
+
+// Comparison is everything a comparison report describes.
+type Comparison struct {
+	Since       time.Time
+	Until       time.Time
+	GeneratedAt time.Time
+	Rows        []Row
+	// ByGPS and ByRate are the leaderboards, see Leaderboard.
+	ByGPS, ByRate []Row
+	// Linked are the photos found under more than one account.
+	Linked []LinkedPhoto
+}
+
+// LinkedPhoto is a photo posted under two accounts, which ties them
+// together however unrelated they look: the image at URL, posted by Npub,
+// is a copy of the one at OtherURL.
+type LinkedPhoto struct {
+	Npub         string `json:"npub"`
+	EventID      string `json:"event_id,omitempty"`
+	URL          string `json:"url"`
+	OtherNpub    string `json:"other_npub"`
+	OtherEventID string `json:"other_event_id,omitempty"`
+	OtherURL     string `json:"other_url"`
+	// Distance is how many bits the perceptual hashes differ in; 0 is
+	// the same picture, a few bits a resized or recompressed copy.
+	Distance int `json:"distance"`
+}
+
+var (
+	compareHTMLTmpl     = template.Must(template.New("compare").Funcs(funcs).Parse(compareHTMLSource))
+	compareMarkdownTmpl = texttemplate.Must(texttemplate.New("compare").Funcs(texttemplate.FuncMap(funcs)).Parse(compareMarkdownSource))
+)
+
+// WriteComparison picks the format from the extension of path like Write.
+func WriteComparison(w io.Writer, path string, c Comparison) error {
+	if c.GeneratedAt.IsZero() {
+		c.GeneratedAt = time.Now()
+	}
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".md", ".markdown":
+		return compareMarkdownTmpl.Execute(w, c)
+	}
+	return compareHTMLTmpl.Execute(w, c)
+}
+
+const compareHTMLSource = `<!DOCTYPE html>
+<html lang="en">
+<head>
+<meta charset="utf-8">
+<title>EXIF scan comparison</title>
+<style>
+body { font-family: system-ui, sans-serif; margin: 2rem; color: #222; }
+table { border-collapse: collapse; width: 100%; }
+th, td { border-bottom: 1px solid #ddd; padding: .4rem; text-align: left; }
+td.n { text-align: right; }
+tr.worst { background: #fde7ea; }
+tr.worst td:first-child::before { content: "🚨 "; }
+</style>
+</head>
+<body>
+<h1>🛡️ EXIF scan comparison</h1>
+<p>{{len .Rows}} accounts · Range: {{date .Since}} → {{date .Until}}<br>
+Generated: {{date .GeneratedAt}}</p>
+<table>
+<tr><th>Account</th><th>Score</th><th>Posts</th><th>Images</th><th>Leaking</th><th>High severity</th><th>With GPS</th></tr>
+{{- range .Rows}}
+<tr{{if .Worst}} class="worst"{{end}}>
+<td><code>{{.Npub}}</code></td>
+{{- if .Err}}
+<td colspan="6">❌ {{.Err}}</td>
+{{- else}}
+<td class="n">{{.Score}}</td><td class="n">{{.Posts}}</td><td class="n">{{.Images}}</td><td class="n">{{.Leaking}}</td><td class="n">{{.High}}</td><td class="n">{{.GPS}}</td>
+{{- end}}
+</tr>
+{{- end}}
+</table>
+{{- with .ByGPS}}
+<h2>Most images with GPS positions</h2>
+<ol>
+{{- range .}}
+<li><code>{{.Npub}}</code>: {{.GPS}} image{{if gt .GPS 1}}s{{end}} with GPS, {{.Leaking}} of {{.Scanned}} leaking ({{.Percent}})</li>
+{{- end}}
+</ol>
+{{- end}}
+{{- with .ByRate}}
+<h2>Highest share of leaking images</h2>
+<ol>
+{{- range .}}
+<li><code>{{.Npub}}</code>: {{.Percent}}, {{.Leaking}} of {{.Scanned}} images{{if .GPS}}, {{.GPS}} with GPS{{end}}</li>
+{{- end}}
+</ol>
+{{- end}}
+{{- with .Linked}}
+<h2>Accounts linked by the same photo</h2>
+<p>The same photo was posted under these accounts, which ties them together even when they are pseudonymous.</p>
+<ul>
+{{- range .}}
+<li><code>{{.Npub}}</code> and <code>{{.OtherNpub}}</code>: <a href="{{.URL}}">{{.URL}}</a> is a copy of <a href="{{.OtherURL}}">{{.OtherURL}}</a> (hash distance {{.Distance}})</li>
+{{- end}}
+</ul>
+{{- end}}
+<p><small>Score: 100 means no image leaks; each leaking image costs its share of the account's images, weighted 1 for high, ½ for medium and ⅕ for low severity leaks.</small></p>
+</body>
+</html>
+`
+
+const compareMarkdownSource = `# 🛡️ EXIF scan comparison
+
+- {{len .Rows}} accounts · Range: {{date .Since}} → {{date .Until}}
+- Generated: {{date .GeneratedAt}}
+
+| Account | Score | Posts | Images | Leaking | High severity | With GPS |
+| ------- | ----: | ----: | -----: | ------: | ------------: | -------: |
+{{- range .Rows}}
+| {{if .Worst}}🚨 {{end}}` + "`{{.Npub}}`" + ` | {{if .Err}}❌ {{cell .Err}} | | | | | |{{else}}{{.Score}} | {{.Posts}} | {{.Images}} | {{.Leaking}} | {{.High}} | {{.GPS}} |{{end}}
+{{- end}}
+{{- with .ByGPS}}
+
+## Most images with GPS positions
+{{range $i, $r := .}}
+{{add $i 1}}. ` + "`{{$r.Npub}}`" + `: {{$r.GPS}} image{{if gt $r.GPS 1}}s{{end}} with GPS, {{$r.Leaking}} of {{$r.Scanned}} leaking ({{$r.Percent}})
+{{- end}}
+{{- end}}
+{{- with .ByRate}}
+
+## Highest share of leaking images
+{{range $i, $r := .}}
+{{add $i 1}}. ` + "`{{$r.Npub}}`" + `: {{$r.Percent}}, {{$r.Leaking}} of {{$r.Scanned}} images{{if $r.GPS}}, {{$r.GPS}} with GPS{{end}}
+{{- end}}
+{{- end}}
+{{- with .Linked}}
+
+## Accounts linked by the same photo
+
+The same photo was posted under these accounts, which ties them together even when they are pseudonymous.
+{{range .}}
+- ` + "`{{.Npub}}`" + ` and ` + "`{{.OtherNpub}}`" + `: {{.URL}} is a copy of {{.OtherURL}} (hash distance {{.Distance}})
+{{- end}}
+{{- end}}
+
+Score: 100 means no image leaks; each leaking image costs its share of the account's images, weighted 1 for high, ½ for medium and ⅕ for low severity leaks.
+`
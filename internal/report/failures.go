@@ -0,0 +1,14 @@
+package report
+
+import "fmt"
+
+// Retries says how often a failed download was tried again.
+func Retries(n int) string {
+	switch n {
+	case 0:
+		return "no retries"
+	case 1:
+		return "1 retry"
+	}
+	return fmt.Sprintf("%d retries", n)
+}
@@ -0,0 +1,49 @@
+package report
+
+import (
+	"net/url"
+	"strings"
+
+	"nostr-exif-scan/pkg/exifscan"
+)
+
+// SearchLink is a reverse image search for a leaking photo, to find where
+// else it has spread.
+type SearchLink struct {
+	Engine string
+	URL    string
+}
+
+// ReverseSearchEngines are the searches Data.ReverseSearch links, by
+// name, with {url} standing for the escaped image URL.
+var ReverseSearchEngines = []struct{ Name, Template string }{
+	{"Google Lens", "https://lens.google.com/uploadbyurl?url={url}"},
+	{"Bing", "https://www.bing.com/images/search?view=detailv2&iss=sbi&q=imgurl:{url}"},
+	{"Yandex", "https://yandex.com/images/search?rpt=imageview&url={url}"},
+}
+
+// ReverseSearch links the searches for r's image: its original when that
+// is what leaks, the archived copy of a dead link, or else its URL.
+func ReverseSearch(r *exifscan.ImageResult) []SearchLink {
+	u := r.URL
+	switch {
+	case r.OriginLeaks, r.ProxyError != "":
+		u = r.Origin
+	case r.Archived != "":
+		u = r.Archived
+	}
+	out := make([]SearchLink, 0, len(ReverseSearchEngines))
+	for _, e := range ReverseSearchEngines {
+		out = append(out, SearchLink{Engine: e.Name, URL: strings.ReplaceAll(e.Template, "{url}", url.QueryEscape(u))})
+	}
+	return out
+}
+
+// pivots is ReverseSearch for the high severity leaks of a report asking
+// for them, and nil otherwise.
+func pivots(d Data, r *exifscan.ImageResult) []SearchLink {
+	if !d.ReverseSearch || r.Severity() != exifscan.SeverityHigh {
+		return nil
+	}
+	return ReverseSearch(r)
+}
@@ -0,0 +1,472 @@
+// Package report renders scan findings for humans.
+package report
+
+import (
+	"encoding/json"
+	"fmt"
+	"html/template"
+	"io"
+	"path/filepath"
+	"strings"
+	texttemplate "text/template"
+	"time"
+
+	"github.com/nbd-wtf/go-nostr/nip19"
+
+	"nostr-exif-scan/pkg/exifscan"
+)
+
+// Data is everything a report describes.
+type Data struct {
+	Npub        string
+	Since       time.Time
+	Until       time.Time
+	GeneratedAt time.Time
+	Findings    *exifscan.Findings
+	// Inventory lists every scanned image with its verdict, not only the
+	// leaking ones.
+	Inventory bool
+	// Sample extrapolates the leak rate when only a random sample of the
+	// images was scanned.
+	Sample *Extrapolation
+	// Auditor is the npub signing the report, see Sign.
+	Auditor string
+	// ReverseSearch links reverse image searches for the images of high
+	// severity leaks, see ReverseSearch.
+	ReverseSearch bool
+}
+
+// Post is a leaking note with all its leaking images, or a single leaking
+// file no note links to.
+type Post struct {
+	EventID string
+	// Source is the media server of an unlinked file.
+	Source string
+	Images []*exifscan.ImageResult
+}
+
+// Categories returns the distinct leak categories across the images.
+func (p Post) Categories() []string {
+	var out []string
+	seen := map[string]bool{}
+	for _, r := range p.Images {
+		for _, c := range r.Categories() {
+			if !seen[c] {
+				seen[c] = true
+				out = append(out, c)
+			}
+		}
+	}
+	return out
+}
+
+// Link links the note in a web client.
+func (p Post) Link() string {
+	return ResultURL(p.Images[0])
+}
+
+// Context describes the note, nil for an unlinked file.
+func (p Post) Context() *exifscan.PostContext {
+	return p.Images[0].Post
+}
+
+// Severity is the highest severity among the images.
+func (p Post) Severity() string {
+	best := ""
+	for _, r := range p.Images {
+		if sev := r.Severity(); exifscan.SeverityRank(sev) > exifscan.SeverityRank(best) {
+			best = sev
+		}
+	}
+	return best
+}
+
+// Posts groups the leaking images by the note linking them, in the order
+// each note first appears in images.
+func Posts(images []*exifscan.ImageResult) []Post {
+	var out []Post
+	idx := map[string]int{}
+	for _, r := range images {
+		if !r.Sensitive() {
+			continue
+		}
+		if r.EventID == "" {
+			out = append(out, Post{Source: r.Source, Images: []*exifscan.ImageResult{r}})
+			continue
+		}
+		i, ok := idx[r.EventID]
+		if !ok {
+			i = len(out)
+			idx[r.EventID] = i
+			out = append(out, Post{EventID: r.EventID})
+		}
+		out[i].Images = append(out[i].Images, r)
+	}
+	return out
+}
+
+// LinkTemplates are the web clients --link-template knows by name.
+var LinkTemplates = map[string]string{
+	"primal": "https://primal.net/e/{nevent}",
+	"njump":  "https://njump.me/{nevent}",
+	"snort":  "https://snort.social/e/{nevent}",
+	"habla":  "https://habla.news/e/{nevent}",
+	"nevent": "nostr:{nevent}",
+}
+
+var linkTemplate = LinkTemplates["primal"]
+
+// SetLinkTemplate changes how EventURL links events: the name of one of
+// LinkTemplates, or a URL with {nevent}, {note} or {id} in it.
+func SetLinkTemplate(t string) error {
+	if named, ok := LinkTemplates[t]; ok {
+		t = named
+	}
+	if !strings.Contains(t, "{nevent}") && !strings.Contains(t, "{note}") && !strings.Contains(t, "{id}") {
+		return fmt.Errorf("link template %q has no {nevent}, {note} or {id}", t)
+	}
+	linkTemplate = t
+	return nil
+}
+
+// EventURL links an event in a web client. The relays it was seen on and
+// its author, when known, go into the nevent as hints.
+func EventURL(id string, relays []string, author string) string {
+	nevent, _ := nip19.EncodeEvent(id, relays, author)
+	note, _ := nip19.EncodeNote(id)
+	return strings.NewReplacer("{nevent}", nevent, "{note}", note, "{id}", id).Replace(linkTemplate)
+}
+
+// ResultURL links the note an image was found in.
+func ResultURL(r *exifscan.ImageResult) string {
+	author := ""
+	if r.Event != nil {
+		author = r.Event.PubKey
+	}
+	return EventURL(r.EventID, r.Relays, author)
+}
+
+var funcs = template.FuncMap{
+	"resultURL": ResultURL,
+	"posts":     Posts,
+	"timeline":  Timeline,
+	"spark":     Sparkline,
+	"span":      Span,
+	"chart":     timelineSVG,
+	"join":      strings.Join,
+	"failure":   exifscan.FailureLabel,
+	"retries":   Retries,
+	"pivots":    pivots,
+	"severity":  func(category string) string { return exifscan.CategorySeverity[category] },
+	"deadReason": func(reason string) string {
+		switch reason {
+		case exifscan.DeadNotFound:
+			return "404 Not Found"
+		case exifscan.DeadGone:
+			return "410 Gone"
+		}
+		return "timed out"
+	},
+	"add": func(a, b int) int { return a + b },
+	"cell": func(s string) string {
+		return strings.ReplaceAll(s, "|", `\|`)
+	},
+	"date": func(t time.Time) string {
+		if t.IsZero() {
+			return "–"
+		}
+		return exifscan.InDisplayZone(t).Format(time.RFC3339)
+	},
+}
+
+var (
+	htmlTmpl     = template.Must(template.New("report").Funcs(funcs).Parse(htmlSource))
+	markdownTmpl = texttemplate.Must(texttemplate.New("report").Funcs(texttemplate.FuncMap(funcs)).Parse(markdownSource))
+)
+
+// HTML writes a self-contained HTML report.
+func HTML(w io.Writer, d Data) error {
+	return htmlTmpl.Execute(w, d.normalize())
+}
+
+// Markdown writes the report as GitHub flavoured Markdown.
+func Markdown(w io.Writer, d Data) error {
+	return markdownTmpl.Execute(w, d.normalize())
+}
+
+// JSON writes the findings as indented JSON, see exifscan.Schema.
+func JSON(w io.Writer, d Data) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(d.normalize().Findings)
+}
+
+// Write picks the format from the extension of path: .md/.markdown for
+// Markdown, .json for JSON and HTML otherwise.
+func Write(w io.Writer, path string, d Data) error {
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".md", ".markdown":
+		return Markdown(w, d)
+	case ".json":
+		return JSON(w, d)
+	}
+	return HTML(w, d)
+}
+
+// ContentType is the MIME type of the report Write writes to path.
+func ContentType(path string) string {
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".md", ".markdown":
+		return "text/markdown; charset=utf-8"
+	case ".json":
+		return "application/json"
+	}
+	return "text/html; charset=utf-8"
+}
+
+func (d Data) normalize() Data {
+	if d.GeneratedAt.IsZero() {
+		d.GeneratedAt = time.Now()
+	}
+	if d.Findings == nil {
+		d.Findings = &exifscan.Findings{}
+	}
+	return d
+}
+
+const htmlSource = `<!DOCTYPE html>
+<html lang="en">
+<head>
+<meta charset="utf-8">
+<title>EXIF scan report – {{.Npub}}</title>
+<style>
+body { font-family: system-ui, sans-serif; margin: 2rem; color: #222; }
+table { border-collapse: collapse; width: 100%; }
+th, td { border-bottom: 1px solid #ddd; padding: .4rem; text-align: left; vertical-align: top; }
+.leak { color: #b00020; font-weight: 600; }
+code { font-size: .85em; }
+.timeline rect { fill: #b00020; }
+.timeline text { font-size: 10px; fill: #666; }
+</style>
+</head>
+<body>
+<h1>🛡️ EXIF scan report</h1>
+<p><strong>{{.Npub}}</strong><br>
+Range: {{date .Since}} → {{date .Until}}<br>
+Generated: {{date .GeneratedAt}}
+{{- with .Auditor}}<br>
+Signed by: {{.}}{{end}}</p>
+{{- $flagged := .Findings.Flagged}}
+<p>📚 {{.Findings.Events}} posts · 📸 {{len .Findings.Images}} images · 🚨 <span class="leak">{{len $flagged}} leaking</span></p>
+{{- with .Sample}}
+<p>🎲 Random sample: {{.}}</p>
+{{- end}}
+{{- with timeline $flagged}}
+<h2>Leaking posts per month</h2>
+<p>{{chart .}}<br><small>{{span .}}</small></p>
+{{- end}}
+{{- if $flagged}}
+{{- $posts := posts $flagged}}
+<p>{{len $posts}} posts to act on:</p>
+<table>
+<tr><th>Post</th><th>Severity</th><th>Leaks</th><th>Images</th></tr>
+{{- range $posts}}
+<tr>
+<td>{{if .EventID}}<a href="{{.Link}}">{{printf "%.12s" .EventID}}…</a>{{else}}unlinked on {{.Source}}{{end}}
+{{- with .Context}}<br><small>{{date .CreatedAt}}{{if ne .Kind 1}} · kind {{.Kind}}{{end}}{{if .Reply}} · reply{{end}}{{if .ContentWarning}} · content warning{{with .ContentWarningReason}}: {{.}}{{end}}{{end}}{{with .Excerpt}}<br>“{{.}}”{{end}}</small>{{end}}</td>
+<td>{{.Severity}}</td>
+<td class="leak">{{join .Categories ", "}}</td>
+<td><details{{if eq (len .Images) 1}} open{{end}}><summary>{{len .Images}} image{{if gt (len .Images) 1}}s{{end}}</summary>
+{{- range .Images}}
+<p><a href="{{.URL}}">{{.URL}}</a>{{with .Archived}} (dead, <a href="{{.}}">archived copy</a>){{end}}{{if .OriginLeaks}} (clean, but its <a href="{{.Origin}}">original</a> leaks){{end}}{{if .ProxyError}} (unreachable, scanned its <a href="{{.Origin}}">original</a>){{end}}<br>
+{{- range .Tags}}{{if .Value}}<code>{{.Field}}: {{.Value}}</code><br>{{end}}{{end}}
+{{- with .GPS}}🌍 <a href="{{.MapsURL}}">{{.String}}</a>, accurate to {{.Accuracy}}{{end}}{{with .GeoTag}}<br>📍 Note {{.}}{{end}}
+{{- with pivots $ .}}<br>🔍 Where else it spread: {{range $i, $l := .}}{{if $i}} · {{end}}<a href="{{$l.URL}}">{{$l.Engine}}</a>{{end}}{{end}}</p>
+{{- end}}
+</details></td>
+</tr>
+{{- end}}
+</table>
+{{- else}}
+<p>✅ No sensitive EXIF metadata found.</p>
+{{- end}}
+{{- with .Findings.Profile}}
+<h2>Profile</h2>
+<p>The account's profile (kind 0) gives away personal details:</p>
+<table>
+<tr><th>Field</th><th>Severity</th><th>Leaks</th><th>Found</th></tr>
+{{- range .}}
+<tr><td><code>{{.Field}}</code></td><td>{{severity .Category}}</td><td class="leak">{{.Category}}</td><td>{{.Value}}</td></tr>
+{{- end}}
+</table>
+{{- end}}
+{{- with .Findings.Mistyped}}
+<h2>Format mismatches</h2>
+<p>{{len .}} link{{if gt (len .) 1}}s serve{{else}} serves{{end}} another format than {{if gt (len .) 1}}their{{else}}its{{end}} extension says, which points at broken or suspicious hosting.</p>
+<table>
+<tr><th>Post</th><th>Image</th><th>Served</th></tr>
+{{- range .}}
+<tr><td>{{if .EventID}}<a href="{{resultURL .}}">{{printf "%.12s" .EventID}}…</a>{{else}}unlinked on {{.Source}}{{end}}</td><td><a href="{{.URL}}">{{.URL}}</a></td><td><code>{{.ContentType}}</code></td></tr>
+{{- end}}
+</table>
+{{- end}}
+{{- with .Findings.Mismatched}}
+<h2>Hash mismatches</h2>
+<p>The bytes served for {{len .}} image{{if gt (len .) 1}}s{{end}} don't match the SHA-256 their post published: what was scanned isn't the file the author uploaded.</p>
+<table>
+<tr><th>Post</th><th>Image</th><th>Published</th><th>Served</th></tr>
+{{- range .}}
+<tr><td>{{if .EventID}}<a href="{{resultURL .}}">{{printf "%.12s" .EventID}}…</a>{{else}}unlinked on {{.Source}}{{end}}</td><td><a href="{{.URL}}">{{.URL}}</a></td><td><code>{{.DeclaredSHA256}}</code></td><td><code>{{.SHA256}}</code></td></tr>
+{{- end}}
+</table>
+{{- end}}
+{{- with .Findings.Stego}}
+<h2>Possible hidden data</h2>
+<p>{{len .}} image{{if gt (len .) 1}}s carry{{else}} carries{{end}} signs of hidden data. They are informational: phones append motion photo videos the same way.</p>
+<table>
+<tr><th>Post</th><th>Image</th><th>Hint</th></tr>
+{{- range $r := .}}{{range .Stego}}
+<tr><td>{{if $r.EventID}}<a href="{{resultURL $r}}">{{printf "%.12s" $r.EventID}}…</a>{{else}}unlinked on {{$r.Source}}{{end}}</td><td><a href="{{$r.URL}}">{{$r.URL}}</a></td><td>{{.Detail}}</td></tr>
+{{- end}}{{end}}
+</table>
+{{- end}}
+{{- with .Findings.Failed}}
+<h2>Download failures</h2>
+<p>{{len .}} image{{if gt (len .) 1}}s{{end}} couldn't be downloaded or read, so {{if gt (len .) 1}}their{{else}}its{{end}} metadata is unknown.</p>
+<table>
+<tr><th>Post</th><th>Image</th><th>Status</th><th>Class</th><th>Retries</th><th>Error</th></tr>
+{{- range .}}
+<tr><td>{{if .EventID}}<a href="{{resultURL .}}">{{printf "%.12s" .EventID}}…</a>{{else}}unlinked on {{.Source}}{{end}}</td><td><a href="{{.URL}}">{{.URL}}</a></td><td>{{if .Status}}{{.Status}}{{else}}–{{end}}</td><td>{{failure .ErrorClass}}</td><td>{{.Retries}}</td><td><small>{{.Error}}</small></td></tr>
+{{- end}}
+</table>
+{{- end}}
+{{- with .Findings.Dead}}
+<h2>Broken media</h2>
+<p>{{len .}} linked image{{if gt (len .) 1}}s are{{else}} is{{end}} already gone; there is nothing left to delete or re-upload for them.</p>
+<table>
+<tr><th>Post</th><th>Image</th><th>Reason</th></tr>
+{{- range .}}
+<tr><td>{{if .EventID}}<a href="{{resultURL .}}">{{printf "%.12s" .EventID}}…</a>{{else}}unlinked on {{.Source}}{{end}}</td><td><a href="{{.URL}}">{{.URL}}</a></td><td>{{deadReason .Dead}}{{with .Archived}}; <a href="{{.}}">archived copy</a> scanned{{end}}</td></tr>
+{{- end}}
+</table>
+{{- end}}
+{{- if .Inventory}}
+<h2>Inventory</h2>
+<p>Every scanned image and its verdict.</p>
+<table>
+<tr><th>Post</th><th>Image</th><th>Verdict</th></tr>
+{{- range .Findings.Images}}
+<tr><td>{{if .EventID}}<a href="{{resultURL .}}">{{printf "%.12s" .EventID}}…</a>{{else}}unlinked on {{.Source}}{{end}}</td><td><a href="{{.URL}}">{{.URL}}</a></td><td{{if eq .Verdict "leaking"}} class="leak"{{end}}>{{.Verdict}}<br><small>{{.VerdictDetail}}</small></td></tr>
+{{- end}}
+</table>
+{{- end}}
+</body>
+</html>
+`
+
+const markdownSource = `# 🛡️ EXIF scan report
+
+**{{.Npub}}**
+
+- Range: {{date .Since}} → {{date .Until}}
+- Generated: {{date .GeneratedAt}}
+{{- with .Auditor}}
+- Signed by: {{.}}
+{{- end}}
+{{- $flagged := .Findings.Flagged}}
+- 📚 {{.Findings.Events}} posts · 📸 {{len .Findings.Images}} images · 🚨 **{{len $flagged}} leaking**
+{{- with .Sample}}
+- 🎲 Random sample: {{.}}
+{{- end}}
+{{- with timeline $flagged}}
+- 📈 Leaking posts per month, {{span .}}: ` + "`{{spark .}}`" + `
+{{- end}}
+{{if $flagged}}
+{{- $posts := posts $flagged}}
+{{len $posts}} posts to act on:
+
+| Post | Severity | Leaks | Images |
+| ---- | -------- | ----- | ------ |
+{{- range $posts}}
+| {{if .EventID}}[{{printf "%.12s" .EventID}}…]({{.Link}}){{else}}unlinked on {{.Source}}{{end}}{{with .Context}}<br><sub>{{date .CreatedAt}}{{if ne .Kind 1}} · kind {{.Kind}}{{end}}{{if .Reply}} · reply{{end}}{{if .ContentWarning}} · content warning{{with .ContentWarningReason}}: {{cell .}}{{end}}{{end}}{{with .Excerpt}}<br>“{{cell .}}”{{end}}</sub>{{end}} | {{.Severity}} | {{join .Categories ", "}} | <details><summary>{{len .Images}} image{{if gt (len .Images) 1}}s{{end}}</summary>{{range $i, $r := .Images}}{{if $i}}<br>{{end}}[image {{add $i 1}}]({{$r.URL}}){{with $r.Archived}} ([archived copy]({{.}})){{end}}{{if $r.OriginLeaks}} (clean, but its [original]({{$r.Origin}}) leaks){{end}}{{if $r.ProxyError}} (unreachable, scanned its [original]({{$r.Origin}})){{end}}{{range $r.Tags}}{{if .Value}} ` + "`{{.Field}}: {{cell .Value}}`" + `{{end}}{{end}}{{with $r.GPS}} 🌍 [{{.String}}]({{.MapsURL}}), accurate to {{.Accuracy}}{{end}}{{with $r.GeoTag}} 📍 note {{.}}{{end}}{{with pivots $ $r}} 🔍 {{range $j, $l := .}}{{if $j}} · {{end}}[{{$l.Engine}}]({{$l.URL}}){{end}}{{end}}{{end}}</details> |
+{{- end}}
+{{else}}
+✅ No sensitive EXIF metadata found.
+{{end}}
+{{- with .Findings.Profile}}
+## Profile
+
+The account's profile (kind 0) gives away personal details:
+
+| Field | Severity | Leaks | Found |
+| ----- | -------- | ----- | ----- |
+{{- range .}}
+| ` + "`{{.Field}}`" + ` | {{severity .Category}} | {{.Category}} | {{cell .Value}} |
+{{- end}}
+{{end}}
+{{- with .Findings.Mistyped}}
+## Format mismatches
+
+{{len .}} link{{if gt (len .) 1}}s serve{{else}} serves{{end}} another format than {{if gt (len .) 1}}their{{else}}its{{end}} extension says, which points at broken or suspicious hosting.
+
+| Post | Image | Served |
+| ---- | ----- | ------ |
+{{- range .}}
+| {{if .EventID}}[{{printf "%.12s" .EventID}}…]({{resultURL .}}){{else}}unlinked on {{.Source}}{{end}} | <{{.URL}}> | ` + "`{{.ContentType}}`" + ` |
+{{- end}}
+{{end}}
+{{- with .Findings.Mismatched}}
+## Hash mismatches
+
+The bytes served for {{len .}} image{{if gt (len .) 1}}s{{end}} don't match the SHA-256 their post published: what was scanned isn't the file the author uploaded.
+
+| Post | Image | Published | Served |
+| ---- | ----- | --------- | ------ |
+{{- range .}}
+| {{if .EventID}}[{{printf "%.12s" .EventID}}…]({{resultURL .}}){{else}}unlinked on {{.Source}}{{end}} | <{{.URL}}> | ` + "`{{.DeclaredSHA256}}`" + ` | ` + "`{{.SHA256}}`" + ` |
+{{- end}}
+{{end}}
+{{- with .Findings.Stego}}
+## Possible hidden data
+
+{{len .}} image{{if gt (len .) 1}}s carry{{else}} carries{{end}} signs of hidden data. They are informational: phones append motion photo videos the same way.
+
+| Post | Image | Hint |
+| ---- | ----- | ---- |
+{{- range $r := .}}{{range .Stego}}
+| {{if $r.EventID}}[{{printf "%.12s" $r.EventID}}…]({{resultURL $r}}){{else}}unlinked on {{$r.Source}}{{end}} | <{{$r.URL}}> | {{cell .Detail}} |
+{{- end}}{{end}}
+{{end}}
+{{- with .Findings.Failed}}
+## Download failures
+
+{{len .}} image{{if gt (len .) 1}}s{{end}} couldn't be downloaded or read, so {{if gt (len .) 1}}their{{else}}its{{end}} metadata is unknown.
+
+| Post | Image | Status | Class | Retries | Error |
+| ---- | ----- | ------ | ----- | ------- | ----- |
+{{- range .}}
+| {{if .EventID}}[{{printf "%.12s" .EventID}}…]({{resultURL .}}){{else}}unlinked on {{.Source}}{{end}} | <{{.URL}}> | {{if .Status}}{{.Status}}{{else}}–{{end}} | {{failure .ErrorClass}} | {{.Retries}} | {{cell .Error}} |
+{{- end}}
+{{end}}
+{{- with .Findings.Dead}}
+## Broken media
+
+{{len .}} linked image{{if gt (len .) 1}}s are{{else}} is{{end}} already gone; there is nothing left to delete or re-upload for them.
+
+| Post | Image | Reason |
+| ---- | ----- | ------ |
+{{- range .}}
+| {{if .EventID}}[{{printf "%.12s" .EventID}}…]({{resultURL .}}){{else}}unlinked on {{.Source}}{{end}} | <{{.URL}}> | {{deadReason .Dead}}{{with .Archived}}; [archived copy]({{.}}) scanned{{end}} |
+{{- end}}
+{{end}}
+{{- if .Inventory}}
+## Inventory
+
+Every scanned image and its verdict.
+
+| Post | Image | Verdict |
+| ---- | ----- | ------- |
+{{- range .Findings.Images}}
+| {{if .EventID}}[{{printf "%.12s" .EventID}}…]({{resultURL .}}){{else}}unlinked on {{.Source}}{{end}} | <{{.URL}}> | {{if eq .Verdict "leaking"}}🚨{{else if eq .Verdict "clean"}}✅{{else}}❌{{end}} {{.Verdict}}: {{cell .VerdictDetail}} |
+{{- end}}
+{{end}}`
@@ -0,0 +1,76 @@
+package report
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/nbd-wtf/go-nostr"
+)
+
+// KindSignature is the NIP-78 application data kind of report signatures.
+// They are embedded in the report, not published.
+const KindSignature = 30078
+
+// The signature trailer is an HTML comment, so it stays out of sight in
+// both HTML and rendered Markdown.
+const (
+	signatureStart = "\n<!-- nostr-exif-scan signature "
+	signatureEnd   = " -->\n"
+)
+
+// Sign appends to a rendered report an event kr signs over its SHA-256,
+// dated at and carrying params, the tags describing the scan.
+func Sign(ctx context.Context, body []byte, kr nostr.Keyer, at time.Time, params nostr.Tags) ([]byte, error) {
+	sum := sha256.Sum256(body)
+	hash := hex.EncodeToString(sum[:])
+	evt := &nostr.Event{
+		Kind:      KindSignature,
+		CreatedAt: nostr.Timestamp(at.Unix()),
+		Tags: append(nostr.Tags{
+			{"d", "nostr-exif-scan-report:" + hash},
+			{"x", hash},
+			{"alt", "Signature of an EXIF scan report"},
+		}, params...),
+		Content: "nostr-exif-scan report " + hash,
+	}
+	if err := kr.SignEvent(ctx, evt); err != nil {
+		return nil, fmt.Errorf("signing the report: %w", err)
+	}
+	sig, err := json.Marshal(evt)
+	if err != nil {
+		return nil, err
+	}
+	out := append(bytes.Clone(body), signatureStart...)
+	out = append(out, sig...)
+	return append(out, signatureEnd...), nil
+}
+
+// Verify checks the signature Sign embedded in a report and returns the
+// signed event.
+func Verify(signed []byte) (*nostr.Event, error) {
+	i := bytes.LastIndex(signed, []byte(signatureStart))
+	if i < 0 || !bytes.HasSuffix(signed, []byte(signatureEnd)) {
+		return nil, errors.New("the report carries no signature")
+	}
+	var evt nostr.Event
+	if err := json.Unmarshal(signed[i+len(signatureStart):len(signed)-len(signatureEnd)], &evt); err != nil {
+		return nil, fmt.Errorf("unreadable signature: %w", err)
+	}
+	if evt.Kind != KindSignature {
+		return nil, fmt.Errorf("signature is a kind %d event, want %d", evt.Kind, KindSignature)
+	}
+	if ok, err := evt.CheckSignature(); err != nil || !ok {
+		return nil, errors.New("the signature doesn't match its event")
+	}
+	sum := sha256.Sum256(signed[:i])
+	if x := evt.Tags.Find("x"); x == nil || x[1] != hex.EncodeToString(sum[:]) {
+		return nil, errors.New("the report was changed after it was signed")
+	}
+	return &evt, nil
+}
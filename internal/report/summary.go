@@ -0,0 +1,185 @@
+package report
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+
+	"nostr-exif-scan/pkg/exifscan"
+)
+
+// Summary aggregates a scan's results. The breakdowns count leaking
+// images, except FailedByHost.
+type Summary struct {
+	Images       int
+	WithMetadata int
+	Leaking      int
+	Failed       int
+	// Dead counts the failed images whose links are broken.
+	Dead int
+	// Mismatched counts images whose bytes don't match their published hash.
+	Mismatched int
+	// Mistyped counts images whose bytes aren't what their extension says.
+	Mistyped int
+	// Stego counts images with signs of hidden data, see
+	// exifscan.StegoHint.
+	Stego int
+	// Archived counts dead links scanned from a Wayback Machine snapshot.
+	Archived   int
+	ByCategory map[string]int
+	ByHost     map[string]int
+	// ByMonth is keyed by the linking note's month ("2006-01").
+	ByMonth      map[string]int
+	ByDevice     map[string]int
+	FailedByHost map[string]int
+	// CleanByProvenance counts the images without leaks by their
+	// provenance class.
+	CleanByProvenance map[string]int
+}
+
+// Count is one line of a breakdown.
+type Count struct {
+	Key   string
+	Count int
+}
+
+// Summarize computes the summary of f.
+func Summarize(f *exifscan.Findings) Summary {
+	s := Summary{
+		ByCategory:   map[string]int{},
+		ByHost:       map[string]int{},
+		ByMonth:      map[string]int{},
+		ByDevice:     map[string]int{},
+		FailedByHost: map[string]int{},
+
+		CleanByProvenance: map[string]int{},
+	}
+	for _, r := range f.Images {
+		s.Images++
+		if r.Error != "" {
+			s.Failed++
+			if r.Dead != "" {
+				s.Dead++
+			}
+			s.FailedByHost[r.Host()]++
+			continue
+		}
+		if r.Archived != "" {
+			s.Archived++
+		}
+		if r.HashMismatch {
+			s.Mismatched++
+		}
+		if r.TypeMismatch {
+			s.Mistyped++
+		}
+		if r.Stego != nil {
+			s.Stego++
+		}
+		if r.HasMetadata {
+			s.WithMetadata++
+		}
+		if !r.Sensitive() {
+			if r.Provenance != nil {
+				s.CleanByProvenance[r.Provenance.Class]++
+			}
+			continue
+		}
+		s.Leaking++
+		for _, c := range r.Categories() {
+			s.ByCategory[c]++
+		}
+		s.ByHost[r.Host()]++
+		if r.Event != nil {
+			s.ByMonth[exifscan.InDisplayZone(r.Event.CreatedAt.Time()).Format("2006-01")]++
+		}
+		if d := device(r); d != "" {
+			s.ByDevice[d]++
+		}
+	}
+	return s
+}
+
+// device names the camera from its Make and Model tags.
+func device(r *exifscan.ImageResult) string {
+	var mk, model string
+	for _, t := range r.Tags {
+		switch t.Field {
+		case "Make":
+			mk = t.Value
+		case "Model":
+			model = t.Value
+		}
+	}
+	// Models usually repeat the make ("Canon PowerShot"), sometimes in
+	// another case.
+	if f := strings.Fields(mk); len(f) > 0 && strings.HasPrefix(strings.ToLower(model), strings.ToLower(f[0])) {
+		return model
+	}
+	return strings.TrimSpace(mk + " " + model)
+}
+
+// Ranked orders a breakdown by count, then key.
+func Ranked(m map[string]int) []Count {
+	out := make([]Count, 0, len(m))
+	for k, n := range m {
+		out = append(out, Count{k, n})
+	}
+	sort.Slice(out, func(i, k int) bool {
+		if out[i].Count != out[k].Count {
+			return out[i].Count > out[k].Count
+		}
+		return out[i].Key < out[k].Key
+	})
+	return out
+}
+
+// Chronological orders a breakdown by key.
+func Chronological(m map[string]int) []Count {
+	out := make([]Count, 0, len(m))
+	for k, n := range m {
+		out = append(out, Count{k, n})
+	}
+	sort.Slice(out, func(i, k int) bool { return out[i].Key < out[k].Key })
+	return out
+}
+
+// Extrapolation projects the leak rate of a random sample onto the
+// images it was drawn from.
+type Extrapolation struct {
+	// Sample counts the sampled images that could be scanned, Leaking
+	// the ones among them that leak, Population all images.
+	Sample, Leaking, Population int
+	// Rate is the sample's leak rate, Low and High the bounds of its 95%
+	// Wilson score interval.
+	Rate, Low, High float64
+}
+
+// Extrapolate computes the leak rate of the sample s summarizes, drawn
+// from population images.
+func Extrapolate(s Summary, population int) Extrapolation {
+	e := Extrapolation{Sample: s.Images - s.Failed, Leaking: s.Leaking, Population: population}
+	if e.Sample == 0 {
+		return e
+	}
+	const z = 1.96
+	n, p := float64(e.Sample), float64(e.Leaking)/float64(e.Sample)
+	center := (p + z*z/(2*n)) / (1 + z*z/n)
+	margin := z / (1 + z*z/n) * math.Sqrt(p*(1-p)/n+z*z/(4*n*n))
+	e.Rate, e.Low, e.High = p, max(0, center-margin), min(1, center+margin)
+	return e
+}
+
+// Leaks estimates how many of the population leak, with the interval
+// bounds.
+func (e Extrapolation) Leaks() (est, low, high int) {
+	n := float64(e.Population)
+	return int(math.Round(e.Rate * n)), int(math.Round(e.Low * n)), int(math.Round(e.High * n))
+}
+
+func (e Extrapolation) String() string {
+	est, low, high := e.Leaks()
+	return fmt.Sprintf("%.1f%% of %d sampled images leak (95%% CI %.1f–%.1f%%): ~%d of all %d images (%d–%d)",
+		100*e.Rate, e.Sample, 100*e.Low, 100*e.High, est, e.Population, low, high)
+}
@@ -0,0 +1,96 @@
+package report
+
+import (
+	"fmt"
+	"html/template"
+	"strings"
+	"time"
+
+	"nostr-exif-scan/pkg/exifscan"
+)
+
+// Timeline counts the leaking posts per month, from the month of the
+// oldest to that of the newest, including months without leaks. Files no
+// note links to have no date and are left out.
+func Timeline(images []*exifscan.ImageResult) []Count {
+	months := map[string]int{}
+	var first, last time.Time
+	for _, p := range Posts(images) {
+		if p.EventID == "" || p.Images[0].Event == nil {
+			continue
+		}
+		t := exifscan.InDisplayZone(p.Images[0].Event.CreatedAt.Time())
+		t = time.Date(t.Year(), t.Month(), 1, 0, 0, 0, 0, time.UTC)
+		months[t.Format("2006-01")]++
+		if first.IsZero() || t.Before(first) {
+			first = t
+		}
+		if t.After(last) {
+			last = t
+		}
+	}
+	if first.IsZero() {
+		return nil
+	}
+	var out []Count
+	for t := first; !t.After(last); t = t.AddDate(0, 1, 0) {
+		key := t.Format("2006-01")
+		out = append(out, Count{key, months[key]})
+	}
+	return out
+}
+
+var sparks = []rune("▁▂▃▄▅▆▇█")
+
+// Sparkline draws counts as one block character each; months without
+// leaks get the lowest block.
+func Sparkline(counts []Count) string {
+	most := 0
+	for _, c := range counts {
+		most = max(most, c.Count)
+	}
+	var b strings.Builder
+	for _, c := range counts {
+		i := 0
+		if c.Count > 0 {
+			i = 1 + (c.Count-1)*(len(sparks)-2)/max(most-1, 1)
+		}
+		b.WriteRune(sparks[i])
+	}
+	return b.String()
+}
+
+// Span names the range of a timeline.
+func Span(counts []Count) string {
+	if len(counts) == 1 {
+		return counts[0].Key
+	}
+	return counts[0].Key + " → " + counts[len(counts)-1].Key
+}
+
+// timelineSVG draws counts as a bar chart for the HTML report.
+func timelineSVG(counts []Count) template.HTML {
+	const barWidth, height = 14, 80
+	most := 1
+	for _, c := range counts {
+		most = max(most, c.Count)
+	}
+	// Wide enough for the first and last month labels.
+	width := max(len(counts)*barWidth, 120)
+	var b strings.Builder
+	fmt.Fprintf(&b, `<svg class="timeline" viewBox="0 0 %d %d" width="%d" height="%d" role="img" aria-label="Leaking posts per month">`, width, height+16, width, height+16)
+	for i, c := range counts {
+		h := c.Count * height / most
+		if c.Count > 0 {
+			h = max(h, 2)
+		}
+		fmt.Fprintf(&b, `<rect x="%d" y="%d" width="%d" height="%d"><title>%s: %d</title></rect>`,
+			i*barWidth+1, height-h, barWidth-2, h, template.HTMLEscapeString(c.Key), c.Count)
+	}
+	fmt.Fprintf(&b, `<text x="0" y="%d">%s</text>`, height+13, counts[0].Key)
+	if len(counts) > 1 {
+		fmt.Fprintf(&b, `<text x="%d" y="%d" text-anchor="end">%s</text>`, width, height+13, counts[len(counts)-1].Key)
+	}
+	b.WriteString(`</svg>`)
+	return template.HTML(b.String())
+}
@@ -0,0 +1,155 @@
+package report
+
+import (
+	"html/template"
+	"io"
+	"path/filepath"
+	"strings"
+	texttemplate "text/template"
+	"time"
+)
+
+// Outcomes of re-checking a flagged image.
+const (
+	// VerifyReplaced: the post is gone and the clean copy remediate
+	// published in its place has no leaks.
+	VerifyReplaced = "replaced"
+	VerifyDeleted  = "deleted"
+	// VerifyGone: the post is still there but its image link is dead.
+	VerifyGone    = "gone"
+	VerifyCleaned = "cleaned"
+	VerifyLeaking = "leaking"
+	// VerifyUnknown: the image couldn't be downloaded for another reason.
+	VerifyUnknown = "unknown"
+)
+
+// Verified is one previously flagged image and how it stands now.
+type Verified struct {
+	EventID string
+	// Link links the post in a web client.
+	Link   string
+	URL    string
+	Status string
+	// Detail explains the status, e.g. the replacement's URL.
+	Detail string
+}
+
+// Fixed reports whether the leak is no longer reachable.
+func (v Verified) Fixed() bool {
+	switch v.Status {
+	case VerifyReplaced, VerifyDeleted, VerifyGone, VerifyCleaned:
+		return true
+	}
+	return false
+}
+
+// Verification is everything a remediation progress report describes.
+type Verification struct {
+	RunID       string
+	Npub        string
+	RunAt       time.Time
+	GeneratedAt time.Time
+	Items       []Verified
+}
+
+// Fixed counts the images whose leak is no longer reachable.
+func (v Verification) Fixed() int {
+	n := 0
+	for _, it := range v.Items {
+		if it.Fixed() {
+			n++
+		}
+	}
+	return n
+}
+
+// Percent is the share of fixed images, rounded down.
+func (v Verification) Percent() int {
+	if len(v.Items) == 0 {
+		return 100
+	}
+	return 100 * v.Fixed() / len(v.Items)
+}
+
+// ByStatus counts the images per status, most common first.
+func (v Verification) ByStatus() []Count {
+	m := map[string]int{}
+	for _, it := range v.Items {
+		m[it.Status]++
+	}
+	return Ranked(m)
+}
+
+var (
+	verifyHTMLTmpl     = template.Must(template.New("verify").Funcs(funcs).Parse(verifyHTMLSource))
+	verifyMarkdownTmpl = texttemplate.Must(texttemplate.New("verify").Funcs(texttemplate.FuncMap(funcs)).Parse(verifyMarkdownSource))
+)
+
+// WriteVerification picks the format from the extension of path like Write.
+func WriteVerification(w io.Writer, path string, v Verification) error {
+	if v.GeneratedAt.IsZero() {
+		v.GeneratedAt = time.Now()
+	}
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".md", ".markdown":
+		return verifyMarkdownTmpl.Execute(w, v)
+	}
+	return verifyHTMLTmpl.Execute(w, v)
+}
+
+const verifyHTMLSource = `<!DOCTYPE html>
+<html lang="en">
+<head>
+<meta charset="utf-8">
+<title>Remediation progress – {{.Npub}}</title>
+<style>
+body { font-family: system-ui, sans-serif; margin: 2rem; color: #222; }
+table { border-collapse: collapse; width: 100%; }
+th, td { border-bottom: 1px solid #ddd; padding: .4rem; text-align: left; vertical-align: top; }
+.leak { color: #b00020; font-weight: 600; }
+.fixed { color: #1b7f3b; }
+progress { width: 20rem; }
+</style>
+</head>
+<body>
+<h1>🛡️ Remediation progress</h1>
+<p><strong>{{.Npub}}</strong><br>
+Run: <code>{{.RunID}}</code> from {{date .RunAt}}<br>
+Generated: {{date .GeneratedAt}}</p>
+<p><progress max="{{len .Items}}" value="{{.Fixed}}"></progress> {{.Fixed}} of {{len .Items}} flagged images fixed ({{.Percent}}%)</p>
+<ul>
+{{- range .ByStatus}}
+<li>{{.Key}}: {{.Count}}</li>
+{{- end}}
+</ul>
+<table>
+<tr><th>Post</th><th>Image</th><th>Status</th></tr>
+{{- range .Items}}
+<tr>
+<td>{{if .EventID}}<a href="{{.Link}}">{{printf "%.12s" .EventID}}…</a>{{else}}unlinked{{end}}</td>
+<td><a href="{{.URL}}">{{.URL}}</a></td>
+<td class="{{if .Fixed}}fixed{{else}}leak{{end}}">{{.Status}}{{with .Detail}}<br><small>{{.}}</small>{{end}}</td>
+</tr>
+{{- end}}
+</table>
+</body>
+</html>
+`
+
+const verifyMarkdownSource = `# 🛡️ Remediation progress
+
+**{{.Npub}}**
+
+- Run: ` + "`{{.RunID}}`" + ` from {{date .RunAt}}
+- Generated: {{date .GeneratedAt}}
+- **{{.Fixed}} of {{len .Items}}** flagged images fixed ({{.Percent}}%)
+{{- range .ByStatus}}
+  - {{.Key}}: {{.Count}}
+{{- end}}
+
+| Post | Image | Status |
+| ---- | ----- | ------ |
+{{- range .Items}}
+| {{if .EventID}}[{{printf "%.12s" .EventID}}…]({{.Link}}){{else}}unlinked{{end}} | <{{.URL}}> | {{if .Fixed}}✅{{else}}🚨{{end}} {{.Status}}{{with .Detail}}: {{cell .}}{{end}} |
+{{- end}}
+`
@@ -0,0 +1,75 @@
+package server
+
+import (
+	"container/heap"
+	"context"
+	"sync"
+)
+
+// jobQueue holds jobs waiting for a worker, highest priority first and,
+// within a priority, oldest first.
+type jobQueue struct {
+	mu    sync.Mutex
+	items jobHeap
+	// wake has room for one pending signal; a worker that takes a job
+	// passes the signal on while jobs remain.
+	wake chan struct{}
+}
+
+func newJobQueue() *jobQueue {
+	return &jobQueue{wake: make(chan struct{}, 1)}
+}
+
+func (q *jobQueue) push(job *Job) {
+	q.mu.Lock()
+	heap.Push(&q.items, job)
+	q.mu.Unlock()
+	q.signal()
+}
+
+// pop blocks until a job is available or ctx is done.
+func (q *jobQueue) pop(ctx context.Context) (*Job, bool) {
+	for {
+		q.mu.Lock()
+		if len(q.items) > 0 {
+			job := heap.Pop(&q.items).(*Job)
+			more := len(q.items) > 0
+			q.mu.Unlock()
+			if more {
+				q.signal()
+			}
+			return job, true
+		}
+		q.mu.Unlock()
+		select {
+		case <-q.wake:
+		case <-ctx.Done():
+			return nil, false
+		}
+	}
+}
+
+func (q *jobQueue) signal() {
+	select {
+	case q.wake <- struct{}{}:
+	default:
+	}
+}
+
+type jobHeap []*Job
+
+func (h jobHeap) Len() int { return len(h) }
+func (h jobHeap) Less(i, j int) bool {
+	if h[i].Priority != h[j].Priority {
+		return h[i].Priority > h[j].Priority
+	}
+	return h[i].CreatedAt.Before(h[j].CreatedAt)
+}
+func (h jobHeap) Swap(i, j int) { h[i], h[j] = h[j], h[i] }
+func (h *jobHeap) Push(x any)   { *h = append(*h, x.(*Job)) }
+func (h *jobHeap) Pop() any {
+	old := *h
+	job := old[len(old)-1]
+	*h = old[:len(old)-1]
+	return job
+}
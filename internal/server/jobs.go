@@ -0,0 +1,293 @@
+package server
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"log"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/nbd-wtf/go-nostr"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+
+	"nostr-exif-scan/internal/health"
+	"nostr-exif-scan/internal/metrics"
+	"nostr-exif-scan/internal/queue"
+	"nostr-exif-scan/internal/store"
+	"nostr-exif-scan/pkg/exifscan"
+)
+
+// Job states.
+const (
+	StatusQueued  = "queued"
+	StatusRunning = "running"
+	StatusDone    = "done"
+	StatusFailed  = "failed"
+)
+
+// Progress counts work done so far on a running job.
+type Progress struct {
+	Events        int `json:"events"`
+	ImagesTotal   int `json:"images_total"`
+	ImagesScanned int `json:"images_scanned"`
+	Findings      int `json:"findings"`
+}
+
+// Job is one scan request and its outcome.
+type Job struct {
+	ID         string             `json:"id"`
+	Tenant     string             `json:"tenant,omitempty"`
+	Priority   int                `json:"priority"`
+	Npub       string             `json:"npub"`
+	Pubkey     string             `json:"pubkey"`
+	Since      time.Time          `json:"since,omitzero"`
+	Until      time.Time          `json:"until,omitzero"`
+	Status     string             `json:"status"`
+	Error      string             `json:"error,omitempty"`
+	CreatedAt  time.Time          `json:"created_at"`
+	StartedAt  time.Time          `json:"started_at,omitzero"`
+	FinishedAt time.Time          `json:"finished_at,omitzero"`
+	Progress   Progress           `json:"progress"`
+	Findings   *exifscan.Findings `json:"findings,omitempty"`
+}
+
+// jobs runs scans on a fixed pool of workers taking them from a priority
+// queue. When db is set, jobs are persisted on every state change and
+// reloaded on start, with unfinished ones queued again.
+type jobs struct {
+	opts    exifscan.Options
+	waiting *jobQueue
+	db      *store.Store
+	met     *metrics.Metrics
+	mon     *health.Monitor
+	// backend, when set, scans images on remote workers instead of here.
+	backend queue.Backend
+
+	mu   sync.RWMutex
+	byID map[string]*Job
+}
+
+// newJobs starts workers running scans until ctx is done.
+func newJobs(ctx context.Context, cfg Config) (*jobs, error) {
+	js := &jobs{
+		opts:    cfg.Scan,
+		waiting: newJobQueue(),
+		db:      cfg.DB,
+		met:     cfg.Metrics,
+		mon:     cfg.Health,
+		backend: cfg.Queue,
+		byID:    map[string]*Job{},
+	}
+	db := js.db
+	if db != nil {
+		ids, err := db.IDs()
+		if err != nil {
+			return nil, err
+		}
+		for _, id := range ids {
+			job := &Job{}
+			if err := db.Get(id, job); err != nil {
+				return nil, err
+			}
+			if job.Status == StatusQueued || job.Status == StatusRunning {
+				// Scans are cheap to redo, so interrupted ones start over.
+				job.Status = StatusQueued
+				job.StartedAt = time.Time{}
+				job.Progress = Progress{}
+				js.waiting.push(job)
+			}
+			js.byID[job.ID] = job
+		}
+	}
+	for range cfg.MaxJobs {
+		go js.work(ctx)
+	}
+	return js, nil
+}
+
+// submit queues a scan for t, or returns a *quotaError when t is over one
+// of its limits.
+func (js *jobs) submit(t *Tenant, priority int, npub, pubkey string, since, until time.Time) (*Job, error) {
+	job := &Job{
+		ID:        newID(),
+		Tenant:    t.Name,
+		Priority:  min(priority, t.Priority),
+		Npub:      npub,
+		Pubkey:    pubkey,
+		Since:     since,
+		Until:     until,
+		Status:    StatusQueued,
+		CreatedAt: time.Now().UTC(),
+	}
+	js.mu.Lock()
+	if err := js.checkQuota(t, job.CreatedAt); err != nil {
+		js.mu.Unlock()
+		return nil, err
+	}
+	js.byID[job.ID] = job
+	js.persist(job)
+	js.mu.Unlock()
+	js.waiting.push(job)
+	return job, nil
+}
+
+// list returns summaries of the tenant's jobs, newest first, without
+// findings.
+func (js *jobs) list(tenant string) []Job {
+	js.mu.RLock()
+	out := make([]Job, 0, len(js.byID))
+	for _, job := range js.byID {
+		if job.Tenant != tenant {
+			continue
+		}
+		j := *job
+		j.Findings = nil
+		out = append(out, j)
+	}
+	js.mu.RUnlock()
+	sort.Slice(out, func(i, k int) bool {
+		return out[i].CreatedAt.After(out[k].CreatedAt)
+	})
+	return out
+}
+
+// counts returns the number of running and queued jobs.
+func (js *jobs) counts() (running, queued int) {
+	js.mu.RLock()
+	defer js.mu.RUnlock()
+	for _, job := range js.byID {
+		switch job.Status {
+		case StatusRunning:
+			running++
+		case StatusQueued:
+			queued++
+		}
+	}
+	return running, queued
+}
+
+// get returns a snapshot of the tenant's job that is safe to serialize.
+func (js *jobs) get(tenant, id string) (Job, bool) {
+	js.mu.RLock()
+	defer js.mu.RUnlock()
+	job, ok := js.byID[id]
+	if !ok || job.Tenant != tenant {
+		return Job{}, false
+	}
+	return *job, true
+}
+
+func (js *jobs) update(job *Job, fn func(*Job)) {
+	js.mu.Lock()
+	fn(job)
+	js.mu.Unlock()
+}
+
+// finish records the final state of job and persists it.
+func (js *jobs) finish(job *Job, fn func(*Job)) {
+	js.mu.Lock()
+	fn(job)
+	job.FinishedAt = time.Now().UTC()
+	js.persist(job)
+	js.mu.Unlock()
+}
+
+// persist writes job to the results database. Callers must hold js.mu.
+func (js *jobs) persist(job *Job) {
+	if js.db == nil {
+		return
+	}
+	if err := js.db.Put(job.ID, job); err != nil {
+		log.Printf("persisting scan %s: %v", job.ID, err)
+	}
+}
+
+func (js *jobs) work(ctx context.Context) {
+	for {
+		job, ok := js.waiting.pop(ctx)
+		if !ok {
+			return
+		}
+		js.run(ctx, job)
+	}
+}
+
+func (js *jobs) run(ctx context.Context, job *Job) {
+	ctx, span := otel.Tracer("nostr-exif-scan/internal/server").Start(ctx, "server.scanJob",
+		trace.WithAttributes(attribute.String("job.id", job.ID), attribute.String("nostr.pubkey", job.Pubkey)))
+	defer span.End()
+
+	js.update(job, func(j *Job) {
+		j.Status = StatusRunning
+		j.StartedAt = time.Now().UTC()
+		js.persist(j)
+	})
+
+	opts := js.opts
+	opts.Since, opts.Until = job.Since, job.Until
+	opts.Hooks = exifscan.Hooks{
+		OnEventFetched: func(*nostr.Event) {
+			js.update(job, func(j *Job) { j.Progress.Events++ })
+		},
+		OnImageScanned: func(r *exifscan.ImageResult) {
+			js.update(job, func(j *Job) {
+				j.Progress.ImagesScanned++
+				if r.Sensitive() {
+					j.Progress.Findings++
+				}
+			})
+		},
+	}
+	if js.met != nil {
+		opts.Hooks = js.met.Hooks(opts.Hooks)
+	}
+	if js.mon != nil {
+		opts.Hooks = js.mon.Hooks(opts.Hooks)
+	}
+	scanner := exifscan.New(opts)
+
+	events, err := scanner.FetchEvents(ctx, job.Pubkey)
+	if err != nil {
+		js.finish(job, func(j *Job) {
+			j.Status = StatusFailed
+			j.Error = err.Error()
+		})
+		return
+	}
+	images := exifscan.ExtractImages(events)
+	js.update(job, func(j *Job) { j.Progress.ImagesTotal = len(images) })
+
+	results := scanner.ScanImages
+	if js.backend != nil {
+		results = func(ctx context.Context, images []exifscan.Image) <-chan *exifscan.ImageResult {
+			return js.scanRemote(ctx, job.ID, images, opts.Hooks)
+		}
+	}
+	findings := &exifscan.Findings{Pubkey: job.Pubkey, Events: len(events)}
+	if opts.Profile {
+		profile, _ := scanner.FetchProfile(ctx, job.Pubkey)
+		findings.Profile = exifscan.ProfileLeaks(profile)
+	}
+	for r := range results(ctx, images) {
+		findings.Images = append(findings.Images, r)
+	}
+	if ctx.Err() != nil {
+		// Shutting down: the job stays running in the database and is
+		// queued again on the next start.
+		return
+	}
+	js.finish(job, func(j *Job) {
+		j.Status = StatusDone
+		j.Findings = findings
+	})
+}
+
+func newID() string {
+	b := make([]byte, 16)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
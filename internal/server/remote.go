@@ -0,0 +1,97 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"nostr-exif-scan/internal/queue"
+	"nostr-exif-scan/pkg/exifscan"
+)
+
+// remoteIdleTimeout fails a job's outstanding images when no worker
+// answered for that long, e.g. because none is running.
+const remoteIdleTimeout = 2 * time.Minute
+
+var errNoWorker = errors.New("no queue worker answered")
+
+// scanRemote hands images to the workers behind js.backend and yields their
+// results, calling hooks as a local scan would.
+func (js *jobs) scanRemote(ctx context.Context, jobID string, images []exifscan.Image, hooks exifscan.Hooks) <-chan *exifscan.ImageResult {
+	out := make(chan *exifscan.ImageResult)
+	go func() {
+		defer close(out)
+		ctx, cancel := context.WithCancel(ctx)
+		defer cancel()
+
+		emit := func(r *exifscan.ImageResult) bool {
+			if r.Error != "" && r.Err == nil {
+				r.Err = errors.New(r.Error)
+			}
+			if hooks.OnImageScanned != nil {
+				hooks.OnImageScanned(r)
+			}
+			if hooks.OnFinding != nil && r.Sensitive() {
+				hooks.OnFinding(r)
+			}
+			select {
+			case out <- r:
+				return true
+			case <-ctx.Done():
+				return false
+			}
+		}
+		failAll := func(pending map[queue.Task]int, err error) {
+			for t, n := range pending {
+				for range n {
+					if !emit(&exifscan.ImageResult{EventID: t.EventID, URL: t.URL, Source: t.Source, Err: err, Error: err.Error()}) {
+						return
+					}
+				}
+			}
+		}
+
+		pending := map[queue.Task]int{}
+		tasks := make([]queue.Task, len(images))
+		for i, img := range images {
+			tasks[i] = queue.Task{Job: jobID, EventID: img.EventID, URL: img.URL, Source: img.Source}
+			pending[tasks[i]]++
+		}
+		results, err := js.backend.Results(ctx, jobID)
+		if err == nil {
+			err = js.backend.Push(ctx, tasks)
+		}
+		if err != nil {
+			failAll(pending, err)
+			return
+		}
+
+		left := len(tasks)
+		idle := time.NewTimer(remoteIdleTimeout)
+		defer idle.Stop()
+		for left > 0 {
+			select {
+			case r, ok := <-results:
+				if !ok {
+					return
+				}
+				key := queue.Task{Job: jobID, EventID: r.EventID, URL: r.URL, Source: r.Source}
+				if pending[key] == 0 {
+					continue // a duplicate delivery
+				}
+				pending[key]--
+				left--
+				idle.Reset(remoteIdleTimeout)
+				if !emit(r) {
+					return
+				}
+			case <-idle.C:
+				failAll(pending, errNoWorker)
+				return
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+	return out
+}
@@ -0,0 +1,216 @@
+// Package server exposes scans over an HTTP API.
+package server
+
+import (
+	"context"
+	_ "embed"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"net/http"
+	"strconv"
+	"time"
+
+	"nostr-exif-scan/internal/health"
+	"nostr-exif-scan/internal/metrics"
+	"nostr-exif-scan/internal/queue"
+	"nostr-exif-scan/internal/report"
+	"nostr-exif-scan/internal/store"
+	"nostr-exif-scan/pkg/exifscan"
+)
+
+//go:embed openapi.yaml
+var openAPISpec []byte
+
+//go:embed web/index.html
+var dashboardHTML []byte
+
+// Config configures a Server.
+type Config struct {
+	// Scan holds the scanner options applied to every job.
+	Scan exifscan.Options
+	// MaxJobs is the number of workers, bounding how many scans run at
+	// once; further jobs queue by priority.
+	MaxJobs int
+	// DB persists jobs across restarts; nil keeps them in memory only.
+	DB *store.Store
+	// Metrics, when set, records scan activity and is served at /metrics.
+	Metrics *metrics.Metrics
+	// Health, when set, is served at /healthz, /readyz and /status.
+	Health *health.Monitor
+	// Queue, when set, hands image downloads to worker processes sharing
+	// it instead of scanning them in this process.
+	Queue queue.Backend
+	// Tenants are the API keys allowed to use the server, each seeing only
+	// its own scans. When empty the server is open to anyone.
+	Tenants []Tenant
+}
+
+// Server runs scan jobs submitted over HTTP.
+type Server struct {
+	jobs    *jobs
+	tenants []Tenant
+	mux     *http.ServeMux
+}
+
+// New returns a Server whose jobs are cancelled when ctx is done.
+func New(ctx context.Context, cfg Config) (*Server, error) {
+	if cfg.MaxJobs <= 0 {
+		cfg.MaxJobs = 4
+	}
+	for i, t := range cfg.Tenants {
+		if t.Key == "" || t.Name == "" {
+			return nil, fmt.Errorf("tenant %d: name and key are required", i+1)
+		}
+	}
+	jobs, err := newJobs(ctx, cfg)
+	if err != nil {
+		return nil, err
+	}
+	s := &Server{
+		jobs:    jobs,
+		tenants: cfg.Tenants,
+		mux:     http.NewServeMux(),
+	}
+	s.mux.HandleFunc("GET /{$}", s.getDashboard)
+	s.mux.HandleFunc("GET /scans", s.authed(s.listScans))
+	s.mux.HandleFunc("POST /scans", s.authed(s.createScan))
+	s.mux.HandleFunc("GET /scans/{id}", s.authed(s.getScan))
+	s.mux.HandleFunc("GET /scans/{id}/report.html", s.authed(s.getReport))
+	s.mux.HandleFunc("GET /openapi.yaml", s.getSpec)
+	s.mux.HandleFunc("GET /schema.json", s.getSchema)
+	if cfg.Metrics != nil {
+		s.mux.Handle("GET /metrics", cfg.Metrics.Handler())
+	}
+	if cfg.Health != nil {
+		cfg.Health.SetJobs(jobs.counts)
+		cfg.Health.Register(s.mux)
+		cfg.Health.SetReady(true)
+	}
+	return s, nil
+}
+
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.mux.ServeHTTP(w, r)
+}
+
+type scanRequest struct {
+	Npub  string `json:"npub"`
+	Since string `json:"since"`
+	Until string `json:"until"`
+	// Priority defaults to, and is capped at, the tenant's priority.
+	Priority *int `json:"priority"`
+}
+
+// authed resolves the request's tenant before calling h.
+func (s *Server) authed(h func(http.ResponseWriter, *http.Request, *Tenant)) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		t, ok := s.tenant(r)
+		if !ok {
+			w.Header().Set("WWW-Authenticate", "Bearer")
+			writeError(w, http.StatusUnauthorized, "missing or unknown API key")
+			return
+		}
+		h(w, r, t)
+	}
+}
+
+func (s *Server) createScan(w http.ResponseWriter, r *http.Request, t *Tenant) {
+	var req scanRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, "invalid JSON body: "+err.Error())
+		return
+	}
+	pubkey, err := exifscan.DecodePubkey(req.Npub)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "invalid npub: "+err.Error())
+		return
+	}
+	since, err := parseTime(req.Since)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "invalid since: "+err.Error())
+		return
+	}
+	until, err := parseTime(req.Until)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "invalid until: "+err.Error())
+		return
+	}
+
+	priority := t.Priority
+	if req.Priority != nil {
+		priority = *req.Priority
+	}
+	job, err := s.jobs.submit(t, priority, req.Npub, pubkey, since, until)
+	var qe *quotaError
+	if errors.As(err, &qe) {
+		w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(qe.retryAfter.Seconds()))))
+		writeError(w, http.StatusTooManyRequests, qe.msg)
+		return
+	}
+	w.Header().Set("Location", "/scans/"+job.ID)
+	writeJSON(w, http.StatusAccepted, job)
+}
+
+func (s *Server) listScans(w http.ResponseWriter, r *http.Request, t *Tenant) {
+	writeJSON(w, http.StatusOK, s.jobs.list(t.Name))
+}
+
+func (s *Server) getScan(w http.ResponseWriter, r *http.Request, t *Tenant) {
+	job, ok := s.jobs.get(t.Name, r.PathValue("id"))
+	if !ok {
+		writeError(w, http.StatusNotFound, "scan not found")
+		return
+	}
+	writeJSON(w, http.StatusOK, job)
+}
+
+func (s *Server) getReport(w http.ResponseWriter, r *http.Request, t *Tenant) {
+	job, ok := s.jobs.get(t.Name, r.PathValue("id"))
+	if !ok {
+		writeError(w, http.StatusNotFound, "scan not found")
+		return
+	}
+	if job.Status != StatusDone {
+		writeError(w, http.StatusConflict, "scan is "+job.Status)
+		return
+	}
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	report.HTML(w, report.Data{
+		Npub:        job.Npub,
+		Since:       job.Since,
+		Until:       job.Until,
+		GeneratedAt: job.FinishedAt,
+		Findings:    job.Findings,
+	})
+}
+
+func (s *Server) getDashboard(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.Write(dashboardHTML)
+}
+
+func (s *Server) getSpec(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/yaml")
+	w.Write(openAPISpec)
+}
+
+func (s *Server) getSchema(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/schema+json")
+	w.Write(exifscan.Schema)
+}
+
+func parseTime(s string) (time.Time, error) {
+	return exifscan.ParseTime(s, time.Now())
+}
+
+func writeJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
+
+func writeError(w http.ResponseWriter, status int, msg string) {
+	writeJSON(w, status, map[string]string{"error": msg})
+}
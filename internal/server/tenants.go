@@ -0,0 +1,99 @@
+package server
+
+import (
+	"crypto/subtle"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Tenant is an API key of a shared server and the limits applied to it.
+type Tenant struct {
+	Name string `json:"name"`
+	Key  string `json:"key"`
+	// Priority orders queued scans, higher first. Requests may ask for a
+	// lower priority but never a higher one.
+	Priority int `json:"priority,omitempty"`
+	// PerHour rate limits the scans submitted per hour (0: unlimited).
+	PerHour int `json:"per_hour,omitempty"`
+	// PerDay is the quota of scans per 24 hours (0: unlimited).
+	PerDay int `json:"per_day,omitempty"`
+	// MaxQueued caps the tenant's queued and running scans (0: unlimited).
+	MaxQueued int `json:"max_queued,omitempty"`
+}
+
+// anonymous is the tenant of every request on a server without API keys.
+var anonymous = &Tenant{}
+
+// tenant returns the tenant whose key the request carries, as a Bearer
+// token or in X-API-Key. Without configured tenants every request is
+// anonymous.
+func (s *Server) tenant(r *http.Request) (*Tenant, bool) {
+	if len(s.tenants) == 0 {
+		return anonymous, true
+	}
+	key := r.Header.Get("X-API-Key")
+	if auth := r.Header.Get("Authorization"); key == "" && strings.HasPrefix(auth, "Bearer ") {
+		key = strings.TrimPrefix(auth, "Bearer ")
+	}
+	if key == "" {
+		return nil, false
+	}
+	for i := range s.tenants {
+		if subtle.ConstantTimeCompare([]byte(key), []byte(s.tenants[i].Key)) == 1 {
+			return &s.tenants[i], true
+		}
+	}
+	return nil, false
+}
+
+// quotaError is returned by jobs.submit when a tenant is over a limit.
+type quotaError struct {
+	msg        string
+	retryAfter time.Duration
+}
+
+func (e *quotaError) Error() string { return e.msg }
+
+// checkQuota applies t's limits to its jobs. Callers must hold js.mu.
+func (js *jobs) checkQuota(t *Tenant, now time.Time) error {
+	if t.PerHour == 0 && t.PerDay == 0 && t.MaxQueued == 0 {
+		return nil
+	}
+	var active int
+	var lastHour, lastDay []time.Time
+	for _, job := range js.byID {
+		if job.Tenant != t.Name {
+			continue
+		}
+		if job.Status == StatusQueued || job.Status == StatusRunning {
+			active++
+		}
+		if age := now.Sub(job.CreatedAt); age < 24*time.Hour {
+			lastDay = append(lastDay, job.CreatedAt)
+			if age < time.Hour {
+				lastHour = append(lastHour, job.CreatedAt)
+			}
+		}
+	}
+	if t.MaxQueued > 0 && active >= t.MaxQueued {
+		return &quotaError{msg: fmt.Sprintf("%d scans already queued or running", active), retryAfter: time.Minute}
+	}
+	if t.PerHour > 0 && len(lastHour) >= t.PerHour {
+		return &quotaError{msg: fmt.Sprintf("rate limit of %d scans per hour reached", t.PerHour), retryAfter: untilFree(lastHour, t.PerHour, time.Hour, now)}
+	}
+	if t.PerDay > 0 && len(lastDay) >= t.PerDay {
+		return &quotaError{msg: fmt.Sprintf("daily quota of %d scans used up", t.PerDay), retryAfter: untilFree(lastDay, t.PerDay, 24*time.Hour, now)}
+	}
+	return nil
+}
+
+// untilFree returns how long until fewer than limit of times fall within
+// window before now.
+func untilFree(times []time.Time, limit int, window time.Duration, now time.Time) time.Duration {
+	// The limit frees up when the len(times)-limit+1 oldest have aged out.
+	sort.Slice(times, func(i, j int) bool { return times[i].Before(times[j]) })
+	return times[len(times)-limit].Add(window).Sub(now)
+}
@@ -0,0 +1,39 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"maps"
+	"slices"
+	"strings"
+
+	"nostr-exif-scan/internal/report"
+	"nostr-exif-scan/pkg/exifscan"
+)
+
+func linkTemplateFlag(fs *flag.FlagSet) *string {
+	names := slices.Sorted(maps.Keys(report.LinkTemplates))
+	return fs.String("link-template", "primal", "Web client to link posts in: "+strings.Join(names, ", ")+", or a URL with {nevent}, {note} or {id}")
+}
+
+// setLinkTemplate applies --link-template, reporting a bad template.
+func setLinkTemplate(t string) bool {
+	if err := report.SetLinkTemplate(t); err != nil {
+		fmt.Println("\033[31m❌ Invalid --link-template:\033[0m", err)
+		return false
+	}
+	return true
+}
+
+func coordFormatFlag(fs *flag.FlagSet) *string {
+	return fs.String("coord-format", exifscan.CoordDecimal, "Notation GPS findings are printed and exported in: "+strings.Join(exifscan.CoordFormats, ", "))
+}
+
+// setCoordFormat applies --coord-format, reporting an unknown format.
+func setCoordFormat(name string) bool {
+	if err := exifscan.SetCoordFormat(name); err != nil {
+		fmt.Println("\033[31m❌ Invalid --coord-format:\033[0m", err)
+		return false
+	}
+	return true
+}
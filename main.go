@@ -4,268 +4,950 @@ import (
 	"bufio"
 	"bytes"
 	"context"
+	"errors"
 	"flag"
 	"fmt"
 	"io"
-	"net/http"
 	"os"
-	"regexp"
-	"sort"
+	"path/filepath"
 	"strings"
-	"sync"
+	"sync/atomic"
 	"time"
 
-	exif "github.com/rwcarlsen/goexif/exif"
 	"github.com/nbd-wtf/go-nostr"
 	"github.com/nbd-wtf/go-nostr/nip19"
+
+	"nostr-exif-scan/internal/dm"
+	"nostr-exif-scan/internal/export"
+	"nostr-exif-scan/internal/mailer"
+	"nostr-exif-scan/internal/publish"
+	"nostr-exif-scan/internal/report"
+	"nostr-exif-scan/pkg/exifscan"
 )
 
 var (
-	npubFlag   = flag.String("npub", "", "npub1... public key (required)")
-	threads    = flag.Int("threads", 8, "Number of parallel workers (max 32)")
-	limit      = flag.Int("limit", 10000, "Maximum number of events to fetch")
-	sinceFlag  = flag.String("since", "", "Only fetch events after this RFC3339 timestamp")
-	untilFlag  = flag.String("until", "", "Only fetch events before this RFC3339 timestamp")
-	verbose    = flag.Bool("v", false, "Verbose output: show full EXIF details")
-	maxThreads = 32
+	npubFlag       = flag.String("npub", "", "npub1... public key (required)")
+	threads        = threadsFlag(flag.CommandLine, "Number of parallel workers")
+	rps            = rpsFlag(flag.CommandLine)
+	limit          = flag.Int("limit", 10000, "Maximum number of events to fetch")
+	last           = flag.Int("last", 0, "Only scan the N most recent notes, whatever their dates")
+	geohashFlag    = flag.String("geohash", "", "Only scan notes g-tagged inside these comma separated geohash cells (e.g. u33d,u09t)")
+	contentMatch   = flag.String("content-match", "", "Only scan notes whose text matches this regular expression, case-insensitively (e.g. 'vacation|holiday')")
+	onlyMedia      = flag.Bool("only-media-events", false, "Drop notes that neither carry imeta tags nor link an image as they are fetched, to hold fewer in memory")
+	includeCW      = flag.Bool("include-sensitive-content", false, "Also download the images of notes with a NIP-36 content warning, which are skipped by default")
+	noReplies      = flag.Bool("no-replies", false, "Skip replies and only scan top-level posts")
+	contentExclude = flag.String("content-exclude", "", "Skip notes whose text matches this regular expression, case-insensitively (e.g. '#meme')")
+	maxInFlight    = flag.Int64("max-inflight", exifscan.DefaultMaxInFlightBytes>>20, "MiB of downloaded images held in memory at once")
+	proxyOrigins   = flag.Bool("proxy-origins", true, "Also scan the original behind image proxy and resizer links (wsrv.nl, imgproxy, Cloudflare...)")
+	wayback        = flag.Bool("wayback", false, "Scan the Internet Archive's snapshot of images whose links are dead")
+	spoolMiB       = flag.Int64("spool", exifscan.DefaultSpoolBytes>>20, "Write downloads bigger than this many MiB to a temp file ($TMPDIR) instead of memory; 0 never does")
+	maxSpoolMiB    = flag.Int64("max-spool", exifscan.DefaultMaxSpoolBytes>>20, "Fail downloads that grow past this many MiB on disk; 0 never does")
+	ocrFlag        = flag.Bool("ocr", false, "Read the text visible in images with tesseract, for burned-in GPS overlays, street signs and date stamps")
+	ocrLang        = flag.String("ocr-lang", "", "Tesseract languages for --ocr, e.g. eng+deu (default: tesseract's own)")
+	stego          = flag.Bool("stego", false, "Also look for data appended after the end of images and for LSB embedding in PNGs, reported as informational hints")
+	maxArchiveMiB  = flag.Int64("max-archive", exifscan.DefaultMaxArchiveBytes>>20, "Open linked .zip archives up to this many MiB and scan the files inside; 0 never does")
+	sinceFlag      = sinceFlagFor(flag.CommandLine)
+	untilFlag      = untilFlagFor(flag.CommandLine)
+	verbose        = verbosityFlag(flag.CommandLine)
+	archiveDir     = flag.String("archive", "", "Save flagged images, their EXIF and source events to this directory")
+	dumpDir        = flag.String("dump-exif", "", "Write the full decoded EXIF of every image with metadata to this directory")
+	dumpAllTags    = flag.Bool("dump-all-tags", false, "List every decoded EXIF tag of every image with metadata, not just the sensitive ones")
+	reportPath     = flag.String("report", "", "Write an HTML (.html) or Markdown (.md) report to this file")
+	encryptTo      = flag.String("encrypt-to", "", "Encrypt the --report for this npub (NIP-44) or these age1... recipients; only the encrypted copy is written")
+	reportBlossom  = flag.Bool("publish-report-blossom", false, "Upload the --report to --blossom, or your first kind 10063 Blossom server, and print its URL")
+	signReport     = flag.Bool("sign-report", false, "Sign the --report with the --sign-with key, so a third party can verify who produced it and when")
+	emailFlag      = flag.Bool("email", false, "Email the report using the smtp section of the config file")
+	configPath     = flag.String("config", defaultConfigPath, "Path to the JSON config file")
+	dmFlag         = flag.Bool("dm", false, "Send the scanned user a NIP-17 DM listing the leaking posts")
+	publishReports = flag.Bool("publish-reports", false, "Publish NIP-56 (kind 1984) privacy reports for leaking posts")
+	publishLabels  = flag.Bool("publish-labels", false, "Publish NIP-32 (kind 1985) exif-scan labels for leaking posts")
+	publishResult  = flag.Bool("publish-result", false, "Publish the scan's summary, encrypted for the scanned account, as an addressable event replacing its previous result")
+	resultKind     = flag.Int("result-kind", publish.KindResult, "Addressable kind (30000-39999) of --publish-result events")
+	hosted         = flag.Bool("hosted", false, "Also scan images on the user's Blossom servers that no note links to")
+	reviewFlag     = flag.Bool("review", false, "Review the leaking posts one by one after the scan: open, ignore, delete or re-upload them clean")
+	baselinePath   = baselineFlag(flag.CommandLine)
+	filters        = filterFlags(flag.CommandLine)
+	sortSpec       = sortFlag(flag.CommandLine)
+	parquetDir     = flag.String("parquet", "", "Write images.parquet and findings.parquet to this directory")
+	signWith       = signerFlag(flag.CommandLine)
+	pprofListen    = pprofFlag(flag.CommandLine)
+	linkTmpl       = linkTemplateFlag(flag.CommandLine)
+	tz             = tzFlag(flag.CommandLine)
+	coordFmt       = coordFormatFlag(flag.CommandLine)
+	dryRun         = flag.Bool("dry-run", false, "Fetch the posts and list what would be downloaded, sized with HEAD requests, without downloading any image")
+	cost           = costFlags(flag.CommandLine)
+	perEvent       = flag.Int("max-images-per-event", 0, "Scan at most this many images of a single note; 0 doesn't cap")
+	sampleShare    = flag.String("sample", "", "Scan a random share of the image links, e.g. 10% or 0.1, and extrapolate the leak rate")
+	sampleN        = flag.Int("sample-n", 0, "Scan this many random image links and extrapolate the leak rate")
+	perHost        = flag.Int("max-images-per-host", 0, "Scan at most this many images from a single host; 0 doesn't cap")
+	inventory      = flag.Bool("inventory", false, "List every scanned image with its verdict (clean, leaking or unreachable), in the console and the report")
+	ordered        = flag.Bool("ordered", false, "Print the results in the order of the posts rather than as downloads finish, holding back those done early")
+	reverseSearch  = flag.Bool("reverse-search", false, "Link Google Lens, Bing and Yandex reverse image searches for the high-severity leaks in the report, to see where else the photos spread")
+	onError        = flag.String("on-error", onErrorContinue, "What unreachable relays and failed downloads do: continue the scan, or fail it right away with exit status 2")
+	maxFailures    = flag.String("max-failures", "", "Abort the scan once this many downloads failed, or this share of them, e.g. 50 or 30%")
+	profile        = flag.Bool("profile", true, "Also check the profile's about, website, lud16 and nip05 for phone numbers, emails, street addresses and real names")
 )
 
+// Media servers for the clean copies --review re-uploads.
+var blossomServer, nip96Server = mediaFlags(flag.CommandLine)
+
 func main() {
+	os.Args = leadingPlain(os.Args)
+	defer stopPlain()
+	if len(os.Args) > 1 {
+		switch os.Args[1] {
+		case "check":
+			exit(runCheck(os.Args[2:]))
+		case "serve":
+			exit(runServe(os.Args[2:]))
+		case "watch":
+			exit(runWatch(os.Args[2:]))
+		case "dvm":
+			exit(runDVM(os.Args[2:]))
+		case "remediate":
+			exit(runRemediate(os.Args[2:]))
+		case "upload":
+			exit(runUpload(os.Args[2:]))
+		case "daemon":
+			exit(runDaemon(os.Args[2:]))
+		case "worker":
+			exit(runWorker(os.Args[2:]))
+		case "mcp":
+			exit(runMCP(os.Args[2:]))
+		case "compare":
+			exit(runCompare(os.Args[2:]))
+		case "state":
+			exit(runState(os.Args[2:]))
+		case "verify":
+			exit(runVerify(os.Args[2:]))
+		case "bench":
+			exit(runBench(os.Args[2:]))
+		case "decrypt":
+			exit(runDecrypt(os.Args[2:]))
+		}
+	}
+
 	flag.Usage = func() {
 		fmt.Fprintf(os.Stderr, "Usage of %s:\n", os.Args[0])
 		flag.PrintDefaults()
 		fmt.Println("\nExample:")
 		fmt.Printf("  %s --npub npub1... --threads 8 --limit 5000 --since 2024-01-01T00:00:00Z --until 2025-01-01T00:00:00Z -v\n", os.Args[0])
+		fmt.Printf("  %s check image.jpg\n", os.Args[0])
+		fmt.Printf("  %s check --format json - < image.jpg\n", os.Args[0])
+		fmt.Printf("  %s check --format github assets/\n", os.Args[0])
+		fmt.Printf("  %s serve --listen :8080\n", os.Args[0])
+		fmt.Printf("  %s watch --npub npub1...,npub1...\n", os.Args[0])
+		fmt.Printf("  %s watch --tag foodpics\n", os.Args[0])
+		fmt.Printf("  %s dvm --sign-with bunker://...\n", os.Args[0])
+		fmt.Printf("  %s remediate\n", os.Args[0])
+		fmt.Printf("  %s upload --strip photo.jpg\n", os.Args[0])
+		fmt.Printf("  %s worker --queue redis://localhost:6379/0\n", os.Args[0])
+		fmt.Printf("  %s mcp\n", os.Args[0])
+		fmt.Printf("  %s compare --follows npub1... --report compare.html\n", os.Args[0])
+		fmt.Printf("  %s daemon --schedule \"0 3 * * *\" --npub npub1...\n", os.Args[0])
+		fmt.Printf("  %s state export audit.tar.gz\n", os.Args[0])
+		fmt.Printf("  %s verify --from-run run-<pubkey>-1735700000 --report progress.html\n", os.Args[0])
+		fmt.Printf("  %s bench --rounds 5 testdata/\n", os.Args[0])
+		fmt.Printf("  %s decrypt report.html.nip44\n", os.Args[0])
 	}
 
+	plainFlag(flag.CommandLine)
 	flag.Parse()
+	if plainRequested {
+		startPlain()
+	}
 	if len(os.Args) == 1 {
 		flag.Usage()
-		os.Exit(1)
+		exit(1)
 	}
 
 	if *npubFlag == "" {
 		fmt.Println("\033[31m❌ Please provide --npub\033[0m")
-		os.Exit(1)
+		exit(1)
 	}
-	if *threads < 1 || *threads > maxThreads {
-		fmt.Printf("\033[31m❌ --threads must be between 1 and %d\033[0m\n", maxThreads)
-		os.Exit(1)
+	if !setLinkTemplate(*linkTmpl) || !setTimezone(*tz) || !setCoordFormat(*coordFmt) {
+		exit(1)
 	}
-
-	pubkey, err := decodeNpub(*npubFlag)
+	if _, err := sampleSize(*sampleShare, *sampleN, 0); err != nil {
+		fmt.Println("\033[31m❌ Invalid sample:\033[0m", err)
+		exit(1)
+	}
+	failLimit, err := parseMaxFailures(*maxFailures)
+	if err != nil {
+		fmt.Println("\033[31m❌ Invalid --max-failures:\033[0m", err)
+		exit(1)
+	}
+	if *onError != onErrorContinue && *onError != onErrorFail {
+		fmt.Printf("\033[31m❌ Invalid --on-error %q, want %s or %s\033[0m\n", *onError, onErrorContinue, onErrorFail)
+		exit(1)
+	}
+	failFast := *onError == onErrorFail
+	switch {
+	case (*signReport || *reportBlossom) && *reportPath == "":
+		fmt.Println("\033[31m❌ --sign-report and --publish-report-blossom need --report\033[0m")
+		exit(1)
+	case *signReport && strings.EqualFold(filepath.Ext(*reportPath), ".json"):
+		// The signature is an HTML comment, which JSON has no room for.
+		fmt.Println("\033[31m❌ --sign-report signs HTML and Markdown reports, not JSON\033[0m")
+		exit(1)
+	}
+	if *resultKind < 30000 || *resultKind > 39999 {
+		fmt.Println("\033[31m❌ --result-kind must be an addressable kind, 30000 to 39999\033[0m")
+		exit(1)
+	}
+	cipher, err := parseEncryptTo(*encryptTo)
+	switch {
+	case err != nil:
+		fmt.Println("\033[31m❌ Invalid --encrypt-to:\033[0m", err)
+		exit(1)
+	case cipher != nil && *reportPath == "":
+		fmt.Println("\033[31m❌ --encrypt-to needs --report\033[0m")
+		exit(1)
+	case cipher != nil && *emailFlag:
+		// Mail would carry the report in clear.
+		fmt.Println("\033[31m❌ --encrypt-to can't be combined with --email; send the encrypted file instead\033[0m")
+		exit(1)
+	}
+	pubkey, err := exifscan.DecodePubkey(*npubFlag)
 	if err != nil {
 		fmt.Println("\033[31m❌ Invalid npub:\033[0m", err)
-		os.Exit(1)
+		exit(1)
+	}
+	cfg, err := loadConfig(*configPath)
+	if err != nil {
+		fmt.Println("\033[31m❌ Cannot load config:\033[0m", err)
+		exit(1)
+	}
+	base, err := loadBaseline(*baselinePath)
+	if err != nil {
+		fmt.Println("\033[31m❌ Cannot load baseline:\033[0m", err)
+		exit(1)
+	}
+	filter, err := filters.parse()
+	if err != nil {
+		fmt.Println("\033[31m❌", err, "\033[0m")
+		exit(1)
+	}
+	sortFindings, err := parseSort(*sortSpec)
+	if err != nil {
+		fmt.Println("\033[31m❌", err, "\033[0m")
+		exit(1)
+	}
+	geohashes, err := parseGeohashes(*geohashFlag)
+	if err != nil {
+		fmt.Println("\033[31m❌ Invalid --geohash:\033[0m", err)
+		exit(1)
+	}
+	matchRE, err := contentRegexp(*contentMatch)
+	if err != nil {
+		fmt.Println("\033[31m❌ Invalid --content-match:\033[0m", err)
+		exit(1)
+	}
+	excludeRE, err := contentRegexp(*contentExclude)
+	if err != nil {
+		fmt.Println("\033[31m❌ Invalid --content-exclude:\033[0m", err)
+		exit(1)
 	}
 
-	relays := loadRelays("relays.txt")
-	events := fetchEvents(pubkey, relays)
+	// --spool 0 keeps everything in memory, which the library spells -1.
+	spool := *spoolMiB << 20
+	if spool == 0 {
+		spool = -1
+	}
+	maxSpool := *maxSpoolMiB << 20
+	if maxSpool == 0 {
+		maxSpool = -1
+	}
+	maxArchive := *maxArchiveMiB << 20
+	if maxArchive == 0 {
+		maxArchive = -1
+	}
+	ctx, stopRun := context.WithCancel(context.Background())
+	defer stopRun()
+	var relayFailed atomic.Bool
+	opts := exifscan.Options{
+		Relays:           loadRelays("relays.txt"),
+		Limit:            *limit,
+		Last:             *last,
+		Geohashes:        geohashes,
+		ContentMatch:     matchRE,
+		ContentExclude:   excludeRE,
+		NoReplies:        *noReplies,
+		OnlyMediaEvents:  *onlyMedia,
+		Threads:          *threads,
+		RateLimit:        exifscan.NewRateLimit(*rps),
+		KeepData:         *archiveDir != "" || *dumpDir != "" || *verbose >= verboseExif,
+		MaxInFlightBytes: *maxInFlight << 20,
+		SpoolBytes:       spool,
+		MaxSpoolBytes:    maxSpool,
+		MaxArchiveBytes:  maxArchive,
+		Hooks: exifscan.Hooks{
+			OnError: func(err error) {
+				var se *exifscan.ScanError
+				if !errors.As(err, &se) {
+					return
+				}
+				switch se.Stage {
+				case exifscan.StageRelay:
+					if relayFailed.Load() {
+						// Cut off by stopRun, not unreachable.
+						return
+					}
+					console.printf("⚠️  Relay unreachable \033[33m%s\033[0m: %v\n", se.URL, se.Err)
+					if failFast {
+						relayFailed.Store(true)
+						stopRun()
+					}
+				case exifscan.StageOCR:
+					console.printf("⚠️  OCR failed on \033[33m%s\033[0m: %v\n", se.URL, se.Err)
+				}
+			},
+		},
+	}
+	traceRelays(&opts.Hooks, *verbose)
+	if opts.Since, opts.Until, err = timeRange(*sinceFlag, *untilFlag); err != nil {
+		fmt.Println("\033[31m❌", err, "\033[0m")
+		exit(1)
+	}
+	opts.ProxyOrigins = *proxyOrigins
+	opts.AllTags = *dumpAllTags
+	opts.Stego = *stego
+	if *ocrFlag {
+		t := exifscan.Tesseract{Languages: *ocrLang}
+		if err := t.Check(ctx); err != nil {
+			fmt.Println("\033[31m❌ --ocr needs tesseract installed:\033[0m", err)
+			exit(1)
+		}
+		opts.OCR = t
+	}
+	if *wayback {
+		opts.WaybackURL = exifscan.DefaultWaybackURL
+	}
+	scanner := exifscan.New(opts)
+	servePprof(*pprofListen)
+
+	// Load the signer before the scan, so a bunker asks for approval
+	// while the operator is still watching.
+	var operator, reportSigner nostr.Keyer
+	if *signReport || *reportBlossom || *publishResult {
+		if operator, err = loadSigner(ctx, nostr.NewSimplePool(ctx), *signWith, cfg.Signer); err != nil {
+			fmt.Println("\033[31m❌ Cannot load signer:\033[0m", err)
+			exit(1)
+		}
+	}
+	if *signReport {
+		reportSigner = operator
+	}
+	events, err := scanner.FetchEvents(ctx, pubkey)
+	if relayFailed.Load() {
+		fmt.Println("🛑 \033[31mStopping: a relay is unreachable (--on-error fail)\033[0m")
+		exit(exitFailFast)
+	}
+	if err != nil {
+		fmt.Println("\033[31m❌ Fetching posts failed:\033[0m", err)
+		exit(1)
+	}
 	if len(events) == 0 {
 		fmt.Println("ℹ️  No posts found.")
 		return
 	}
 
-	sort.Slice(events, func(i, j int) bool {
-		return events[i].CreatedAt < events[j].CreatedAt
-	})
-
-	first := time.Unix(int64(events[0].CreatedAt), 0).Format(time.RFC3339)
-	last := time.Unix(int64(events[len(events)-1].CreatedAt), 0).Format(time.RFC3339)
+	first := exifscan.InDisplayZone(events[0].CreatedAt.Time()).Format(time.RFC3339)
+	last := exifscan.InDisplayZone(events[len(events)-1].CreatedAt.Time()).Format(time.RFC3339)
 
 	fmt.Printf("📚 Total posts: \033[36m%d\033[0m\n", len(events))
 	fmt.Printf("📅 Oldest post: \033[36m%s\033[0m\n", first)
 	fmt.Printf("📅 Newest post: \033[36m%s\033[0m\n", last)
 
-	imagePosts := extractImageLinks(events)
-	fmt.Printf("📸 Found \033[36m%d\033[0m image links\n", len(imagePosts))
-	scanImages(imagePosts, *threads, *verbose)
-}
+	var profileLeaks []exifscan.Tag
+	if *profile {
+		evt, _ := scanner.FetchProfile(ctx, pubkey)
+		profileLeaks = exifscan.ProfileLeaks(evt)
+	}
 
-type imagePost struct {
-	ID  string
-	URL string
-}
+	images := exifscan.ExtractImages(events)
+	fmt.Printf("📸 Found \033[36m%d\033[0m image links\n", len(images))
+	if *hosted {
+		pool := nostr.NewSimplePool(ctx)
+		signer := optionalSigner(ctx, pool, *signWith, cfg.Signer)
+		images = append(images, hostedImages(ctx, pool, opts.Relays, pubkey, images, signer)...)
+	}
+	if !*includeCW {
+		if kept, dropped := exifscan.SkipContentWarnings(images); dropped > 0 {
+			images = kept
+			fmt.Printf("🙈 Skipping \033[33m%d\033[0m image links of notes with a content warning (--include-sensitive-content scans them)\n", dropped)
+		}
+	}
+	if kept, dropped := exifscan.CapImages(images, *perEvent, *perHost); dropped > 0 {
+		images = kept
+		fmt.Printf("✂️  Skipping \033[33m%d\033[0m image links over --max-images-per-event or --max-images-per-host\n", dropped)
+	}
+	population := len(images)
+	n, _ := sampleSize(*sampleShare, *sampleN, population)
+	var extrapolate bool
+	if n > 0 && n < population {
+		images, extrapolate = exifscan.SampleImages(images, n), true
+		fmt.Printf("🎲 Scanning a random sample of \033[36m%d\033[0m of %d image links\n", n, population)
+	}
+	if *dryRun {
+		printPlan(scanner.Plan(ctx, images, 0))
+		return
+	}
+	if !cost.confirm(ctx, scanner, images) {
+		fmt.Println("Nothing scanned.")
+		return
+	}
 
-func decodeNpub(npub string) (string, error) {
-	_, data, err := nip19.Decode(npub)
-	if err != nil {
-		return "", err
+	var arc *archive
+	if *archiveDir != "" {
+		arc, err = newArchive(*archiveDir, *npubFlag)
+		if err != nil {
+			fmt.Println("\033[31m❌ Cannot create archive:\033[0m", err)
+			exit(1)
+		}
+	}
+	if *dumpDir != "" {
+		if err := os.MkdirAll(*dumpDir, 0o700); err != nil {
+			fmt.Println("\033[31m❌ Cannot create dump directory:\033[0m", err)
+			exit(1)
+		}
 	}
-	return data.(string), nil
-}
 
-func loadRelays(path string) []string {
-	file, err := os.Open(path)
-	if err != nil {
-		return []string{
-			"wss://relay.nostr.band",
-			"wss://nos.lol",
-			"wss://relay.snort.social",
+	var pq *export.Parquet
+	if *parquetDir != "" {
+		if pq, err = export.NewParquet(*parquetDir); err != nil {
+			fmt.Println("\033[31m❌ Cannot create Parquet files:\033[0m", err)
+			exit(1)
 		}
 	}
-	defer file.Close()
-	scanner := bufio.NewScanner(file)
-	var relays []string
-	for scanner.Scan() {
-		relay := strings.TrimSpace(scanner.Text())
-		if relay != "" {
-			relays = append(relays, relay)
+
+	findings := &exifscan.Findings{Pubkey: pubkey, Events: len(events), Profile: profileLeaks}
+	accepted, failed := 0, 0
+	aborted := false
+	scanCtx, stopScan := context.WithCancel(ctx)
+	defer stopScan()
+	// seqs numbers the links in the order of the posts, for --ordered.
+	seqs := map[string][]int{}
+	for i, img := range images {
+		seqs[img.EventID+" "+img.URL] = append(seqs[img.EventID+" "+img.URL], i)
+	}
+	console.start(*ordered)
+	for r := range scanner.ScanImages(scanCtx, images) {
+		findings.Images = append(findings.Images, r)
+		done := len(findings.Images)
+		seq := -1
+		if q := seqs[r.EventID+" "+r.URL]; len(q) > 0 {
+			seq, seqs[r.EventID+" "+r.URL] = q[0], q[1:]
+		}
+		n := done
+		if *ordered && seq >= 0 {
+			n = seq + 1
+		}
+		var out strings.Builder
+		fmt.Fprintf(&out, "[%d/%d] 🔎 Checking \033[36m%s\033[0m\n", n, len(images), r.URL)
+		if base.apply(r) {
+			accepted++
+		}
+		filter.apply(r)
+		if pq != nil {
+			if err := pq.Add(pubkey, r); err != nil {
+				console.printf("\033[31m❌ Writing Parquet failed:\033[0m %v\n", err)
+				exit(1)
+			}
+		}
+		if sortFindings == nil || r.Err != nil {
+			printResult(&out, r, *verbose)
+		}
+
+		if r.Exif != nil && *dumpDir != "" {
+			if err := writeExifDump(*dumpDir, r); err != nil {
+				fmt.Fprintf(&out, "    ❌ EXIF dump failed for \033[31m%s\033[0m: %v\n", r.URL, err)
+			}
+		}
+		if r.Sensitive() && arc != nil {
+			if err := arc.add(r); err != nil {
+				fmt.Fprintf(&out, "    ❌ Archive failed for \033[31m%s\033[0m: %v\n", r.URL, err)
+			}
+		}
+		console.result(seq, out.String())
+		if sortFindings == nil {
+			r.Discard()
+		}
+		if r.Err != nil {
+			failed++
+		}
+		if failFast && systemicFailure(r) {
+			console.printf("🛑 \033[31mStopping: downloading %s failed (%s, --on-error fail)\033[0m\n", r.URL, exifscan.FailureLabel(r.ErrorClass))
+			exit(exitFailFast)
+		}
+		if failLimit.exceeded(failed, done, len(images)) {
+			aborted = true
+			stopScan()
+			break
 		}
 	}
-	return relays
+	console.stop()
+	if sortFindings != nil {
+		// Sorted results are printed once all are in; failures were
+		// already.
+		sortFindings(findings.Images)
+		fmt.Printf("📋 Results by \033[36m%s\033[0m:\n", *sortSpec)
+		for _, r := range findings.Images {
+			if r.Err == nil {
+				fmt.Printf("🖼️  \033[36m%s\033[0m\n", r.URL)
+				printResult(os.Stdout, r, *verbose)
+			}
+			r.Discard()
+		}
+	}
+	printPosts(report.Posts(findings.Images), *verbose)
+	printProfile(findings.Profile)
+	printFailures(findings.Failed())
+	if aborted {
+		fmt.Printf("🛑 \033[31mScan aborted after %d of %d downloads failed (--max-failures %s), %d images left unscanned.\033[0m\n", failed, len(findings.Images), *maxFailures, len(images)-len(findings.Images))
+		fmt.Println("   " + diagnoseFailures(findings.Failed()))
+	}
+	if *inventory {
+		printInventory(findings.Images)
+	}
+	summary := report.Summarize(findings)
+	printSummary(summary)
+	var sample *report.Extrapolation
+	if extrapolate {
+		e := report.Extrapolate(summary, population)
+		sample = &e
+		fmt.Printf("🎲 Extrapolated: %s\n", e)
+	}
+	if tl := report.Timeline(findings.Images); len(tl) > 0 {
+		fmt.Printf("📈 Leaking posts per month, %s:\n   %s\n", report.Span(tl), report.Sparkline(tl))
+	}
+	if accepted > 0 {
+		fmt.Printf("🙈 \033[36m%d\033[0m images had findings suppressed by %s\n", accepted, *baselinePath)
+	}
+	if *threads == exifscan.AutoThreads {
+		fmt.Printf("⚙️  --threads auto settled at \033[36m%d\033[0m parallel downloads\n", scanner.Threads())
+	}
+	if pq != nil {
+		if err := pq.Close(); err != nil {
+			fmt.Println("\033[31m❌ Writing Parquet failed:\033[0m", err)
+			exit(1)
+		}
+		fmt.Printf("🧮 Parquet files written to \033[36m%s\033[0m\n", *parquetDir)
+	}
+	if arc != nil {
+		fmt.Printf("🗄️  Evidence archived in \033[36m%s\033[0m\n", arc)
+	}
+
+	rd := report.Data{Npub: *npubFlag, Since: opts.Since, Until: opts.Until, GeneratedAt: time.Now(), Findings: findings, Inventory: *inventory, Sample: sample, ReverseSearch: *reverseSearch}
+	if *reportPath != "" {
+		var params nostr.Tags
+		if reportSigner != nil {
+			pk, err := reportSigner.GetPublicKey(ctx)
+			if err != nil {
+				fmt.Println("\033[31m❌ Cannot load signer:\033[0m", err)
+				exit(1)
+			}
+			rd.Auditor, _ = nip19.EncodePublicKey(pk)
+			params = scanParams(pubkey, opts.Relays, opts.Since, opts.Until)
+		}
+		path, body, err := writeReport(ctx, *reportPath, rd, reportSigner, params, cipher)
+		if err != nil {
+			fmt.Println("\033[31m❌ Writing report failed:\033[0m", err)
+			exit(1)
+		}
+		switch {
+		case cipher != nil:
+			fmt.Printf("🔐 Encrypted report written to \033[36m%s\033[0m\n", path)
+		case reportSigner != nil:
+			fmt.Printf("🔏 Report signed by \033[36m%s\033[0m and written to \033[36m%s\033[0m\n", rd.Auditor, path)
+		default:
+			fmt.Printf("📝 Report written to \033[36m%s\033[0m\n", path)
+		}
+		if *reportBlossom {
+			url, err := uploadReport(ctx, opts.Relays, operator, *blossomServer, path, body)
+			if err != nil {
+				fmt.Println("\033[31m❌ Uploading report failed:\033[0m", err)
+				exit(1)
+			}
+			fmt.Printf("☁️  Report uploaded: \033[36m%s\033[0m\n", url)
+		}
+	}
+	rd.Auditor = ""
+	if *emailFlag {
+		if err := emailReport(cfg.SMTP, rd); err != nil {
+			fmt.Println("\033[31m❌ Emailing report failed:\033[0m", err)
+			exit(1)
+		}
+		fmt.Printf("📧 Report emailed to \033[36m%s\033[0m\n", strings.Join(cfg.SMTP.To, ", "))
+	}
+	if aborted {
+		// An incomplete scan isn't worth telling the author about.
+		exit(1)
+	}
+	if *publishResult {
+		pub := publish.New(operator, nostr.NewSimplePool(ctx), opts.Relays)
+		if err := pub.PublishResult(ctx, *resultKind, publish.Summarize(findings, rd.GeneratedAt)); err != nil {
+			fmt.Println("\033[31m❌ Publishing the result failed:\033[0m", err)
+		} else {
+			fmt.Printf("📣 Published the result as kind %d, d tag %s\n", *resultKind, pubkey)
+		}
+	}
+	if (*dmFlag || *publishReports || *publishLabels) && len(findings.Flagged()) > 0 {
+		pool := nostr.NewSimplePool(ctx)
+		kr, err := loadSigner(ctx, pool, *signWith, cfg.Signer)
+		if err != nil {
+			fmt.Println("\033[31m❌ Cannot load signer:\033[0m", err)
+			exit(1)
+		}
+		pub := publish.New(kr, pool, opts.Relays)
+		if *publishReports {
+			publishAll(ctx, pub, nostr.KindReporting, findings.Images, publish.Report)
+		}
+		if *publishLabels {
+			publishAll(ctx, pub, nostr.KindLabel, findings.Images, publish.Label)
+		}
+		if *dmFlag {
+			sender, err := dm.New(cfg.DM, kr, pool, opts.Relays)
+			if err != nil {
+				fmt.Println("\033[31m❌ Cannot set up DMs:\033[0m", err)
+				exit(1)
+			}
+			sendDM(ctx, sender, findings.Pubkey, findings.Images)
+		}
+	}
+	if *reviewFlag {
+		rv := &reviewer{
+			base:     base,
+			relays:   opts.Relays,
+			signWith: *signWith,
+			signer:   cfg.Signer,
+			blossom:  *blossomServer,
+			nip96:    *nip96Server,
+			auditLog: "remediation-log.jsonl",
+		}
+		if err := rv.run(ctx, findings); err != nil {
+			fmt.Println("\033[31m❌ Review failed:\033[0m", err)
+			exit(1)
+		}
+	}
+}
+
+// publishAll publishes one event built by build per flagged note, skipping
+// notes we already published such an event about.
+func publishAll(ctx context.Context, p *publish.Publisher, kind int, images []*exifscan.ImageResult, build func([]*exifscan.ImageResult) *nostr.Event) {
+	groups := publish.ByEvent(images)
+	ids := make([]string, len(groups))
+	for i, g := range groups {
+		ids[i] = g[0].EventID
+	}
+	done := p.Existing(ctx, kind, ids)
+	published := 0
+	for _, g := range groups {
+		if done[g[0].EventID] {
+			continue
+		}
+		if err := p.Publish(ctx, build(g)); err != nil {
+			fmt.Printf("    ❌ Publishing kind %d for \033[31m%s\033[0m failed: %v\n", kind, report.ResultURL(g[0]), err)
+			continue
+		}
+		published++
+	}
+	fmt.Printf("📣 Published \033[36m%d\033[0m kind %d events (%d already published)\n", published, kind, len(done))
 }
 
-func fetchEvents(pubkey string, relays []string) []nostr.Event {
-	var events []nostr.Event
-	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
-	defer cancel()
+func sendDM(ctx context.Context, sender *dm.Sender, pubkey string, images []*exifscan.ImageResult) {
+	err := sender.Send(ctx, pubkey, images)
+	switch {
+	case errors.Is(err, dm.ErrOptedOut):
+		fmt.Println("🔕 Not messaging the author: they opted out")
+	case errors.Is(err, dm.ErrTooSoon):
+		fmt.Println("🔕 Not messaging the author: already messaged recently")
+	case err != nil:
+		fmt.Println("\033[31m❌ Sending DM failed:\033[0m", err)
+	default:
+		fmt.Println("✉️  Sent the author a private message about the leaks")
+	}
+}
 
-	filter := nostr.Filter{
-		Kinds:   []int{1},
-		Authors: []string{pubkey},
-		Limit:   *limit,
+func emailReport(cfg mailer.Config, d report.Data) error {
+	var md, html bytes.Buffer
+	if err := report.Markdown(&md, d); err != nil {
+		return err
 	}
+	if err := report.HTML(&html, d); err != nil {
+		return err
+	}
+	return mailer.Send(cfg, mailer.Message{
+		Subject:  fmt.Sprintf("EXIF scan report for %s: %d leaking images", d.Npub, len(d.Findings.Flagged())),
+		Markdown: md.Bytes(),
+		HTML:     html.Bytes(),
+	})
+}
 
-	if *sinceFlag != "" {
-		if t, err := time.Parse(time.RFC3339, *sinceFlag); err == nil {
-			ts := nostr.Timestamp(t.Unix())
-			filter.Since = &ts
+func printResult(w io.Writer, r *exifscan.ImageResult, v verbosity) {
+	if r.Err != nil {
+		// Failures are listed together after the scan.
+		if v < verboseTags {
+			return
 		}
+		fmt.Fprintf(w, "    ❌ %s \033[31m%s\033[0m\n", r.Error, r.URL)
+		var se *exifscan.StatusError
+		if v >= verboseExif && errors.As(r.Err, &se) {
+			fmt.Fprintf(w, "    🌐 HTTP %s after %s", se.Status, r.Duration.Round(time.Millisecond))
+			if se.RetryAfter > 0 {
+				fmt.Fprintf(w, ", Retry-After %s", se.RetryAfter)
+			}
+			fmt.Fprintln(w)
+		}
+		return
+	}
+	if v >= verboseExif {
+		fmt.Fprintf(w, "    🌐 HTTP 200, %s, %d bytes in %s\n", r.ContentType, r.Size, r.Duration.Round(time.Millisecond))
+	}
+	if r.TypeMismatch {
+		fmt.Fprintf(w, "    ⚠️  Not the format the link's extension says: the server sent \033[33m%s\033[0m\n", r.ContentType)
+	}
+	if r.ExternalRefs != nil {
+		fmt.Fprintf(w, "    ⚠️  Loads external content when rendered: \033[33m%s\033[0m\n", strings.Join(r.ExternalRefs, ", "))
+	}
+	for _, h := range r.Stego {
+		fmt.Fprintf(w, "    🕵️  Possible hidden data: \033[33m%s\033[0m\n", h.Detail)
+	}
+	if r.HashMismatch {
+		fmt.Fprintf(w, "    ⚠️  Hash mismatch: the post published \033[33m%s\033[0m, the server sent \033[33m%s\033[0m\n", r.DeclaredSHA256, r.SHA256)
+	}
+	if r.OriginLeaks {
+		fmt.Fprintf(w, "    🪞 The proxied copy is clean but its original leaks: \033[36m%s\033[0m\n", r.Origin)
+	}
+	if r.ProxyError != "" {
+		fmt.Fprintf(w, "    🪞 The proxied copy failed (%s), scanned its original \033[36m%s\033[0m\n", r.ProxyError, r.Origin)
+	}
+	if r.Archived != "" {
+		fmt.Fprintf(w, "    🏛️  Link is dead, scanned the archived copy \033[36m%s\033[0m\n", r.Archived)
 	}
-	if *untilFlag != "" {
-		if t, err := time.Parse(time.RFC3339, *untilFlag); err == nil {
-			ts := nostr.Timestamp(t.Unix())
-			filter.Until = &ts
+	if r.Archive != nil && (v >= verboseTags || r.Sensitive()) {
+		fmt.Fprintf(w, "    🗜️  Archive of %d files: %s\n", len(r.Archive), archiveFiles(r.Archive, 10))
+	}
+	if v >= verboseExif && r.VisibleText != "" {
+		fmt.Fprintf(w, "    🔤 Visible text: %s\n", strings.Join(strings.Fields(r.VisibleText), " "))
+	}
+	switch {
+	case r.AllTags != nil:
+		printAllTags(w, r.AllTags)
+	case v >= verboseExif && r.Exif != nil:
+		printAllTags(w, exifscan.AllTags(r.Exif))
+	case v >= verboseTags:
+		printTags(w, r.Tags)
+	}
+	if v >= verboseTags && !r.Sensitive() && r.Provenance != nil {
+		fmt.Fprintf(w, "    🧪 Clean, %s\n", r.Provenance)
+	}
+	if r.Sensitive() {
+		if r.EventID == "" {
+			fmt.Fprintf(w, "🚨 \033[31mSensitive EXIF found\033[0m in unlinked upload on %s: \033[4m%s\033[0m\n", r.Source, r.URL)
+		} else {
+			fmt.Fprintf(w, "🚨 \033[31mSensitive EXIF found\033[0m in post: \033[4m%s\033[0m\n", report.ResultURL(r))
+		}
+		if v >= verboseTags && r.GPS != nil {
+			fmt.Fprintf(w, "    🌍 GPS: %s (accurate to %s) %s\n", r.GPS, r.GPS.Accuracy(), r.GPS.MapsURL())
+		}
+		if v >= verboseTags && r.GeoTag != nil {
+			fmt.Fprintf(w, "    📍 Note %s\n", r.GeoTag)
+		}
+		if v >= verboseTags {
+			fmt.Fprintf(w, "    🔖 Fingerprint: %s\n", r.Fingerprint)
 		}
 	}
+}
 
-	pool := nostr.NewSimplePool(ctx)
-	ch := pool.SubManyEose(ctx, relays, nostr.Filters{filter})
-	for evt := range ch {
-		events = append(events, *evt.Event)
+// archiveFiles lists the first n files of an archive.
+func archiveFiles(files []exifscan.ArchiveFile, n int) string {
+	var names []string
+	for _, f := range files[:min(n, len(files))] {
+		names = append(names, f.Name)
+	}
+	if len(files) > n {
+		names = append(names, fmt.Sprintf("and %d more", len(files)-n))
 	}
-	return events
+	return strings.Join(names, ", ")
 }
 
-func extractImageLinks(events []nostr.Event) []imagePost {
-	var out []imagePost
-	imgRE := regexp.MustCompile(`https?://[^\s]+?\.(?i)(jpg|jpeg|png|gif|webp)`)
-	for _, evt := range events {
-		matches := imgRE.FindAllString(evt.Content, -1)
-		for _, url := range matches {
-			out = append(out, imagePost{ID: evt.ID, URL: url})
+// printPlan prints what a scan would download.
+func printPlan(p *exifscan.Plan) {
+	fmt.Printf("🧾 Dry run: \033[36m%d\033[0m image links to %d distinct URLs (%d duplicates)\n", p.Links, len(p.URLs), p.Duplicates)
+	fmt.Printf("   Estimated size:  \033[36m%.1f MB\033[0m over %d images, %d more of unknown size\n", float64(p.Bytes)/1e6, p.Sized, p.Unsized)
+	fmt.Printf("   Estimated time:  \033[36m%s\033[0m\n", p.Duration.Round(time.Second))
+	if p.Failed > 0 {
+		fmt.Printf("   HEAD failed:     \033[33m%d\033[0m images\n", p.Failed)
+	}
+	fmt.Println("   By host:")
+	for _, c := range report.Ranked(p.ByHost) {
+		fmt.Printf("      %-24s %d\n", c.Key, c.Count)
+	}
+}
+
+// printInventory lists every image with its verdict.
+func printInventory(images []*exifscan.ImageResult) {
+	fmt.Printf("🗂️  Inventory of \033[36m%d\033[0m images:\n", len(images))
+	for _, r := range images {
+		icon := "✅"
+		switch r.Verdict() {
+		case exifscan.VerdictLeaking:
+			icon = "🚨"
+		case exifscan.VerdictUnreachable:
+			icon = "❌"
+		}
+		fmt.Printf("   %s %-11s \033[36m%s\033[0m (%s)\n", icon, r.Verdict(), r.URL, r.VerdictDetail())
+		if r.EventID != "" {
+			fmt.Printf("      in %s\n", report.ResultURL(r))
 		}
 	}
-	return out
 }
 
-func scanImages(posts []imagePost, threadCount int, verbose bool) {
-	sem := make(chan struct{}, threadCount)
-	var wg sync.WaitGroup
-	client := &http.Client{Timeout: 10 * time.Second}
-	total := len(posts)
-
-	sensitiveTags := []exif.FieldName{
-		exif.GPSLatitude,
-		exif.GPSLongitude,
-		exif.GPSAltitude,
-		exif.GPSTimeStamp,
-		exif.GPSDateStamp,
-		exif.GPSImgDirection,
-		exif.Model,
-		exif.Make,
-		exif.DateTimeOriginal,
-		exif.FieldName("CreateDate"),
-		exif.Software,
-		exif.LensModel,
-		exif.LensMake,
-	}
-
-	for i, post := range posts {
-		wg.Add(1)
-		sem <- struct{}{}
-		go func(idx int, p imagePost) {
-			defer wg.Done()
-			fmt.Printf("[%d/%d] 🔎 Checking \033[36m%s\033[0m\n", idx+1, total, p.URL)
-
-			resp, err := client.Get(p.URL)
-			if err != nil {
-				fmt.Printf("    ❌ Failed to fetch \033[31m%s\033[0m\n", p.URL)
-				<-sem
-				return
+// printPosts rolls the leaking images up into one entry per post.
+func printPosts(posts []report.Post, v verbosity) {
+	if len(posts) == 0 {
+		return
+	}
+	fmt.Printf("📋 \033[31m%d\033[0m posts to act on:\n", len(posts))
+	for _, p := range posts {
+		where := p.Link()
+		if p.EventID == "" {
+			where = "unlinked upload on " + p.Source + ": " + p.Images[0].URL
+		}
+		images := "1 image"
+		if len(p.Images) > 1 {
+			images = fmt.Sprintf("%d images", len(p.Images))
+		}
+		fmt.Printf("  🚨 [%s] \033[4m%s\033[0m – %s: %s\n", p.Severity(), where, images, strings.Join(p.Categories(), ", "))
+		if c := p.Context(); c != nil {
+			fmt.Printf("      📝 %s\n", c)
+		}
+		if v < verboseTags {
+			continue
+		}
+		for _, r := range p.Images {
+			fmt.Printf("      🖼️  %s: %s\n", r.URL, strings.Join(r.Categories(), ", "))
+			if r.GPS != nil {
+				fmt.Printf("      🌍 GPS: %s (accurate to %s) %s\n", r.GPS, r.GPS.Accuracy(), r.GPS.MapsURL())
 			}
-			defer resp.Body.Close()
-			buf, err := io.ReadAll(resp.Body)
-			if err != nil {
-				fmt.Printf("    ❌ Read failed for \033[31m%s\033[0m\n", p.URL)
-				<-sem
-				return
+			if r.GeoTag != nil {
+				fmt.Printf("      📍 Note %s\n", r.GeoTag)
 			}
+		}
+	}
+}
 
-			r := bytes.NewReader(buf)
-			x, err := exif.Decode(r)
-			if err != nil {
-				<-sem
-				return // No EXIF or unreadable
-			}
+func printProfile(tags []exifscan.Tag) {
+	if len(tags) == 0 {
+		return
+	}
+	fmt.Printf("👤 Profile gives away \033[31m%d\033[0m personal details:\n", len(tags))
+	for _, t := range tags {
+		fmt.Printf("  🚨 [%s] %s in %s: %s\n", exifscan.CategorySeverity[t.Category], t.Category, t.Field, t.Value)
+	}
+}
 
-			sensitive := false
-			var lat, lon float64
-			var latRef, lonRef string
-
-			for _, field := range sensitiveTags {
-				if tag, err := x.Get(field); err == nil {
-					sensitive = true
-					if verbose {
-						if field == exif.GPSLatitude || field == exif.GPSLongitude {
-							refTag, _ := x.Get(exif.FieldName(string(field) + "Ref"))
-							ref, _ := refTag.StringVal()
-							num0, denom0, err0 := tag.Rat2(0)
-							num1, denom1, err1 := tag.Rat2(1)
-							num2, denom2, err2 := tag.Rat2(2)
-							if err0 == nil && err1 == nil && err2 == nil {
-								deg := float64(num0) / float64(denom0)
-								min := float64(num1) / float64(denom1)
-								sec := float64(num2) / float64(denom2)
-								total := deg + (min / 60) + (sec / 3600)
-								if field == exif.GPSLatitude {
-									lat = total
-									latRef = ref
-								} else {
-									lon = total
-									lonRef = ref
-								}
-								fmt.Printf("    ➕ %s: %.6f° (%s)\n", field, total, ref)
-							}
-							continue
-						}
-						if val, err := tag.StringVal(); err == nil {
-							fmt.Printf("    ➕ %s: %s\n", field, val)
-						}
-					}
-				}
-			}
+func printFailures(failed []*exifscan.ImageResult) {
+	if len(failed) == 0 {
+		return
+	}
+	byClass := map[string]int{}
+	for _, r := range failed {
+		byClass[r.ErrorClass]++
+	}
+	fmt.Printf("❌ \033[31m%d\033[0m downloads failed (%s):\n", len(failed), classList(byClass))
+	for _, r := range failed {
+		status := "no response"
+		if r.Status != 0 {
+			status = fmt.Sprintf("HTTP %d", r.Status)
+		}
+		fmt.Printf("   [%s] %s, %s: \033[31m%s\033[0m\n", exifscan.FailureLabel(r.ErrorClass), status, report.Retries(r.Retries), r.URL)
+		fmt.Printf("      %s\n", r.Error)
+	}
+}
 
-			if sensitive {
-				nevent, _ := nip19.EncodeEvent(p.ID, nil, "")
-				fmt.Printf("🚨 \033[31mSensitive EXIF found\033[0m in post: \033[4mhttps://primal.net/e/%s\033[0m\n", nevent)
-				if verbose && lat != 0 && lon != 0 {
-					fmt.Printf("    🌍 GPS: https://maps.google.com/?q=%.6f,%+.6f\n", lat*(sign(latRef)), lon*(sign(lonRef)))
-				}
+func printSummary(s report.Summary) {
+	pct := 0
+	if scanned := s.Images - s.Failed; scanned > 0 {
+		pct = 100 * s.WithMetadata / scanned
+	}
+	fmt.Println("📊 Summary")
+	fmt.Printf("   Images scanned:  \033[36m%d\033[0m (%d failed to download, %d of them gone for good)\n", s.Images, s.Failed, s.Dead)
+	if s.Mismatched > 0 {
+		fmt.Printf("   Hash mismatch:   \033[33m%d\033[0m images differ from the file their post published\n", s.Mismatched)
+	}
+	if s.Mistyped > 0 {
+		fmt.Printf("   Wrong format:    \033[33m%d\033[0m images aren't the format their link's extension says\n", s.Mistyped)
+	}
+	if s.Stego > 0 {
+		fmt.Printf("   Hidden data:     \033[33m%d\033[0m images show signs of appended or embedded data\n", s.Stego)
+	}
+	if s.Archived > 0 {
+		fmt.Printf("   From archive:    \033[36m%d\033[0m dead links scanned from Wayback Machine snapshots\n", s.Archived)
+	}
+	fmt.Printf("   With metadata:   \033[36m%d\033[0m (%d%%)\n", s.WithMetadata, pct)
+	fmt.Printf("   Leaking:         \033[31m%d\033[0m\n", s.Leaking)
+	// Months are all listed; the other breakdowns are cut to the top 10.
+	for _, b := range []struct {
+		name   string
+		counts []report.Count
+		top    int
+	}{
+		{"By category:", report.Ranked(s.ByCategory), 10},
+		{"By host:", report.Ranked(s.ByHost), 10},
+		{"By month:", report.Chronological(s.ByMonth), 0},
+		{"By device:", report.Ranked(s.ByDevice), 10},
+		{"Failures by host:", report.Ranked(s.FailedByHost), 10},
+		{"Clean images by provenance:", report.Ranked(s.CleanByProvenance), 0},
+	} {
+		if len(b.counts) == 0 {
+			continue
+		}
+		fmt.Printf("   %s\n", b.name)
+		for i, c := range b.counts {
+			if i == b.top && b.top > 0 {
+				fmt.Printf("      … %d more\n", len(b.counts)-i)
+				break
 			}
-			<-sem
-		}(i, post)
+			fmt.Printf("      %-24s %d\n", c.Key, c.Count)
+		}
 	}
-	wg.Wait()
 }
 
-func sign(ref string) float64 {
-	switch ref {
-	case "S", "W":
-		return -1
-	default:
-		return 1
+func printTags(w io.Writer, tags []exifscan.Tag) {
+	for _, t := range tags {
+		if t.Value == "" {
+			continue
+		}
+		fmt.Fprintf(w, "    ➕ %s: %s\n", t.Field, t.Value)
 	}
 }
 
+// printAllTags prints every decoded field, marking the sensitive ones.
+func printAllTags(w io.Writer, tags []exifscan.Tag) {
+	for _, t := range tags {
+		if t.Category != "" {
+			fmt.Fprintf(w, "    ➕ \033[33m%s: %s\033[0m (%s)\n", t.Field, t.Value, t.Category)
+		} else {
+			fmt.Fprintf(w, "    ·  %s: %s\n", t.Field, t.Value)
+		}
+	}
+}
+
+func loadRelays(path string) []string {
+	file, err := os.Open(path)
+	if err != nil {
+		return exifscan.DefaultRelays
+	}
+	defer file.Close()
+	scanner := bufio.NewScanner(file)
+	var relays []string
+	for scanner.Scan() {
+		relay := strings.TrimSpace(scanner.Text())
+		if relay != "" {
+			relays = append(relays, relay)
+		}
+	}
+	return relays
+}
@@ -0,0 +1,229 @@
+package main
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"os/signal"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/nbd-wtf/go-nostr/nip19"
+
+	"nostr-exif-scan/internal/mcp"
+	"nostr-exif-scan/internal/report"
+	"nostr-exif-scan/pkg/exifscan"
+)
+
+// runMCP speaks the Model Context Protocol on stdin/stdout. Nothing else
+// may be written to stdout, so diagnostics go to stderr.
+func runMCP(args []string) int {
+	fs := newFlagSet("mcp")
+	threads := threadsFlag(fs, "Number of parallel workers per scan")
+	limit := fs.Int("limit", 10000, "Maximum number of events to fetch per scan")
+	linkTmpl := linkTemplateFlag(fs)
+	tz := tzFlag(fs)
+	coordFmt := coordFormatFlag(fs)
+	// mcp's stdout is JSON-RPC, not for people: --plain is accepted but
+	// changes nothing.
+	fs.Parse(args)
+	if !setLinkTemplate(*linkTmpl) {
+		return 1
+	}
+	if !setTimezone(*tz) || !setCoordFormat(*coordFmt) {
+		return 1
+	}
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+
+	t := &mcpTools{
+		opts:  exifscan.Options{Relays: loadRelays("relays.txt"), Limit: *limit, Threads: *threads},
+		scans: map[string]*exifscan.Findings{},
+	}
+	srv := &mcp.Server{Name: "nostr-exif-scan", Version: "1.0.0", Tools: t.list()}
+	if err := srv.Serve(ctx, os.Stdin, os.Stdout); err != nil {
+		fmt.Fprintln(os.Stderr, "mcp:", err)
+		return 1
+	}
+	return 0
+}
+
+// mcpTools keeps the findings of this session's scans so get_findings can
+// return them without scanning again.
+type mcpTools struct {
+	opts exifscan.Options
+
+	mu    sync.Mutex
+	scans map[string]*exifscan.Findings
+}
+
+func (t *mcpTools) list() []mcp.Tool {
+	return []mcp.Tool{
+		{
+			Name:        "scan_npub",
+			Description: "Scan the images a nostr user posted for leaked EXIF metadata (GPS position, camera serial numbers, owner names, device, timestamps). Returns a summary and a scan_id for get_findings.",
+			InputSchema: json.RawMessage(`{"type":"object","properties":{` +
+				`"npub":{"type":"string","description":"npub, nprofile or hex public key"},` +
+				`"since":{"type":"string","description":"Only notes after this RFC3339 time, date, or age like 90d or 6mo"},` +
+				`"until":{"type":"string","description":"Only notes before this RFC3339 time, date, now, or age like 30d"}},` +
+				`"required":["npub"]}`),
+			Call: t.scanNpub,
+		},
+		{
+			Name:        "scan_url",
+			Description: "Download one image and report the sensitive EXIF metadata it carries.",
+			InputSchema: json.RawMessage(`{"type":"object","properties":{"url":{"type":"string","description":"http(s) URL of the image"}},"required":["url"]}`),
+			Call:        t.scanURL,
+		},
+		{
+			Name:        "get_findings",
+			Description: "Return the leaking images of an earlier scan_npub call, with post links, leaked fields and GPS positions.",
+			InputSchema: json.RawMessage(`{"type":"object","properties":{` +
+				`"scan_id":{"type":"string"},` +
+				`"include_clean":{"type":"boolean","description":"Also list images without sensitive metadata"}},` +
+				`"required":["scan_id"]}`),
+			Call: t.getFindings,
+		},
+	}
+}
+
+func (t *mcpTools) scanNpub(ctx context.Context, raw json.RawMessage) (string, any, error) {
+	var args struct {
+		Npub  string `json:"npub"`
+		Since string `json:"since"`
+		Until string `json:"until"`
+	}
+	if err := json.Unmarshal(raw, &args); err != nil {
+		return "", nil, err
+	}
+	pubkey, err := exifscan.DecodePubkey(strings.TrimSpace(args.Npub))
+	if err != nil {
+		return "", nil, fmt.Errorf("invalid npub: %w", err)
+	}
+	opts := t.opts
+	for _, p := range []struct {
+		s   string
+		dst *time.Time
+	}{{args.Since, &opts.Since}, {args.Until, &opts.Until}} {
+		if p.s == "" {
+			continue
+		}
+		if *p.dst, err = exifscan.ParseTime(p.s, time.Now()); err != nil {
+			return "", nil, err
+		}
+	}
+	findings, err := exifscan.New(opts).Scan(ctx, pubkey)
+	if err != nil {
+		return "", nil, err
+	}
+
+	b := make([]byte, 8)
+	rand.Read(b)
+	id := hex.EncodeToString(b)
+	t.mu.Lock()
+	t.scans[id] = findings
+	t.mu.Unlock()
+
+	flagged := findings.Flagged()
+	counts := map[string]int{}
+	gps := 0
+	for _, r := range flagged {
+		for _, c := range r.Categories() {
+			counts[c]++
+		}
+		if r.GPS != nil {
+			gps++
+		}
+	}
+	npub, _ := nip19.EncodePublicKey(pubkey)
+	summary := map[string]any{
+		"scan_id":         id,
+		"npub":            npub,
+		"posts":           findings.Events,
+		"images":          len(findings.Images),
+		"leaking_images":  len(flagged),
+		"with_gps":        gps,
+		"leaks_per_field": counts,
+	}
+	text := fmt.Sprintf("Scanned %d posts with %d images of %s: %d leak metadata (%d reveal a GPS position). Call get_findings with scan_id %q for details.",
+		findings.Events, len(findings.Images), npub, len(flagged), gps, id)
+	return text, summary, nil
+}
+
+func (t *mcpTools) scanURL(ctx context.Context, raw json.RawMessage) (string, any, error) {
+	var args struct {
+		URL string `json:"url"`
+	}
+	if err := json.Unmarshal(raw, &args); err != nil {
+		return "", nil, err
+	}
+	if !strings.HasPrefix(args.URL, "http://") && !strings.HasPrefix(args.URL, "https://") {
+		return "", nil, errors.New("url must be http(s)")
+	}
+	r := exifscan.New(t.opts).ScanImage(ctx, exifscan.Image{URL: args.URL})
+	if r.Err != nil {
+		return "", nil, r.Err
+	}
+	out := map[string]any{"result": r}
+	if !r.Sensitive() {
+		return "The image carries no sensitive metadata.", out, nil
+	}
+	text := "The image leaks: " + strings.Join(r.Categories(), ", ") + "."
+	if r.GPS != nil {
+		text += " It reveals where it was taken, to within " + r.GPS.Accuracy() + ": " + r.GPS.MapsURL()
+	}
+	return text, out, nil
+}
+
+func (t *mcpTools) getFindings(ctx context.Context, raw json.RawMessage) (string, any, error) {
+	var args struct {
+		ScanID       string `json:"scan_id"`
+		IncludeClean bool   `json:"include_clean"`
+	}
+	if err := json.Unmarshal(raw, &args); err != nil {
+		return "", nil, err
+	}
+	t.mu.Lock()
+	findings := t.scans[args.ScanID]
+	t.mu.Unlock()
+	if findings == nil {
+		return "", nil, fmt.Errorf("unknown scan_id %q: run scan_npub first", args.ScanID)
+	}
+	images := findings.Flagged()
+	if args.IncludeClean {
+		images = findings.Images
+	}
+	type finding struct {
+		*exifscan.ImageResult
+		PostURL string `json:"post_url,omitempty"`
+	}
+	list := make([]finding, len(images))
+	var text strings.Builder
+	fmt.Fprintf(&text, "%d images:\n", len(images))
+	for i, r := range images {
+		list[i] = finding{ImageResult: r}
+		if r.EventID != "" {
+			list[i].PostURL = report.ResultURL(r)
+		}
+		fmt.Fprintf(&text, "- %s", r.URL)
+		if cats := r.Categories(); len(cats) > 0 {
+			fmt.Fprintf(&text, " leaks %s", strings.Join(cats, ", "))
+		}
+		if r.GPS != nil {
+			fmt.Fprintf(&text, " at %s (accurate to %s)", r.GPS.MapsURL(), r.GPS.Accuracy())
+		}
+		if list[i].PostURL != "" {
+			fmt.Fprintf(&text, " (post %s)", list[i].PostURL)
+		}
+		if r.Post != nil {
+			fmt.Fprintf(&text, ": %s", r.Post)
+		}
+		text.WriteString("\n")
+	}
+	return text.String(), map[string]any{"pubkey": findings.Pubkey, "images": list}, nil
+}
@@ -0,0 +1,151 @@
+// Package mobile wraps exifscan in types that gomobile bind can export to
+// Java/Kotlin and Objective-C/Swift. Lists are passed as newline or comma
+// separated strings and full results as JSON, since gomobile cannot bind
+// slices of structs.
+package mobile
+
+import (
+	"context"
+	"encoding/json"
+	"strings"
+	"sync"
+	"time"
+
+	"nostr-exif-scan/pkg/exifscan"
+)
+
+// CheckResult is the verdict for a single image.
+type CheckResult struct {
+	HasMetadata bool
+	Sensitive   bool
+	// Categories is a comma separated list such as "GPS,device".
+	Categories string
+	HasGPS     bool
+	Lat        float64
+	Lon        float64
+	// JSON is the full exifscan.ImageResult.
+	JSON string
+}
+
+// CheckImage inspects image bytes locally, e.g. right before upload.
+func CheckImage(data []byte) *CheckResult {
+	r := exifscan.ScanBytes(data)
+	res := &CheckResult{
+		HasMetadata: r.HasMetadata,
+		Sensitive:   r.Sensitive(),
+		Categories:  strings.Join(r.Categories(), ","),
+		JSON:        toJSON(r),
+	}
+	if r.GPS != nil {
+		res.HasGPS = true
+		res.Lat, res.Lon = r.GPS.Lat, r.GPS.Lon
+	}
+	return res
+}
+
+// AuditListener receives progress while an audit runs. Methods are called
+// from background goroutines.
+type AuditListener interface {
+	OnImageScanned(url string, sensitive bool)
+	OnFinding(eventID, url, categories, resultJSON string)
+	OnError(message string)
+}
+
+// AuditOptions configures an Auditor. Zero values select the defaults.
+type AuditOptions struct {
+	// Relays is a newline separated list of relay URLs.
+	Relays  string
+	Limit   int
+	Threads int
+	// Since and Until are unix timestamps; 0 means unbounded.
+	Since int64
+	Until int64
+}
+
+func NewAuditOptions() *AuditOptions {
+	return &AuditOptions{}
+}
+
+// Auditor scans a profile's image posts and can be cancelled from the UI.
+type Auditor struct {
+	opts   *AuditOptions
+	mu     sync.Mutex
+	cancel context.CancelFunc
+}
+
+func NewAuditor(opts *AuditOptions) *Auditor {
+	if opts == nil {
+		opts = NewAuditOptions()
+	}
+	return &Auditor{opts: opts}
+}
+
+// Run blocks until the audit of npub finishes and returns the findings as
+// JSON. Call it off the main thread.
+func (a *Auditor) Run(npub string, listener AuditListener) (string, error) {
+	pubkey, err := exifscan.DecodePubkey(npub)
+	if err != nil {
+		return "", err
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	a.mu.Lock()
+	a.cancel = cancel
+	a.mu.Unlock()
+	defer cancel()
+
+	opts := exifscan.Options{
+		Limit:   a.opts.Limit,
+		Threads: a.opts.Threads,
+		// App sandboxes may not have a usable temp dir; keep downloads in
+		// memory.
+		SpoolBytes: -1,
+	}
+	for _, relay := range strings.Split(a.opts.Relays, "\n") {
+		if relay = strings.TrimSpace(relay); relay != "" {
+			opts.Relays = append(opts.Relays, relay)
+		}
+	}
+	if a.opts.Since > 0 {
+		opts.Since = time.Unix(a.opts.Since, 0)
+	}
+	if a.opts.Until > 0 {
+		opts.Until = time.Unix(a.opts.Until, 0)
+	}
+	if listener != nil {
+		opts.Hooks = exifscan.Hooks{
+			OnImageScanned: func(r *exifscan.ImageResult) {
+				listener.OnImageScanned(r.URL, r.Sensitive())
+			},
+			OnFinding: func(r *exifscan.ImageResult) {
+				listener.OnFinding(r.EventID, r.URL, strings.Join(r.Categories(), ","), toJSON(r))
+			},
+			OnError: func(err error) {
+				listener.OnError(err.Error())
+			},
+		}
+	}
+
+	findings, err := exifscan.New(opts).Scan(ctx, pubkey)
+	if err != nil {
+		return "", err
+	}
+	return toJSON(findings), nil
+}
+
+// Cancel stops a running audit.
+func (a *Auditor) Cancel() {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if a.cancel != nil {
+		a.cancel()
+	}
+}
+
+func toJSON(v any) string {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return ""
+	}
+	return string(data)
+}
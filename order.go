@@ -0,0 +1,77 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"sort"
+	"strings"
+
+	"nostr-exif-scan/pkg/exifscan"
+)
+
+func sortFlag(fs *flag.FlagSet) *string {
+	return fs.String("sort", "", "Order the findings by comma separated keys: severity, date, host, tags; prefix - to reverse (e.g. severity,-date)")
+}
+
+// sortKeys compare two results by one key in its natural order: most
+// severe and most tags first, oldest post first, hosts alphabetically.
+var sortKeys = map[string]func(a, b *exifscan.ImageResult) int{
+	"severity": func(a, b *exifscan.ImageResult) int {
+		return exifscan.SeverityRank(b.Severity()) - exifscan.SeverityRank(a.Severity())
+	},
+	"date": func(a, b *exifscan.ImageResult) int {
+		switch ta, tb := postedAt(a), postedAt(b); {
+		case ta < tb:
+			return -1
+		case ta > tb:
+			return 1
+		}
+		return 0
+	},
+	"host": func(a, b *exifscan.ImageResult) int {
+		return strings.Compare(a.Host(), b.Host())
+	},
+	"tags": func(a, b *exifscan.ImageResult) int {
+		return len(b.Tags) - len(a.Tags)
+	},
+}
+
+// postedAt is the creation time of the linking note, 0 for unlinked files.
+func postedAt(r *exifscan.ImageResult) int64 {
+	if r.Post == nil {
+		return 0
+	}
+	return r.Post.CreatedAt.Unix()
+}
+
+// parseSort turns a --sort value into a stable in-place sort; it returns
+// nil when spec is empty.
+func parseSort(spec string) (func([]*exifscan.ImageResult), error) {
+	if spec == "" {
+		return nil, nil
+	}
+	var cmps []func(a, b *exifscan.ImageResult) int
+	for _, key := range strings.Split(spec, ",") {
+		key = strings.ToLower(strings.TrimSpace(key))
+		desc := strings.HasPrefix(key, "-")
+		cmp, ok := sortKeys[strings.TrimPrefix(key, "-")]
+		if !ok {
+			return nil, fmt.Errorf("--sort: unknown key %q (want severity, date, host or tags)", key)
+		}
+		if desc {
+			asc := cmp
+			cmp = func(a, b *exifscan.ImageResult) int { return asc(b, a) }
+		}
+		cmps = append(cmps, cmp)
+	}
+	return func(images []*exifscan.ImageResult) {
+		sort.SliceStable(images, func(i, k int) bool {
+			for _, cmp := range cmps {
+				if c := cmp(images[i], images[k]); c != 0 {
+					return c < 0
+				}
+			}
+			return false
+		})
+	}, nil
+}
@@ -0,0 +1,88 @@
+package exifscan
+
+import (
+	"fmt"
+	"math"
+
+	exif "github.com/rwcarlsen/goexif/exif"
+	"github.com/rwcarlsen/goexif/tiff"
+)
+
+// metersPerDegree is the length of a degree of latitude, close enough for
+// longitude as a radius estimate.
+const metersPerDegree = 111_320
+
+// uere is the typical ranging error of a consumer GPS fix; times the
+// dilution of precision it approximates the horizontal error.
+const uere = 5.0
+
+// precision is the resolution of a degrees, minutes, seconds coordinate
+// in meters: a unit of its finest non-zero component. A position rounded
+// to whole minutes says no more than ~1.9 km, whatever the receiver knew.
+func precision(tag *tiff.Tag) float64 {
+	units := [3]float64{metersPerDegree, metersPerDegree / 60, metersPerDegree / 3600}
+	res := units[0]
+	for i, unit := range units {
+		num, denom, err := tag.Rat2(i)
+		if err != nil || denom == 0 {
+			break
+		}
+		if num != 0 {
+			res = unit / float64(denom)
+		}
+	}
+	return res
+}
+
+// rational reads a single rational field as a float, 0 when absent.
+func rational(x *exif.Exif, field exif.FieldName) float64 {
+	tag, err := x.Get(field)
+	if err != nil {
+		return 0
+	}
+	num, denom, err := tag.Rat2(0)
+	if err != nil || denom == 0 {
+		return 0
+	}
+	return float64(num) / float64(denom)
+}
+
+// setAccuracy fills in how precise g is from what x tells: the receiver's
+// horizontal error or dilution of precision, and how finely the
+// coordinates were written.
+func (g *GPS) setAccuracy(x *exif.Exif, lat, lon *tiff.Tag) {
+	g.PrecisionM = math.Max(precision(lat), precision(lon))
+	g.DOP = rational(x, exif.GPSDOP)
+	g.ErrorM = rational(x, GPSHPositioningError)
+	if g.ErrorM == 0 && g.DOP > 0 {
+		g.ErrorM = g.DOP * uere
+	}
+}
+
+// Radius is the estimated radius, in meters, the photo was taken within.
+func (g GPS) Radius() float64 {
+	return math.Max(g.PrecisionM, g.ErrorM)
+}
+
+// Accuracy describes Radius, e.g. "~5 m" or "~10 km".
+func (g GPS) Accuracy() string {
+	return approxMeters(g.Radius())
+}
+
+// approxMeters rounds a distance for display, e.g. ~30 m or ~2.4 km; 0
+// is unknown.
+func approxMeters(r float64) string {
+	switch {
+	case r == 0:
+		return "unknown"
+	case r < 10:
+		return fmt.Sprintf("~%.0f m", math.Max(r, 1))
+	case r < 1000:
+		// Two significant digits: ~30 m, ~850 m.
+		p := math.Pow(10, math.Floor(math.Log10(r))-1)
+		return fmt.Sprintf("~%.0f m", math.Round(r/p)*p)
+	case r < 10_000:
+		return fmt.Sprintf("~%.1f km", r/1000)
+	}
+	return fmt.Sprintf("~%.0f km", r/1000)
+}
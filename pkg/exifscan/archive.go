@@ -0,0 +1,100 @@
+package exifscan
+
+import (
+	"archive/zip"
+	"context"
+	"io"
+)
+
+// DefaultMaxArchiveBytes is the default Options.MaxArchiveBytes.
+const DefaultMaxArchiveBytes = 64 << 20
+
+// maxArchiveFiles bounds the files of an archive that are scanned, and
+// maxChunk how much of each is read, so a zip bomb costs little.
+const maxArchiveFiles = 500
+
+// ArchiveFile is a file of a linked zip archive.
+type ArchiveFile struct {
+	Name string `json:"name"`
+	Size int64  `json:"size"`
+	// Scanned is set for the files that were inspected, those whose
+	// extension names a format read for metadata.
+	Scanned bool `json:"scanned,omitempty"`
+}
+
+// inspectArchive lists the files of a zip archive and scans those it can
+// read like downloads of their own. Their tags are reported as the
+// archive's, with the file's name in front of the field.
+func (s *Scanner) inspectArchive(ctx context.Context, r *ImageResult, p *payload) {
+	limit := s.opts.MaxArchiveBytes
+	if limit == 0 {
+		limit = DefaultMaxArchiveBytes
+	}
+	if limit < 0 || p.size > limit {
+		return
+	}
+	zr, err := zip.NewReader(p.readerAt(), p.size)
+	if err != nil {
+		return
+	}
+	r.Archive = []ArchiveFile{}
+	scanned := 0
+	for _, f := range zr.File {
+		if f.FileInfo().IsDir() {
+			continue
+		}
+		af := ArchiveFile{Name: f.Name, Size: int64(f.UncompressedSize64)}
+		format := extType(f.Name)
+		if format != "" && format != "application/zip" && scanned < maxArchiveFiles && ctx.Err() == nil {
+			scanned++
+			af.Scanned = true
+			s.inspectArchived(ctx, r, f)
+		}
+		r.Archive = append(r.Archive, af)
+	}
+	if r.Sensitive() {
+		r.Fingerprint = r.fingerprint()
+	}
+	if r.HasMetadata {
+		s.keepData(r, p)
+	}
+}
+
+// inspectArchived scans the file f of an archive into r.
+func (s *Scanner) inspectArchived(ctx context.Context, r *ImageResult, f *zip.File) {
+	rc, err := f.Open()
+	if err != nil {
+		return
+	}
+	buf, err := io.ReadAll(io.LimitReader(rc, maxChunk))
+	rc.Close()
+	if err != nil && len(buf) == 0 {
+		return
+	}
+	s.inspectNested(ctx, r, buf, f.Name)
+}
+
+// inspectNested scans a file held in r's, an archived file or an embedded
+// image, adding its tags to r's with name in front of the field.
+func (s *Scanner) inspectNested(ctx context.Context, r *ImageResult, buf []byte, name string) {
+	sub := &ImageResult{}
+	inner := &Scanner{opts: s.opts}
+	inner.opts.KeepData = false
+	inner.opts.MaxArchiveBytes = -1
+	inner.inspect(ctx, sub, memoryPayload(buf))
+	if !sub.HasMetadata {
+		return
+	}
+	r.HasMetadata = true
+	for _, t := range sub.Tags {
+		t.Field = name + ": " + t.Field
+		r.Tags = append(r.Tags, t)
+	}
+	for _, t := range sub.AllTags {
+		t.Field = name + ": " + t.Field
+		r.AllTags = append(r.AllTags, t)
+	}
+	if r.GPS == nil {
+		r.GPS = sub.GPS
+	}
+}
@@ -0,0 +1,510 @@
+package exifscan
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"io"
+	"math"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode/utf16"
+)
+
+// Audio files carry no EXIF, but ID3 frames, MP4 metadata atoms and Vorbis
+// comments hold the same kind of details: who recorded a voice message,
+// on which phone, when and where.
+
+// metaField rates a metadata field of an audio file or document; the
+// name is the Tag.Field it is reported as.
+type metaField struct {
+	name     string
+	category string
+}
+
+// id3Fields are the ID3v2.3/2.4 frames reported, with their ID3v2.2
+// equivalents.
+var id3Fields = map[string]metaField{
+	"TPE1": {"ID3 artist", CategoryOwner},
+	"TPE2": {"ID3 album artist", CategoryOwner},
+	"TCOM": {"ID3 composer", CategoryOwner},
+	"TOWN": {"ID3 file owner", CategoryOwner},
+	"TDRC": {"ID3 recording time", CategoryTimestamp},
+	"TYER": {"ID3 year", CategoryTimestamp},
+	"TENC": {"ID3 encoded by", CategorySoftware},
+	"TSSE": {"ID3 encoder", CategorySoftware},
+	"TIT2": {"ID3 title", ""},
+	"TALB": {"ID3 album", ""},
+}
+
+var id3v22 = map[string]string{
+	"TP1": "TPE1", "TP2": "TPE2", "TCM": "TCOM", "TYE": "TYER",
+	"TEN": "TENC", "TSS": "TSSE", "TT2": "TIT2", "TAL": "TALB", "TXX": "TXXX",
+}
+
+// mp4Fields are the iTunes-style ilst items and QuickTime udta atoms
+// reported, by atom type or mdta key.
+var mp4Fields = map[string]metaField{
+	"\xa9ART": {"MP4 artist", CategoryOwner},
+	"aART":    {"MP4 album artist", CategoryOwner},
+	"\xa9wrt": {"MP4 composer", CategoryOwner},
+	"\xa9aut": {"MP4 author", CategoryOwner},
+	"\xa9day": {"MP4 date", CategoryTimestamp},
+	"\xa9too": {"MP4 encoder", CategorySoftware},
+	"\xa9swr": {"MP4 software", CategorySoftware},
+	"\xa9mak": {"MP4 make", CategoryDevice},
+	"\xa9mod": {"MP4 model", CategoryDevice},
+	"\xa9xyz": {"MP4 location", CategoryGPS},
+	"\xa9nam": {"MP4 title", ""},
+	"\xa9alb": {"MP4 album", ""},
+
+	"com.apple.quicktime.location.ISO6709": {"MP4 location", CategoryGPS},
+	"com.apple.quicktime.make":             {"MP4 make", CategoryDevice},
+	"com.apple.quicktime.model":            {"MP4 model", CategoryDevice},
+	"com.apple.quicktime.software":         {"MP4 software", CategorySoftware},
+	"com.apple.quicktime.creationdate":     {"MP4 creation date", CategoryTimestamp},
+	"com.apple.quicktime.author":           {"MP4 author", CategoryOwner},
+	"com.android.version":                  {"MP4 Android version", CategorySoftware},
+	"com.android.manufacturer":             {"MP4 make", CategoryDevice},
+	"com.android.model":                    {"MP4 model", CategoryDevice},
+}
+
+// vorbisFields are the Vorbis comments, of Ogg Vorbis and Opus files,
+// reported. LOCATION is where the recording was made.
+var vorbisFields = map[string]metaField{
+	"ARTIST":        {"Vorbis ARTIST", CategoryOwner},
+	"PERFORMER":     {"Vorbis PERFORMER", CategoryOwner},
+	"COMPOSER":      {"Vorbis COMPOSER", CategoryOwner},
+	"DATE":          {"Vorbis DATE", CategoryTimestamp},
+	"CREATION_TIME": {"Vorbis CREATION_TIME", CategoryTimestamp},
+	"ENCODER":       {"Vorbis ENCODER", CategorySoftware},
+	"LOCATION":      {"Vorbis LOCATION", CategoryPlace},
+	"TITLE":         {"Vorbis TITLE", ""},
+	"ALBUM":         {"Vorbis ALBUM", ""},
+}
+
+// isAudio tells the formats audioTags reads. MP4 files are read whatever
+// their brand: voice recorders often label M4A files as plain MP4.
+func isAudio(format string) bool {
+	switch format {
+	case "audio/mpeg", "audio/mp4", "video/mp4", "application/ogg", "audio/ogg":
+		return true
+	}
+	return false
+}
+
+// audioTags reads the metadata of an audio file: every text field found,
+// the position a location field gives, and whether there was any
+// metadata at all. Sensitive fields have a Category.
+func audioTags(format string, r io.Reader) (fields []Tag, g *GPS, found bool) {
+	var raw []Tag
+	switch format {
+	case "audio/mpeg":
+		raw = id3Tags(r)
+	case "audio/mp4", "video/mp4":
+		raw = mp4Tags(r)
+	default:
+		raw = vorbisTags(r)
+	}
+	for _, t := range raw {
+		if t.Value = strings.TrimSpace(strings.TrimRight(t.Value, "\x00")); t.Value == "" {
+			continue
+		}
+		if g == nil && (t.Category == CategoryGPS || t.Category == CategoryPlace) {
+			if pos, ok := parseISO6709(t.Value); ok {
+				g, t.Category = pos, CategoryGPS
+			}
+		}
+		fields = append(fields, t)
+	}
+	sort.SliceStable(fields, func(i, j int) bool { return fields[i].Field < fields[j].Field })
+	return fields, g, len(fields) > 0
+}
+
+// sensitiveOnly keeps the fields with a category.
+func sensitiveOnly(fields []Tag) []Tag {
+	var out []Tag
+	for _, t := range fields {
+		if t.Category != "" {
+			out = append(out, t)
+		}
+	}
+	return out
+}
+
+// id3Tags reads the text frames of an ID3v2 tag at the start of r.
+func id3Tags(r io.Reader) []Tag {
+	var hdr [10]byte
+	if _, err := io.ReadFull(r, hdr[:]); err != nil || string(hdr[:3]) != "ID3" {
+		return nil
+	}
+	version, flags := hdr[3], hdr[5]
+	body := readChunk(r, int64(syncsafe(hdr[6:10])))
+	if body == nil || version < 2 || version > 4 {
+		return nil
+	}
+	if flags&0x40 != 0 && version >= 3 && len(body) >= 4 {
+		// Skip the extended header: v2.4 counts its own size, v2.3 not.
+		n := int(binary.BigEndian.Uint32(body[:4]))
+		if version == 4 {
+			n = int(syncsafe(body[:4]))
+		} else {
+			n += 4
+		}
+		body = body[min(n, len(body)):]
+	}
+	idLen, hdrLen := 4, 10
+	if version == 2 {
+		idLen, hdrLen = 3, 6
+	}
+	var tags []Tag
+	for len(body) >= hdrLen && body[0] != 0 {
+		id := string(body[:idLen])
+		var size int
+		switch version {
+		case 2:
+			size = int(body[3])<<16 | int(body[4])<<8 | int(body[5])
+		case 3:
+			size = int(binary.BigEndian.Uint32(body[4:8]))
+		default:
+			size = int(syncsafe(body[4:8]))
+		}
+		if size > len(body)-hdrLen {
+			break
+		}
+		data := body[hdrLen : hdrLen+size]
+		body = body[hdrLen+size:]
+		if version == 2 {
+			id = id3v22[id]
+		}
+		if id == "TXXX" {
+			if t, ok := id3UserText(data); ok {
+				tags = append(tags, t)
+			}
+			continue
+		}
+		if f, ok := id3Fields[id]; ok {
+			tags = append(tags, Tag{Field: f.name, Category: f.category, Value: id3Text(data)})
+		}
+	}
+	return tags
+}
+
+// id3UserText reads a TXXX frame, reporting those whose description says
+// they hold a location.
+func id3UserText(data []byte) (Tag, bool) {
+	if len(data) < 1 {
+		return Tag{}, false
+	}
+	parts := splitID3(data[0], data[1:])
+	if len(parts) < 2 {
+		return Tag{}, false
+	}
+	desc := parts[0]
+	t := Tag{Field: "ID3 " + desc, Value: parts[1]}
+	if d := strings.ToLower(desc); strings.Contains(d, "location") || strings.Contains(d, "gps") || strings.Contains(d, "coordinates") {
+		t.Category = CategoryPlace
+	}
+	return t, true
+}
+
+// id3Text decodes a text frame: an encoding byte, then the text.
+func id3Text(data []byte) string {
+	if len(data) < 1 {
+		return ""
+	}
+	return strings.Join(splitID3(data[0], data[1:]), ", ")
+}
+
+// splitID3 decodes the null separated strings of a frame in the given
+// encoding: ISO-8859-1, UTF-16 with a BOM, UTF-16BE or UTF-8.
+func splitID3(enc byte, b []byte) []string {
+	var out []string
+	if enc == 1 || enc == 2 {
+		for len(b) >= 2 {
+			end := len(b) &^ 1
+			for i := 0; i+1 < len(b); i += 2 {
+				if b[i] == 0 && b[i+1] == 0 {
+					end = i
+					break
+				}
+			}
+			out = append(out, utf16String(enc, b[:end]))
+			b = b[min(end+2, len(b)):]
+		}
+		return out
+	}
+	for _, s := range bytes.Split(bytes.TrimRight(b, "\x00"), []byte{0}) {
+		if enc == 0 {
+			out = append(out, latin1(s))
+		} else {
+			out = append(out, strings.ToValidUTF8(string(s), "�"))
+		}
+	}
+	return out
+}
+
+func latin1(b []byte) string {
+	runes := make([]rune, len(b))
+	for i, c := range b {
+		runes[i] = rune(c)
+	}
+	return string(runes)
+}
+
+func utf16String(enc byte, b []byte) string {
+	order := binary.ByteOrder(binary.BigEndian)
+	if enc == 1 && len(b) >= 2 {
+		if b[0] == 0xff && b[1] == 0xfe {
+			order = binary.LittleEndian
+		}
+		if (b[0] == 0xff && b[1] == 0xfe) || (b[0] == 0xfe && b[1] == 0xff) {
+			b = b[2:]
+		}
+	}
+	u := make([]uint16, len(b)/2)
+	for i := range u {
+		u[i] = order.Uint16(b[2*i:])
+	}
+	return string(utf16.Decode(u))
+}
+
+func syncsafe(b []byte) uint32 {
+	return uint32(b[0]&0x7f)<<21 | uint32(b[1]&0x7f)<<14 | uint32(b[2]&0x7f)<<7 | uint32(b[3]&0x7f)
+}
+
+// mp4Tags reads the metadata of the moov atom, wherever it is in the file.
+func mp4Tags(r io.Reader) []Tag {
+	br := bufio.NewReader(r)
+	for {
+		var hdr [8]byte
+		if _, err := io.ReadFull(br, hdr[:]); err != nil {
+			return nil
+		}
+		size := int64(binary.BigEndian.Uint32(hdr[:4])) - 8
+		if size == 1-8 {
+			var ext [8]byte
+			if _, err := io.ReadFull(br, ext[:]); err != nil {
+				return nil
+			}
+			size = int64(binary.BigEndian.Uint64(ext[:])) - 16
+		}
+		if size < 0 {
+			return nil
+		}
+		if string(hdr[4:]) == "moov" {
+			moov := readChunk(br, size)
+			if moov == nil {
+				return nil
+			}
+			var tags []Tag
+			mp4Walk(moov, nil, &tags)
+			return tags
+		}
+		if _, err := br.Discard(int(size)); err != nil {
+			return nil
+		}
+	}
+}
+
+// mp4Boxes calls fn for every box in b.
+func mp4Boxes(b []byte, fn func(typ string, body []byte)) {
+	for len(b) >= 8 {
+		size := int(binary.BigEndian.Uint32(b[:4]))
+		if size < 8 || size > len(b) {
+			return
+		}
+		fn(string(b[4:8]), b[8:size])
+		b = b[size:]
+	}
+}
+
+// mp4Walk descends into the boxes holding metadata; keys are the mdta
+// key names ilst items of a QuickTime meta box refer to by index.
+func mp4Walk(b []byte, keys []string, tags *[]Tag) {
+	mp4Boxes(b, func(typ string, body []byte) {
+		switch typ {
+		case "udta", "trak":
+			mp4Walk(body, nil, tags)
+		case "meta":
+			// ISO meta is a full box, QuickTime's starts with hdlr.
+			if len(body) >= 8 && string(body[4:8]) != "hdlr" {
+				body = body[4:]
+			}
+			var keys []string
+			mp4Boxes(body, func(typ string, body []byte) {
+				if typ == "keys" {
+					keys = mp4Keys(body)
+				}
+			})
+			mp4Walk(body, keys, tags)
+		case "ilst":
+			mp4Boxes(body, func(item string, body []byte) {
+				key := item
+				if i := int(binary.BigEndian.Uint32([]byte(item))); i >= 1 && i <= len(keys) {
+					key = keys[i-1]
+				}
+				if v, ok := mp4Data(body); ok {
+					*tags = append(*tags, mp4Tag(key, v))
+				}
+			})
+		default:
+			if typ[0] == 0xa9 && len(body) >= 4 {
+				// QuickTime user data text: length, language, text.
+				n := int(binary.BigEndian.Uint16(body[:2]))
+				if 4+n <= len(body) {
+					*tags = append(*tags, mp4Tag(typ, string(body[4:4+n])))
+				}
+			}
+		}
+	})
+}
+
+func mp4Tag(key, value string) Tag {
+	f, ok := mp4Fields[key]
+	if !ok {
+		f.name = "MP4 " + strings.TrimPrefix(key, "\xa9")
+	}
+	return Tag{Field: f.name, Category: f.category, Value: strings.ToValidUTF8(value, "�")}
+}
+
+// mp4Keys reads the key names of a keys box.
+func mp4Keys(b []byte) []string {
+	if len(b) < 8 {
+		return nil
+	}
+	var keys []string
+	b = b[8:]
+	for len(b) >= 8 {
+		size := int(binary.BigEndian.Uint32(b[:4]))
+		if size < 8 || size > len(b) {
+			break
+		}
+		keys = append(keys, string(b[8:size]))
+		b = b[size:]
+	}
+	return keys
+}
+
+// mp4Data returns the text of the data box of an ilst item.
+func mp4Data(item []byte) (string, bool) {
+	var value string
+	var ok bool
+	mp4Boxes(item, func(typ string, body []byte) {
+		// Type 1 is UTF-8 text; the locale follows.
+		if typ == "data" && !ok && len(body) >= 8 && binary.BigEndian.Uint32(body[:4]) == 1 {
+			value, ok = string(body[8:]), true
+		}
+	})
+	return value, ok
+}
+
+// oggCommentMaxLen bounds how far into an Ogg file the comment header is
+// looked for; it follows the identification header on the first pages.
+const oggCommentMaxLen = 1 << 16
+
+// vorbisTags reads the comment header of an Ogg Opus or Vorbis file. The
+// header is read as it lies in the file, so comments crossing an Ogg page
+// boundary are cut there.
+func vorbisTags(r io.Reader) []Tag {
+	buf, _ := io.ReadAll(io.LimitReader(r, oggCommentMaxLen))
+	i := bytes.Index(buf, []byte("OpusTags"))
+	skip := len("OpusTags")
+	if i < 0 {
+		i, skip = bytes.Index(buf, []byte("\x03vorbis")), len("\x03vorbis")
+	}
+	if i < 0 {
+		return nil
+	}
+	b := buf[i+skip:]
+	next := func() ([]byte, bool) {
+		if len(b) < 4 {
+			return nil, false
+		}
+		n := int(binary.LittleEndian.Uint32(b[:4]))
+		if n > len(b)-4 {
+			return nil, false
+		}
+		s := b[4 : 4+n]
+		b = b[4+n:]
+		return s, true
+	}
+	if _, ok := next(); !ok { // vendor, the encoding library
+		return nil
+	}
+	if len(b) < 4 {
+		return nil
+	}
+	count := int(binary.LittleEndian.Uint32(b[:4]))
+	b = b[4:]
+	var tags []Tag
+	for range count {
+		c, ok := next()
+		if !ok {
+			break
+		}
+		key, value, ok := strings.Cut(string(c), "=")
+		if !ok {
+			continue
+		}
+		key = strings.ToUpper(key)
+		f, known := vorbisFields[key]
+		if !known {
+			f.name = "Vorbis " + key
+		}
+		tags = append(tags, Tag{Field: f.name, Category: f.category, Value: strings.ToValidUTF8(value, "�")})
+	}
+	return tags
+}
+
+// iso6709RE matches the start of an ISO 6709 position such as
+// +40.7128-074.0060+010.000/: latitude and longitude in degrees, or in
+// degrees and minutes (±DDMM.M, ±DDDMM.M) or and seconds too.
+var iso6709RE = regexp.MustCompile(`^([+-])(\d{2}|\d{4}|\d{6})(\.\d+)?([+-])(\d{3}|\d{5}|\d{7})(\.\d+)?`)
+
+// parseISO6709 reads the position of an ISO 6709 string, the format
+// phones write the location of recordings in.
+func parseISO6709(s string) (*GPS, bool) {
+	m := iso6709RE.FindStringSubmatch(s)
+	if m == nil {
+		return nil, false
+	}
+	lat, latUnit := iso6709Axis(m[1], m[2], m[3], 2)
+	lon, lonUnit := iso6709Axis(m[4], m[5], m[6], 3)
+	if math.Abs(lat) > 90 || math.Abs(lon) > 180 || (lat == 0 && lon == 0) {
+		return nil, false
+	}
+	return &GPS{Lat: lat, Lon: lon, PrecisionM: math.Max(latUnit, lonUnit) * metersPerDegree}, true
+}
+
+// iso6709Axis converts one axis, with degDigits digits of whole degrees,
+// and returns its value and the resolution it was written with, both in
+// degrees.
+func iso6709Axis(sign, digits, frac string, degDigits int) (float64, float64) {
+	f, _ := strconv.ParseFloat("0"+frac, 64)
+	unit := 1.0
+	if frac != "" {
+		unit = math.Pow(10, -float64(len(frac)-1))
+	}
+	var v float64
+	switch len(digits) - degDigits {
+	case 0:
+		deg, _ := strconv.Atoi(digits)
+		v = float64(deg) + f
+	case 2:
+		deg, _ := strconv.Atoi(digits[:degDigits])
+		mins, _ := strconv.Atoi(digits[degDigits:])
+		v, unit = float64(deg)+(float64(mins)+f)/60, unit/60
+	default:
+		deg, _ := strconv.Atoi(digits[:degDigits])
+		mins, _ := strconv.Atoi(digits[degDigits : degDigits+2])
+		secs, _ := strconv.Atoi(digits[degDigits+2:])
+		v, unit = float64(deg)+float64(mins)/60+(float64(secs)+f)/3600, unit/3600
+	}
+	if sign == "-" {
+		v = -v
+	}
+	return v, unit
+}
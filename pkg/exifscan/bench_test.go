@@ -0,0 +1,112 @@
+package exifscan
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"fmt"
+	"image"
+	"image/jpeg"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+)
+
+// benchJPEG returns a small JPEG with an EXIF block naming the camera,
+// padded past its end of image marker to size bytes.
+func benchJPEG(b *testing.B, size int) []byte {
+	b.Helper()
+	var img bytes.Buffer
+	if err := jpeg.Encode(&img, image.NewGray(image.Rect(0, 0, 64, 64)), nil); err != nil {
+		b.Fatal(err)
+	}
+
+	// TIFF header and one IFD with Make and Model, values after the IFD.
+	makeVal, modelVal := "Canon\x00", "Canon EOS 5D Mark IV\x00"
+	var tiff bytes.Buffer
+	tiff.WriteString("II*\x00")
+	binary.Write(&tiff, binary.LittleEndian, uint32(8))
+	binary.Write(&tiff, binary.LittleEndian, uint16(2))
+	data := uint32(8 + 2 + 2*12 + 4)
+	for _, e := range []struct {
+		tag uint16
+		val string
+	}{{0x010f, makeVal}, {0x0110, modelVal}} {
+		binary.Write(&tiff, binary.LittleEndian, e.tag)
+		binary.Write(&tiff, binary.LittleEndian, uint16(2)) // ASCII
+		binary.Write(&tiff, binary.LittleEndian, uint32(len(e.val)))
+		binary.Write(&tiff, binary.LittleEndian, data)
+		data += uint32(len(e.val))
+	}
+	binary.Write(&tiff, binary.LittleEndian, uint32(0))
+	tiff.WriteString(makeVal + modelVal)
+
+	app1 := append([]byte("Exif\x00\x00"), tiff.Bytes()...)
+	var out bytes.Buffer
+	out.Write([]byte{0xff, 0xd8, 0xff, 0xe1})
+	binary.Write(&out, binary.BigEndian, uint16(len(app1)+2))
+	out.Write(app1)
+	out.Write(img.Bytes()[2:])
+	if pad := size - out.Len(); pad > 0 {
+		out.Write(make([]byte, pad))
+	}
+	return out.Bytes()
+}
+
+// BenchmarkScanImages scans 64 images per op from a local server with 8
+// workers, announcing their length or sending them chunked.
+func BenchmarkScanImages(b *testing.B) {
+	for _, bc := range []struct {
+		name    string
+		size    int
+		chunked bool
+	}{
+		{"1MiB", 1 << 20, false},
+		{"1MiB-chunked", 1 << 20, true},
+		{"100KiB", 100 << 10, false},
+	} {
+		b.Run(bc.name, func(b *testing.B) {
+			buf := benchJPEG(b, bc.size)
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if !bc.chunked {
+					w.Header().Set("Content-Length", strconv.Itoa(len(buf)))
+				}
+				w.Write(buf)
+				if bc.chunked {
+					w.(http.Flusher).Flush()
+				}
+			}))
+			defer srv.Close()
+
+			images := make([]Image, 64)
+			for i := range images {
+				images[i] = Image{EventID: strconv.Itoa(i), URL: fmt.Sprintf("%s/%d.jpg", srv.URL, i)}
+			}
+			s := New(Options{Threads: 8, HTTPClient: srv.Client()})
+			b.SetBytes(int64(len(buf) * len(images)))
+			b.ReportAllocs()
+			b.ResetTimer()
+			for range b.N {
+				for r := range s.ScanImages(context.Background(), images) {
+					if r.Err != nil {
+						b.Fatal(r.Err)
+					}
+				}
+			}
+		})
+	}
+}
+
+// BenchmarkScanBytes inspects one JPEG already in memory.
+func BenchmarkScanBytes(b *testing.B) {
+	buf := benchJPEG(b, 0)
+	if r := ScanBytes(buf); !r.HasMetadata {
+		b.Fatal("benchmark image has no EXIF")
+	}
+	b.ReportAllocs()
+	b.ResetTimer()
+	for range b.N {
+		ScanBytes(buf)
+	}
+}
@@ -0,0 +1,68 @@
+package exifscan
+
+import (
+	"context"
+
+	"golang.org/x/sync/semaphore"
+)
+
+// DefaultMaxInFlightBytes is the default Options.MaxInFlightBytes.
+const DefaultMaxInFlightBytes = 256 << 20
+
+// unknownSize is reserved for downloads without a Content-Length; the
+// reservation doubles as the body turns out bigger.
+const unknownSize = 512 << 10
+
+// budget bounds the bytes all downloads of a Scanner hold at once.
+type budget struct {
+	sem  *semaphore.Weighted
+	size int64
+}
+
+func newBudget(size int64) *budget {
+	return &budget{sem: semaphore.NewWeighted(size), size: size}
+}
+
+// reservation is the part of the budget one download holds.
+type reservation struct {
+	b *budget
+	n int64
+}
+
+func (b *budget) reserve(ctx context.Context, n int64) (*reservation, error) {
+	r := &reservation{b: b}
+	return r, r.grow(ctx, n)
+}
+
+// grow makes the reservation at least n bytes. When the budget has no
+// room it gives back what it holds before waiting, so downloads that grow
+// at the same time can't deadlock all holding part of what they need.
+func (r *reservation) grow(ctx context.Context, n int64) error {
+	if n <= r.n {
+		return nil
+	}
+	if n > r.b.size {
+		return r.b.tooLarge()
+	}
+	if r.b.sem.TryAcquire(n - r.n) {
+		r.n = n
+		return nil
+	}
+	r.release()
+	if err := r.b.sem.Acquire(ctx, n); err != nil {
+		return err
+	}
+	r.n = n
+	return nil
+}
+
+func (b *budget) tooLarge() error {
+	return &TooLargeError{Limit: b.size}
+}
+
+func (r *reservation) release() {
+	if r.n > 0 {
+		r.b.sem.Release(r.n)
+		r.n = 0
+	}
+}
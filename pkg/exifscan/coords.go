@@ -0,0 +1,136 @@
+package exifscan
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"strings"
+)
+
+// Coordinate formats GPS positions are shown in.
+const (
+	CoordDecimal  = "decimal"
+	CoordDMS      = "dms"
+	CoordGeohash  = "geohash"
+	CoordPlusCode = "pluscode"
+)
+
+// CoordFormats lists the formats SetCoordFormat takes.
+var CoordFormats = []string{CoordDecimal, CoordDMS, CoordGeohash, CoordPlusCode}
+
+var coordFormat = CoordDecimal
+
+// SetCoordFormat sets the format GPS positions are shown and exported in.
+func SetCoordFormat(name string) error {
+	switch strings.ToLower(name) {
+	case "", CoordDecimal:
+		coordFormat = CoordDecimal
+	case CoordDMS:
+		coordFormat = CoordDMS
+	case CoordGeohash:
+		coordFormat = CoordGeohash
+	case CoordPlusCode, "olc":
+		coordFormat = CoordPlusCode
+	default:
+		return fmt.Errorf("unknown coordinate format %q, want one of %s", name, strings.Join(CoordFormats, ", "))
+	}
+	return nil
+}
+
+// String renders the position in the format set by SetCoordFormat.
+func (g GPS) String() string {
+	switch coordFormat {
+	case CoordDMS:
+		return dms(g.Lat, "N", "S") + " " + dms(g.Lon, "E", "W")
+	case CoordGeohash:
+		return EncodeGeohash(g.Lat, g.Lon, 9)
+	case CoordPlusCode:
+		return PlusCode(g.Lat, g.Lon)
+	}
+	return fmt.Sprintf("%.6f, %.6f", g.Lat, g.Lon)
+}
+
+// MarshalJSON adds the position as String renders it, as coordinates.
+func (g GPS) MarshalJSON() ([]byte, error) {
+	type plain GPS
+	return json.Marshal(struct {
+		plain
+		Coordinates string `json:"coordinates"`
+	}{plain(g), g.String()})
+}
+
+// axisString renders one signed coordinate of a GPSLatitude or
+// GPSLongitude tag, ref being its hemisphere.
+func axisString(deg float64, ref string) string {
+	if coordFormat == CoordDMS {
+		pos, neg := "N", "S"
+		if ref == "E" || ref == "W" {
+			pos, neg = "E", "W"
+		}
+		return dms(deg*sign(ref), pos, neg)
+	}
+	return fmt.Sprintf("%.6f° (%s)", deg, ref)
+}
+
+// dms renders decimal degrees as degrees, minutes and seconds, e.g.
+// 40°42'46.1"N.
+func dms(deg float64, pos, neg string) string {
+	hemi := pos
+	if deg < 0 {
+		hemi, deg = neg, -deg
+	}
+	// Round to a tenth of a second first so 59.96" doesn't print as 60.0".
+	tenths := int64(math.Round(deg * 36000))
+	d, m, s := tenths/36000, tenths/600%60, float64(tenths%600)/10
+	return fmt.Sprintf("%d°%d'%.1f\"%s", d, m, s, hemi)
+}
+
+// EncodeGeohash returns the geohash of length n of a position.
+func EncodeGeohash(lat, lon float64, n int) string {
+	latR, lonR := [2]float64{-90, 90}, [2]float64{-180, 180}
+	var b strings.Builder
+	even, v, bits := true, 0, 0
+	for b.Len() < n {
+		rng, x := &latR, lat
+		if even {
+			rng, x = &lonR, lon
+		}
+		mid := (rng[0] + rng[1]) / 2
+		v <<= 1
+		if x >= mid {
+			v |= 1
+			rng[0] = mid
+		} else {
+			rng[1] = mid
+		}
+		even = !even
+		if bits++; bits == 5 {
+			b.WriteByte(geohashAlphabet[v])
+			v, bits = 0, 0
+		}
+	}
+	return b.String()
+}
+
+const plusCodeAlphabet = "23456789CFGHJMPQRVWX"
+
+// PlusCode returns the 11 digit Open Location Code of a position, a cell
+// of about 3 by 3 meters, e.g. 8FVC9G8F+6XQ.
+func PlusCode(lat, lon float64) string {
+	// Work in integer units of the last digit's cell: 1/8000 of a degree
+	// divided into 5 rows and 4 columns.
+	const latUnits, lonUnits = 8000 * 5, 8000 * 4
+	la := int64(math.Floor((max(-90, min(90, lat)) + 90) * latUnits))
+	la = min(la, 180*latUnits-1)
+	lo := int64(math.Floor((lon + 180) * lonUnits))
+	lo = (lo%(360*lonUnits) + 360*lonUnits) % (360 * lonUnits)
+
+	grid := plusCodeAlphabet[(la%5)*4+lo%4]
+	la, lo = la/5, lo/4
+	var pairs [10]byte
+	for i := 8; i >= 0; i -= 2 {
+		pairs[i], pairs[i+1] = plusCodeAlphabet[la%20], plusCodeAlphabet[lo%20]
+		la, lo = la/20, lo/20
+	}
+	return string(pairs[:8]) + "+" + string(pairs[8:]) + string(grid)
+}
@@ -0,0 +1,46 @@
+package exifscan
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/http"
+)
+
+// Reasons in ImageResult.Dead.
+const (
+	DeadNotFound = "404"
+	DeadGone     = "410"
+	DeadTimeout  = "timeout"
+)
+
+// deadReason tells whether a failed download means the media is gone, as
+// opposed to a host error that may pass.
+func deadReason(err error) string {
+	var se *StatusError
+	if errors.As(err, &se) {
+		switch se.StatusCode {
+		case http.StatusNotFound:
+			return DeadNotFound
+		case http.StatusGone:
+			return DeadGone
+		}
+		return ""
+	}
+	var ne net.Error
+	if errors.Is(err, context.DeadlineExceeded) || errors.As(err, &ne) && ne.Timeout() {
+		return DeadTimeout
+	}
+	return ""
+}
+
+// Dead returns the images whose links are broken.
+func (f *Findings) Dead() []*ImageResult {
+	var out []*ImageResult
+	for _, r := range f.Images {
+		if r.Dead != "" {
+			out = append(out, r)
+		}
+	}
+	return out
+}
@@ -0,0 +1,287 @@
+package exifscan
+
+import (
+	"bytes"
+	"io"
+	"slices"
+	"strings"
+
+	exif "github.com/rwcarlsen/goexif/exif"
+	"github.com/rwcarlsen/goexif/tiff"
+)
+
+// goexif doesn't map these Exif and GPS sub-IFD tags, so load them
+// ourselves.
+const (
+	CameraOwnerName      exif.FieldName = "CameraOwnerName"
+	BodySerialNumber     exif.FieldName = "BodySerialNumber"
+	LensSerialNumber     exif.FieldName = "LensSerialNumber"
+	GPSHPositioningError exif.FieldName = "GPSHPositioningError"
+	OffsetTimeOriginal   exif.FieldName = "OffsetTimeOriginal"
+)
+
+// exifIFDFields are loaded in one walk of the Exif sub-IFD: the owner
+// and serial numbers, and the UTC offset of DateTimeOriginal.
+var exifIFDFields = map[uint16]exif.FieldName{
+	0xA430: CameraOwnerName,
+	0xA431: BodySerialNumber,
+	0xA435: LensSerialNumber,
+	0x9011: OffsetTimeOriginal,
+}
+
+var gpsErrorFields = map[uint16]exif.FieldName{
+	0x001F: GPSHPositioningError,
+}
+
+// extraParser loads fields from the sub-IFD pointer points to.
+type extraParser struct {
+	pointer exif.FieldName
+	fields  map[uint16]exif.FieldName
+}
+
+func (p extraParser) Parse(x *exif.Exif) error {
+	tag, err := x.Get(p.pointer)
+	if err != nil {
+		return nil
+	}
+	offset, err := tag.Int64(0)
+	if err != nil {
+		return nil
+	}
+	// Only decode the missing tags: goexif already decoded the rest of the
+	// sub-IFD, and decoding it all again doubled the cost of Decode.
+	raw, order := x.Raw, x.Tiff.Order
+	if offset < 0 || offset+2 > int64(len(raw)) {
+		return nil
+	}
+	r := bytes.NewReader(raw)
+	dir := &tiff.Dir{}
+	for i := range int64(order.Uint16(raw[offset:])) {
+		at := offset + 2 + 12*i
+		if at+12 > int64(len(raw)) {
+			break
+		}
+		if _, ok := p.fields[order.Uint16(raw[at:])]; !ok {
+			continue
+		}
+		r.Seek(at, io.SeekStart)
+		if t, err := tiff.DecodeTag(r, order); err == nil {
+			dir.Tags = append(dir.Tags, t)
+		}
+	}
+	x.LoadTags(dir, p.fields, false)
+	return nil
+}
+
+func init() {
+	exif.RegisterParsers(
+		extraParser{exif.ExifIFDPointer, exifIFDFields},
+		extraParser{exif.GPSInfoIFDPointer, gpsErrorFields},
+	)
+}
+
+// Leak categories reported for sensitive tags.
+const (
+	CategoryGPS       = "GPS"
+	CategoryDevice    = "device"
+	CategorySerial    = "serial"
+	CategoryOwner     = "owner"
+	CategoryTimestamp = "timestamp"
+	CategorySoftware  = "software"
+	CategoryLens      = "lens"
+	// CategoryPlace is a place name in the image's file name or URL, or
+	// the location field of an audio file.
+	CategoryPlace = "place"
+	// Categories of personal details in profile text, see ProfileLeaks.
+	CategoryPhone   = "phone"
+	CategoryEmail   = "email"
+	CategoryAddress = "address"
+	CategoryName    = "name"
+)
+
+// Severities, from least to most dangerous.
+const (
+	SeverityLow    = "low"
+	SeverityMedium = "medium"
+	SeverityHigh   = "high"
+)
+
+// CategorySeverity rates how dangerous each leak category is. Location and
+// identifiers that tie photos to one person or device rate high.
+var CategorySeverity = map[string]string{
+	CategoryGPS:       SeverityHigh,
+	CategorySerial:    SeverityHigh,
+	CategoryOwner:     SeverityHigh,
+	CategoryDevice:    SeverityMedium,
+	CategoryPlace:     SeverityMedium,
+	CategoryPhone:     SeverityHigh,
+	CategoryAddress:   SeverityHigh,
+	CategoryEmail:     SeverityMedium,
+	CategoryName:      SeverityMedium,
+	CategoryLens:      SeverityLow,
+	CategoryTimestamp: SeverityLow,
+	CategorySoftware:  SeverityLow,
+}
+
+// SeverityRank orders severities; unknown values rank 0.
+func SeverityRank(severity string) int {
+	switch severity {
+	case SeverityLow:
+		return 1
+	case SeverityMedium:
+		return 2
+	case SeverityHigh:
+		return 3
+	}
+	return 0
+}
+
+// SensitiveTag maps an EXIF field to the category of information it leaks.
+type SensitiveTag struct {
+	Field    exif.FieldName
+	Category string
+}
+
+// SensitiveTags lists the fields that flag an image, in reporting order.
+var SensitiveTags = []SensitiveTag{
+	{exif.GPSLatitude, CategoryGPS},
+	{exif.GPSLongitude, CategoryGPS},
+	{exif.GPSAltitude, CategoryGPS},
+	{exif.GPSTimeStamp, CategoryGPS},
+	{exif.GPSDateStamp, CategoryGPS},
+	{exif.GPSImgDirection, CategoryGPS},
+	{exif.Model, CategoryDevice},
+	{exif.Make, CategoryDevice},
+	{BodySerialNumber, CategorySerial},
+	{LensSerialNumber, CategorySerial},
+	{CameraOwnerName, CategoryOwner},
+	{exif.Artist, CategoryOwner},
+	{exif.DateTimeOriginal, CategoryTimestamp},
+	{exif.FieldName("CreateDate"), CategoryTimestamp},
+	{exif.Software, CategorySoftware},
+	{exif.LensModel, CategoryLens},
+	{exif.LensMake, CategoryLens},
+}
+
+// Decode returns nil when buf carries no usable EXIF block. Partially
+// decoded data (e.g. a corrupt interop IFD) is still returned. The
+// format is told from the bytes, so a PNG named .jpg is read as a PNG.
+func Decode(buf []byte) *exif.Exif {
+	return decodeAs(sniff(buf[:min(len(buf), sniffLen)]), bytes.NewReader(buf))
+}
+
+func decodeReader(r io.Reader) *exif.Exif {
+	x, err := exif.Decode(r)
+	if x == nil || (err != nil && exif.IsCriticalError(err)) {
+		return nil
+	}
+	return x
+}
+
+// Inspect extracts the sensitive tags and GPS position from x.
+func Inspect(x *exif.Exif) ([]Tag, *GPS) {
+	var tags []Tag
+	var lat, lon float64
+	var latRef, lonRef string
+	var latTag, lonTag *tiff.Tag
+	var haveLat, haveLon bool
+
+	for _, st := range SensitiveTags {
+		tag, err := x.Get(st.Field)
+		if err != nil {
+			continue
+		}
+		t := Tag{Field: string(st.Field), Category: st.Category}
+		if st.Field == exif.GPSLatitude || st.Field == exif.GPSLongitude {
+			refTag, _ := x.Get(exif.FieldName(string(st.Field) + "Ref"))
+			var ref string
+			if refTag != nil {
+				ref, _ = refTag.StringVal()
+			}
+			num0, denom0, err0 := tag.Rat2(0)
+			num1, denom1, err1 := tag.Rat2(1)
+			num2, denom2, err2 := tag.Rat2(2)
+			if err0 == nil && err1 == nil && err2 == nil && denom0 != 0 && denom1 != 0 && denom2 != 0 {
+				deg := float64(num0) / float64(denom0)
+				min := float64(num1) / float64(denom1)
+				sec := float64(num2) / float64(denom2)
+				total := deg + (min / 60) + (sec / 3600)
+				if st.Field == exif.GPSLatitude {
+					lat, latRef, latTag, haveLat = total, ref, tag, true
+				} else {
+					lon, lonRef, lonTag, haveLon = total, ref, tag, true
+				}
+				t.Value = axisString(total, ref)
+			}
+		} else if val, err := tag.StringVal(); err == nil {
+			t.Value = strings.TrimRight(val, "\x00 ")
+			if st.Field == exif.DateTimeOriginal {
+				t.Value = cameraTime(t.Value, stringTag(x, OffsetTimeOriginal))
+			}
+		} else {
+			t.Value = tag.String()
+		}
+		tags = append(tags, t)
+	}
+
+	if haveLat && haveLon && lat != 0 && lon != 0 {
+		g := &GPS{Lat: lat * sign(latRef), Lon: lon * sign(lonRef)}
+		g.setAccuracy(x, latTag, lonTag)
+		return tags, g
+	}
+	return tags, nil
+}
+
+// maxTagValue bounds the values AllTags returns; maker notes and
+// thumbnails would otherwise print as kilobytes of numbers.
+const maxTagValue = 200
+
+// AllTags returns every field decoded from x, sensitive or not, sorted by
+// name. Category is only set for SensitiveTags.
+func AllTags(x *exif.Exif) []Tag {
+	category := map[exif.FieldName]string{}
+	for _, st := range SensitiveTags {
+		category[st.Field] = st.Category
+	}
+	var w tagWalker
+	x.Walk(&w)
+	for i := range w {
+		w[i].Category = category[exif.FieldName(w[i].Field)]
+	}
+	slices.SortFunc(w, func(a, b Tag) int { return strings.Compare(a.Field, b.Field) })
+	return w
+}
+
+type tagWalker []Tag
+
+func (w *tagWalker) Walk(name exif.FieldName, tag *tiff.Tag) error {
+	val, err := tag.StringVal()
+	if err != nil {
+		val = tag.String()
+	}
+	val = strings.TrimRight(val, "\x00 ")
+	if r := []rune(val); len(r) > maxTagValue {
+		val = string(r[:maxTagValue]) + "…"
+	}
+	*w = append(*w, Tag{Field: string(name), Value: val})
+	return nil
+}
+
+// stringTag returns the value of a string field, or "".
+func stringTag(x *exif.Exif, name exif.FieldName) string {
+	tag, err := x.Get(name)
+	if err != nil {
+		return ""
+	}
+	val, _ := tag.StringVal()
+	return strings.TrimRight(val, "\x00 ")
+}
+
+func sign(ref string) float64 {
+	switch ref {
+	case "S", "W":
+		return -1
+	default:
+		return 1
+	}
+}
@@ -0,0 +1,85 @@
+package exifscan
+
+import (
+	"bytes"
+	"encoding/binary"
+	"testing"
+
+	exif "github.com/rwcarlsen/goexif/exif"
+)
+
+// ifdEntry is a TIFF directory entry.
+type ifdEntry struct {
+	Tag, Type    uint16
+	Count, Value uint32
+}
+
+// tiffWithExifIFD returns a little endian TIFF whose IFD0 only points to
+// an Exif sub-IFD holding the given ASCII tags.
+func tiffWithExifIFD(tags map[uint16]string) []byte {
+	const exifIFD = 8 + 2 + 12 + 4
+	ids := make([]uint16, 0, len(tags))
+	for id := range tags {
+		ids = append(ids, id)
+	}
+	var b, data bytes.Buffer
+	le := binary.LittleEndian
+	b.WriteString("II*\x00")
+	binary.Write(&b, le, uint32(8))
+	binary.Write(&b, le, uint16(1))
+	binary.Write(&b, le, ifdEntry{0x8769, 4, 1, exifIFD})
+	binary.Write(&b, le, uint32(0))
+	binary.Write(&b, le, uint16(len(ids)))
+	at := uint32(exifIFD + 2 + 12*len(ids) + 4)
+	for _, id := range ids {
+		val := tags[id] + "\x00"
+		e := ifdEntry{id, 2, uint32(len(val)), at + uint32(data.Len())}
+		if len(val) <= 4 {
+			var inline [4]byte
+			copy(inline[:], val)
+			e.Value = le.Uint32(inline[:])
+		} else {
+			data.WriteString(val)
+		}
+		binary.Write(&b, le, e)
+	}
+	binary.Write(&b, le, uint32(0))
+	b.Write(data.Bytes())
+	return b.Bytes()
+}
+
+func TestExifIFDFields(t *testing.T) {
+	for _, tc := range []struct {
+		name string
+		tags map[uint16]string
+		want map[exif.FieldName]string
+	}{
+		{
+			name: "offset and serial",
+			tags: map[uint16]string{0x9003: "2024:05:01 12:00:00", 0x9011: "+02:00", 0xA431: "123456789"},
+			want: map[exif.FieldName]string{OffsetTimeOriginal: "+02:00", BodySerialNumber: "123456789", CameraOwnerName: ""},
+		},
+		{
+			name: "offset only",
+			tags: map[uint16]string{0x9003: "2024:05:01 12:00:00", 0x9011: "-05:30"},
+			want: map[exif.FieldName]string{OffsetTimeOriginal: "-05:30", BodySerialNumber: ""},
+		},
+		{
+			name: "neither",
+			tags: map[uint16]string{0x9003: "2024:05:01 12:00:00"},
+			want: map[exif.FieldName]string{OffsetTimeOriginal: "", LensSerialNumber: ""},
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			x, err := exif.Decode(bytes.NewReader(tiffWithExifIFD(tc.tags)))
+			if err != nil {
+				t.Fatal(err)
+			}
+			for field, want := range tc.want {
+				if got := stringTag(x, field); got != want {
+					t.Errorf("%s = %q, want %q", field, got, want)
+				}
+			}
+		})
+	}
+}
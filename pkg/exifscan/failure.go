@@ -0,0 +1,129 @@
+package exifscan
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"strings"
+	"syscall"
+	"time"
+)
+
+// Error classes of failed downloads, in ImageResult.ErrorClass.
+const (
+	FailNotFound    = "not_found"
+	FailThrottled   = "throttled"
+	FailClientError = "client_error"
+	FailServerError = "server_error"
+	FailTimeout     = "timeout"
+	FailDNS         = "dns"
+	FailRefused     = "refused"
+	FailConnection  = "connection"
+	FailTLS         = "tls"
+	FailTooLarge    = "too_large"
+	FailRead        = "read"
+	FailOther       = "other"
+)
+
+// DefaultRetries is how often a download failing with a transient error
+// is tried again.
+const DefaultRetries = 2
+
+// TooLargeError is an image that doesn't fit the in-flight budget, or
+// with Spooled, the spool size limit.
+type TooLargeError struct {
+	Limit   int64
+	Spooled bool
+}
+
+func (e *TooLargeError) Error() string {
+	if e.Spooled {
+		return fmt.Sprintf("image larger than the %d MiB spool limit", e.Limit>>20)
+	}
+	return fmt.Sprintf("image larger than the %d MiB in-flight budget", e.Limit>>20)
+}
+
+// FailureLabel spells out an error class for people: "not found".
+func FailureLabel(class string) string {
+	return strings.ReplaceAll(class, "_", " ")
+}
+
+// classifyFailure returns the HTTP status, if any, and the error class of
+// a failed download.
+func classifyFailure(stage string, err error) (int, string) {
+	var se *StatusError
+	if errors.As(err, &se) {
+		switch c := se.StatusCode; {
+		case c == http.StatusNotFound || c == http.StatusGone:
+			return c, FailNotFound
+		case c == http.StatusTooManyRequests:
+			return c, FailThrottled
+		case c >= 500:
+			return c, FailServerError
+		default:
+			return c, FailClientError
+		}
+	}
+	var dnsErr *net.DNSError
+	var tooLarge *TooLargeError
+	var certErr *tls.CertificateVerificationError
+	var authErr x509.UnknownAuthorityError
+	var hostErr x509.HostnameError
+	var recordErr tls.RecordHeaderError
+	switch {
+	case errors.As(err, &tooLarge):
+		return 0, FailTooLarge
+	case errors.As(err, &dnsErr):
+		return 0, FailDNS
+	case deadReason(err) == DeadTimeout:
+		return 0, FailTimeout
+	case errors.As(err, &certErr) || errors.As(err, &authErr) || errors.As(err, &hostErr) || errors.As(err, &recordErr):
+		return 0, FailTLS
+	case errors.Is(err, syscall.ECONNREFUSED):
+		return 0, FailRefused
+	case errors.Is(err, syscall.ECONNRESET) || errors.Is(err, io.ErrUnexpectedEOF) || errors.Is(err, io.EOF):
+		return 0, FailConnection
+	case stage == StageRead:
+		return 0, FailRead
+	}
+	return 0, FailOther
+}
+
+// transient reports whether a failure of class may pass when tried again.
+func transient(class string) bool {
+	return class == FailThrottled || class == FailServerError || class == FailConnection
+}
+
+// retryDelay waits before try number n (from 1) of a download that failed
+// with err, as long as the server's Retry-After asks, or 500ms doubling.
+func retryDelay(ctx context.Context, n int, err error) error {
+	d := 500 * time.Millisecond << (n - 1)
+	var se *StatusError
+	if errors.As(err, &se) && se.RetryAfter > 0 {
+		d = se.RetryAfter
+	}
+	t := time.NewTimer(d)
+	defer t.Stop()
+	select {
+	case <-t.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// Failed returns the images that couldn't be downloaded or read.
+func (f *Findings) Failed() []*ImageResult {
+	var out []*ImageResult
+	for _, r := range f.Images {
+		if r.Error != "" {
+			out = append(out, r)
+		}
+	}
+	return out
+}
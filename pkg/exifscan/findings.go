@@ -0,0 +1,263 @@
+package exifscan
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"net/url"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/nbd-wtf/go-nostr"
+	exif "github.com/rwcarlsen/goexif/exif"
+)
+
+// Tag is a sensitive EXIF field found in an image.
+type Tag struct {
+	Field    string `json:"field"`
+	Category string `json:"category"`
+	Value    string `json:"value,omitempty"`
+}
+
+// GPS is a decoded position in signed decimal degrees.
+type GPS struct {
+	Lat float64 `json:"lat"`
+	Lon float64 `json:"lon"`
+	// PrecisionM is the resolution the coordinates were written with and
+	// ErrorM the receiver's horizontal error, from GPSHPositioningError
+	// or estimated from GPSDOP, both in meters; see Radius.
+	PrecisionM float64 `json:"precision_m,omitempty"`
+	ErrorM     float64 `json:"error_m,omitempty"`
+	DOP        float64 `json:"dop,omitempty"`
+}
+
+// MapsURL links the position on Google Maps.
+func (g GPS) MapsURL() string {
+	return fmt.Sprintf("https://maps.google.com/?q=%.6f,%+.6f", g.Lat, g.Lon)
+}
+
+// ImageResult is the outcome of scanning one image link.
+type ImageResult struct {
+	EventID string `json:"event_id,omitempty"`
+	URL     string `json:"url,omitempty"`
+	SHA256  string `json:"sha256,omitempty"`
+	// DeclaredSHA256 is the hash the file was published with. When the
+	// downloaded bytes hash differently, HashMismatch is set: what was
+	// scanned isn't what the author posted.
+	DeclaredSHA256 string `json:"declared_sha256,omitempty"`
+	HashMismatch   bool   `json:"hash_mismatch,omitempty"`
+	Size           int    `json:"size,omitempty"`
+	// ContentType is the format told from the bytes. TypeMismatch is set
+	// when the URL's extension announces another one, e.g. a .jpg link
+	// serving a PNG or an HTML error page.
+	ContentType  string `json:"content_type,omitempty"`
+	TypeMismatch bool   `json:"type_mismatch,omitempty"`
+	HasMetadata  bool   `json:"has_metadata"`
+	Tags         []Tag  `json:"tags,omitempty"`
+	GPS          *GPS   `json:"gps,omitempty"`
+	// AllTags is every decoded field, sensitive or not, with
+	// Options.AllTags.
+	AllTags []Tag  `json:"all_tags,omitempty"`
+	Error   string `json:"error,omitempty"`
+	// Status is the HTTP status a failed download was answered with,
+	// ErrorClass one of the Fail constants and Retries how often it was
+	// tried again.
+	Status     int    `json:"status,omitempty"`
+	ErrorClass string `json:"error_class,omitempty"`
+	Retries    int    `json:"retries,omitempty"`
+	// Dead is why the link is broken, one of DeadNotFound, DeadGone or
+	// DeadTimeout, when the download failed that way.
+	Dead string `json:"dead,omitempty"`
+	// Archived is the Wayback Machine snapshot scanned in place of a dead
+	// link; the results describe the archived copy.
+	Archived string `json:"archived,omitempty"`
+	// Origin is the original an image proxy link wraps, scanned with
+	// Options.ProxyOrigins. OriginLeaks is set when the original leaks
+	// while the proxied copy doesn't; the tags are then the original's.
+	// When the proxy link itself can't be downloaded, the original is
+	// scanned in its place, ProxyError tells why and the results describe
+	// the original.
+	Origin      string `json:"origin_url,omitempty"`
+	OriginLeaks bool   `json:"origin_leaks,omitempty"`
+	ProxyError  string `json:"proxy_error,omitempty"`
+	// Duration is the time spent fetching and inspecting the image.
+	Duration time.Duration `json:"duration_ns,omitempty"`
+	// Source is the media server the file was listed on, for files that
+	// no note links to.
+	Source string `json:"source,omitempty"`
+	// Relays the linking note was seen on, used as nevent relay hints.
+	Relays []string `json:"relays,omitempty"`
+	// Provenance guesses whether the file is an original, a recompressed
+	// copy or had its metadata stripped, which tells how far a clean
+	// verdict can be trusted.
+	Provenance *Provenance `json:"provenance,omitempty"`
+	// Post describes the linking note.
+	Post *PostContext `json:"post,omitempty"`
+	// GeoTag compares the GPS position with the geohash the note was
+	// tagged with, when it has both.
+	GeoTag *GeoTagCheck `json:"geo_tag,omitempty"`
+	// ExternalRefs are the URLs an SVG loads or links to, which tell its
+	// host who views it and can swap what it shows.
+	ExternalRefs []string `json:"external_refs,omitempty"`
+	// VisibleText is the text Options.OCR read off the image.
+	VisibleText string `json:"visible_text,omitempty"`
+	// PHash is the image's PerceptualHash in hex, set with
+	// Options.PerceptualHash for JPEG, PNG and GIF images.
+	PHash string `json:"phash,omitempty"`
+	// Stego are the signs of hidden data found with Options.Stego.
+	Stego []StegoHint `json:"stego,omitempty"`
+	// Archive lists the files of a zip archive; the tags of those scanned
+	// are the archive's, their fields prefixed with the file name.
+	Archive []ArchiveFile `json:"archive,omitempty"`
+	// Fingerprint identifies a leak across runs; it is set for images with
+	// sensitive tags.
+	Fingerprint string `json:"fingerprint,omitempty"`
+
+	// Event is the note that linked the image.
+	Event *nostr.Event `json:"-"`
+	// Data and Exif are only retained when Options.KeepData is set.
+	// Images spooled to disk keep the temp file in DataFile instead of
+	// Data; Discard removes it.
+	Data     []byte     `json:"-"`
+	DataFile string     `json:"-"`
+	Exif     *exif.Exif `json:"-"`
+	Err      error      `json:"-"`
+}
+
+// Discard drops the data KeepData retained.
+func (r *ImageResult) Discard() {
+	if r.DataFile != "" {
+		os.Remove(r.DataFile)
+	}
+	r.Data, r.DataFile, r.Exif = nil, "", nil
+}
+
+// Sensitive reports whether the image carries any sensitive tag.
+func (r *ImageResult) Sensitive() bool {
+	return len(r.Tags) > 0
+}
+
+// AddTags adds sensitive tags found outside the image's metadata, such
+// as URLLocation's, and updates Fingerprint.
+func (r *ImageResult) AddTags(tags ...Tag) {
+	if len(tags) == 0 {
+		return
+	}
+	r.Tags = append(r.Tags, tags...)
+	r.Fingerprint = r.fingerprint()
+}
+
+// SetTags replaces the sensitive tags, e.g. with the ones a filter kept,
+// and updates Fingerprint, which is cleared when none are left.
+func (r *ImageResult) SetTags(tags []Tag) {
+	r.Tags = tags
+	r.Fingerprint = ""
+	if r.Sensitive() {
+		r.Fingerprint = r.fingerprint()
+	}
+}
+
+// Verdicts of an inventory.
+const (
+	VerdictClean       = "clean"
+	VerdictLeaking     = "leaking"
+	VerdictUnreachable = "unreachable"
+)
+
+// Verdict sums up the result: unreachable when the image couldn't be
+// fetched or read, leaking when it has sensitive tags, clean otherwise.
+func (r *ImageResult) Verdict() string {
+	switch {
+	case r.Error != "":
+		return VerdictUnreachable
+	case r.Sensitive():
+		return VerdictLeaking
+	}
+	return VerdictClean
+}
+
+// VerdictDetail explains the verdict: the leak categories, the error, or
+// for a clean image whether it had metadata at all and where it likely
+// came from.
+func (r *ImageResult) VerdictDetail() string {
+	switch r.Verdict() {
+	case VerdictUnreachable:
+		return r.Error
+	case VerdictLeaking:
+		return strings.Join(r.Categories(), ", ")
+	}
+	s := "no EXIF"
+	if r.HasMetadata {
+		s = "EXIF without sensitive tags"
+	}
+	if r.Provenance != nil {
+		s += ", " + r.Provenance.Class
+	}
+	return s
+}
+
+// Categories returns the distinct leak categories in SensitiveTags order.
+func (r *ImageResult) Categories() []string {
+	var out []string
+	seen := map[string]bool{}
+	for _, t := range r.Tags {
+		if !seen[t.Category] {
+			seen[t.Category] = true
+			out = append(out, t.Category)
+		}
+	}
+	return out
+}
+
+// fingerprint hashes the note, the image bytes and the leaked categories,
+// leaving out the URL, which mirrors and re-hosting change.
+func (r *ImageResult) fingerprint() string {
+	cats := r.Categories()
+	sort.Strings(cats)
+	sum := sha256.Sum256([]byte(r.EventID + "\x00" + r.SHA256 + "\x00" + strings.Join(cats, ",")))
+	return hex.EncodeToString(sum[:16])
+}
+
+// Severity is the highest severity among the image's leak categories, or
+// "" when it has none.
+func (r *ImageResult) Severity() string {
+	best := ""
+	for _, t := range r.Tags {
+		if sev := CategorySeverity[t.Category]; SeverityRank(sev) > SeverityRank(best) {
+			best = sev
+		}
+	}
+	return best
+}
+
+// Host returns the host serving the image.
+func (r *ImageResult) Host() string {
+	u, err := url.Parse(r.URL)
+	if err != nil {
+		return ""
+	}
+	return u.Hostname()
+}
+
+// Findings is the result of a full scan of one author.
+type Findings struct {
+	Pubkey string         `json:"pubkey"`
+	Events int            `json:"events"`
+	Images []*ImageResult `json:"images"`
+	// Profile lists the personal details found in the account's kind 0
+	// metadata, with Options.Profile.
+	Profile []Tag `json:"profile,omitempty"`
+}
+
+// Flagged returns the images that carry sensitive metadata.
+func (f *Findings) Flagged() []*ImageResult {
+	var out []*ImageResult
+	for _, r := range f.Images {
+		if r.Sensitive() {
+			out = append(out, r)
+		}
+	}
+	return out
+}
@@ -0,0 +1,174 @@
+package exifscan
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"io"
+	"net/http"
+	"net/url"
+	"path"
+	"strings"
+
+	exif "github.com/rwcarlsen/goexif/exif"
+)
+
+// sniffLen is how much of a file sniff looks at.
+const sniffLen = 512
+
+// maxChunk bounds the metadata chunk read from a PNG or WebP file.
+const maxChunk = 16 << 20
+
+// sniff names the format of the file starting with head, by its magic
+// bytes rather than what the URL or the server claim.
+func sniff(head []byte) string {
+	if len(head) >= 12 && string(head[4:8]) == "ftyp" {
+		switch string(head[8:12]) {
+		case "heic", "heix", "heim", "heis", "mif1", "msf1":
+			return "image/heic"
+		case "avif", "avis":
+			return "image/avif"
+		case "M4A ", "M4B ":
+			return "audio/mp4"
+		}
+	}
+	if bytes.HasPrefix(head, []byte("II*\x00")) || bytes.HasPrefix(head, []byte("MM\x00*")) {
+		return "image/tiff"
+	}
+	ct := http.DetectContentType(head)
+	ct, _, _ = strings.Cut(ct, ";")
+	if (ct == "text/xml" || ct == "text/plain") && isSVG(head) {
+		return "image/svg+xml"
+	}
+	return ct
+}
+
+var extTypes = map[string]string{
+	".jpg":  "image/jpeg",
+	".jpeg": "image/jpeg",
+	".png":  "image/png",
+	".gif":  "image/gif",
+	".webp": "image/webp",
+	".svg":  "image/svg+xml",
+	".heic": "image/heic",
+	".heif": "image/heic",
+	".avif": "image/avif",
+	".tif":  "image/tiff",
+	".tiff": "image/tiff",
+	".mp3":  "audio/mpeg",
+	".m4a":  "audio/mp4",
+	".opus": "application/ogg",
+	".ogg":  "application/ogg",
+	".pdf":  "application/pdf",
+	".zip":  "application/zip",
+}
+
+// sameContainer tells formats that only differ by the brand of an MP4
+// file: recorders write M4A audio as plain MP4.
+func sameContainer(a, b string) bool {
+	return (a == "audio/mp4" || a == "video/mp4") && (b == "audio/mp4" || b == "video/mp4")
+}
+
+// extType is the format the extension of link's path announces, or "".
+func extType(link string) string {
+	u, err := url.Parse(link)
+	if err != nil {
+		return ""
+	}
+	return extTypes[strings.ToLower(path.Ext(u.Path))]
+}
+
+// decodeAs finds the EXIF block where the format keeps it: a PNG eXIf
+// chunk, a WebP EXIF chunk, or the Exif item of a HEIC/AVIF file. JPEG,
+// TIFF and anything unrecognised go to goexif as they are.
+func decodeAs(format string, r io.Reader) *exif.Exif {
+	switch {
+	case format == "image/png":
+		return decodeChunk(pngChunk(r, "eXIf"))
+	case format == "image/webp":
+		return decodeChunk(webpChunk(r, "EXIF"))
+	case format == "image/heic" || format == "image/avif":
+		return decodeReader(bytes.NewReader(exifItem(r)))
+	case format == "image/gif" || strings.HasPrefix(format, "text/"):
+		// goexif would take them for a JPEG without EXIF.
+		return nil
+	}
+	return decodeReader(r)
+}
+
+func decodeChunk(chunk []byte) *exif.Exif {
+	if chunk == nil {
+		return nil
+	}
+	return decodeReader(bytes.NewReader(chunk))
+}
+
+// pngChunk returns the data of the first chunk of the given type.
+func pngChunk(r io.Reader, typ string) []byte {
+	br := bufio.NewReader(r)
+	if _, err := br.Discard(8); err != nil {
+		return nil
+	}
+	var hdr [8]byte
+	for {
+		if _, err := io.ReadFull(br, hdr[:]); err != nil {
+			return nil
+		}
+		n := binary.BigEndian.Uint32(hdr[:4])
+		switch string(hdr[4:]) {
+		case typ:
+			return readChunk(br, int64(n))
+		case "IDAT", "IEND":
+			// eXIf must come before the image data.
+			return nil
+		}
+		if _, err := br.Discard(int(n) + 4); err != nil {
+			return nil
+		}
+	}
+}
+
+// webpChunk returns the data of the first RIFF chunk of the given type.
+func webpChunk(r io.Reader, typ string) []byte {
+	br := bufio.NewReader(r)
+	if _, err := br.Discard(12); err != nil {
+		return nil
+	}
+	var hdr [8]byte
+	for {
+		if _, err := io.ReadFull(br, hdr[:]); err != nil {
+			return nil
+		}
+		n := int64(binary.LittleEndian.Uint32(hdr[4:]))
+		if string(hdr[:4]) == typ {
+			return readChunk(br, n)
+		}
+		// Chunks are padded to an even size.
+		if _, err := br.Discard(int(n + n&1)); err != nil {
+			return nil
+		}
+	}
+}
+
+func readChunk(r io.Reader, n int64) []byte {
+	if n > maxChunk {
+		return nil
+	}
+	buf := make([]byte, n)
+	if _, err := io.ReadFull(r, buf); err != nil {
+		return nil
+	}
+	return buf
+}
+
+// exifItem looks for the Exif item of a HEIF container. Rather than
+// resolving the item locations of the meta box, it looks for the
+// "Exif\0\0" header the item starts with in the first maxChunk bytes.
+func exifItem(r io.Reader) []byte {
+	buf, _ := io.ReadAll(io.LimitReader(r, maxChunk))
+	i := bytes.Index(buf, []byte("Exif\x00\x00"))
+	if i < 0 {
+		return nil
+	}
+	return buf[i:]
+}
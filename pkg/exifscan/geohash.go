@@ -0,0 +1,37 @@
+package exifscan
+
+import "strings"
+
+const geohashAlphabet = "0123456789bcdefghjkmnpqrstuvwxyz"
+
+// DecodeGeohash returns the center of the cell a geohash names and its
+// half-height in meters, or ok false when s isn't a geohash.
+func DecodeGeohash(s string) (g GPS, ok bool) {
+	if s == "" {
+		return GPS{}, false
+	}
+	lat, lon := [2]float64{-90, 90}, [2]float64{-180, 180}
+	even := true
+	for _, c := range strings.ToLower(s) {
+		v := strings.IndexRune(geohashAlphabet, c)
+		if v < 0 {
+			return GPS{}, false
+		}
+		for bit := 4; bit >= 0; bit-- {
+			rng := &lat
+			if even {
+				rng = &lon
+			}
+			mid := (rng[0] + rng[1]) / 2
+			if v>>bit&1 == 1 {
+				rng[0] = mid
+			} else {
+				rng[1] = mid
+			}
+			even = !even
+		}
+	}
+	g = GPS{Lat: (lat[0] + lat[1]) / 2, Lon: (lon[0] + lon[1]) / 2}
+	g.PrecisionM = (lat[1] - lat[0]) / 2 * metersPerDegree
+	return g, true
+}
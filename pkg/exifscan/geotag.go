@@ -0,0 +1,96 @@
+package exifscan
+
+import (
+	"fmt"
+	"math"
+	"strings"
+
+	"github.com/nbd-wtf/go-nostr"
+)
+
+// earthRadiusM is the mean radius of the earth, for great circle
+// distances.
+const earthRadiusM = 6_371_000
+
+// GeoTagCheck compares an image's EXIF position with the geohash its note
+// was tagged with, the g tags of NIP-52 and geo clients.
+type GeoTagCheck struct {
+	// Geohash is the most precise g tag of the note.
+	Geohash string `json:"geohash"`
+	// Inside is set when the EXIF position lies in the tagged cell: it
+	// refines the region the author declared, usually down to the spot.
+	// Outside it contradicts it.
+	Inside bool `json:"inside"`
+	// DistanceM is how far the EXIF position is from the cell's center.
+	DistanceM float64 `json:"distance_m"`
+}
+
+// String describes the check, e.g. "tagged u33db (~2.4 km cell), the EXIF
+// position refines it, ~340 m from its center".
+func (c *GeoTagCheck) String() string {
+	cell, _ := DecodeGeohash(c.Geohash)
+	verdict := "contradicts it, " + approxMeters(c.DistanceM) + " away"
+	if c.Inside {
+		verdict = "refines it, " + approxMeters(c.DistanceM) + " from its center"
+	}
+	return fmt.Sprintf("tagged %s (%s cell), the EXIF position %s", c.Geohash, approxMeters(2*cell.PrecisionM), verdict)
+}
+
+// DeclaredGeohash returns the most precise valid g tag of evt, lowercased,
+// or "" when it has none.
+func DeclaredGeohash(evt *nostr.Event) string {
+	if evt == nil {
+		return ""
+	}
+	best := ""
+	for _, t := range evt.Tags {
+		if len(t) < 2 || t[0] != "g" || len(t[1]) <= len(best) {
+			continue
+		}
+		if _, ok := DecodeGeohash(t[1]); ok {
+			best = strings.ToLower(t[1])
+		}
+	}
+	return best
+}
+
+// InGeohash reports whether evt carries a g tag inside one of the cells
+// prefixes name. Tags coarser than a prefix don't match: they don't say
+// the note is from that cell.
+func InGeohash(evt *nostr.Event, prefixes []string) bool {
+	for _, t := range evt.Tags {
+		if len(t) < 2 || t[0] != "g" {
+			continue
+		}
+		for _, p := range prefixes {
+			if strings.HasPrefix(strings.ToLower(t[1]), p) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func checkGeoTag(g *GPS, evt *nostr.Event) *GeoTagCheck {
+	if g == nil {
+		return nil
+	}
+	gh := DeclaredGeohash(evt)
+	if gh == "" {
+		return nil
+	}
+	cell, _ := DecodeGeohash(gh)
+	return &GeoTagCheck{
+		Geohash:   gh,
+		Inside:    EncodeGeohash(g.Lat, g.Lon, len(gh)) == gh,
+		DistanceM: distanceM(*g, cell),
+	}
+}
+
+// distanceM is the great circle distance between two positions.
+func distanceM(a, b GPS) float64 {
+	rad := math.Pi / 180
+	dLat, dLon := (b.Lat-a.Lat)*rad, (b.Lon-a.Lon)*rad
+	h := math.Pow(math.Sin(dLat/2), 2) + math.Cos(a.Lat*rad)*math.Cos(b.Lat*rad)*math.Pow(math.Sin(dLon/2), 2)
+	return 2 * earthRadiusM * math.Asin(math.Sqrt(math.Min(h, 1)))
+}
@@ -0,0 +1,111 @@
+package exifscan
+
+import (
+	"bytes"
+	"io"
+	"regexp"
+	"strings"
+)
+
+var (
+	// gifToolRE and gifCreatorRE tell the comments stamped by the tool
+	// that made a GIF from those naming who did.
+	gifToolRE    = regexp.MustCompile(`(?i)\b(?:created|made|generated|edited|optimi[sz]ed|converted)\s+(?:with|by|using|in|on)\b|\b(?:gimp|photoshop|imagemagick|ezgif|giphy|gifsicle|screentogif|licecap)\b`)
+	gifCreatorRE = regexp.MustCompile(`(?i)©|\(c\)|\bcopyright\b|\bauthor\b|\bartist\b|\bby\s+\S`)
+)
+
+// gifTags reads the comment extensions and the XMP application extension
+// of the first maxChunk bytes of a GIF.
+func gifTags(r io.Reader) ([]Tag, *GPS, bool) {
+	b, _ := io.ReadAll(io.LimitReader(r, maxChunk))
+	var tags []Tag
+	gifWalk(b, func(label byte, data []byte) {
+		switch {
+		case label == 0xfe:
+			var comment []byte
+			for i := 0; i < len(data) && data[i] != 0; i += int(data[i]) + 1 {
+				comment = append(comment, data[i+1:min(i+1+int(data[i]), len(data))]...)
+			}
+			tags = append(tags, gifComment(latin1(comment))...)
+		case label == 0xff && bytes.HasPrefix(data, []byte("\x0bXMP DataXMP")):
+			// The packet is written raw, not in sub-blocks; its trailer
+			// lets them be skipped all the same.
+			if packet := xmpPacket(data[12:]); packet != nil {
+				tags = append(tags, xmpTags(packet, "XMP ")...)
+			}
+		}
+	})
+	fields := documentFields(tags)
+	return fields, nil, len(fields) > 0
+}
+
+// gifWalk calls ext with the label and the sub-blocks of each extension
+// of the GIF b and returns where its trailer ends, or -1 when b is cut
+// short or isn't one.
+func gifWalk(b []byte, ext func(label byte, data []byte)) int {
+	if len(b) < 13 || !bytes.HasPrefix(b, []byte("GIF")) {
+		return -1
+	}
+	pos := 13
+	if b[10]&0x80 != 0 {
+		pos += 3 << (b[10]&7 + 1)
+	}
+	for pos < len(b) {
+		switch b[pos] {
+		case 0x3b:
+			return pos + 1
+		case 0x2c: // image descriptor
+			if pos+10 > len(b) {
+				return -1
+			}
+			flags := b[pos+9]
+			pos += 10
+			if flags&0x80 != 0 {
+				pos += 3 << (flags&7 + 1)
+			}
+			// The LZW minimum code size, then the image data.
+			pos = gifSkip(b, pos+1)
+		case 0x21: // extension
+			if pos+2 > len(b) {
+				return -1
+			}
+			label, start := b[pos+1], pos+2
+			pos = gifSkip(b, start)
+			ext(label, b[start:min(pos, len(b))])
+		default:
+			return -1
+		}
+	}
+	return -1
+}
+
+// gifSkip skips the data sub-blocks at pos.
+func gifSkip(b []byte, pos int) int {
+	for pos < len(b) {
+		n := int(b[pos])
+		pos++
+		if n == 0 {
+			break
+		}
+		pos += n
+	}
+	return pos
+}
+
+// gifComment rates a comment extension: the tool that stamped it, the
+// creator it names and the email addresses in it are sensitive.
+func gifComment(c string) []Tag {
+	c = strings.TrimSpace(strings.TrimRight(c, "\x00"))
+	category := ""
+	switch {
+	case gifToolRE.MatchString(c):
+		category = CategorySoftware
+	case gifCreatorRE.MatchString(c):
+		category = CategoryOwner
+	}
+	tags := []Tag{{Field: "GIF comment", Category: category, Value: c}}
+	for _, m := range emailRE.FindAllString(c, -1) {
+		tags = append(tags, Tag{Field: "GIF comment (email)", Category: CategoryEmail, Value: m})
+	}
+	return tags
+}
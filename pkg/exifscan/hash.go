@@ -0,0 +1,109 @@
+package exifscan
+
+import (
+	"encoding/hex"
+	"net/url"
+	"path"
+	"strings"
+
+	"github.com/nbd-wtf/go-nostr"
+)
+
+// declaredHash is the SHA-256 the author published for link: the x field
+// of its NIP-92 imeta tag, the x tag of a NIP-94 file event, or else the
+// hash a Blossom URL is named after. It is "" when nothing declares one.
+func declaredHash(evt *nostr.Event, link string) string {
+	if x := imetaField(evt, link, "x"); isHash(x) {
+		return strings.ToLower(x)
+	}
+	if evt != nil {
+		if u := evt.Tags.GetFirst([]string{"url", link}); u != nil {
+			if x := evt.Tags.GetFirst([]string{"x", ""}); x != nil && isHash((*x)[1]) {
+				return strings.ToLower((*x)[1])
+			}
+		}
+	}
+	return blossomHash(link)
+}
+
+// originalHash is the SHA-256 of the file uploaded for link before the
+// server transformed it: the ox field of its imeta tag, or "".
+func originalHash(evt *nostr.Event, link string) string {
+	if ox := imetaField(evt, link, "ox"); isHash(ox) {
+		return strings.ToLower(ox)
+	}
+	return ""
+}
+
+// imetaField returns the key field of the NIP-92 imeta tag evt describes
+// link with, or "".
+func imetaField(evt *nostr.Event, link, key string) string {
+	if evt == nil {
+		return ""
+	}
+	for _, tag := range evt.Tags {
+		if len(tag) < 2 || tag[0] != "imeta" {
+			continue
+		}
+		var u, v string
+		for _, field := range tag[1:] {
+			k, val, _ := strings.Cut(field, " ")
+			switch k {
+			case "url":
+				u = val
+			case key:
+				v = val
+			}
+		}
+		if u == link && v != "" {
+			return v
+		}
+	}
+	return ""
+}
+
+// blossomHash returns the hash a BUD-01 URL (/<sha256>[.ext]) names.
+func blossomHash(link string) string {
+	u, err := url.Parse(link)
+	if err != nil {
+		return ""
+	}
+	name := path.Base(u.Path)
+	name = strings.TrimSuffix(name, path.Ext(name))
+	if !isHash(name) {
+		return ""
+	}
+	return strings.ToLower(name)
+}
+
+func isHash(s string) bool {
+	if len(s) != 64 {
+		return false
+	}
+	_, err := hex.DecodeString(s)
+	return err == nil
+}
+
+// Mistyped returns the images whose bytes aren't the format their URL's
+// extension announces.
+func (f *Findings) Mistyped() []*ImageResult {
+	var out []*ImageResult
+	for _, r := range f.Images {
+		if r.TypeMismatch {
+			out = append(out, r)
+		}
+	}
+	return out
+}
+
+// Mismatched returns the images whose bytes don't hash to what they were
+// published with.
+func (f *Findings) Mismatched() []*ImageResult {
+	var out []*ImageResult
+	for _, r := range f.Images {
+		if r.HashMismatch {
+			out = append(out, r)
+		}
+	}
+	return out
+}
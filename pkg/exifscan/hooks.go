@@ -0,0 +1,100 @@
+package exifscan
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/nbd-wtf/go-nostr"
+)
+
+// Hooks receive scan lifecycle events. They are called from the scanning
+// goroutines, so they must be safe for concurrent use and return quickly.
+// Any hook may be nil.
+type Hooks struct {
+	// OnEventFetched is called once per distinct note received from relays.
+	OnEventFetched func(evt *nostr.Event)
+	// OnImageScanned is called for every image, including failed ones.
+	OnImageScanned func(r *ImageResult)
+	// OnFinding is called for images that carry sensitive metadata.
+	OnFinding func(r *ImageResult)
+	// OnError is called with a *ScanError for relay and download failures.
+	OnError func(err error)
+	// OnRelayConnected is called for every relay a scan connected to.
+	OnRelayConnected func(url string)
+	// OnRelayTrace follows the relay protocol: connections, the REQs
+	// sent and what each relay answered. relay is empty for notices,
+	// which go-nostr doesn't attribute.
+	OnRelayTrace func(relay, msg string)
+}
+
+// Stages reported in ScanError.
+const (
+	StageRelay = "relay"
+	StageFetch = "fetch"
+	StageRead  = "read"
+	// StageOCR is a failure of Options.OCR; the image's other findings
+	// stand.
+	StageOCR = "ocr"
+)
+
+// ScanError describes a failure that did not abort the scan.
+type ScanError struct {
+	Stage string
+	// URL is the relay or image URL that failed.
+	URL     string
+	EventID string
+	Err     error
+}
+
+func (e *ScanError) Error() string {
+	return fmt.Sprintf("%s %s: %v", e.Stage, e.URL, e.Err)
+}
+
+func (e *ScanError) Unwrap() error {
+	return e.Err
+}
+
+func (s *Scanner) eventFetched(evt *nostr.Event) {
+	if h := s.opts.Hooks.OnEventFetched; h != nil {
+		h(evt)
+	}
+}
+
+func (s *Scanner) imageScanned(r *ImageResult) {
+	if h := s.opts.Hooks.OnImageScanned; h != nil {
+		h(r)
+	}
+	if h := s.opts.Hooks.OnFinding; h != nil && r.Sensitive() {
+		h(r)
+	}
+}
+
+func (s *Scanner) relayConnected(url string) {
+	if h := s.opts.Hooks.OnRelayConnected; h != nil {
+		h(url)
+	}
+}
+
+func (s *Scanner) trace(relay, format string, args ...any) {
+	if h := s.opts.Hooks.OnRelayTrace; h != nil {
+		h(relay, fmt.Sprintf(format, args...))
+	}
+}
+
+// newPool opens a relay pool that reports notices and messages go-nostr
+// doesn't handle to OnRelayTrace.
+func (s *Scanner) newPool(ctx context.Context, opts ...nostr.PoolOption) *nostr.SimplePool {
+	if s.opts.Hooks.OnRelayTrace != nil {
+		opts = append(opts, nostr.WithRelayOptions(
+			nostr.WithNoticeHandler(func(notice string) { s.trace("", "NOTICE %s", notice) }),
+			nostr.WithCustomHandler(func(data string) { s.trace("", "unhandled %s", data) }),
+		))
+	}
+	return nostr.NewSimplePool(ctx, opts...)
+}
+
+func (s *Scanner) fail(err *ScanError) {
+	if h := s.opts.Hooks.OnError; h != nil {
+		h(err)
+	}
+}
@@ -0,0 +1,186 @@
+package exifscan
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"math"
+	"os/exec"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// Fields of the tags found in the text OCR reads off an image.
+const (
+	FieldOCRCoordinates = "OCR coordinates"
+	FieldOCRTimestamp   = "OCR timestamp"
+	FieldOCRAddress     = "OCR address"
+	FieldOCRStreet      = "OCR street name"
+)
+
+// TextReader reads the text visible in an image, for Options.OCR.
+// Tesseract is one; an ONNX model can be plugged in the same way.
+type TextReader interface {
+	ReadText(ctx context.Context, img []byte) (string, error)
+}
+
+// Tesseract reads text with the tesseract command line tool.
+type Tesseract struct {
+	// Path is the tesseract binary, looked up in $PATH when empty.
+	Path string
+	// Languages are the trained models passed as -l, e.g. "eng+deu";
+	// empty uses tesseract's default.
+	Languages string
+}
+
+func (t Tesseract) path() string {
+	if t.Path == "" {
+		return "tesseract"
+	}
+	return t.Path
+}
+
+// Check runs tesseract --version, to fail early when it isn't installed.
+func (t Tesseract) Check(ctx context.Context) error {
+	if out, err := exec.CommandContext(ctx, t.path(), "--version").CombinedOutput(); err != nil {
+		return fmt.Errorf("running %s: %w %s", t.path(), err, strings.TrimSpace(string(out)))
+	}
+	return nil
+}
+
+// ReadText passes img to tesseract on stdin. Page segmentation mode 11
+// looks for sparse text, which overlays and signs are.
+func (t Tesseract) ReadText(ctx context.Context, img []byte) (string, error) {
+	args := []string{"stdin", "stdout", "--psm", "11"}
+	if t.Languages != "" {
+		args = append(args, "-l", t.Languages)
+	}
+	cmd := exec.CommandContext(ctx, t.path(), args...)
+	cmd.Stdin = bytes.NewReader(img)
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout, cmd.Stderr = &stdout, &stderr
+	if err := cmd.Run(); err != nil {
+		return "", fmt.Errorf("tesseract: %w: %s", err, strings.TrimSpace(stderr.String()))
+	}
+	return stdout.String(), nil
+}
+
+// ocrFormats are the formats tesseract reads.
+var ocrFormats = map[string]bool{
+	"image/jpeg": true, "image/png": true, "image/webp": true,
+	"image/gif": true, "image/tiff": true,
+}
+
+var (
+	// Coordinates as GPS camera apps and dashcams burn them in: signed
+	// decimals after labels, decimals with a hemisphere, or degrees,
+	// minutes and seconds. OCR often reads the degree sign as º.
+	ocrLabelledRE   = regexp.MustCompile(`(?i)\blat(?:itude)?\s*[:=]?\s*(-?\d{1,2}\.\d{3,})\s*°?[\s,;]*\blo?ng?(?:itude)?\s*[:=]?\s*(-?\d{1,3}\.\d{3,})`)
+	ocrHemisphereRE = regexp.MustCompile(`\b(\d{1,2}\.\d{3,})\s*[°º]?\s*([NS])[\s,;]+(\d{1,3}\.\d{3,})\s*[°º]?\s*([EW])\b`)
+	ocrDMSRE        = regexp.MustCompile(`\b([NS])?\s*(\d{1,2})\s*[°º]\s*(\d{1,2})\s*['’′]\s*(\d{1,2}(?:\.\d+)?)\s*(?:"|”|″|'')?\s*([NS])?[\s,;]+([EW])?\s*(\d{1,3})\s*[°º]\s*(\d{1,2})\s*['’′]\s*(\d{1,2}(?:\.\d+)?)\s*(?:"|”|″|'')?\s*([EW])?`)
+	// Date stamps: year first, or day and month first as cameras set to
+	// European and US locales print them, with an optional time.
+	ocrDateRE = regexp.MustCompile(`\b(?:(?:19|20)\d{2}[-/.](?:0?[1-9]|1[0-2])[-/.](?:0?[1-9]|[12]\d|3[01])|(?:0?[1-9]|[12]\d|3[01])[-/.](?:0?[1-9]|[12]\d|3[01])[-/.](?:19|20)\d{2})(?:[ T]+\d{1,2}:\d{2}(?::\d{2})?(?:\s*[AP]M)?)?\b`)
+	// Street names on signs, without the house number addressRE wants.
+	ocrStreetRE = regexp.MustCompile(`(?i)\b(?:(?:rue|calle|via|avenida|avenue|rua) (?:de la |de l'|des |del |de |du |da |do )?[a-zà-ÿ]{3,}(?: [a-zà-ÿ]{3,})?|[a-zà-ÿ]{3,}(?:strasse|straße|gasse|weg|platz|straat|gatan|vej)|(?:[a-z]{3,} ){1,2}(?:street|avenue|boulevard|road))\b`)
+)
+
+// VisibleLeaks finds the locations, dates and addresses in text read off
+// an image, and returns the first position it gives as well.
+func VisibleLeaks(text string) ([]Tag, *GPS) {
+	text = strings.Join(strings.Fields(text), " ")
+	var tags []Tag
+	var pos *GPS
+	addGPS := func(value string, lat, lon, unit float64) {
+		if math.Abs(lat) > 90 || math.Abs(lon) > 180 || lat == 0 && lon == 0 {
+			return
+		}
+		tags = append(tags, Tag{Field: FieldOCRCoordinates, Category: CategoryGPS, Value: strings.TrimSpace(value)})
+		if pos == nil {
+			pos = &GPS{Lat: lat, Lon: lon, PrecisionM: unit * metersPerDegree}
+		}
+	}
+	for _, m := range ocrLabelledRE.FindAllStringSubmatch(text, -1) {
+		lat, _ := strconv.ParseFloat(m[1], 64)
+		lon, _ := strconv.ParseFloat(m[2], 64)
+		addGPS(m[0], lat, lon, decimalUnit(m[1], m[2]))
+	}
+	for _, m := range ocrHemisphereRE.FindAllStringSubmatch(text, -1) {
+		lat, _ := strconv.ParseFloat(m[1], 64)
+		lon, _ := strconv.ParseFloat(m[3], 64)
+		addGPS(m[0], hemisphere(lat, m[2]), hemisphere(lon, m[4]), decimalUnit(m[1], m[3]))
+	}
+	for _, m := range ocrDMSRE.FindAllStringSubmatch(text, -1) {
+		ns, ew := m[1]+m[5], m[6]+m[10]
+		if len(ns) != 1 || len(ew) != 1 {
+			// One hemisphere letter per axis, before or after it.
+			continue
+		}
+		lat := dmsValue(m[2], m[3], m[4])
+		lon := dmsValue(m[7], m[8], m[9])
+		addGPS(m[0], hemisphere(lat, ns), hemisphere(lon, ew), 1.0/3600)
+	}
+	for _, m := range ocrDateRE.FindAllString(text, -1) {
+		tags = append(tags, Tag{Field: FieldOCRTimestamp, Category: CategoryTimestamp, Value: m})
+	}
+	addresses := addressRE.FindAllString(text, -1)
+	for _, m := range addresses {
+		tags = append(tags, Tag{Field: FieldOCRAddress, Category: CategoryAddress, Value: m})
+	}
+	for _, m := range ocrStreetRE.FindAllString(text, -1) {
+		if !slices.ContainsFunc(addresses, func(a string) bool { return strings.Contains(a, m) }) {
+			tags = append(tags, Tag{Field: FieldOCRStreet, Category: CategoryPlace, Value: m})
+		}
+	}
+	return tags, pos
+}
+
+// decimalUnit is the resolution of the coarser of two decimal values.
+func decimalUnit(a, b string) float64 {
+	digits := func(s string) int { return len(s) - strings.IndexByte(s, '.') - 1 }
+	return math.Pow(10, -float64(min(digits(a), digits(b))))
+}
+
+func dmsValue(deg, mins, secs string) float64 {
+	d, _ := strconv.ParseFloat(deg, 64)
+	m, _ := strconv.ParseFloat(mins, 64)
+	s, _ := strconv.ParseFloat(secs, 64)
+	return d + m/60 + s/3600
+}
+
+func hemisphere(v float64, ref string) float64 {
+	if ref == "S" || ref == "W" {
+		return -v
+	}
+	return v
+}
+
+// readText runs Options.OCR on r's image and adds what the text leaks to
+// its tags. Failures are reported to OnError and leave r alone.
+func (s *Scanner) readText(ctx context.Context, r *ImageResult, p *payload) {
+	if r.Err != nil || !ocrFormats[r.ContentType] {
+		return
+	}
+	b, ok := p.bytes()
+	if !ok {
+		return
+	}
+	text, err := s.opts.OCR.ReadText(ctx, b)
+	if err != nil {
+		s.fail(&ScanError{Stage: StageOCR, URL: r.URL, EventID: r.EventID, Err: err})
+		return
+	}
+	r.VisibleText = strings.TrimSpace(text)
+	tags, g := VisibleLeaks(text)
+	if tags == nil {
+		return
+	}
+	if r.GPS == nil {
+		r.GPS = g
+	}
+	r.AddTags(tags...)
+	// Images without metadata weren't kept by inspect.
+	s.keepData(r, p)
+}
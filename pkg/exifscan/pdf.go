@@ -0,0 +1,196 @@
+package exifscan
+
+import (
+	"bytes"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// pdfFields are the document information dictionary entries reported.
+// Company is written by Office exports.
+var pdfFields = map[string]metaField{
+	"Author":       {"PDF Author", CategoryOwner},
+	"Company":      {"PDF Company", CategoryOwner},
+	"Creator":      {"PDF Creator", CategorySoftware},
+	"Producer":     {"PDF Producer", CategorySoftware},
+	"CreationDate": {"PDF CreationDate", CategoryTimestamp},
+	"ModDate":      {"PDF ModDate", CategoryTimestamp},
+	"Title":        {"PDF Title", ""},
+	"Subject":      {"PDF Subject", ""},
+	"Keywords":     {"PDF Keywords", ""},
+}
+
+// pdfKeyRE finds the entries of pdfFields followed by a literal or hex
+// string.
+var pdfKeyRE = regexp.MustCompile(`/(Author|Company|Creator|Producer|CreationDate|ModDate|Title|Subject|Keywords)\s*[(<]`)
+
+// homePathRE finds a user's home directory in a value, like the source
+// path print-to-PDF drivers put in the title: the name is the account's.
+var homePathRE = regexp.MustCompile(`(?i)(?:[a-z]:\\(?:users|documents and settings)\\|/home/|/Users/)([^\\/]+)`)
+
+// pdfDateRE matches a PDF date, D:YYYYMMDDHHmmSS with an optional zone.
+var pdfDateRE = regexp.MustCompile(`^D:(\d{4})(\d{2})?(\d{2})?(\d{2})?(\d{2})?(\d{2})?(Z|[+-]\d{2}'?\d{2}'?)?`)
+
+// pdfTags reads the document information dictionary and the XMP packet
+// of the first maxChunk bytes of a PDF. Entries given again by later
+// incremental updates win, and values the XMP repeats are left out.
+func pdfTags(r io.Reader) ([]Tag, *GPS, bool) {
+	buf, _ := io.ReadAll(io.LimitReader(r, maxChunk))
+	info := map[string]string{}
+	for _, m := range pdfKeyRE.FindAllSubmatchIndex(buf, -1) {
+		if v, ok := pdfString(buf[m[1]-1:]); ok {
+			info[string(buf[m[2]:m[3]])] = v
+		}
+	}
+	keys := make([]string, 0, len(info))
+	for key := range info {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	var tags []Tag
+	for _, key := range keys {
+		f, v := pdfFields[key], info[key]
+		if f.category == CategoryTimestamp {
+			v = pdfDate(v)
+		}
+		tags = append(tags, Tag{Field: f.name, Category: f.category, Value: v})
+	}
+	if packet := xmpPacket(buf); packet != nil {
+		tags = append(tags, xmpTags(packet, "XMP ")...)
+	}
+	fields := documentFields(tags)
+	return fields, nil, len(fields) > 0
+}
+
+// documentFields trims tags and drops the empty ones and the values
+// already given in the same category, keeping the first, then adds an
+// owner tag for the home folders they name. The result is sorted by field.
+func documentFields(tags []Tag) []Tag {
+	var fields []Tag
+	seen := map[string]bool{}
+	for _, t := range tags {
+		if t.Value = strings.TrimSpace(t.Value); t.Value == "" || seen[t.Category+"\x00"+t.Value] {
+			continue
+		}
+		seen[t.Category+"\x00"+t.Value] = true
+		fields = append(fields, t)
+		if m := homePathRE.FindStringSubmatch(t.Value); m != nil && t.Category != CategoryOwner {
+			fields = append(fields, Tag{Field: t.Field + " (user folder)", Category: CategoryOwner, Value: m[1]})
+		}
+	}
+	sort.SliceStable(fields, func(i, j int) bool { return fields[i].Field < fields[j].Field })
+	return fields
+}
+
+// pdfString decodes the literal (...) or hex <...> string b starts with.
+func pdfString(b []byte) (string, bool) {
+	var raw []byte
+	switch b[0] {
+	case '<':
+		end := bytes.IndexByte(b, '>')
+		if end < 0 {
+			return "", false
+		}
+		var digits []byte
+		for _, c := range b[1:end] {
+			if c > ' ' {
+				digits = append(digits, c)
+			}
+		}
+		if len(digits)%2 == 1 {
+			// A missing last digit is 0.
+			digits = append(digits, '0')
+		}
+		var err error
+		if raw, err = hex.DecodeString(string(digits)); err != nil {
+			return "", false
+		}
+	case '(':
+		depth := 0
+		for i := 1; i < len(b); i++ {
+			c := b[i]
+			switch {
+			case c == '\\' && i+1 < len(b):
+				i++
+				switch e := b[i]; e {
+				case 'n':
+					raw = append(raw, '\n')
+				case 'r':
+					raw = append(raw, '\r')
+				case 't':
+					raw = append(raw, '\t')
+				case 'b':
+					raw = append(raw, '\b')
+				case 'f':
+					raw = append(raw, '\f')
+				case '\r', '\n':
+					// A line continuation.
+				default:
+					if e >= '0' && e <= '7' {
+						v := int(e - '0')
+						for n := 0; n < 2 && i+1 < len(b) && b[i+1] >= '0' && b[i+1] <= '7'; n++ {
+							i++
+							v = v*8 + int(b[i]-'0')
+						}
+						raw = append(raw, byte(v))
+					} else {
+						raw = append(raw, e)
+					}
+				}
+			case c == '(':
+				depth++
+				raw = append(raw, c)
+			case c == ')' && depth == 0:
+				return pdfText(raw), true
+			case c == ')':
+				depth--
+				raw = append(raw, c)
+			default:
+				raw = append(raw, c)
+			}
+		}
+		return "", false
+	default:
+		return "", false
+	}
+	return pdfText(raw), true
+}
+
+// pdfText decodes a text string: UTF-16BE with a byte order mark, or
+// PDFDocEncoding, close enough to Latin-1 for names.
+func pdfText(raw []byte) string {
+	if len(raw) >= 2 && raw[0] == 0xfe && raw[1] == 0xff {
+		return utf16String(1, raw)
+	}
+	return latin1(raw)
+}
+
+// pdfDate renders a PDF date like D:20240601123000+02'00' as
+// 2024-06-01 12:30:00 +02:00, or returns it as it is.
+func pdfDate(s string) string {
+	m := pdfDateRE.FindStringSubmatch(s)
+	if m == nil {
+		return s
+	}
+	for i := 2; i <= 6; i++ {
+		switch {
+		case m[i] != "":
+		case i <= 3:
+			m[i] = "01" // month and day
+		default:
+			m[i] = "00"
+		}
+	}
+	out := fmt.Sprintf("%s-%s-%s %s:%s:%s", m[1], m[2], m[3], m[4], m[5], m[6])
+	switch zone := strings.ReplaceAll(m[7], "'", ""); {
+	case zone == "Z":
+		out += " UTC"
+	case zone != "":
+		out += " " + zone[:3] + ":" + zone[3:]
+	}
+	return out
+}
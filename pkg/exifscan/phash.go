@@ -0,0 +1,119 @@
+package exifscan
+
+import (
+	"context"
+	"fmt"
+	"image"
+	"image/color"
+	_ "image/gif"
+	_ "image/jpeg"
+	_ "image/png"
+	"math/bits"
+	"strconv"
+)
+
+// maxPHashPixels bounds the images decoded for a perceptual hash to
+// photo sizes, so a huge panorama doesn't take the memory of a hundred
+// photos. The decoded pixels also count against MaxInFlightBytes.
+const maxPHashPixels = 24_000_000
+
+// phashFormats are the formats the standard library decodes.
+var phashFormats = map[string]bool{"image/jpeg": true, "image/png": true, "image/gif": true}
+
+// PerceptualHash is the 64-bit difference hash of img: the image shrunk
+// to 9×8 gray cells, one bit per pair of neighbouring cells telling
+// whether brightness rises to the right. Resizing, recompression and
+// stripping the metadata leave it nearly unchanged, so copies of one
+// photo are a few bits apart; see HashDistance.
+func PerceptualHash(img image.Image) uint64 {
+	const w, h = 9, 8
+	var cells [h][w]float64
+	b := img.Bounds()
+	for cy := 0; cy < h; cy++ {
+		y0, y1 := b.Min.Y+cy*b.Dy()/h, b.Min.Y+(cy+1)*b.Dy()/h
+		for cx := 0; cx < w; cx++ {
+			x0, x1 := b.Min.X+cx*b.Dx()/w, b.Min.X+(cx+1)*b.Dx()/w
+			// Sample at most 16×16 pixels per cell: averaging all of them
+			// changes the hash little and costs a lot on large photos.
+			sx, sy := max(1, (x1-x0)/16), max(1, (y1-y0)/16)
+			sum, n := 0.0, 0
+			for y := y0; y < max(y1, y0+1); y += sy {
+				for x := x0; x < max(x1, x0+1); x += sx {
+					r, g, b, _ := img.At(x, y).RGBA()
+					sum += 0.299*float64(r) + 0.587*float64(g) + 0.114*float64(b)
+					n++
+				}
+			}
+			cells[cy][cx] = sum / float64(n)
+		}
+	}
+	var hash uint64
+	for y := 0; y < h; y++ {
+		for x := 0; x < w-1; x++ {
+			hash <<= 1
+			if cells[y][x+1] > cells[y][x] {
+				hash |= 1
+			}
+		}
+	}
+	return hash
+}
+
+// HashDistance is the number of bits two ImageResult.PHash values differ
+// in, or -1 when either isn't one.
+func HashDistance(a, b string) int {
+	x, err := strconv.ParseUint(a, 16, 64)
+	if err != nil || len(a) != 16 {
+		return -1
+	}
+	y, err := strconv.ParseUint(b, 16, 64)
+	if err != nil || len(b) != 16 {
+		return -1
+	}
+	return bits.OnesCount64(x ^ y)
+}
+
+// pixelBytes is at most what a pixel of an image in model takes decoded:
+// 16-bit PNGs decode to 8 bytes a pixel, everything else to 4 or less.
+func pixelBytes(model color.Model) int64 {
+	if model == color.RGBA64Model || model == color.NRGBA64Model {
+		return 8
+	}
+	return 4
+}
+
+// phash decodes p's image for ImageResult.PHash, once the in-flight
+// budget has room for its pixels. It is "" for formats the standard
+// library can't decode and images too large to.
+func (s *Scanner) phash(ctx context.Context, format string, p *payload) string {
+	if !phashFormats[format] {
+		return ""
+	}
+	pr := p.open()
+	if pr == nil {
+		return ""
+	}
+	cfg, _, err := image.DecodeConfig(pr)
+	if err != nil || cfg.Width*cfg.Height > maxPHashPixels || cfg.Width*cfg.Height == 0 {
+		return ""
+	}
+	// Growing the download's own reservation keeps workers that all wait
+	// for room from holding the budget between them; spooled downloads
+	// hold none.
+	res := p.res
+	if res == nil {
+		res = &reservation{b: s.budget}
+		defer res.release()
+	}
+	if res.grow(ctx, res.n+int64(cfg.Width)*int64(cfg.Height)*pixelBytes(cfg.ColorModel)) != nil {
+		return ""
+	}
+	if pr = p.open(); pr == nil {
+		return ""
+	}
+	img, _, err := image.Decode(pr)
+	if err != nil {
+		return ""
+	}
+	return fmt.Sprintf("%016x", PerceptualHash(img))
+}
@@ -0,0 +1,221 @@
+package exifscan
+
+import (
+	"context"
+	"fmt"
+	"math/rand/v2"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+
+	"golang.org/x/sync/errgroup"
+)
+
+// AssumedBytesPerSecond is the download speed of a single connection
+// Plan estimates scan times with.
+const AssumedBytesPerSecond = 2e6
+
+// Plan is what scanning a set of images would download, estimated from
+// HEAD requests without fetching any image.
+type Plan struct {
+	// Links counts the image links found, Duplicates the ones linking a
+	// URL already found.
+	Links      int
+	Duplicates int
+	URLs       []string
+	ByHost     map[string]int
+	// Sampled counts the URLs a HEAD request was sent for. Bytes adds up
+	// the Content-Length of the Sized ones; Unsized ones answered without
+	// a length and Failed ones not at all.
+	Sampled int
+	Bytes   int64
+	Sized   int
+	Unsized int
+	Failed  int
+	// Latency is the mean time the HEAD requests took.
+	Latency time.Duration
+	// Duration estimates how long downloading every URL takes, with the
+	// scanner's concurrency and rate limit.
+	Duration time.Duration
+}
+
+// TotalBytes extrapolates the size of all URLs from the sized ones.
+func (p *Plan) TotalBytes() int64 {
+	if p.Sized == 0 {
+		return 0
+	}
+	return p.Bytes * int64(len(p.URLs)) / int64(p.Sized)
+}
+
+// Plan dedupes the URLs of images and asks their hosts how big they are,
+// with the concurrency and rate limits of a scan. With sample > 0 only
+// that many URLs, spread over the list, are asked.
+func (s *Scanner) Plan(ctx context.Context, images []Image, sample int) *Plan {
+	p := &Plan{Links: len(images), ByHost: map[string]int{}}
+	seen := map[string]bool{}
+	for _, img := range images {
+		if seen[img.URL] {
+			p.Duplicates++
+			continue
+		}
+		seen[img.URL] = true
+		p.URLs = append(p.URLs, img.URL)
+		p.ByHost[(&ImageResult{URL: img.URL}).Host()]++
+	}
+
+	urls := p.URLs
+	if sample > 0 && sample < len(urls) {
+		urls = make([]string, sample)
+		for i := range urls {
+			urls[i] = p.URLs[i*len(p.URLs)/sample]
+		}
+	}
+	p.Sampled = len(urls)
+
+	var mu sync.Mutex
+	var spent time.Duration
+	l := s.newLimiter()
+	g, ctx := errgroup.WithContext(ctx)
+	for _, url := range urls {
+		r := &ImageResult{URL: url}
+		if l.acquire(ctx, r.Host()) != nil {
+			break
+		}
+		g.Go(func() error {
+			start := time.Now()
+			size, err := s.head(ctx, url)
+			r.Err, r.Duration = err, time.Since(start)
+			l.release(r)
+			mu.Lock()
+			defer mu.Unlock()
+			spent += r.Duration
+			switch {
+			case err != nil:
+				p.Failed++
+			case size < 0:
+				p.Unsized++
+			default:
+				p.Sized++
+				p.Bytes += size
+			}
+			return nil
+		})
+	}
+	g.Wait()
+	if p.Sampled > 0 {
+		p.Latency = spent / time.Duration(p.Sampled)
+	}
+	p.Duration = s.downloadTime(p)
+	return p
+}
+
+// downloadTime estimates how long downloading the URLs of p takes: one
+// HEAD latency plus the transfer at AssumedBytesPerSecond per image,
+// spread over the concurrent downloads, and no faster than the rate limit
+// allows. AutoThreads is taken at its starting limits.
+func (s *Scanner) downloadTime(p *Plan) time.Duration {
+	n := len(p.URLs)
+	if n == 0 {
+		return 0
+	}
+	threads := s.opts.Threads
+	if threads == AutoThreads {
+		threads = 0
+		for _, c := range p.ByHost {
+			threads += min(c, hostStart)
+		}
+		threads = max(autoStart, min(threads, MaxAutoThreads))
+	}
+	each := p.Latency
+	if p.Sized > 0 {
+		each += time.Duration(float64(p.Bytes) / float64(p.Sized) / AssumedBytesPerSecond * float64(time.Second))
+	}
+	rounds := (n + threads - 1) / threads
+	d := time.Duration(rounds) * each
+	if rl := s.opts.RateLimit; rl != nil {
+		d = max(d, time.Duration(float64(n)/float64(rl.l.Limit())*float64(time.Second)))
+	}
+	return d
+}
+
+// head returns the Content-Length url answers a HEAD request with, or -1
+// when it doesn't tell.
+func (s *Scanner) head(ctx context.Context, url string) (int64, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodHead, url, nil)
+	if err != nil {
+		return 0, err
+	}
+	if err := s.opts.RateLimit.wait(ctx); err != nil {
+		return 0, err
+	}
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return 0, fmt.Errorf("fetch failed: %w", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return 0, &StatusError{
+			StatusCode: resp.StatusCode,
+			Status:     resp.Status,
+			RetryAfter: retryAfter(resp.Header.Get("Retry-After")),
+		}
+	}
+	return resp.ContentLength, nil
+}
+
+// CapImages keeps at most perEvent images of every note and perHost of
+// every host, in order, so one big gallery or one dominant host can't
+// take up a whole scan. Limits of 0 don't cap. It returns the kept images
+// and how many were dropped.
+func CapImages(images []Image, perEvent, perHost int) ([]Image, int) {
+	if perEvent <= 0 && perHost <= 0 {
+		return images, 0
+	}
+	events, hosts := map[string]int{}, map[string]int{}
+	var kept []Image
+	for _, img := range images {
+		host := (&ImageResult{URL: img.URL}).Host()
+		if perEvent > 0 && img.EventID != "" && events[img.EventID] >= perEvent {
+			continue
+		}
+		if perHost > 0 && hosts[host] >= perHost {
+			continue
+		}
+		events[img.EventID]++
+		hosts[host]++
+		kept = append(kept, img)
+	}
+	return kept, len(images) - len(kept)
+}
+
+// SkipContentWarnings leaves out the images of notes with a NIP-36
+// content warning, for operators who don't want that material downloaded.
+// It returns the kept images and how many were dropped.
+func SkipContentWarnings(images []Image) ([]Image, int) {
+	var kept []Image
+	for _, img := range images {
+		if img.Event != nil {
+			if _, ok := ContentWarning(img.Event); ok {
+				continue
+			}
+		}
+		kept = append(kept, img)
+	}
+	return kept, len(images) - len(kept)
+}
+
+// SampleImages picks n of images at random, keeping their order. It
+// returns images as they are when n doesn't leave any out.
+func SampleImages(images []Image, n int) []Image {
+	if n <= 0 || n >= len(images) {
+		return images
+	}
+	idx := rand.Perm(len(images))[:n]
+	sort.Ints(idx)
+	out := make([]Image, n)
+	for i, j := range idx {
+		out[i] = images[j]
+	}
+	return out
+}
@@ -0,0 +1,59 @@
+package exifscan
+
+import (
+	"math/bits"
+	"sync"
+)
+
+// Read buffers are pooled in power of two sizes from minPooled to
+// maxPooled, so a server scanning tens of thousands of images reuses a
+// few buffers instead of allocating one per download.
+const (
+	minPooledShift = 16 // 64 KiB
+	maxPooledShift = 24 // 16 MiB
+)
+
+var bufPools [maxPooledShift - minPooledShift + 1]sync.Pool
+
+// pooledSize rounds n up to the size of a pooled buffer, unless that
+// would exceed limit.
+func pooledSize(n, limit int64) int64 {
+	if n > 1<<maxPooledShift {
+		return n
+	}
+	size := int64(1) << minPooledShift
+	if n > size {
+		size = 1 << bits.Len64(uint64(n-1))
+	}
+	if size > limit {
+		return n
+	}
+	return size
+}
+
+func poolIndex(size int) (int, bool) {
+	if size&(size-1) != 0 {
+		return 0, false
+	}
+	shift := bits.TrailingZeros(uint(size))
+	return shift - minPooledShift, shift >= minPooledShift && shift <= maxPooledShift
+}
+
+// getBuf returns an empty buffer of capacity size, reused when size is
+// one pooledSize returns.
+func getBuf(size int64) []byte {
+	if i, ok := poolIndex(int(size)); ok {
+		if b, _ := bufPools[i].Get().(*[]byte); b != nil {
+			return (*b)[:0]
+		}
+	}
+	return make([]byte, 0, size)
+}
+
+// putBuf hands b back for reuse; nothing may use it afterwards.
+func putBuf(b []byte) {
+	if i, ok := poolIndex(cap(b)); ok {
+		b = b[:0]
+		bufPools[i].Put(&b)
+	}
+}
@@ -0,0 +1,109 @@
+package exifscan
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/nbd-wtf/go-nostr"
+)
+
+// excerptLen is how many characters of a note PostContext keeps.
+const excerptLen = 100
+
+var linkRE = regexp.MustCompile(`(https?://|nostr:)\S+`)
+
+// PostContext tells which note an image is from without opening it.
+type PostContext struct {
+	// Excerpt is the start of the note's text, links left out, or the
+	// title of an article.
+	Excerpt   string    `json:"excerpt,omitempty"`
+	CreatedAt time.Time `json:"created_at"`
+	Kind      int       `json:"kind"`
+	Reply     bool      `json:"reply,omitempty"`
+	// ContentWarning is set when the note carries a NIP-36
+	// content-warning tag, with its reason when it gives one.
+	ContentWarning       bool   `json:"content_warning,omitempty"`
+	ContentWarningReason string `json:"content_warning_reason,omitempty"`
+}
+
+// String is a one line description, e.g.
+// 2024-05-01 14:03 UTC · reply · “Lunch at the harbour”.
+func (c *PostContext) String() string {
+	parts := []string{InDisplayZone(c.CreatedAt).Format("2006-01-02 15:04 MST")}
+	if c.Kind != 1 {
+		parts = append(parts, fmt.Sprintf("kind %d", c.Kind))
+	}
+	if c.Reply {
+		parts = append(parts, "reply")
+	}
+	if c.ContentWarning {
+		parts = append(parts, c.warning())
+	}
+	if c.Excerpt != "" {
+		parts = append(parts, "“"+c.Excerpt+"”")
+	}
+	return strings.Join(parts, " · ")
+}
+
+func (c *PostContext) warning() string {
+	if c.ContentWarningReason != "" {
+		return "content warning: " + c.ContentWarningReason
+	}
+	return "content warning"
+}
+
+func postContext(evt *nostr.Event) *PostContext {
+	if evt == nil {
+		return nil
+	}
+	text := evt.Content
+	if title := evt.Tags.Find("title"); title != nil && title[1] != "" {
+		text = title[1]
+	}
+	c := &PostContext{
+		Excerpt:   excerpt(text),
+		CreatedAt: InDisplayZone(evt.CreatedAt.Time()),
+		Kind:      evt.Kind,
+		Reply:     isReply(evt),
+	}
+	c.ContentWarningReason, c.ContentWarning = ContentWarning(evt)
+	return c
+}
+
+// ContentWarning reports whether evt carries a NIP-36 content-warning tag
+// and the reason it gives, if any.
+func ContentWarning(evt *nostr.Event) (reason string, ok bool) {
+	for _, tag := range evt.Tags {
+		if len(tag) >= 1 && tag[0] == "content-warning" {
+			if len(tag) >= 2 {
+				reason = tag[1]
+			}
+			return reason, true
+		}
+	}
+	return "", false
+}
+
+func excerpt(content string) string {
+	s := strings.Join(strings.Fields(linkRE.ReplaceAllString(content, "")), " ")
+	if r := []rune(s); len(r) > excerptLen {
+		return strings.TrimSpace(string(r[:excerptLen])) + "…"
+	}
+	return s
+}
+
+// isReply follows NIP-10: an e tag marked root or reply, or an unmarked
+// one in the deprecated positional scheme, makes the note a reply.
+func isReply(evt *nostr.Event) bool {
+	for _, tag := range evt.Tags {
+		if len(tag) < 2 || tag[0] != "e" {
+			continue
+		}
+		if len(tag) < 4 || tag[3] == "root" || tag[3] == "reply" || tag[3] == "" {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,169 @@
+package exifscan
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/nbd-wtf/go-nostr"
+)
+
+// ProfileFields are the kind 0 fields ProfileLeaks reads.
+var ProfileFields = []string{"about", "website", "lud16", "nip05"}
+
+var (
+	emailRE = regexp.MustCompile(`(?i)\b[a-z0-9._%+-]+@[a-z0-9-]+(?:\.[a-z0-9-]+)*\.[a-z]{2,}\b`)
+	// phoneRE matches groups of digits with the single separators phone
+	// numbers are written with; phoneNumber checks how many digits they
+	// have.
+	phoneRE = regexp.MustCompile(`(?:\+|\()?\b\d+(?:(?:[ .-]|\) ?)\d+)*\b`)
+	// addressRE matches a house number and a street, English or
+	// continental style: 221B Baker Street, Hauptstraße 12, 5 rue de Rivoli.
+	addressRE = regexp.MustCompile(`\b\d{1,5}[A-Za-z]?,? (?:[A-Z][a-z]+ ){1,3}(?:Street|St|Avenue|Ave|Road|Rd|Boulevard|Blvd|Lane|Ln|Drive|Dr|Court|Ct|Place|Pl|Square|Sq|Terrace)\b\.?` +
+		`|\b[A-ZÄÖÜ][a-zäöüß]+(?:straße|strasse|str\.|weg|gasse|allee|platz|laan|gatan|vej) \d{1,4}[a-z]?\b` +
+		`|\b\d{1,4},? (?:rue|avenue|boulevard|via|calle|rua) (?:[a-z']+ ){0,3}[A-Z][a-zé]+\b`)
+	// nameRE catches people introducing themselves by first and last name.
+	nameRE = regexp.MustCompile(`(?:[Mm]y name is|[Nn]ame:|I am|I'm|[Ss]igned,?) ([A-Z][a-z]+(?: [A-Z][a-z]+)+)`)
+	// handleNameRE matches first.last or first_last handles, the shape
+	// of a real name in NIP-05 and lightning addresses.
+	handleNameRE = regexp.MustCompile(`^[a-z]{2,}[._][a-z]{2,}$`)
+	dateRE       = regexp.MustCompile(`^\d{4}[-./]\d{1,2}[-./]\d{1,2}`)
+)
+
+// mailProviders are domains that serve mailboxes, not lightning wallets or
+// NIP-05, so an address at one of them in lud16 or nip05 is an email.
+var mailProviders = map[string]bool{
+	"gmail.com": true, "googlemail.com": true, "outlook.com": true, "hotmail.com": true,
+	"live.com": true, "yahoo.com": true, "icloud.com": true, "me.com": true,
+	"proton.me": true, "protonmail.com": true, "gmx.de": true, "gmx.net": true,
+	"web.de": true, "aol.com": true, "mail.ru": true, "yandex.ru": true, "tutanota.com": true,
+}
+
+// identitySites are social networks whose profile links carry a legal
+// name by policy or custom.
+var identitySites = map[string]string{
+	"linkedin.com": "LinkedIn", "facebook.com": "Facebook", "xing.com": "XING",
+}
+
+// FetchProfile returns the newest kind 0 metadata event of pubkey on the
+// configured relays, or nil when none has one.
+func (s *Scanner) FetchProfile(ctx context.Context, pubkey string) (*nostr.Event, error) {
+	if !nostr.IsValidPublicKey(pubkey) {
+		return nil, fmt.Errorf("invalid public key %q", pubkey)
+	}
+	ctx, cancel := context.WithTimeout(ctx, s.opts.FetchTimeout)
+	defer cancel()
+	filter := nostr.Filter{Kinds: []int{nostr.KindProfileMetadata}, Authors: []string{pubkey}, Limit: 1}
+	var newest *nostr.Event
+	pool := s.newPool(ctx)
+	for evt := range pool.SubManyEose(ctx, s.opts.Relays, nostr.Filters{filter}) {
+		if newest == nil || evt.CreatedAt > newest.CreatedAt {
+			newest = evt.Event
+		}
+	}
+	return newest, nil
+}
+
+// ProfileLeaks looks for personal details in the text fields of a kind 0
+// event: phone numbers, emails, street addresses and real names. Tags
+// carry the field they were found in.
+func ProfileLeaks(evt *nostr.Event) []Tag {
+	if evt == nil {
+		return nil
+	}
+	var meta map[string]any
+	if json.Unmarshal([]byte(evt.Content), &meta) != nil {
+		return nil
+	}
+	var tags []Tag
+	seen := map[Tag]bool{}
+	add := func(field, category, value string) {
+		t := Tag{Field: field, Category: category, Value: strings.TrimSpace(value)}
+		if !seen[t] {
+			seen[t] = true
+			tags = append(tags, t)
+		}
+	}
+	// Addresses the profile names as its own lud16 or nip05 are expected
+	// in the about text and aren't emails.
+	own := map[string]bool{}
+	for _, field := range []string{"lud16", "nip05"} {
+		if v, ok := meta[field].(string); ok {
+			own[strings.ToLower(strings.TrimSpace(v))] = true
+		}
+	}
+	for _, field := range ProfileFields {
+		v, _ := meta[field].(string)
+		if v == "" {
+			continue
+		}
+		switch field {
+		case "lud16", "nip05":
+			local, domain, ok := strings.Cut(strings.ToLower(strings.TrimSpace(v)), "@")
+			if !ok {
+				continue
+			}
+			if mailProviders[domain] {
+				add(field, CategoryEmail, v)
+			}
+			if handleNameRE.MatchString(local) {
+				add(field, CategoryName, v+" (looks like first and last name)")
+			}
+			if phoneNumber(local) {
+				add(field, CategoryPhone, v)
+			}
+			continue
+		case "website":
+			if u, err := url.Parse(v); err == nil {
+				host := strings.TrimPrefix(strings.ToLower(u.Host), "www.")
+				if site, ok := identitySites[host]; ok && strings.Trim(u.Path, "/") != "" {
+					add(field, CategoryName, site+" profile "+v)
+				}
+			}
+		}
+		for _, m := range emailRE.FindAllString(v, -1) {
+			if !own[strings.ToLower(m)] {
+				add(field, CategoryEmail, m)
+			}
+		}
+		for _, m := range phoneRE.FindAllString(v, -1) {
+			if phoneNumber(m) {
+				add(field, CategoryPhone, m)
+			}
+		}
+		for _, m := range addressRE.FindAllString(v, -1) {
+			add(field, CategoryAddress, m)
+		}
+		for _, m := range nameRE.FindAllStringSubmatch(v, -1) {
+			add(field, CategoryName, m[1])
+		}
+	}
+	sort.SliceStable(tags, func(i, j int) bool {
+		return SeverityRank(CategorySeverity[tags[i].Category]) > SeverityRank(CategorySeverity[tags[j].Category])
+	})
+	return tags
+}
+
+// phoneNumber tells phone numbers from other digit runs: they have 9 to
+// 15 digits, and unless written with a + or separators, at least 10.
+func phoneNumber(s string) bool {
+	digits := 0
+	for _, c := range s {
+		switch {
+		case c >= '0' && c <= '9':
+			digits++
+		case strings.ContainsRune(" ().-+", c):
+		default:
+			return false
+		}
+	}
+	plain := digits == len(s)
+	if digits < 9 || digits > 15 || plain && digits < 10 {
+		return false
+	}
+	return !dateRE.MatchString(s)
+}
@@ -0,0 +1,308 @@
+package exifscan
+
+import (
+	"bufio"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+
+	exif "github.com/rwcarlsen/goexif/exif"
+)
+
+// Provenance classes: what most likely happened to a file before it was
+// posted.
+const (
+	// ProvenanceOriginal: the file is as the camera or phone wrote it.
+	ProvenanceOriginal = "likely original"
+	// ProvenanceRecompressed: a messenger or client re-encoded it,
+	// dropping the metadata with the original pixels.
+	ProvenanceRecompressed = "likely messenger-recompressed"
+	// ProvenanceStripped: the metadata was cut out of an otherwise
+	// untouched file.
+	ProvenanceStripped = "likely client-stripped"
+)
+
+// Confidence levels of a provenance guess.
+const (
+	ConfidenceLow    = "low"
+	ConfidenceMedium = "medium"
+	ConfidenceHigh   = "high"
+)
+
+// Provenance explains why a file carries the metadata it does. For a
+// clean file it tells how much the verdict says about the original: a
+// recompressed copy may come from a photo that still leaks elsewhere.
+type Provenance struct {
+	Class      string `json:"class"`
+	Confidence string `json:"confidence"`
+	// Quality is the estimated JPEG quality on the libjpeg scale.
+	Quality int      `json:"quality,omitempty"`
+	Width   int      `json:"width,omitempty"`
+	Height  int      `json:"height,omitempty"`
+	Reasons []string `json:"reasons,omitempty"`
+}
+
+func (p *Provenance) String() string {
+	s := fmt.Sprintf("%s (%s confidence)", p.Class, p.Confidence)
+	if len(p.Reasons) > 0 {
+		s += ": " + strings.Join(p.Reasons, ", ")
+	}
+	return s
+}
+
+// messengerSizes are the long sides messengers and Nostr clients scale
+// photos down to.
+var messengerSizes = map[int]bool{800: true, 960: true, 1080: true, 1280: true, 1600: true, 2048: true, 2560: true}
+
+// jpegInfo is what classify needs of a JPEG's header segments.
+type jpegInfo struct {
+	width, height int
+	progressive   bool
+	jfif, icc     bool
+	// others counts APPn segments other than JFIF, Exif and ICC, e.g.
+	// MPF, XMP or Photoshop's.
+	others int
+	luma   []int
+}
+
+// jpegHeader reads the segments of a JPEG up to the start of scan.
+func jpegHeader(r io.Reader) (jpegInfo, bool) {
+	var info jpegInfo
+	br := bufio.NewReader(r)
+	var soi [2]byte
+	if _, err := io.ReadFull(br, soi[:]); err != nil || soi != [2]byte{0xFF, 0xD8} {
+		return info, false
+	}
+	for {
+		var hdr [4]byte
+		if _, err := io.ReadFull(br, hdr[:2]); err != nil || hdr[0] != 0xFF {
+			return info, info.width > 0
+		}
+		marker := hdr[1]
+		if marker == 0xFF || marker == 0x01 || marker >= 0xD0 && marker <= 0xD7 {
+			// Fill bytes and markers without a length.
+			continue
+		}
+		if _, err := io.ReadFull(br, hdr[2:]); err != nil {
+			return info, false
+		}
+		n := int(binary.BigEndian.Uint16(hdr[2:])) - 2
+		if n < 0 {
+			return info, false
+		}
+		if marker == 0xDA || marker == 0xD9 {
+			return info, info.width > 0
+		}
+		seg := readChunk(br, int64(n))
+		if seg == nil {
+			return info, false
+		}
+		switch {
+		case marker == 0xE0 && strings.HasPrefix(string(seg), "JFIF\x00"):
+			info.jfif = true
+		case marker == 0xE1 && strings.HasPrefix(string(seg), "Exif\x00"):
+		case marker == 0xE2 && strings.HasPrefix(string(seg), "ICC_PROFILE\x00"):
+			info.icc = true
+		case marker >= 0xE0 && marker <= 0xEF:
+			info.others++
+		case marker == 0xDB && info.luma == nil:
+			info.luma = lumaTable(seg)
+		case marker >= 0xC0 && marker <= 0xCF && marker != 0xC4 && marker != 0xC8 && marker != 0xCC:
+			if len(seg) >= 5 {
+				info.height = int(binary.BigEndian.Uint16(seg[1:]))
+				info.width = int(binary.BigEndian.Uint16(seg[3:]))
+			}
+			info.progressive = marker == 0xC2
+		}
+	}
+}
+
+// lumaTable returns quantization table 0 of a DQT segment in zigzag order.
+func lumaTable(seg []byte) []int {
+	for len(seg) > 0 {
+		pq, tq := seg[0]>>4, seg[0]&0x0F
+		size := 64
+		if pq == 1 {
+			size = 128
+		}
+		if len(seg) < 1+size {
+			return nil
+		}
+		if tq == 0 {
+			t := make([]int, 64)
+			for i := range t {
+				if pq == 1 {
+					t[i] = int(binary.BigEndian.Uint16(seg[1+2*i:]))
+				} else {
+					t[i] = int(seg[1+i])
+				}
+			}
+			return t
+		}
+		seg = seg[1+size:]
+	}
+	return nil
+}
+
+// stdLuma is the luminance table of the JPEG standard's annex K, which
+// libjpeg scales for its quality setting, in zigzag order.
+var stdLuma = [64]int{
+	16, 11, 12, 14, 12, 10, 16, 14, 13, 14, 18, 17, 16, 19, 24, 40,
+	26, 24, 22, 22, 24, 49, 35, 37, 29, 40, 58, 51, 61, 60, 57, 51,
+	56, 55, 64, 72, 92, 78, 64, 68, 87, 69, 55, 56, 80, 109, 81, 87,
+	95, 98, 103, 104, 103, 62, 77, 113, 121, 112, 100, 120, 92, 101, 103, 99,
+}
+
+// jpegQuality estimates the libjpeg quality a luminance table was made
+// with, and whether it is exactly libjpeg's table for that quality.
+// Cameras and phones tune their own tables; re-encoders mostly don't.
+func jpegQuality(t []int) (q int, standard bool) {
+	sum := 0.0
+	for i, v := range t {
+		sum += float64(v) * 100 / float64(stdLuma[i])
+	}
+	scale := sum / 64
+	if scale <= 100 {
+		q = int((200-scale)/2 + 0.5)
+	} else {
+		q = int(5000/scale + 0.5)
+	}
+	q = max(1, min(100, q))
+	for c := max(1, q-2); c <= min(100, q+2); c++ {
+		if sameTable(t, c) {
+			return c, true
+		}
+	}
+	return q, false
+}
+
+func sameTable(t []int, q int) bool {
+	scale := 200 - 2*q
+	if q < 50 {
+		scale = 5000 / q
+	}
+	for i, v := range t {
+		want := max(1, min(255, (stdLuma[i]*scale+50)/100))
+		if v != want {
+			return false
+		}
+	}
+	return true
+}
+
+// classify guesses the provenance of a file of the given format from its
+// header and the EXIF decoded from it, if any. It returns nil for formats
+// it can't tell anything about, like PNG screenshots.
+func classify(format string, r io.Reader, x *exif.Exif) *Provenance {
+	camera := false
+	if x != nil {
+		_, errMake := x.Get(exif.Make)
+		_, errModel := x.Get(exif.Model)
+		camera = errMake == nil || errModel == nil
+	}
+	switch format {
+	case "image/jpeg":
+		info, ok := jpegHeader(r)
+		if !ok {
+			return nil
+		}
+		return classifyJPEG(info, x != nil, camera)
+	case "image/heic", "image/avif", "image/webp":
+		p := &Provenance{Class: ProvenanceStripped, Confidence: ConfidenceLow}
+		switch {
+		case camera:
+			p.Class, p.Confidence = ProvenanceOriginal, ConfidenceMedium
+			p.Reasons = []string{"camera make and model present"}
+		case format == "image/heic":
+			p.Reasons = []string{"phone format without EXIF"}
+		default:
+			// Cameras don't write WebP or AVIF; a client converted it.
+			p.Class = ProvenanceRecompressed
+			p.Reasons = []string{"converted to " + strings.TrimPrefix(format, "image/")}
+		}
+		return p
+	}
+	return nil
+}
+
+func classifyJPEG(info jpegInfo, hasExif, camera bool) *Provenance {
+	p := &Provenance{Width: info.width, Height: info.height}
+	var original, recompressed, stripped int
+	reason := func(s string) { p.Reasons = append(p.Reasons, s) }
+
+	switch {
+	case camera:
+		original += 3
+		reason("camera make and model present")
+	case hasExif:
+		stripped++
+		reason("EXIF without camera tags")
+	default:
+		reason("no EXIF")
+	}
+	if info.luma != nil {
+		q, standard := jpegQuality(info.luma)
+		p.Quality = q
+		switch {
+		case q <= 85:
+			recompressed += 2
+			reason(fmt.Sprintf("quality ~%d", q))
+		case q >= 92:
+			original++
+			stripped++
+			reason(fmt.Sprintf("quality ~%d", q))
+		}
+		if standard {
+			recompressed++
+			reason("libjpeg quantization tables")
+		} else {
+			original++
+			stripped++
+			reason("encoder-specific quantization tables")
+		}
+	}
+	long := max(info.width, info.height)
+	switch {
+	case messengerSizes[long]:
+		recompressed += 2
+		reason(fmt.Sprintf("%d px long side", long))
+	case long >= 3000:
+		original++
+		stripped++
+		reason(fmt.Sprintf("%d×%d, a camera resolution", info.width, info.height))
+	}
+	if info.progressive {
+		recompressed++
+		reason("progressive")
+	}
+	if !hasExif {
+		if info.icc || info.others > 0 {
+			// Re-encoders rarely carry over more than JFIF.
+			stripped += 2
+			reason("other metadata segments kept")
+		} else if info.jfif {
+			recompressed++
+			reason("only a JFIF header")
+		}
+		// Without EXIF a file can't be as the camera wrote it.
+		original = 0
+	}
+
+	scores := []struct {
+		class string
+		score int
+	}{{ProvenanceOriginal, original}, {ProvenanceRecompressed, recompressed}, {ProvenanceStripped, stripped}}
+	sort.SliceStable(scores, func(i, j int) bool { return scores[i].score > scores[j].score })
+	p.Class = scores[0].class
+	switch margin := scores[0].score - scores[1].score; {
+	case margin >= 3:
+		p.Confidence = ConfidenceHigh
+	case margin >= 1:
+		p.Confidence = ConfidenceMedium
+	default:
+		p.Confidence = ConfidenceLow
+	}
+	return p
+}
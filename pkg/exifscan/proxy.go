@@ -0,0 +1,287 @@
+package exifscan
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"path"
+	"strings"
+	"sync"
+	"time"
+)
+
+// proxyParams are the query parameters resizing proxies take the origin
+// URL in: wsrv.nl and Next.js use url, Primal's media cache u.
+var proxyParams = []string{"url", "u", "src", "image", "img", "imageUrl"}
+
+// OriginURL returns the image an image proxy or CDN resizer link wraps,
+// or "" when link doesn't look like one. It knows query parameter
+// proxies (wsrv.nl, Next.js, Primal), imgproxy's plain and base64 source
+// URLs, Cloudflare's /cdn-cgi/image/, Camo, proxies taking the origin
+// as the rest of their path and nostr.build's resized variants.
+func OriginURL(link string) string {
+	u, err := url.Parse(link)
+	if err != nil || u.Host == "" {
+		return ""
+	}
+	if isNostrBuild(u.Host) {
+		return nostrBuildOriginal(u)
+	}
+	origin := ""
+	q := u.Query()
+	for _, p := range proxyParams {
+		if v := q.Get(p); v != "" {
+			if isWeserv(u.Host) && !strings.Contains(v, "://") {
+				v = "https://" + v
+			}
+			if isHTTP(v) {
+				origin = v
+				break
+			}
+		}
+	}
+	p := u.EscapedPath()
+	if origin == "" {
+		if _, rest, ok := strings.Cut(p, "/plain/"); ok {
+			// imgproxy: /<signature>/<options>/plain/<url>@<extension>
+			if i := strings.LastIndex(rest, "@"); i > 0 {
+				rest = rest[:i]
+			}
+			origin, _ = url.PathUnescape(rest)
+		} else if _, rest, ok := strings.Cut(p, "/cdn-cgi/image/"); ok {
+			// Cloudflare: /cdn-cgi/image/<options>/<absolute or host relative URL>
+			if _, src, ok := strings.Cut(rest, "/"); ok {
+				src, _ = url.PathUnescape(src)
+				if !isHTTP(src) {
+					src = u.Scheme + "://" + u.Host + "/" + src
+				}
+				origin = src
+			}
+		} else if i := embeddedURL(p); i > 0 {
+			origin, _ = url.PathUnescape(p[i:])
+			// Path cleaning often collapses the scheme's slashes.
+			if s, rest, ok := strings.Cut(origin, ":/"); ok && !strings.HasPrefix(rest, "/") {
+				origin = s + "://" + rest
+			}
+		} else if strings.Contains(u.Host, "camo") {
+			// Camo: /<digest>/<hex encoded URL>
+			if b, err := hex.DecodeString(path.Base(p)); err == nil {
+				origin = string(b)
+			}
+		} else {
+			origin = imgproxyBase64(p)
+		}
+	}
+	if !isHTTP(origin) || origin == link {
+		return ""
+	}
+	return origin
+}
+
+func isNostrBuild(host string) bool {
+	return host == "nostr.build" || strings.HasSuffix(host, ".nostr.build")
+}
+
+// nostrBuildVariants are the path prefixes nostr.build serves resized
+// copies of an upload under; the upload itself is at /<file>.
+var nostrBuildVariants = []string{"/resp/", "/thumb/", "/thumbs/"}
+
+// nostrBuildOriginal maps a resized nostr.build delivery URL to the full
+// upload on the same host, from the URL layout alone. The scanner asks
+// their API first when the note names the original; see originURLs.
+func nostrBuildOriginal(u *url.URL) string {
+	p := u.Path
+	for _, prefix := range nostrBuildVariants {
+		rest, ok := strings.CutPrefix(p, prefix)
+		if !ok {
+			continue
+		}
+		if prefix == "/resp/" {
+			// /resp/<240p|360p|480p|720p|1080p>/<file>
+			_, rest, ok = strings.Cut(rest, "/")
+			if !ok {
+				return ""
+			}
+		}
+		o := url.URL{Scheme: u.Scheme, Host: u.Host, Path: "/" + rest}
+		return o.String()
+	}
+	if u.RawQuery != "" {
+		// Sizing parameters, e.g. ?w=640
+		o := *u
+		o.RawQuery = ""
+		return o.String()
+	}
+	return ""
+}
+
+func isHTTP(s string) bool {
+	return strings.HasPrefix(s, "https://") || strings.HasPrefix(s, "http://")
+}
+
+func isWeserv(host string) bool {
+	return host == "wsrv.nl" || host == "images.weserv.nl"
+}
+
+// embeddedURL finds an absolute URL in an escaped path, past its first
+// segment, e.g. /100x150/https://example.com/a.jpg.
+func embeddedURL(p string) int {
+	for _, scheme := range []string{"/https:/", "/http:/", "/https%3A", "/http%3A"} {
+		if i := strings.Index(p, scheme); i >= 0 {
+			return i + 1
+		}
+	}
+	return -1
+}
+
+// imgproxyBase64 decodes imgproxy's /<signature>/<options>/<base64 URL>.<ext>,
+// where the encoded URL may be split into several segments.
+func imgproxyBase64(p string) string {
+	segs := strings.Split(strings.Trim(p, "/"), "/")
+	for i := 1; i < len(segs); i++ {
+		enc := strings.Join(segs[i:], "")
+		enc = strings.TrimSuffix(enc, path.Ext(enc))
+		b, err := base64.RawURLEncoding.DecodeString(strings.TrimRight(enc, "="))
+		if err == nil && isHTTP(string(b)) {
+			return string(b)
+		}
+	}
+	return ""
+}
+
+// nostrBuildInfo is where nostr.build publishes its NIP-96 API URL.
+const nostrBuildInfo = "https://nostr.build/.well-known/nostr/nip96.json"
+
+// nostrBuildAPI is nostr.build's NIP-96 API URL, discovered on first use.
+// Only a success is kept: failed lookups are tried again after a delay
+// that doubles up to an hour, so a long running daemon recovers.
+type nostrBuildAPI struct {
+	mu       sync.Mutex
+	url      string
+	failures int
+	retryAt  time.Time
+}
+
+// nostrBuildTimeout bounds one discovery, which outlives the scan of the
+// image that started it.
+const nostrBuildTimeout = 10 * time.Second
+
+// nostrBuildURL returns nostr.build's NIP-96 API URL, or "" while it
+// can't be discovered.
+func (s *Scanner) nostrBuildURL(ctx context.Context) string {
+	a := &s.nostrBuild
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if a.url != "" || time.Now().Before(a.retryAt) {
+		return a.url
+	}
+	ctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), nostrBuildTimeout)
+	defer cancel()
+	api, err := s.discoverNostrBuild(ctx)
+	if err != nil {
+		a.retryAt = time.Now().Add(min(time.Minute<<a.failures, time.Hour))
+		a.failures = min(a.failures+1, 6)
+		return ""
+	}
+	a.url, a.failures = api, 0
+	return api
+}
+
+func (s *Scanner) discoverNostrBuild(ctx context.Context) (string, error) {
+	if err := s.opts.RateLimit.wait(ctx); err != nil {
+		return "", err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, nostrBuildInfo, nil)
+	if err != nil {
+		return "", err
+	}
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("nostr.build discovery: %s", resp.Status)
+	}
+	var info struct {
+		APIURL string `json:"api_url"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&info); err != nil {
+		return "", fmt.Errorf("nostr.build discovery: %w", err)
+	}
+	if !isHTTP(info.APIURL) {
+		return "", fmt.Errorf("nostr.build discovery: no api_url")
+	}
+	return strings.TrimRight(info.APIURL, "/"), nil
+}
+
+// originURLs returns the originals to try for r, best first. For
+// nostr.build uploads whose note names the original's hash (the imeta
+// ox field), that is the NIP-96 download route, $api_url/<ox>, which
+// serves the file as uploaded; OriginURL's guess from the link's layout
+// comes after.
+func (s *Scanner) originURLs(ctx context.Context, r *ImageResult) []string {
+	var out []string
+	if u, err := url.Parse(r.URL); err == nil && isNostrBuild(u.Host) {
+		if ox := originalHash(r.Event, r.URL); ox != "" && ox != r.DeclaredSHA256 {
+			if api := s.nostrBuildURL(ctx); api != "" {
+				out = append(out, api+"/"+ox+path.Ext(u.Path))
+			}
+		}
+	}
+	if o := OriginURL(r.URL); o != "" {
+		out = append(out, o)
+	}
+	return out
+}
+
+// downloadOrigin downloads the first of r's originals that can be.
+func (s *Scanner) downloadOrigin(ctx context.Context, r *ImageResult) (*payload, string) {
+	for _, origin := range s.originURLs(ctx, r) {
+		if p, _, err := s.download(ctx, origin); err == nil {
+			return p, origin
+		}
+	}
+	return nil, ""
+}
+
+// scanOrigin also scans the original behind a proxy link. When it leaks
+// but the proxied copy doesn't, its findings replace the copy's and
+// OriginLeaks is set.
+func (s *Scanner) scanOrigin(ctx context.Context, r *ImageResult) {
+	p, origin := s.downloadOrigin(ctx, r)
+	if p == nil {
+		return
+	}
+	o := &ImageResult{EventID: r.EventID, URL: origin}
+	s.inspect(ctx, o, p)
+	p.close(o.Data != nil || o.DataFile != "")
+	r.Origin = origin
+	if !o.Sensitive() || r.Sensitive() {
+		o.Discard()
+		return
+	}
+	r.Discard()
+	r.OriginLeaks = true
+	r.HasMetadata, r.Tags, r.GPS, r.AllTags = true, o.Tags, o.GPS, o.AllTags
+	r.Exif, r.Data, r.DataFile = o.Exif, o.Data, o.DataFile
+	r.Fingerprint = r.fingerprint()
+}
+
+// fetchOrigin downloads the original behind r's proxy link in its place
+// when the link failed with err. The hash r's bytes are checked against
+// becomes the one declared for the original, if any.
+func (s *Scanner) fetchOrigin(ctx context.Context, r *ImageResult, err error) (*payload, bool) {
+	p, origin := s.downloadOrigin(ctx, r)
+	if p == nil {
+		return nil, false
+	}
+	r.Origin, r.ProxyError = origin, err.Error()
+	// What the note declared describes the proxied copy, not these bytes.
+	r.DeclaredSHA256 = declaredHash(r.Event, origin)
+	return p, true
+}
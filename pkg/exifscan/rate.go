@@ -0,0 +1,32 @@
+package exifscan
+
+import (
+	"context"
+	"math"
+
+	"golang.org/x/time/rate"
+)
+
+// RateLimit caps the image requests per second of every Scanner whose
+// Options share it, on top of the per-host limits of Threads.
+type RateLimit struct {
+	l *rate.Limiter
+}
+
+// NewRateLimit allows rps requests per second, or returns nil, which
+// doesn't limit, when rps isn't positive. Bursts are one second's worth,
+// rounded up.
+func NewRateLimit(rps float64) *RateLimit {
+	if rps <= 0 {
+		return nil
+	}
+	return &RateLimit{l: rate.NewLimiter(rate.Limit(rps), int(math.Ceil(rps)))}
+}
+
+// wait blocks until the next request may start.
+func (r *RateLimit) wait(ctx context.Context) error {
+	if r == nil {
+		return nil
+	}
+	return r.l.Wait(ctx)
+}
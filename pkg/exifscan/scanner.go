@@ -0,0 +1,689 @@
+// Package exifscan finds nostr image posts that leak EXIF metadata such as
+// GPS coordinates, device models and serial numbers.
+package exifscan
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/nbd-wtf/go-nostr"
+	"github.com/nbd-wtf/go-nostr/nip19"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+	"golang.org/x/sync/errgroup"
+)
+
+// DefaultRelays are queried when Options.Relays is empty.
+var DefaultRelays = []string{
+	"wss://relay.nostr.band",
+	"wss://nos.lol",
+	"wss://relay.snort.social",
+}
+
+// MaxThreads caps Options.Threads.
+const MaxThreads = 32
+
+// Options configures a Scanner. Zero values select the defaults.
+type Options struct {
+	Relays []string
+	// Limit is the maximum number of events requested per relay.
+	Limit int
+	// Last, when set, keeps only the Last most recent notes across all
+	// relays, replacing Limit.
+	Last  int
+	Since time.Time
+	Until time.Time
+	// Geohashes, when set, keeps only the notes with a g tag inside one
+	// of these geohash cells, see InGeohash. Relays match g tags exactly,
+	// so the notes are filtered after fetching and Limit counts them all.
+	Geohashes []string
+	// ContentMatch, when set, keeps only the notes whose text it matches,
+	// and ContentExclude drops those it matches, before their links are
+	// extracted.
+	ContentMatch   *regexp.Regexp
+	ContentExclude *regexp.Regexp
+	// NoReplies drops the notes e tags mark as replies (NIP-10), keeping
+	// top-level posts.
+	NoReplies bool
+	// OnlyMediaEvents drops the notes that neither carry an imeta tag nor
+	// link an image as they arrive, so text-only notes aren't held in
+	// memory; see HasMedia.
+	OnlyMediaEvents bool
+	// SkipContentWarnings makes Watch leave out the images of notes with
+	// a NIP-36 content warning; see SkipContentWarnings for scans.
+	SkipContentWarnings bool
+	// Threads is the number of concurrent image downloads, or AutoThreads.
+	Threads int
+	// FetchTimeout bounds the relay query.
+	FetchTimeout time.Duration
+	HTTPClient   *http.Client
+	// MaxInFlightBytes bounds the downloaded bytes held in memory at once
+	// by all workers; downloads wait for room and larger images fail.
+	// Zero selects DefaultMaxInFlightBytes. Data kept by KeepData no
+	// longer counts once its result is returned.
+	MaxInFlightBytes int64
+	// SpoolBytes is the size above which a download is written to a temp
+	// file in SpoolDir (default os.TempDir()) and decoded from disk, so
+	// RAW and video files can be scanned on small machines. Zero selects
+	// DefaultSpoolBytes; negative keeps every download in memory.
+	SpoolBytes int64
+	SpoolDir   string
+	// MaxSpoolBytes bounds a spooled download on disk, so an endless
+	// body can't fill SpoolDir; bigger ones fail. Zero selects
+	// DefaultMaxSpoolBytes; negative removes the bound.
+	MaxSpoolBytes int64
+	// RateLimit, when set, caps the image requests per second; Scanners
+	// sharing one are capped together.
+	RateLimit *RateLimit
+	// WaybackURL is the availability API asked for a snapshot of links
+	// that are dead, to scan the archived copy instead; empty disables it.
+	// DefaultWaybackURL is the Internet Archive's.
+	WaybackURL string
+	// ProxyOrigins also scans the original URL image proxy and resizer
+	// links wrap (see OriginURL), which often strip what the original
+	// still carries.
+	ProxyOrigins bool
+	// MaxArchiveBytes is the size up to which linked zip archives are
+	// opened and the files inside scanned. Zero selects
+	// DefaultMaxArchiveBytes; negative never opens them.
+	MaxArchiveBytes int64
+	// Stego looks for data appended to images and for LSB embedding in
+	// PNGs, see StegoHint.
+	Stego bool
+	// OCR, when set, reads the text visible in images for the locations,
+	// addresses and date stamps burned into them, which no metadata
+	// carries; see VisibleLeaks.
+	OCR TextReader
+	// PerceptualHash sets ImageResult.PHash, which tells copies of a photo
+	// apart from other photos across accounts.
+	PerceptualHash bool
+	// AllTags fills ImageResult.AllTags with every decoded field.
+	AllTags bool
+	// Retries is how often a download failing transiently, with a 429,
+	// a 5xx or a dropped connection, is tried again. Zero selects
+	// DefaultRetries; negative never retries.
+	Retries int
+	// Profile makes Scan also check the author's kind 0 metadata for
+	// personal details, see ProfileLeaks.
+	Profile bool
+	// KeepData retains the downloaded bytes and decoded EXIF on results
+	// that carry metadata.
+	KeepData bool
+	Hooks    Hooks
+}
+
+// Scanner fetches an author's notes and scans the images they link.
+type Scanner struct {
+	opts    Options
+	client  *http.Client
+	budget  *budget
+	threads atomic.Int32
+	seen    sightings
+	// nostrBuild is looked up for the first nostr.build link with an
+	// original.
+	nostrBuild nostrBuildAPI
+}
+
+func New(opts Options) *Scanner {
+	if len(opts.Relays) == 0 {
+		opts.Relays = DefaultRelays
+	}
+	if opts.Limit <= 0 {
+		opts.Limit = 10000
+	}
+	if opts.Threads <= 0 && opts.Threads != AutoThreads {
+		opts.Threads = 8
+	}
+	if opts.Threads > MaxThreads {
+		opts.Threads = MaxThreads
+	}
+	if opts.MaxInFlightBytes <= 0 {
+		opts.MaxInFlightBytes = DefaultMaxInFlightBytes
+	}
+	if opts.SpoolBytes == 0 {
+		opts.SpoolBytes = DefaultSpoolBytes
+	}
+	if opts.MaxSpoolBytes == 0 {
+		opts.MaxSpoolBytes = DefaultMaxSpoolBytes
+	}
+	if opts.Retries == 0 {
+		opts.Retries = DefaultRetries
+	}
+	if opts.FetchTimeout <= 0 {
+		opts.FetchTimeout = 30 * time.Second
+	}
+	client := opts.HTTPClient
+	if client == nil {
+		client = &http.Client{Timeout: 10 * time.Second}
+	}
+	return &Scanner{opts: opts, client: client, budget: newBudget(opts.MaxInFlightBytes)}
+}
+
+// DecodePubkey accepts an npub, nprofile or hex public key and returns the
+// hex form.
+func DecodePubkey(s string) (string, error) {
+	if nostr.IsValidPublicKey(s) {
+		return s, nil
+	}
+	prefix, data, err := nip19.Decode(s)
+	if err != nil {
+		return "", err
+	}
+	switch v := data.(type) {
+	case string:
+		if prefix == "npub" {
+			return v, nil
+		}
+	case nostr.ProfilePointer:
+		return v.PublicKey, nil
+	}
+	return "", fmt.Errorf("%s is not a public key", prefix)
+}
+
+// Scan fetches the notes of pubkey (hex) and scans every linked image.
+func (s *Scanner) Scan(ctx context.Context, pubkey string) (*Findings, error) {
+	events, err := s.FetchEvents(ctx, pubkey)
+	if err != nil {
+		return nil, err
+	}
+	findings := &Findings{Pubkey: pubkey, Events: len(events)}
+	if s.opts.Profile {
+		profile, _ := s.FetchProfile(ctx, pubkey)
+		findings.Profile = ProfileLeaks(profile)
+	}
+	for r := range s.ScanImages(ctx, ExtractImages(events)) {
+		findings.Images = append(findings.Images, r)
+	}
+	return findings, nil
+}
+
+// FetchEvents returns the kind 1 notes of pubkey, oldest first.
+func (s *Scanner) FetchEvents(ctx context.Context, pubkey string) ([]nostr.Event, error) {
+	if !nostr.IsValidPublicKey(pubkey) {
+		return nil, fmt.Errorf("invalid public key %q", pubkey)
+	}
+	ctx, span := tracer.Start(ctx, "exifscan.FetchEvents", trace.WithAttributes(
+		attribute.String("nostr.pubkey", pubkey),
+		attribute.StringSlice("nostr.relays", s.opts.Relays),
+	))
+	defer span.End()
+	ctx, cancel := context.WithTimeout(ctx, s.opts.FetchTimeout)
+	defer cancel()
+
+	filter := nostr.Filter{
+		Kinds:   []int{1},
+		Authors: []string{pubkey},
+		Limit:   s.opts.Limit,
+	}
+	if s.opts.Last > 0 && !s.filtersNotes() {
+		// Relays answer newest first, so each one's Last newest cover
+		// the Last newest overall. With note filters they may not pass
+		// them, so Limit stays.
+		filter.Limit = s.opts.Last
+	}
+	if !s.opts.Since.IsZero() {
+		ts := nostr.Timestamp(s.opts.Since.Unix())
+		filter.Since = &ts
+	}
+	if !s.opts.Until.IsZero() {
+		ts := nostr.Timestamp(s.opts.Until.Unix())
+		filter.Until = &ts
+	}
+
+	var events []nostr.Event
+	seen := map[string]bool{}
+	pool := s.newPool(ctx, nostr.WithDuplicateMiddleware(func(relay, id string) {
+		s.seen.add(id, relay)
+		s.trace(relay, "EVENT %s (duplicate)", id)
+	}))
+	relays := s.connectRelays(ctx, pool)
+	for _, url := range relays {
+		s.trace(url, "REQ %s", filter)
+	}
+	for evt := range pool.SubManyEose(ctx, relays, nostr.Filters{filter}) {
+		if evt.Relay != nil {
+			s.seen.add(evt.ID, evt.Relay.URL)
+			s.trace(evt.Relay.URL, "EVENT %s kind %d", evt.ID, evt.Kind)
+		}
+		if seen[evt.ID] {
+			continue
+		}
+		seen[evt.ID] = true
+		if !s.keepEvent(evt.Event) {
+			continue
+		}
+		events = append(events, *evt.Event)
+		s.eventFetched(evt.Event)
+	}
+	sort.Slice(events, func(i, j int) bool {
+		return events[i].CreatedAt < events[j].CreatedAt
+	})
+	if s.opts.Last > 0 && len(events) > s.opts.Last {
+		events = events[len(events)-s.opts.Last:]
+	}
+	s.trace("", "EOSE from all relays or timed out, %d distinct events", len(events))
+	span.SetAttributes(attribute.Int("nostr.events", len(events)))
+	return events, nil
+}
+
+func (s *Scanner) filtersNotes() bool {
+	return len(s.opts.Geohashes) > 0 || s.opts.ContentMatch != nil || s.opts.ContentExclude != nil || s.opts.NoReplies || s.opts.OnlyMediaEvents
+}
+
+// keepEvent applies the note filters of the options.
+func (s *Scanner) keepEvent(evt *nostr.Event) bool {
+	switch {
+	case len(s.opts.Geohashes) > 0 && !InGeohash(evt, s.opts.Geohashes):
+		return false
+	case s.opts.ContentMatch != nil && !s.opts.ContentMatch.MatchString(evt.Content):
+		return false
+	case s.opts.ContentExclude != nil && s.opts.ContentExclude.MatchString(evt.Content):
+		return false
+	case s.opts.NoReplies && isReply(evt):
+		return false
+	case s.opts.OnlyMediaEvents && !HasMedia(evt):
+		return false
+	}
+	return true
+}
+
+// connectRelays dials every configured relay up front so connection
+// failures can be reported, and returns the ones that answered.
+func (s *Scanner) connectRelays(ctx context.Context, pool *nostr.SimplePool) []string {
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	var ok []string
+	for _, url := range s.opts.Relays {
+		wg.Add(1)
+		go func(url string) {
+			defer wg.Done()
+			_, span := tracer.Start(ctx, "exifscan.connectRelay", trace.WithAttributes(attribute.String("relay.url", url)))
+			defer span.End()
+			s.trace(url, "connecting")
+			start := time.Now()
+			if _, err := pool.EnsureRelay(url); err != nil {
+				s.trace(url, "connect failed after %s: %v", time.Since(start).Round(time.Millisecond), err)
+				s.fail(&ScanError{Stage: StageRelay, URL: url, Err: traceErr(span, err)})
+				return
+			}
+			s.trace(url, "connected in %s", time.Since(start).Round(time.Millisecond))
+			s.relayConnected(url)
+			mu.Lock()
+			ok = append(ok, url)
+			mu.Unlock()
+		}(url)
+	}
+	wg.Wait()
+	return ok
+}
+
+// Image is an image link found in a note, or a file found by listing a
+// media server, in which case Source is that server and EventID is empty.
+type Image struct {
+	EventID string
+	URL     string
+	Event   *nostr.Event
+	Source  string
+	// SHA256 is the hash the file was published with, if any; the
+	// downloaded bytes are checked against it.
+	SHA256 string
+	// Relays served the note; FetchEvents remembers them for its events
+	// when unset.
+	Relays []string
+}
+
+// urlRE matches a link up to the first character a URL can't hold, so
+// emoji, ellipses and CJK punctuation end it.
+var urlRE = regexp.MustCompile(`https?://[A-Za-z0-9\-._~:/?#\[\]@!$&'()*+,;=%]+`)
+
+// mediaExtRE finds a media extension ending a path or a query parameter,
+// so hosts like cdn.svgrepo.com or x.zip.example don't count.
+var mediaExtRE = regexp.MustCompile(`\.(?i:jpg|jpeg|png|gif|webp|svg|mp3|m4a|opus|ogg|pdf|zip)(?:[?#&;]|$)`)
+
+// mediaLinks returns the links of text that point to media, with their
+// query strings and without the punctuation that follows them in prose.
+func mediaLinks(text string) []string {
+	var out []string
+	for _, link := range urlRE.FindAllString(text, -1) {
+		link = strings.TrimRight(link, ".,;:!?'\")]")
+		if mediaExtRE.MatchString(link) {
+			out = append(out, link)
+		}
+	}
+	return out
+}
+
+// HasMedia reports whether evt declares media with an imeta tag (NIP-92)
+// or links an image, audio file, PDF or archive ExtractImages would find.
+func HasMedia(evt *nostr.Event) bool {
+	return evt.Tags.Find("imeta") != nil || mediaLinks(evt.Content) != nil
+}
+
+// ExtractImages returns every image link in the content of events, and
+// the audio, PDF and zip links, whose metadata is read the same way.
+func ExtractImages(events []nostr.Event) []Image {
+	var out []Image
+	for i := range events {
+		evt := &events[i]
+		for _, url := range mediaLinks(evt.Content) {
+			out = append(out, Image{EventID: evt.ID, URL: url, Event: evt, SHA256: declaredHash(evt, url)})
+		}
+	}
+	return out
+}
+
+// ScanImages downloads and inspects images concurrently. Results are sent
+// in completion order and the channel is closed once all are done, or
+// once ctx is done and the running downloads gave up.
+func (s *Scanner) ScanImages(ctx context.Context, images []Image) <-chan *ImageResult {
+	out := make(chan *ImageResult)
+	go func() {
+		defer close(out)
+		l := s.newLimiter()
+		if _, ok := l.(*tuner); ok {
+			images = interleaveHosts(images)
+		}
+		g, ctx := errgroup.WithContext(ctx)
+		for _, img := range images {
+			if l.acquire(ctx, (&ImageResult{URL: img.URL}).Host()) != nil {
+				break
+			}
+			g.Go(func() error {
+				r := s.ScanImage(ctx, img)
+				l.release(r)
+				select {
+				case out <- r:
+					return nil
+				case <-ctx.Done():
+					return ctx.Err()
+				}
+			})
+		}
+		g.Wait()
+		s.threads.Store(int32(l.size()))
+	}()
+	return out
+}
+
+func (s *Scanner) newLimiter() limiter {
+	if s.opts.Threads == AutoThreads {
+		return newTuner()
+	}
+	return make(fixedLimiter, s.opts.Threads)
+}
+
+// Threads returns the concurrency the latest ScanImages finished with,
+// which is where AutoThreads settled.
+func (s *Scanner) Threads() int {
+	return int(s.threads.Load())
+}
+
+// ScanImage downloads and inspects a single image.
+func (s *Scanner) ScanImage(ctx context.Context, img Image) *ImageResult {
+	r := s.scanImage(ctx, img)
+	s.imageScanned(r)
+	return r
+}
+
+func (s *Scanner) scanImage(ctx context.Context, img Image) *ImageResult {
+	ctx, span := tracer.Start(ctx, "exifscan.ScanImage", trace.WithAttributes(attribute.String("image.url", img.URL)))
+	defer span.End()
+
+	r := &ImageResult{EventID: img.EventID, URL: img.URL, Event: img.Event, Source: img.Source, DeclaredSHA256: img.SHA256, Relays: img.Relays, Post: postContext(img.Event)}
+	if r.Relays == nil && r.EventID != "" {
+		r.Relays = s.seen.get(r.EventID)
+	}
+	start := time.Now()
+	defer func() { r.Duration = time.Since(start) }()
+
+	p, stage, err := s.fetch(ctx, r)
+	for err != nil && r.Retries < s.opts.Retries {
+		if _, class := classifyFailure(stage, err); !transient(class) || retryDelay(ctx, r.Retries+1, err) != nil {
+			break
+		}
+		r.Retries++
+		p, stage, err = s.fetch(ctx, r)
+	}
+	if err != nil && s.opts.ProxyOrigins {
+		if op, ok := s.fetchOrigin(ctx, r, err); ok {
+			p, err = op, nil
+		}
+	}
+	if err != nil {
+		s.setErr(r, stage, err)
+		span.SetStatus(codes.Error, err.Error())
+		return r
+	}
+	s.inspect(ctx, r, p)
+	if s.opts.OCR != nil {
+		s.readText(ctx, r, p)
+	}
+	if s.opts.PerceptualHash {
+		r.PHash = s.phash(ctx, r.ContentType, p)
+	}
+	r.HashMismatch = r.DeclaredSHA256 != "" && r.DeclaredSHA256 != r.SHA256
+	// Location hints in the link flag images without any metadata too,
+	// whose bytes inspect didn't keep.
+	urlTags := URLLocation(r.URL)
+	if urlTags != nil {
+		s.keepData(r, p)
+	}
+	p.close(r.Data != nil || r.DataFile != "")
+	// The original is only worth downloading when the copy looks clean.
+	if s.opts.ProxyOrigins && r.ProxyError == "" && !r.Sensitive() {
+		s.scanOrigin(ctx, r)
+	}
+	r.AddTags(urlTags...)
+	r.GeoTag = checkGeoTag(r.GPS, img.Event)
+	span.SetAttributes(
+		attribute.Bool("image.has_metadata", r.HasMetadata),
+		attribute.StringSlice("image.leak_categories", r.Categories()),
+		attribute.Bool("image.hash_mismatch", r.HashMismatch),
+	)
+	return r
+}
+
+// download fetches url and reports which stage failed on error. The
+// caller closes the payload once done with the bytes.
+func (s *Scanner) download(ctx context.Context, url string) (*payload, string, error) {
+	ctx, span := tracer.Start(ctx, "exifscan.download")
+	defer span.End()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return nil, StageFetch, traceErr(span, err)
+	}
+	if err := s.opts.RateLimit.wait(ctx); err != nil {
+		return nil, StageFetch, traceErr(span, err)
+	}
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return nil, StageFetch, traceErr(span, fmt.Errorf("fetch failed: %w", err))
+	}
+	defer resp.Body.Close()
+	span.SetAttributes(attribute.Int("http.response.status_code", resp.StatusCode))
+	if resp.StatusCode != http.StatusOK {
+		return nil, StageFetch, traceErr(span, &StatusError{
+			StatusCode: resp.StatusCode,
+			Status:     resp.Status,
+			RetryAfter: retryAfter(resp.Header.Get("Retry-After")),
+		})
+	}
+	p, err := s.readBody(ctx, resp)
+	if err != nil {
+		return nil, StageRead, traceErr(span, fmt.Errorf("read failed: %w", err))
+	}
+	span.SetAttributes(
+		attribute.Int64("http.response.body.size", p.size),
+		attribute.Bool("image.spooled", p.file != nil),
+	)
+	return p, "", nil
+}
+
+// readBody keeps bodies up to SpoolBytes in memory, within the in-flight
+// budget, and spools bigger ones to disk.
+func (s *Scanner) readBody(ctx context.Context, resp *http.Response) (*payload, error) {
+	spoolAt := s.opts.SpoolBytes
+	maxSpool := s.opts.MaxSpoolBytes
+	if spoolAt > 0 && maxSpool > 0 && resp.ContentLength > maxSpool {
+		return nil, &TooLargeError{Limit: maxSpool, Spooled: true}
+	}
+	if spoolAt > 0 && resp.ContentLength > spoolAt {
+		return spool(s.opts.SpoolDir, nil, resp.Body, maxSpool)
+	}
+	// One byte more than announced, so reading up to EOF doesn't grow the
+	// reservation.
+	size := int64(unknownSize)
+	if resp.ContentLength >= 0 {
+		size = resp.ContentLength + 1
+	}
+	limit := s.budget.size
+	if spoolAt > 0 {
+		limit = min(limit, spoolAt)
+	}
+	res, err := s.budget.reserve(ctx, pooledSize(min(size, limit), limit))
+	if err != nil {
+		return nil, err
+	}
+	buf, complete, err := readBody(ctx, resp.Body, res, spoolAt)
+	if err != nil {
+		res.release()
+		return nil, err
+	}
+	if complete {
+		p := memoryPayload(buf)
+		p.res, p.pooled = res, true
+		return p, nil
+	}
+	p, err := spool(s.opts.SpoolDir, buf, resp.Body, maxSpool)
+	putBuf(buf)
+	res.release()
+	return p, err
+}
+
+// ScanBytes inspects an image that is already in memory.
+func ScanBytes(buf []byte) *ImageResult {
+	r := &ImageResult{}
+	(&Scanner{opts: Options{KeepData: true}}).inspect(context.Background(), r, memoryPayload(buf))
+	return r
+}
+
+func (s *Scanner) inspect(ctx context.Context, r *ImageResult, p *payload) {
+	_, span := tracer.Start(ctx, "exifscan.decode")
+	defer span.End()
+
+	r.SHA256 = p.sum
+	r.Size = int(p.size)
+	r.ContentType = p.format()
+	link := r.URL
+	if r.ProxyError != "" {
+		// The bytes are the original's, scanned in place of the link.
+		link = r.Origin
+	}
+	if want := extType(link); want != "" && want != r.ContentType && !sameContainer(want, r.ContentType) {
+		r.TypeMismatch = true
+	}
+	span.SetAttributes(attribute.String("image.content_type", r.ContentType))
+	if s.opts.Stego {
+		r.Stego = stegoHints(r.ContentType, p)
+	}
+	if read := fieldReader(r.ContentType); read != nil {
+		s.inspectFields(r, p, read)
+		return
+	}
+	switch r.ContentType {
+	case "application/zip":
+		s.inspectArchive(ctx, r, p)
+		return
+	case "image/svg+xml":
+		s.inspectSVG(ctx, r, p)
+		return
+	}
+	x := p.decode(r.ContentType)
+	if pr := p.open(); pr != nil {
+		r.Provenance = classify(r.ContentType, pr, x)
+	}
+	if x == nil {
+		return
+	}
+	r.HasMetadata = true
+	r.Tags, r.GPS = Inspect(x)
+	if s.opts.AllTags {
+		r.AllTags = AllTags(x)
+	}
+	if r.Sensitive() {
+		r.Fingerprint = r.fingerprint()
+	}
+	if s.opts.KeepData {
+		r.Exif = x
+	}
+	s.keepData(r, p)
+}
+
+// keepData retains the payload on r with Options.KeepData.
+func (s *Scanner) keepData(r *ImageResult, p *payload) {
+	if !s.opts.KeepData {
+		return
+	}
+	if p.file != nil {
+		r.DataFile = p.file.Name()
+	} else {
+		r.Data = p.buf
+	}
+}
+
+// fieldReader returns how to read the metadata of formats that keep it
+// elsewhere than in EXIF, or nil. They return every field found, with a
+// Category for the sensitive ones.
+func fieldReader(format string) func(io.Reader) ([]Tag, *GPS, bool) {
+	switch {
+	case isAudio(format):
+		return func(r io.Reader) ([]Tag, *GPS, bool) { return audioTags(format, r) }
+	case format == "application/pdf":
+		return pdfTags
+	case format == "image/gif":
+		return gifTags
+	}
+	return nil
+}
+
+// inspectFields reads the metadata of a GIF, an audio file or a document
+// in place of EXIF.
+func (s *Scanner) inspectFields(r *ImageResult, p *payload, read func(io.Reader) ([]Tag, *GPS, bool)) {
+	pr := p.open()
+	if pr == nil {
+		return
+	}
+	fields, g, found := read(pr)
+	if !found {
+		return
+	}
+	r.HasMetadata = true
+	r.Tags, r.GPS = sensitiveOnly(fields), g
+	if s.opts.AllTags {
+		r.AllTags = fields
+	}
+	if r.Sensitive() {
+		r.Fingerprint = r.fingerprint()
+	}
+	s.keepData(r, p)
+}
+
+func (s *Scanner) setErr(r *ImageResult, stage string, err error) {
+	r.Err = err
+	r.Error = err.Error()
+	r.Status, r.ErrorClass = classifyFailure(stage, err)
+	if stage == StageFetch {
+		r.Dead = deadReason(err)
+	}
+	s.fail(&ScanError{Stage: stage, URL: r.URL, EventID: r.EventID, Err: err})
+}
@@ -0,0 +1,50 @@
+package exifscan
+
+import (
+	"slices"
+	"testing"
+
+	"github.com/nbd-wtf/go-nostr"
+)
+
+func TestExtractImages(t *testing.T) {
+	for _, tc := range []struct {
+		content string
+		want    []string
+	}{
+		{"https://x.com/a.jpg", []string{"https://x.com/a.jpg"}},
+		{"look https://x.com/a.jpg: nice", []string{"https://x.com/a.jpg"}},
+		{"https://x.com/a.jpg🔥", []string{"https://x.com/a.jpg"}},
+		{"wait for it https://x.com/a.jpg…", []string{"https://x.com/a.jpg"}},
+		{"写真 https://x.com/a.jpg。", []string{"https://x.com/a.jpg"}},
+		{"see https://x.com/a.JPEG.", []string{"https://x.com/a.JPEG"}},
+		{"(https://x.com/a.gif), https://x.com/b.png!", []string{"https://x.com/a.gif", "https://x.com/b.png"}},
+		{"https://x.com/a.png\nhttps://x.com/b.webp", []string{"https://x.com/a.png", "https://x.com/b.webp"}},
+		{"https://wsrv.nl/?url=example.com/a.jpg&w=300", []string{"https://wsrv.nl/?url=example.com/a.jpg&w=300"}},
+		{"https://image.nostr.build/a.jpg?w=640", []string{"https://image.nostr.build/a.jpg?w=640"}},
+		{"https://x.com/a.jpg#top", []string{"https://x.com/a.jpg#top"}},
+		// Media extensions inside host names don't end the link.
+		{"https://cdn.svgrepo.com/x.png", []string{"https://cdn.svgrepo.com/x.png"}},
+		{"https://files.pdfhost.io/a.jpg", []string{"https://files.pdfhost.io/a.jpg"}},
+		{"https://x.zip.example/q/b.webp", []string{"https://x.zip.example/q/b.webp"}},
+		{"https://voice.example/memo.opus https://x.com/doc.pdf", []string{"https://voice.example/memo.opus", "https://x.com/doc.pdf"}},
+		// Links to pages aren't media.
+		{"https://cdn.svgrepo.com/", nil},
+		{"https://example.zip/page", nil},
+		{"https://x.com/a.jpgs", nil},
+		{"no links here", nil},
+	} {
+		t.Run(tc.content, func(t *testing.T) {
+			var got []string
+			for _, img := range ExtractImages([]nostr.Event{{Content: tc.content}}) {
+				got = append(got, img.URL)
+			}
+			if !slices.Equal(got, tc.want) {
+				t.Errorf("got %q, want %q", got, tc.want)
+			}
+			if has := HasMedia(&nostr.Event{Content: tc.content}); has != (tc.want != nil) {
+				t.Errorf("HasMedia = %v", has)
+			}
+		})
+	}
+}
@@ -0,0 +1,25 @@
+package exifscan
+
+import (
+	_ "embed"
+	"encoding/json"
+)
+
+// SchemaVersion is the version of the JSON output described by Schema.
+// Within a version fields are only added; it is bumped when a field is
+// removed, renamed or changes type or meaning.
+const SchemaVersion = 1
+
+// Schema is the JSON Schema of Findings and of check's verdicts.
+//
+//go:embed schema.json
+var Schema []byte
+
+// MarshalJSON adds schema_version.
+func (f Findings) MarshalJSON() ([]byte, error) {
+	type plain Findings
+	return json.Marshal(struct {
+		SchemaVersion int `json:"schema_version"`
+		plain
+	}{SchemaVersion, plain(f)})
+}
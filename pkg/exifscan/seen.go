@@ -0,0 +1,34 @@
+package exifscan
+
+import (
+	"slices"
+	"sync"
+)
+
+// maxRelayHints bounds the relays remembered per event; a few are
+// enough for a client to find it.
+const maxRelayHints = 3
+
+// sightings remembers which relays served each fetched event, so links
+// to it can carry relay hints.
+type sightings struct {
+	mu sync.Mutex
+	m  map[string][]string
+}
+
+func (s *sightings) add(id, relay string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.m == nil {
+		s.m = map[string][]string{}
+	}
+	if rs := s.m[id]; len(rs) < maxRelayHints && !slices.Contains(rs, relay) {
+		s.m[id] = append(rs, relay)
+	}
+}
+
+func (s *sightings) get(id string) []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return slices.Clone(s.m[id])
+}
@@ -0,0 +1,169 @@
+package exifscan
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"os"
+
+	exif "github.com/rwcarlsen/goexif/exif"
+)
+
+// DefaultSpoolBytes is the default Options.SpoolBytes.
+const DefaultSpoolBytes = 16 << 20
+
+// DefaultMaxSpoolBytes is the default Options.MaxSpoolBytes.
+const DefaultMaxSpoolBytes = 1 << 30
+
+// payload is a downloaded image, in memory or spooled to a temp file.
+type payload struct {
+	buf []byte
+	// pooled is set when buf came from getBuf.
+	pooled bool
+	file   *os.File
+	size   int64
+	sum    string
+	res    *reservation
+}
+
+func memoryPayload(buf []byte) *payload {
+	sum := sha256.Sum256(buf)
+	return &payload{buf: buf, size: int64(len(buf)), sum: hex.EncodeToString(sum[:])}
+}
+
+func (p *payload) decode(format string) *exif.Exif {
+	r := p.open()
+	if r == nil {
+		return nil
+	}
+	return decodeAs(format, r)
+}
+
+// open reads the payload from its start, or returns nil when the spool
+// file can't be rewound.
+func (p *payload) open() io.Reader {
+	if p.file == nil {
+		return bytes.NewReader(p.buf)
+	}
+	if _, err := p.file.Seek(0, io.SeekStart); err != nil {
+		return nil
+	}
+	return bufio.NewReader(p.file)
+}
+
+// bytes returns the whole payload, reading it back from the spool file.
+func (p *payload) bytes() ([]byte, bool) {
+	if p.file == nil {
+		return p.buf, true
+	}
+	r := p.open()
+	if r == nil {
+		return nil, false
+	}
+	b, err := io.ReadAll(r)
+	return b, err == nil
+}
+
+// readerAt reads the payload at random, for zip archives.
+func (p *payload) readerAt() io.ReaderAt {
+	if p.file == nil {
+		return bytes.NewReader(p.buf)
+	}
+	return p.file
+}
+
+// format sniffs the payload's first bytes.
+func (p *payload) format() string {
+	if p.file == nil {
+		return sniff(p.buf[:min(len(p.buf), sniffLen)])
+	}
+	head := make([]byte, sniffLen)
+	n, _ := p.file.ReadAt(head, 0)
+	return sniff(head[:n])
+}
+
+// close gives back the in-flight budget and, unless keep is set, the
+// buffer or the spool file.
+func (p *payload) close(keep bool) {
+	if p.res != nil {
+		p.res.release()
+	}
+	if p.pooled && !keep {
+		putBuf(p.buf)
+	}
+	if p.file != nil {
+		p.file.Close()
+		if !keep {
+			os.Remove(p.file.Name())
+		}
+	}
+}
+
+// readBody reads body into a pooled buffer whose capacity never exceeds
+// the reservation, growing both as needed. Once the buffer would outgrow
+// spoolAt (when positive) it stops and reports the body incomplete.
+func readBody(ctx context.Context, body io.Reader, res *reservation, spoolAt int64) ([]byte, bool, error) {
+	buf := getBuf(res.n)
+	for {
+		if len(buf) == cap(buf) {
+			limit := res.b.size
+			if spoolAt > 0 {
+				if int64(cap(buf)) >= spoolAt {
+					return buf, false, nil
+				}
+				limit = min(limit, spoolAt)
+			}
+			if int64(cap(buf)) >= limit {
+				putBuf(buf)
+				return nil, false, res.b.tooLarge()
+			}
+			size := pooledSize(min(max(2*int64(cap(buf)), unknownSize), limit), limit)
+			if err := res.grow(ctx, size); err != nil {
+				putBuf(buf)
+				return nil, false, err
+			}
+			grown := getBuf(size)[:len(buf)]
+			copy(grown, buf)
+			putBuf(buf)
+			buf = grown
+		}
+		n, err := body.Read(buf[len(buf):cap(buf)])
+		buf = buf[:len(buf)+n]
+		if err == io.EOF {
+			return buf, true, nil
+		}
+		if err != nil {
+			putBuf(buf)
+			return nil, false, err
+		}
+	}
+}
+
+// spool writes head and the rest of body to a temp file in dir. Bodies
+// over max bytes, when positive, fail once max is exceeded, leaving no
+// file behind.
+func spool(dir string, head []byte, body io.Reader, max int64) (*payload, error) {
+	f, err := os.CreateTemp(dir, "exifscan-*")
+	if err != nil {
+		return nil, err
+	}
+	h := sha256.New()
+	w := io.MultiWriter(f, h)
+	r := io.MultiReader(bytes.NewReader(head), body)
+	if max > 0 {
+		r = io.LimitReader(r, max+1)
+	}
+	n, err := io.Copy(w, r)
+	if err == nil && max > 0 && n > max {
+		err = &TooLargeError{Limit: max, Spooled: true}
+	}
+	if err != nil {
+		f.Close()
+		os.Remove(f.Name())
+		return nil, err
+	}
+	return &payload{file: f, size: n, sum: hex.EncodeToString(h.Sum(nil))}, nil
+}
@@ -0,0 +1,289 @@
+package exifscan
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"image"
+	"image/png"
+	"math"
+)
+
+// Kinds of StegoHint.
+const (
+	// StegoAppended is data after the end of the image, where archives
+	// are smuggled: viewers stop at the end marker, unzip reads from the
+	// end of the file.
+	StegoAppended = "appended"
+	// StegoLSB is a histogram whose pairs of neighbouring values have
+	// been evened out, the mark of a message written into the lowest bit
+	// of each pixel.
+	StegoLSB = "lsb"
+)
+
+// maxStegoBytes bounds the files looked at for hidden data, and
+// maxStegoPixels the pixels the LSB test counts.
+const (
+	maxStegoBytes  = 64 << 20
+	maxStegoPixels = 4 << 20
+)
+
+// lsbThreshold is the chi-square probability above which the LSB
+// histogram is flagged.
+const lsbThreshold = 0.99
+
+// StegoHint is a sign that an image hides data, found with Options.Stego.
+// Hints are informational: they aren't leak categories, and benign
+// writers trigger them too, like the video of a motion photo appended
+// to its JPEG.
+type StegoHint struct {
+	Kind string `json:"kind"`
+	// Detail describes the hint, e.g. "48.2 KB after the JPEG end, a zip
+	// archive".
+	Detail string `json:"detail"`
+	// Offset and Size locate the appended data.
+	Offset int64 `json:"offset,omitempty"`
+	Size   int64 `json:"size,omitempty"`
+	// Probability is how likely the LSB histogram is to come from
+	// embedding, by the chi-square attack.
+	Probability float64 `json:"probability,omitempty"`
+}
+
+// stegoHints looks for data appended to the image and, in lossless
+// images, for LSB embedding.
+func stegoHints(format string, p *payload) []StegoHint {
+	if p.size > maxStegoBytes {
+		return nil
+	}
+	b, ok := p.bytes()
+	if !ok {
+		return nil
+	}
+	var hints []StegoHint
+	if h := appendedData(format, b); h != nil {
+		hints = append(hints, *h)
+	}
+	if format == "image/png" {
+		if h := lsbHint(b); h != nil {
+			hints = append(hints, *h)
+		}
+	}
+	return hints
+}
+
+// imageEnd returns where the image data of b ends by its format's
+// structure, or -1 when it can't be told.
+func imageEnd(format string, b []byte) int {
+	switch format {
+	case "image/jpeg":
+		return jpegEnd(b)
+	case "image/png":
+		pos := 8
+		for pos+12 <= len(b) {
+			n := int(binary.BigEndian.Uint32(b[pos:]))
+			typ := string(b[pos+4 : pos+8])
+			pos += 12 + n
+			if typ == "IEND" {
+				return pos
+			}
+		}
+	case "image/webp":
+		if len(b) >= 8 {
+			n := int(binary.LittleEndian.Uint32(b[4:]))
+			return 8 + n + n%2
+		}
+	case "image/gif":
+		return gifWalk(b, func(byte, []byte) {})
+	}
+	return -1
+}
+
+// jpegEnd walks the segments and scans of a JPEG to the end of its EOI
+// marker, so thumbnails in APP1 don't end it early.
+func jpegEnd(b []byte) int {
+	if len(b) < 4 || b[0] != 0xff || b[1] != 0xd8 {
+		return -1
+	}
+	i := 2
+	for i+1 < len(b) {
+		if b[i] != 0xff {
+			return -1
+		}
+		m := b[i+1]
+		switch {
+		case m == 0xff:
+			// Fill byte.
+			i++
+			continue
+		case m == 0xd9:
+			return i + 2
+		case m >= 0xd0 && m <= 0xd7 || m == 0x01:
+			i += 2
+			continue
+		}
+		if i+4 > len(b) {
+			return -1
+		}
+		i += 2 + int(binary.BigEndian.Uint16(b[i+2:]))
+		if m == 0xda {
+			// Entropy-coded data runs to the next marker that isn't a
+			// stuffed byte or a restart.
+			for i+1 < len(b) && (b[i] != 0xff || b[i+1] == 0 || b[i+1] >= 0xd0 && b[i+1] <= 0xd7) {
+				i++
+			}
+		}
+	}
+	return -1
+}
+
+func appendedData(format string, b []byte) *StegoHint {
+	end := imageEnd(format, b)
+	if end < 0 || end >= len(b) {
+		return nil
+	}
+	tail := b[end:]
+	if len(bytes.Trim(tail, "\x00\xff\r\n\t ")) == 0 {
+		// Padding some encoders and cameras write.
+		return nil
+	}
+	return &StegoHint{
+		Kind:   StegoAppended,
+		Detail: fmt.Sprintf("%s after the %s end, %s", formatBytes(len(tail)), formatName(format), describeData(tail)),
+		Offset: int64(end),
+		Size:   int64(len(tail)),
+	}
+}
+
+// describeData names what data appended to an image looks like.
+func describeData(b []byte) string {
+	switch {
+	case bytes.HasPrefix(b, []byte("PK\x03\x04")):
+		return "a zip archive"
+	case bytes.HasPrefix(b, []byte("Rar!\x1a\x07")):
+		return "a RAR archive"
+	case bytes.HasPrefix(b, []byte("7z\xbc\xaf\x27\x1c")):
+		return "a 7-Zip archive"
+	case bytes.HasPrefix(b, []byte("%PDF")):
+		return "a PDF"
+	case len(b) >= 12 && string(b[4:8]) == "ftyp":
+		return "an MP4 video, likely a motion photo"
+	case bytes.HasSuffix(b, []byte("SEFT")):
+		return "Samsung camera app data"
+	}
+	if ct := sniff(b[:min(len(b), sniffLen)]); ct != "application/octet-stream" {
+		return "data sniffed as " + ct
+	}
+	return "unidentified data"
+}
+
+// lsbHint runs the chi-square attack on the pixel values of a PNG: an
+// embedded message evens out the counts of each pair of values 2k and
+// 2k+1, which natural images leave uneven.
+func lsbHint(b []byte) *StegoHint {
+	img, err := png.Decode(bytes.NewReader(b))
+	if err != nil {
+		return nil
+	}
+	var hist [256]int
+	bounds := img.Bounds()
+	n := 0
+	for y := bounds.Min.Y; y < bounds.Max.Y && n < maxStegoPixels; y++ {
+		for x := bounds.Min.X; x < bounds.Max.X && n < maxStegoPixels; x++ {
+			addPixel(&hist, img, x, y)
+			n++
+		}
+	}
+	chi, df := 0.0, 0
+	for k := 0; k < 256; k += 2 {
+		expected := float64(hist[k]+hist[k+1]) / 2
+		if expected < 5 {
+			continue
+		}
+		d := float64(hist[k]) - expected
+		chi += d * d / expected
+		df++
+	}
+	df--
+	if df < 8 {
+		// Too few values, like a flat drawing, to tell.
+		return nil
+	}
+	prob := 1 - chiSquareCDF(chi, df)
+	if prob < lsbThreshold {
+		return nil
+	}
+	return &StegoHint{
+		Kind:        StegoLSB,
+		Detail:      fmt.Sprintf("lowest bits of the pixels look overwritten (chi-square p=%.3f)", prob),
+		Probability: prob,
+	}
+}
+
+func addPixel(hist *[256]int, img image.Image, x, y int) {
+	switch m := img.(type) {
+	case *image.NRGBA:
+		o := m.PixOffset(x, y)
+		hist[m.Pix[o]]++
+		hist[m.Pix[o+1]]++
+		hist[m.Pix[o+2]]++
+	case *image.RGBA:
+		o := m.PixOffset(x, y)
+		hist[m.Pix[o]]++
+		hist[m.Pix[o+1]]++
+		hist[m.Pix[o+2]]++
+	case *image.Gray:
+		hist[m.Pix[m.PixOffset(x, y)]]++
+	case *image.Paletted:
+		// Palette indices, not colors: nothing to count.
+	default:
+		r, g, b, _ := img.At(x, y).RGBA()
+		hist[r>>8]++
+		hist[g>>8]++
+		hist[b>>8]++
+	}
+}
+
+// chiSquareCDF approximates the chi-square distribution function with
+// df degrees of freedom by the Wilson-Hilferty transform, close enough
+// for the df of a byte histogram.
+func chiSquareCDF(x float64, df int) float64 {
+	k := float64(df)
+	z := (math.Cbrt(x/k) - (1 - 2/(9*k))) / math.Sqrt(2/(9*k))
+	return 0.5 * math.Erfc(-z/math.Sqrt2)
+}
+
+// formatName is the short name of an image format, e.g. "JPEG".
+func formatName(format string) string {
+	switch format {
+	case "image/jpeg":
+		return "JPEG"
+	case "image/png":
+		return "PNG"
+	case "image/webp":
+		return "WebP"
+	case "image/gif":
+		return "GIF"
+	}
+	return format
+}
+
+func formatBytes(n int) string {
+	switch {
+	case n >= 1<<20:
+		return fmt.Sprintf("%.1f MB", float64(n)/(1<<20))
+	case n >= 1<<10:
+		return fmt.Sprintf("%.1f KB", float64(n)/(1<<10))
+	}
+	return fmt.Sprintf("%d bytes", n)
+}
+
+// Stego returns the images with StegoHints.
+func (f *Findings) Stego() []*ImageResult {
+	var out []*ImageResult
+	for _, r := range f.Images {
+		if r.Stego != nil {
+			out = append(out, r)
+		}
+	}
+	return out
+}
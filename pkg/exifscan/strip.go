@@ -0,0 +1,146 @@
+package exifscan
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+
+	exif "github.com/rwcarlsen/goexif/exif"
+)
+
+// ErrUnsupportedFormat is returned by Strip for images it can't rewrite.
+var ErrUnsupportedFormat = errors.New("unsupported image format (want JPEG, PNG or WebP)")
+
+// Strip returns a copy of the JPEG, PNG or WebP image in buf without
+// EXIF, XMP, IPTC, comments or text chunks. Pixel data is copied untouched.
+// A JPEG's EXIF orientation is kept, in a minimal EXIF block holding only
+// that tag, so the image isn't displayed rotated.
+func Strip(buf []byte) ([]byte, error) {
+	switch {
+	case bytes.HasPrefix(buf, []byte{0xFF, 0xD8}):
+		return stripJPEG(buf)
+	case bytes.HasPrefix(buf, []byte("\x89PNG\r\n\x1a\n")):
+		return stripPNG(buf)
+	case len(buf) >= 12 && string(buf[:4]) == "RIFF" && string(buf[8:12]) == "WEBP":
+		return stripWebP(buf)
+	}
+	return nil, ErrUnsupportedFormat
+}
+
+var errTruncated = errors.New("truncated image")
+
+func stripJPEG(buf []byte) ([]byte, error) {
+	orientation := 1
+	if x := Decode(buf); x != nil {
+		if tag, err := x.Get(exif.Orientation); err == nil {
+			if v, err := tag.Int(0); err == nil {
+				orientation = v
+			}
+		}
+	}
+
+	out := bytes.NewBuffer(make([]byte, 0, len(buf)))
+	out.Write(buf[:2])
+	wroteOrientation := orientation == 1
+	for i := 2; ; {
+		if i+4 > len(buf) || buf[i] != 0xFF {
+			return nil, errTruncated
+		}
+		marker := buf[i+1]
+		if marker == 0xFF { // fill byte
+			i++
+			continue
+		}
+		if marker == 0xDA { // start of scan: the rest is image data
+			if !wroteOrientation {
+				out.Write(orientationSegment(orientation))
+			}
+			out.Write(buf[i:])
+			return out.Bytes(), nil
+		}
+		n := int(binary.BigEndian.Uint16(buf[i+2:]))
+		end := i + 2 + n
+		if n < 2 || end > len(buf) {
+			return nil, errTruncated
+		}
+		// Keep JFIF (APP0), ICC profiles (APP2) and Adobe colour info
+		// (APP14); drop every other APPn and comments.
+		drop := (marker >= 0xE1 && marker <= 0xEF && marker != 0xE2 && marker != 0xEE) || marker == 0xFE
+		if !drop {
+			if marker != 0xE0 && !wroteOrientation {
+				out.Write(orientationSegment(orientation))
+				wroteOrientation = true
+			}
+			out.Write(buf[i:end])
+		}
+		i = end
+	}
+}
+
+// orientationSegment builds an APP1 EXIF segment holding only the
+// Orientation tag.
+func orientationSegment(v int) []byte {
+	tiff := []byte{
+		'M', 'M', 0, 42, 0, 0, 0, 8, // big endian header, IFD0 at 8
+		0, 1, // one entry
+		0x01, 0x12, 0, 3, 0, 0, 0, 1, byte(v >> 8), byte(v), 0, 0, // Orientation SHORT
+		0, 0, 0, 0, // no next IFD
+	}
+	payload := append([]byte("Exif\x00\x00"), tiff...)
+	seg := []byte{0xFF, 0xE1, 0, 0}
+	binary.BigEndian.PutUint16(seg[2:], uint16(len(payload)+2))
+	return append(seg, payload...)
+}
+
+func stripPNG(buf []byte) ([]byte, error) {
+	drop := map[string]bool{"eXIf": true, "tEXt": true, "zTXt": true, "iTXt": true, "tIME": true}
+	out := bytes.NewBuffer(make([]byte, 0, len(buf)))
+	out.Write(buf[:8])
+	for i := 8; i < len(buf); {
+		if i+12 > len(buf) {
+			return nil, errTruncated
+		}
+		n := int(binary.BigEndian.Uint32(buf[i:]))
+		end := i + 12 + n
+		if n < 0 || end > len(buf) {
+			return nil, errTruncated
+		}
+		if !drop[string(buf[i+4:i+8])] {
+			out.Write(buf[i:end])
+		}
+		i = end
+	}
+	return out.Bytes(), nil
+}
+
+func stripWebP(buf []byte) ([]byte, error) {
+	var body bytes.Buffer
+	body.WriteString("WEBP")
+	for i := 12; i < len(buf); {
+		if i+8 > len(buf) {
+			return nil, errTruncated
+		}
+		fourcc := string(buf[i : i+4])
+		n := int(binary.LittleEndian.Uint32(buf[i+4:]))
+		end := i + 8 + n + n%2
+		if n < 0 || end > len(buf) {
+			return nil, errTruncated
+		}
+		switch fourcc {
+		case "EXIF", "XMP ":
+		case "VP8X":
+			chunk := append([]byte(nil), buf[i:end]...)
+			if n > 0 {
+				chunk[8] &^= 0x08 | 0x04 // EXIF and XMP present flags
+			}
+			body.Write(chunk)
+		default:
+			body.Write(buf[i:end])
+		}
+		i = end
+	}
+	out := make([]byte, 8, 8+body.Len())
+	copy(out, "RIFF")
+	binary.LittleEndian.PutUint32(out[4:], uint32(body.Len()))
+	return append(out, body.Bytes()...), nil
+}
@@ -0,0 +1,141 @@
+package exifscan
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"fmt"
+	"html"
+	"io"
+	"regexp"
+	"strings"
+)
+
+// maxEmbedded bounds the embedded images of an SVG that are scanned;
+// maxExternalRefs the external references listed.
+const (
+	maxEmbedded     = 50
+	maxExternalRefs = 20
+)
+
+// svgFields are the editor attributes reported. Old Inkscape versions
+// wrote the folder the file was saved in as sodipodi:docbase.
+var svgFields = map[string]metaField{
+	"inkscape:version":         {"SVG Inkscape version", CategorySoftware},
+	"inkscape:export-filename": {"SVG export filename", ""},
+	"sodipodi:docname":         {"SVG document name", ""},
+	"sodipodi:docbase":         {"SVG document folder", ""},
+	"sodipodi:absref":          {"SVG linked file", ""},
+}
+
+var (
+	svgAttrRE = regexp.MustCompile(`\s(inkscape:version|inkscape:export-filename|sodipodi:docname|sodipodi:docbase|sodipodi:absref)="([^"]*)"`)
+	// svgGeneratorRE finds the comment Illustrator, Sketch and other
+	// exporters sign their output with.
+	svgGeneratorRE = regexp.MustCompile(`<!--\s*Generator:\s*(.*?)\s*-->`)
+	svgMetadataRE  = regexp.MustCompile(`(?s)<metadata[^>]*>(.*?)</metadata>`)
+	// svgRefRE finds the references of href, src and CSS url().
+	svgRefRE    = regexp.MustCompile(`(?:\s(?:xlink:)?href|\ssrc)\s*=\s*["']([^"']*)["']|url\(\s*["']?([^"')]*)["']?\s*\)`)
+	svgRasterRE = regexp.MustCompile(`^data:image/(?:png|jpe?g|gif|webp|tiff|heic|heif|avif);base64,`)
+)
+
+// isSVG reports whether head, what DetectContentType took for XML or text,
+// starts an SVG document.
+func isSVG(head []byte) bool {
+	return bytes.Contains(head, []byte("<svg")) || bytes.Contains(head, []byte("<!DOCTYPE svg"))
+}
+
+// svgParts is what an SVG gives away: its metadata and editor fields,
+// the raster images embedded as data URIs and the URLs it loads.
+type svgParts struct {
+	fields   []Tag
+	embedded [][]byte
+	external []string
+}
+
+func parseSVG(buf []byte) svgParts {
+	var parts svgParts
+	var tags []Tag
+	if m := svgMetadataRE.FindSubmatch(buf); m != nil {
+		tags = append(tags, xmpTags(m[1], "SVG ")...)
+	}
+	for _, m := range svgGeneratorRE.FindAllSubmatch(buf, -1) {
+		tags = append(tags, Tag{Field: "SVG generator", Category: CategorySoftware, Value: string(m[1])})
+	}
+	for _, m := range svgAttrRE.FindAllSubmatch(buf, -1) {
+		f := svgFields[string(m[1])]
+		tags = append(tags, Tag{Field: f.name, Category: f.category, Value: html.UnescapeString(string(m[2]))})
+	}
+	seen := map[string]bool{}
+	for _, m := range svgRefRE.FindAllSubmatch(buf, -1) {
+		ref := string(m[1])
+		if m[2] != nil {
+			ref = string(m[2])
+		}
+		ref = strings.TrimSpace(html.UnescapeString(ref))
+		lower := strings.ToLower(ref)
+		switch {
+		case ref == "" || strings.HasPrefix(ref, "#"):
+		case svgRasterRE.MatchString(lower):
+			if len(parts.embedded) < maxEmbedded {
+				if b, ok := decodeBase64(ref[strings.IndexByte(ref, ',')+1:]); ok {
+					parts.embedded = append(parts.embedded, b)
+				}
+			}
+		case strings.HasPrefix(lower, "data:"):
+		case strings.HasPrefix(lower, "http://"), strings.HasPrefix(lower, "https://"), strings.HasPrefix(lower, "//"):
+			if !seen[ref] && len(parts.external) < maxExternalRefs {
+				seen[ref] = true
+				parts.external = append(parts.external, ref)
+			}
+		default:
+			// A file on the machine it was drawn on, like the linked
+			// images Illustrator and Inkscape refer to by path.
+			tags = append(tags, Tag{Field: "SVG linked file", Value: ref})
+		}
+	}
+	parts.fields = documentFields(tags)
+	return parts
+}
+
+// decodeBase64 decodes a data URI's payload, which editors wrap over
+// several lines.
+func decodeBase64(s string) ([]byte, bool) {
+	s = strings.Join(strings.Fields(s), "")
+	b, err := base64.StdEncoding.DecodeString(s)
+	if err != nil {
+		if b, err = base64.RawStdEncoding.DecodeString(strings.TrimRight(s, "=")); err != nil {
+			return nil, false
+		}
+	}
+	return b, true
+}
+
+// inspectSVG reads an SVG's metadata and editor fields and scans the
+// images it embeds, reporting their tags as its own. The URLs it loads
+// when rendered are listed in ExternalRefs.
+func (s *Scanner) inspectSVG(ctx context.Context, r *ImageResult, p *payload) {
+	pr := p.open()
+	if pr == nil {
+		return
+	}
+	buf, _ := io.ReadAll(io.LimitReader(pr, maxChunk))
+	parts := parseSVG(buf)
+	r.ExternalRefs = parts.external
+	if fields := parts.fields; fields != nil {
+		r.HasMetadata = true
+		r.Tags = sensitiveOnly(fields)
+		if s.opts.AllTags {
+			r.AllTags = fields
+		}
+	}
+	for i, b := range parts.embedded {
+		s.inspectNested(ctx, r, b, fmt.Sprintf("embedded image %d", i+1))
+	}
+	if r.Sensitive() {
+		r.Fingerprint = r.fingerprint()
+	}
+	if r.HasMetadata {
+		s.keepData(r, p)
+	}
+}
@@ -0,0 +1,100 @@
+package exifscan
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// displayLocation is the zone timestamps are shown in.
+var displayLocation = time.UTC
+
+// SetTimezone sets the zone post dates and EXIF times with a known
+// offset are shown in: "UTC" (the default), "local" or an IANA name like
+// Europe/Paris.
+func SetTimezone(name string) error {
+	switch strings.ToLower(name) {
+	case "", "utc":
+		displayLocation = time.UTC
+	case "local":
+		displayLocation = time.Local
+	default:
+		loc, err := time.LoadLocation(name)
+		if err != nil {
+			return err
+		}
+		displayLocation = loc
+	}
+	return nil
+}
+
+// InDisplayZone returns t in the zone set by SetTimezone.
+func InDisplayZone(t time.Time) time.Time {
+	return t.In(displayLocation)
+}
+
+// cameraTime renders an EXIF "2006:01:02 15:04:05" time. With the offset
+// EXIF 2.31 records next to it, it is converted to the display zone;
+// without one it is the camera's clock in an unknown zone, and says so.
+func cameraTime(val, offset string) string {
+	t, err := time.Parse("2006:01:02 15:04:05", val)
+	if err != nil {
+		return val
+	}
+	if off, err := time.Parse("-07:00", offset); err == nil {
+		_, secs := off.Zone()
+		t = time.Date(t.Year(), t.Month(), t.Day(), t.Hour(), t.Minute(), t.Second(), 0, time.FixedZone(offset, secs))
+		return InDisplayZone(t).Format("2006-01-02 15:04:05 MST")
+	}
+	return t.Format("2006-01-02 15:04:05") + " (camera clock, zone unknown)"
+}
+
+var relativeRE = regexp.MustCompile(`^(?:(\d+)(mo|min|[smhdwy]))+$`)
+var relativePartRE = regexp.MustCompile(`(\d+)(mo|min|[smhdwy])`)
+
+// ParseTime reads a --since or --until value: an RFC3339 timestamp, a
+// date (2006-01-02), "now", or a time that long before now, like 90d,
+// 6mo, 1y6mo or 36h. Units are s, min (or m), h, d, w, mo and y. An
+// empty value is the zero time, which doesn't bound the scan.
+func ParseTime(s string, now time.Time) (time.Time, error) {
+	switch {
+	case s == "":
+		return time.Time{}, nil
+	case s == "now":
+		return now, nil
+	case relativeRE.MatchString(s):
+		t := now
+		for _, m := range relativePartRE.FindAllStringSubmatch(s, -1) {
+			n, err := strconv.Atoi(m[1])
+			if err != nil {
+				return time.Time{}, fmt.Errorf("invalid time %q: %w", s, err)
+			}
+			switch m[2] {
+			case "s":
+				t = t.Add(-time.Duration(n) * time.Second)
+			case "m", "min":
+				t = t.Add(-time.Duration(n) * time.Minute)
+			case "h":
+				t = t.Add(-time.Duration(n) * time.Hour)
+			case "d":
+				t = t.AddDate(0, 0, -n)
+			case "w":
+				t = t.AddDate(0, 0, -7*n)
+			case "mo":
+				t = t.AddDate(0, -n, 0)
+			case "y":
+				t = t.AddDate(-n, 0, 0)
+			}
+		}
+		return t, nil
+	}
+	if t, err := time.Parse(time.RFC3339, s); err == nil {
+		return t, nil
+	}
+	if t, err := time.ParseInLocation(time.DateOnly, s, now.Location()); err == nil {
+		return t, nil
+	}
+	return time.Time{}, fmt.Errorf("invalid time %q: want RFC3339 (2024-01-02T15:04:05Z), a date (2024-01-02), now, or an age like 90d, 6mo or 1y", s)
+}
@@ -0,0 +1,17 @@
+package exifscan
+
+import (
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// tracer reports spans to the global OpenTelemetry provider, which is a
+// no-op unless the embedding program installs one.
+var tracer = otel.Tracer("nostr-exif-scan/pkg/exifscan")
+
+func traceErr(span trace.Span, err error) error {
+	span.RecordError(err)
+	span.SetStatus(codes.Error, err.Error())
+	return err
+}
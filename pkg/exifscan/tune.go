@@ -0,0 +1,235 @@
+package exifscan
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// AutoThreads as Options.Threads lets the scanner adapt its concurrency
+// to the hosts it downloads from, between 1 and MaxAutoThreads.
+const AutoThreads = -1
+
+// MaxAutoThreads caps the concurrency AutoThreads ramps up to.
+const MaxAutoThreads = 128
+
+const (
+	autoStart     = 4
+	hostStart     = 4
+	hostMax       = 16
+	maxRetryAfter = time.Minute
+	// minWindow keeps a single failure at low concurrency from counting
+	// as a high failure rate.
+	minWindow = 8
+	// latencySlack ignores latency changes too small to mean congestion.
+	latencySlack = 50 * time.Millisecond
+)
+
+// StatusError is a download answered with a status other than 200 OK.
+type StatusError struct {
+	StatusCode int
+	Status     string
+	// RetryAfter is the delay a 429 or 503 response asked for.
+	RetryAfter time.Duration
+}
+
+func (e *StatusError) Error() string {
+	return "fetch failed: " + e.Status
+}
+
+// throttled reports whether the host asked us to slow down.
+func (e *StatusError) throttled() bool {
+	return e.StatusCode == http.StatusTooManyRequests || e.StatusCode == http.StatusServiceUnavailable
+}
+
+func retryAfter(h string) time.Duration {
+	if secs, err := strconv.Atoi(h); err == nil {
+		return min(time.Duration(secs)*time.Second, maxRetryAfter)
+	}
+	if t, err := http.ParseTime(h); err == nil {
+		return min(max(time.Until(t), 0), maxRetryAfter)
+	}
+	return 0
+}
+
+// limiter bounds concurrent downloads.
+type limiter interface {
+	acquire(ctx context.Context, host string) error
+	release(r *ImageResult)
+	// size reports the current concurrency.
+	size() int
+}
+
+// fixedLimiter is a plain semaphore of Options.Threads slots.
+type fixedLimiter chan struct{}
+
+func (l fixedLimiter) acquire(ctx context.Context, _ string) error {
+	select {
+	case l <- struct{}{}:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func (l fixedLimiter) release(*ImageResult) { <-l }
+
+func (l fixedLimiter) size() int { return cap(l) }
+
+// tuner adapts the number of concurrent downloads additively increasing
+// and multiplicatively decreasing it, like TCP congestion control. After
+// every window of as many downloads as the current limit (at least
+// minWindow) it grows by one while latency stays near the best seen and
+// few downloads fail, and shrinks by a quarter when latency doubles or
+// failures pile up. Every host also gets its own limit, halved and paused
+// when it answers 429 or 503, so one rate limiting host doesn't hold back
+// the others.
+type tuner struct {
+	mu       sync.Mutex
+	wake     chan struct{}
+	limit    int
+	inFlight int
+	hosts    map[string]*hostLimit
+
+	// The current window.
+	done, failed, ok int
+	latency          time.Duration
+	saturated        bool
+	// best is the lowest mean latency of a window so far.
+	best time.Duration
+}
+
+type hostLimit struct {
+	limit, inFlight, ok int
+	pause               time.Time
+	// throttledAt is the limit the host last answered 429 or 503 at;
+	// growing back to it takes much longer than the initial ramp.
+	throttledAt int
+}
+
+func newTuner() *tuner {
+	return &tuner{wake: make(chan struct{}), limit: autoStart, hosts: map[string]*hostLimit{}}
+}
+
+func (t *tuner) acquire(ctx context.Context, host string) error {
+	for {
+		t.mu.Lock()
+		h := t.hosts[host]
+		if h == nil {
+			h = &hostLimit{limit: hostStart}
+			t.hosts[host] = h
+		}
+		wait := time.Until(h.pause)
+		if t.inFlight < t.limit && h.inFlight < h.limit && wait <= 0 {
+			t.inFlight++
+			h.inFlight++
+			if t.inFlight == t.limit {
+				t.saturated = true
+			}
+			t.mu.Unlock()
+			return nil
+		}
+		wake := t.wake
+		t.mu.Unlock()
+
+		var timer <-chan time.Time
+		if wait > 0 {
+			timer = time.After(wait)
+		}
+		select {
+		case <-wake:
+		case <-timer:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+}
+
+func (t *tuner) release(r *ImageResult) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	defer func() {
+		close(t.wake)
+		t.wake = make(chan struct{})
+	}()
+
+	h := t.hosts[r.Host()]
+	t.inFlight--
+	h.inFlight--
+	t.done++
+
+	var se *StatusError
+	switch {
+	case errors.As(r.Err, &se) && se.throttled():
+		t.failed++
+		h.throttledAt = h.limit
+		h.limit, h.ok = max(1, h.limit/2), 0
+		h.pause = time.Now().Add(max(se.RetryAfter, time.Second))
+	case errors.As(r.Err, &se) && se.StatusCode < 500:
+		// Missing files say nothing about load.
+	case r.Err != nil:
+		t.failed++
+		h.limit, h.ok = max(1, h.limit-1), 0
+	default:
+		t.ok++
+		t.latency += r.Duration
+		need := h.limit
+		if h.limit+1 >= h.throttledAt && h.throttledAt > 0 {
+			need *= 16
+		}
+		if h.ok++; h.ok >= need {
+			h.limit, h.ok = min(hostMax, h.limit+1), 0
+		}
+	}
+	if t.done < max(t.limit, minWindow) {
+		return
+	}
+
+	var mean time.Duration
+	if t.ok > 0 {
+		mean = t.latency / time.Duration(t.ok)
+		if t.best == 0 || mean < t.best {
+			t.best = mean
+		}
+	}
+	switch failRate := float64(t.failed) / float64(t.done); {
+	case failRate > 0.2 || mean > 2*t.best+latencySlack:
+		t.limit = max(1, t.limit*3/4)
+	case failRate < 0.05 && mean <= t.best*3/2+latencySlack && t.saturated:
+		t.limit = min(MaxAutoThreads, t.limit+1)
+	}
+	t.done, t.failed, t.ok, t.latency, t.saturated = 0, 0, 0, 0, false
+}
+
+func (t *tuner) size() int {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.limit
+}
+
+// interleaveHosts orders images round robin by host, so that waiting for
+// a slot on one busy host doesn't stall the downloads from the others.
+func interleaveHosts(images []Image) []Image {
+	var order []string
+	byHost := map[string][]Image{}
+	for _, img := range images {
+		h := (&ImageResult{URL: img.URL}).Host()
+		if _, ok := byHost[h]; !ok {
+			order = append(order, h)
+		}
+		byHost[h] = append(byHost[h], img)
+	}
+	out := make([]Image, 0, len(images))
+	for len(out) < len(images) {
+		for _, h := range order {
+			if q := byHost[h]; len(q) > 0 {
+				out = append(out, q[0])
+				byHost[h] = q[1:]
+			}
+		}
+	}
+	return out
+}
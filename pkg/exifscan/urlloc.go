@@ -0,0 +1,107 @@
+package exifscan
+
+import (
+	"fmt"
+	"net/url"
+	"path"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// Fields of the tags URLLocation reports.
+const (
+	FieldURLCoordinates = "URL coordinates"
+	FieldURLGeohash     = "URL geohash"
+	FieldURLPlace       = "URL place name"
+)
+
+// coordRE matches a latitude and longitude pair with at least three
+// decimals each, as cameras and exporters put them in file names:
+// IMG_40.7128_-74.0060.jpg, 40.7128,-74.0060.
+var coordRE = regexp.MustCompile(`(?:^|[^\d.])(-?\d{1,2}\.\d{3,})[_,;+ x]+(-?\d{1,3}\.\d{3,})(?:\.?[^\d.]|\.?$)`)
+
+// geohashRE only matches labelled geohashes; bare base32 runs are as
+// likely to be content hashes.
+var geohashRE = regexp.MustCompile(`(?i)(?:^|[^a-z])(?:geohash|geo|gh)[=_:-]([0-9bcdefghjkmnpqrstuvwxyz]{5,12})(?:[^0-9a-z]|$)`)
+
+// places are city and country names common in trip folders and file
+// names. Ones that are also everyday words (Nice, Reading, Turkey) are
+// left out.
+var places = map[string]bool{
+	"amsterdam": true, "athens": true, "auckland": true, "bangkok": true, "barcelona": true,
+	"beijing": true, "berlin": true, "bogota": true, "boston": true, "brussels": true,
+	"budapest": true, "buenos-aires": true, "cairo": true, "cancun": true, "cape-town": true,
+	"chicago": true, "copenhagen": true, "dubai": true, "dublin": true, "edinburgh": true,
+	"florence": true, "frankfurt": true, "geneva": true, "hamburg": true, "havana": true,
+	"helsinki": true, "hong-kong": true, "honolulu": true, "istanbul": true, "jakarta": true,
+	"jerusalem": true, "johannesburg": true, "kyoto": true, "lagos": true, "las-vegas": true,
+	"lisbon": true, "london": true, "los-angeles": true, "madrid": true, "manila": true,
+	"marrakech": true, "melbourne": true, "mexico-city": true, "miami": true, "milan": true,
+	"montreal": true, "moscow": true, "mumbai": true, "munich": true, "nairobi": true,
+	"new-york": true, "nyc": true, "osaka": true, "oslo": true, "paris": true,
+	"prague": true, "reykjavik": true, "rio-de-janeiro": true, "rome": true, "san-francisco": true,
+	"santiago": true, "sao-paulo": true, "seattle": true, "seoul": true, "shanghai": true,
+	"singapore": true, "stockholm": true, "sydney": true, "taipei": true, "tel-aviv": true,
+	"tokyo": true, "toronto": true, "vancouver": true, "venice": true, "vienna": true,
+	"warsaw": true, "zurich": true,
+	"argentina": true, "australia": true, "austria": true, "belgium": true, "brazil": true,
+	"canada": true, "croatia": true, "denmark": true, "egypt": true, "finland": true,
+	"france": true, "germany": true, "greece": true, "iceland": true, "india": true,
+	"indonesia": true, "ireland": true, "italy": true, "japan": true, "kenya": true,
+	"mexico": true, "morocco": true, "netherlands": true, "norway": true, "peru": true,
+	"portugal": true, "scotland": true, "spain": true, "sweden": true, "switzerland": true,
+	"thailand": true, "vietnam": true,
+}
+
+var wordRE = regexp.MustCompile(`[a-z]+`)
+
+// URLLocation looks for location hints in the path of link: coordinate
+// pairs, labelled geohashes and place names. They leak where a photo was
+// taken even when its EXIF is clean.
+func URLLocation(link string) []Tag {
+	p := link
+	if u, err := url.Parse(link); err == nil && u.Host != "" {
+		p = u.Path
+		if u.RawQuery != "" {
+			p += "?" + u.RawQuery
+		}
+	}
+	if s, err := url.PathUnescape(p); err == nil {
+		p = s
+	}
+	var tags []Tag
+	for _, m := range coordRE.FindAllStringSubmatch(p, -1) {
+		lat, _ := strconv.ParseFloat(m[1], 64)
+		lon, _ := strconv.ParseFloat(m[2], 64)
+		if lat < -90 || lat > 90 || lon < -180 || lon > 180 || lat == 0 && lon == 0 {
+			continue
+		}
+		tags = append(tags, Tag{Field: FieldURLCoordinates, Category: CategoryGPS, Value: fmt.Sprintf("%s, %s", m[1], m[2])})
+	}
+	for _, m := range geohashRE.FindAllStringSubmatch(p, -1) {
+		if g, ok := DecodeGeohash(m[1]); ok {
+			tags = append(tags, Tag{Field: FieldURLGeohash, Category: CategoryGPS, Value: fmt.Sprintf("%s (%.4f, %.4f)", m[1], g.Lat, g.Lon)})
+		}
+	}
+	// Place names are only looked for in the directories and the file
+	// name, where people put them, not in query strings.
+	dir, file := path.Split(strings.ToLower(strings.SplitN(p, "?", 2)[0]))
+	seen := map[string]bool{}
+	for _, seg := range append(strings.Split(dir, "/"), strings.TrimSuffix(file, path.Ext(file))) {
+		words := wordRE.FindAllString(seg, -1)
+		for i := range words {
+			for n := 3; n >= 1; n-- {
+				if i+n > len(words) {
+					continue
+				}
+				name := strings.Join(words[i:i+n], "-")
+				if places[name] && !seen[name] {
+					seen[name] = true
+					tags = append(tags, Tag{Field: FieldURLPlace, Category: CategoryPlace, Value: name})
+				}
+			}
+		}
+	}
+	return tags
+}
@@ -0,0 +1,61 @@
+package exifscan
+
+import (
+	"context"
+
+	"github.com/nbd-wtf/go-nostr"
+	"golang.org/x/sync/errgroup"
+)
+
+// Watch subscribes to new notes matching filter on the configured relays
+// and scans their images as they arrive. Kinds default to 1 and Since to
+// now. The channel is closed when ctx is done.
+func (s *Scanner) Watch(ctx context.Context, filter nostr.Filter) <-chan *ImageResult {
+	if len(filter.Kinds) == 0 {
+		filter.Kinds = []int{1}
+	}
+	if filter.Since == nil {
+		now := nostr.Now()
+		filter.Since = &now
+	}
+
+	out := make(chan *ImageResult)
+	go func() {
+		defer close(out)
+		pool := s.newPool(ctx)
+		relays := s.connectRelays(ctx, pool)
+		for _, url := range relays {
+			s.trace(url, "REQ %s", filter)
+		}
+		l := s.newLimiter()
+		var g errgroup.Group
+		defer g.Wait()
+		for evt := range pool.SubscribeMany(ctx, relays, filter) {
+			if evt.Relay != nil {
+				s.trace(evt.Relay.URL, "EVENT %s kind %d", evt.ID, evt.Kind)
+			}
+			s.eventFetched(evt.Event)
+			if _, cw := ContentWarning(evt.Event); cw && s.opts.SkipContentWarnings {
+				continue
+			}
+			for _, img := range ExtractImages([]nostr.Event{*evt.Event}) {
+				if evt.Relay != nil {
+					img.Relays = []string{evt.Relay.URL}
+				}
+				if l.acquire(ctx, (&ImageResult{URL: img.URL}).Host()) != nil {
+					return
+				}
+				g.Go(func() error {
+					r := s.ScanImage(ctx, img)
+					l.release(r)
+					select {
+					case out <- r:
+					case <-ctx.Done():
+					}
+					return nil
+				})
+			}
+		}
+	}()
+	return out
+}
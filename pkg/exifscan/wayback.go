@@ -0,0 +1,78 @@
+package exifscan
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// DefaultWaybackURL is the Internet Archive's availability API.
+const DefaultWaybackURL = "https://archive.org/wayback/available"
+
+var errNoSnapshot = errors.New("no archived snapshot")
+
+// fetch downloads img, falling back to its closest Wayback Machine
+// snapshot when the link is dead and Options.WaybackURL is set.
+func (s *Scanner) fetch(ctx context.Context, r *ImageResult) (*payload, string, error) {
+	p, stage, err := s.download(ctx, r.URL)
+	if err == nil || stage != StageFetch || s.opts.WaybackURL == "" {
+		return p, stage, err
+	}
+	dead := deadReason(err)
+	if dead == "" {
+		return nil, stage, err
+	}
+	snap, serr := s.snapshot(ctx, r.URL)
+	if serr != nil {
+		return nil, stage, err
+	}
+	p, astage, aerr := s.download(ctx, snap)
+	if aerr != nil {
+		return nil, stage, err
+	}
+	r.Dead, r.Archived = dead, snap
+	return p, astage, nil
+}
+
+// snapshot asks the availability API for the closest capture of link and
+// returns the URL of its original bytes, without the archive's toolbar.
+func (s *Scanner) snapshot(ctx context.Context, link string) (string, error) {
+	if err := s.opts.RateLimit.wait(ctx); err != nil {
+		return "", err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, s.opts.WaybackURL+"?url="+url.QueryEscape(link), nil)
+	if err != nil {
+		return "", err
+	}
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("wayback: %s", resp.Status)
+	}
+	var body struct {
+		Snapshots struct {
+			Closest struct {
+				Available bool   `json:"available"`
+				URL       string `json:"url"`
+				Timestamp string `json:"timestamp"`
+				Status    string `json:"status"`
+			} `json:"closest"`
+		} `json:"archived_snapshots"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&body); err != nil {
+		return "", fmt.Errorf("wayback: %w", err)
+	}
+	c := body.Snapshots.Closest
+	if !c.Available || c.URL == "" || c.Status != "200" {
+		return "", errNoSnapshot
+	}
+	// The id_ flag serves the capture as it was archived.
+	return strings.Replace(c.URL, "/web/"+c.Timestamp+"/", "/web/"+c.Timestamp+"id_/", 1), nil
+}
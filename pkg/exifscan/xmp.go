@@ -0,0 +1,103 @@
+package exifscan
+
+import (
+	"bytes"
+	"html"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// xmpField is an XMP property reported, written either as an element,
+// with rdf:li items for lists, or as an attribute.
+type xmpField struct {
+	name, category string
+	elem, attr     *regexp.Regexp
+}
+
+func xmpProp(name, category string) xmpField {
+	q := regexp.QuoteMeta(name)
+	return xmpField{
+		name:     name,
+		category: category,
+		elem:     regexp.MustCompile(`(?s)<` + q + `(?:\s[^>]*)?>(.*?)</` + q + `>`),
+		attr:     regexp.MustCompile(`\s` + q + `="([^"]*)"`),
+	}
+}
+
+var xmpFields = []xmpField{
+	xmpProp("dc:creator", CategoryOwner),
+	xmpProp("dc:rights", CategoryOwner),
+	xmpProp("dc:publisher", CategoryOwner),
+	xmpProp("dc:contributor", CategoryOwner),
+	xmpProp("xmpRights:Owner", CategoryOwner),
+	xmpProp("photoshop:AuthorsPosition", CategoryOwner),
+	xmpProp("xmp:CreatorTool", CategorySoftware),
+	xmpProp("pdf:Producer", CategorySoftware),
+	xmpProp("xmp:CreateDate", CategoryTimestamp),
+	xmpProp("xmp:ModifyDate", CategoryTimestamp),
+	xmpProp("xmp:MetadataDate", CategoryTimestamp),
+	xmpProp("dc:title", ""),
+}
+
+var (
+	liRE     = regexp.MustCompile(`(?s)<rdf:li[^>]*>(.*?)</rdf:li>`)
+	xmlTagRE = regexp.MustCompile(`<[^>]*>`)
+)
+
+// xmpPacket returns the first XMP packet in b, or nil.
+func xmpPacket(b []byte) []byte {
+	i := bytes.Index(b, []byte("<x:xmpmeta"))
+	if i < 0 {
+		return nil
+	}
+	end := bytes.Index(b[i:], []byte("</x:xmpmeta>"))
+	if end < 0 {
+		return nil
+	}
+	return b[i : i+end]
+}
+
+// xmpTags reads the xmpFields of an XMP packet, or of the RDF of an SVG's
+// metadata element, naming them with prefix.
+func xmpTags(packet []byte, prefix string) []Tag {
+	var tags []Tag
+	for _, f := range xmpFields {
+		var values []string
+		if m := f.elem.FindSubmatch(packet); m != nil {
+			if items := liRE.FindAllSubmatch(m[1], -1); items != nil {
+				for _, it := range items {
+					values = append(values, string(it[1]))
+				}
+			} else {
+				values = append(values, string(m[1]))
+			}
+		} else if m := f.attr.FindSubmatch(packet); m != nil {
+			values = append(values, string(m[1]))
+		}
+		var kept []string
+		for _, v := range values {
+			// Inkscape nests the creator's name in a cc:Agent.
+			v = strings.Join(strings.Fields(html.UnescapeString(xmlTagRE.ReplaceAllString(v, " "))), " ")
+			if v != "" {
+				kept = append(kept, v)
+			}
+		}
+		if kept != nil {
+			if f.category == CategoryTimestamp {
+				kept = []string{xmpDate(kept[0])}
+			}
+			tags = append(tags, Tag{Field: prefix + f.name, Category: f.category, Value: strings.Join(kept, ", ")})
+		}
+	}
+	return tags
+}
+
+// xmpDate renders an XMP date like a PDF one, so the same date given by
+// both is reported once.
+func xmpDate(s string) string {
+	if t, err := time.Parse(time.RFC3339, s); err == nil {
+		return t.Format("2006-01-02 15:04:05 -07:00")
+	}
+	return s
+}
@@ -0,0 +1,179 @@
+package main
+
+import (
+	"bufio"
+	"flag"
+	"io"
+	"log"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"unicode"
+)
+
+// plainRunes are the ASCII stand-ins --plain prints for the symbols the
+// console output uses. Other emoji are left out and other non-ASCII
+// characters print as "?".
+var plainRunes = map[rune]string{
+	'❌': "[error]", '⚠': "[warning]", '✅': "[ok]", '🚨': "[!]",
+	'·': "-", '…': "...", '–': "-", '→': "->", '“': `"`, '”': `"`, '•': "*",
+	'½': "1/2", '⅕': "1/5", '°': "d", '×': "x",
+	'▁': "_", '▂': ".", '▃': ",", '▄': "-", '▅': "=", '▆': "+", '▇': "*", '█': "#",
+}
+
+// plainRequested is set by --plain, which the main command and every
+// subcommand take.
+var plainRequested bool
+
+// plainFlag defines --plain on fs, keeping a --plain given before the
+// subcommand.
+func plainFlag(fs *flag.FlagSet) {
+	fs.BoolVar(&plainRequested, "plain", plainRequested, "Print plain ASCII, without colours or emoji; every subcommand takes it")
+}
+
+// leadingPlain removes the --plain flags given before the subcommand.
+func leadingPlain(args []string) []string {
+	for len(args) > 1 {
+		name, val, hasVal := strings.Cut(strings.TrimLeft(args[1], "-"), "=")
+		if !strings.HasPrefix(args[1], "-") || name != "plain" {
+			break
+		}
+		plainRequested = true
+		if hasVal {
+			plainRequested, _ = strconv.ParseBool(val)
+		}
+		args = append(args[:1], args[2:]...)
+	}
+	return args
+}
+
+// newFlagSet returns the flag set of a subcommand, with --plain.
+func newFlagSet(name string) *flag.FlagSet {
+	fs := flag.NewFlagSet(name, flag.ExitOnError)
+	plainFlag(fs)
+	return fs
+}
+
+// parseFlags parses a subcommand's arguments and starts the --plain
+// filter when it was asked for. Subcommands with machine-readable output
+// parse their flags themselves and decide.
+func parseFlags(fs *flag.FlagSet, args []string) {
+	fs.Parse(args)
+	if plainRequested {
+		startPlain()
+	}
+}
+
+var plainStarted bool
+
+var stopPlain = func() {}
+
+// startPlain runs stdout and stderr through plainCopy, so the output has
+// neither ANSI codes nor emoji, until exit is called.
+func startPlain() {
+	if plainStarted {
+		return
+	}
+	plainStarted = true
+	var wg sync.WaitGroup
+	var pipes []*os.File
+	for _, f := range []**os.File{&os.Stdout, &os.Stderr} {
+		r, w, err := os.Pipe()
+		if err != nil {
+			continue
+		}
+		out := *f
+		*f = w
+		pipes = append(pipes, w)
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			plainCopy(out, r)
+		}()
+	}
+	log.SetOutput(os.Stderr)
+	stopPlain = func() {
+		for _, w := range pipes {
+			w.Close()
+		}
+		wg.Wait()
+	}
+}
+
+// exit flushes the console and the --plain filter before exiting.
+func exit(code int) {
+	console.stop()
+	stopPlain()
+	os.Exit(code)
+}
+
+func plainCopy(dst io.Writer, src io.Reader) {
+	br := bufio.NewReader(src)
+	bw := bufio.NewWriter(dst)
+	defer bw.Flush()
+	// An emoji left out takes the space after it along.
+	dropSpace := false
+	for {
+		r, _, err := br.ReadRune()
+		if err != nil {
+			return
+		}
+		switch s, ok := plainRunes[r]; {
+		case r == 0x1b:
+			skipEscape(br)
+		case r == 0x200d || r >= 0xfe00 && r <= 0xfe0f || r >= 0x1f3fb && r <= 0x1f3ff:
+			// Joiners, variation selectors and skin tones of an emoji.
+		case r == ' ' && dropSpace:
+			dropSpace = false
+		case r < 0x80:
+			bw.WriteRune(r)
+			dropSpace = false
+		case ok:
+			bw.WriteString(s)
+			dropSpace = false
+		case isEmoji(r):
+			dropSpace = true
+		default:
+			bw.WriteByte('?')
+			dropSpace = false
+		}
+		// Flush whenever the input pauses, so prompts show up.
+		if br.Buffered() == 0 {
+			bw.Flush()
+		}
+	}
+}
+
+// skipEscape reads the rest of an escape sequence: a CSI one like the
+// colour codes, or an OSC one ended by BEL or ST.
+func skipEscape(br *bufio.Reader) {
+	b, err := br.ReadByte()
+	if err != nil {
+		return
+	}
+	switch b {
+	case '[':
+		for {
+			c, err := br.ReadByte()
+			if err != nil || c >= 0x40 && c <= 0x7e {
+				return
+			}
+		}
+	case ']':
+		for {
+			c, err := br.ReadByte()
+			if err != nil || c == 0x07 {
+				return
+			}
+			if c == 0x1b {
+				br.ReadByte()
+				return
+			}
+		}
+	}
+}
+
+func isEmoji(r rune) bool {
+	return unicode.Is(unicode.So, r) || r >= 0x1f000 && r <= 0x1faff || r == 'ℹ'
+}
@@ -0,0 +1,321 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"os/signal"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/nbd-wtf/go-nostr"
+
+	"nostr-exif-scan/internal/media"
+	"nostr-exif-scan/internal/publish"
+	"nostr-exif-scan/internal/report"
+	"nostr-exif-scan/pkg/exifscan"
+)
+
+const deletionReason = "Removing a post whose image leaked photo metadata"
+
+// runRemediate scans the signer's own notes and publishes NIP-09 deletion
+// requests for the flagged ones the user picks, optionally re-uploading
+// clean copies of their images first.
+func runRemediate(args []string) int {
+	fs := newFlagSet("remediate")
+	threads := threadsFlag(fs, "Number of parallel workers")
+	limit := fs.Int("limit", 10000, "Maximum number of events to fetch")
+	reason := fs.String("reason", deletionReason, "Reason sent with the deletion request")
+	reupload := fs.Bool("reupload", false, "Strip the metadata from the selected posts' images, re-upload them and draft replacement notes")
+	blossomServer, nip96Server := mediaFlags(fs)
+	publishReplacements := fs.Bool("publish-replacements", false, "Publish the replacement notes instead of only printing them")
+	auditLog := fs.String("audit-log", "remediation-log.jsonl", "Append a record of every change to this file")
+	signWith := signerFlag(fs)
+	configPath := configFlag(fs)
+	linkTmpl := linkTemplateFlag(fs)
+	tz := tzFlag(fs)
+	coordFmt := coordFormatFlag(fs)
+	parseFlags(fs, args)
+	if !setLinkTemplate(*linkTmpl) {
+		return 1
+	}
+	if !setTimezone(*tz) || !setCoordFormat(*coordFmt) {
+		return 1
+	}
+	cfg, err := loadConfig(*configPath)
+	if err != nil {
+		fmt.Println("\033[31m❌ Cannot load config:\033[0m", err)
+		return 1
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+
+	pool := nostr.NewSimplePool(ctx)
+	kr, err := loadSigner(ctx, pool, *signWith, cfg.Signer)
+	if err != nil {
+		fmt.Println("\033[31m❌ Cannot load signer:\033[0m", err)
+		return 1
+	}
+	pubkey, err := kr.GetPublicKey(ctx)
+	if err != nil {
+		fmt.Println("\033[31m❌ Cannot get the signer's public key:\033[0m", err)
+		return 1
+	}
+
+	relays := publish.OutboxRelays(ctx, pool, loadRelays("relays.txt"), pubkey)
+	scanner := exifscan.New(exifscan.Options{Relays: relays, Limit: *limit, Threads: *threads})
+	fmt.Printf("🔎 Scanning your posts on \033[36m%d\033[0m relays...\n", len(relays))
+	findings, err := scanner.Scan(ctx, pubkey)
+	if err != nil {
+		fmt.Println("\033[31m❌ Fetching posts failed:\033[0m", err)
+		return 1
+	}
+	groups := publish.ByEvent(findings.Images)
+	if len(groups) == 0 {
+		fmt.Println("✅ None of your posts leak image metadata.")
+		return 0
+	}
+	sort.SliceStable(groups, func(i, k int) bool {
+		return exifscan.SeverityRank(groupSeverity(groups[i])) > exifscan.SeverityRank(groupSeverity(groups[k]))
+	})
+
+	fmt.Printf("🚨 \033[31m%d\033[0m posts leak metadata:\n", len(groups))
+	for i, g := range groups {
+		fmt.Printf("  %2d) [%s] %s\n      %s\n", i+1, groupSeverity(g), report.ResultURL(g[0]), strings.Join(dedup(categories(g)), ", "))
+	}
+
+	in := bufio.NewReader(os.Stdin)
+	fmt.Print("\nSelect posts (e.g. 1,3-5, all; empty to quit): ")
+	line, _ := in.ReadString('\n')
+	picked, err := parseSelection(line, len(groups))
+	if err != nil {
+		fmt.Println("\033[31m❌", err, "\033[0m")
+		return 1
+	}
+	if len(picked) == 0 {
+		fmt.Println("Nothing deleted.")
+		return 0
+	}
+	if *reupload {
+		uploader, server, err := media.Pick(ctx, pool, relays, kr, *blossomServer, *nip96Server)
+		if err != nil {
+			fmt.Println("\033[31m❌", err, "\033[0m")
+			return 1
+		}
+		fmt.Printf("☁️  Re-uploading to \033[36m%s\033[0m\n", server)
+		rm := &remediator{
+			scanner:  exifscan.New(exifscan.Options{Relays: relays, Threads: 1, KeepData: true, SpoolBytes: -1}),
+			uploader: uploader,
+			pub:      publish.New(kr, pool, relays),
+			publish:  *publishReplacements,
+			log:      *auditLog,
+		}
+		for _, n := range picked {
+			if err := rm.replace(ctx, groups[n]); err != nil {
+				fmt.Printf("    ❌ Re-uploading \033[31m%s\033[0m failed: %v\n", report.ResultURL(groups[n][0]), err)
+			}
+		}
+	}
+
+	fmt.Printf("Publish deletion requests for %d posts to %d relays? [y/N] ", len(picked), len(relays))
+	if answer, _ := in.ReadString('\n'); !strings.EqualFold(strings.TrimSpace(answer), "y") {
+		fmt.Println("Nothing deleted.")
+		return 0
+	}
+
+	ids := make([]string, len(picked))
+	for i, n := range picked {
+		ids[i] = groups[n][0].EventID
+	}
+	if err := publish.New(kr, pool, relays).Publish(ctx, publish.Deletion(ids, *reason)); err != nil {
+		fmt.Println("\033[31m❌ Publishing the deletion request failed:\033[0m", err)
+		return 1
+	}
+	fmt.Printf("🗑️  Deletion requested for \033[36m%d\033[0m posts. Relays and clients that honour NIP-09 will drop them; copies of the images may remain on media hosts.\n", len(ids))
+	return 0
+}
+
+// remediator re-uploads clean copies of leaking images and drafts notes
+// pointing at them.
+type remediator struct {
+	// scanner must keep the images in memory for Strip.
+	scanner  *exifscan.Scanner
+	uploader media.Uploader
+	pub      *publish.Publisher
+	publish  bool
+	log      string
+}
+
+// auditEntry is one line of the remediation audit log.
+type auditEntry struct {
+	Time          time.Time `json:"time"`
+	EventID       string    `json:"event_id"`
+	OldURL        string    `json:"old_url"`
+	OldSHA256     string    `json:"old_sha256"`
+	NewURL        string    `json:"new_url"`
+	NewSHA256     string    `json:"new_sha256"`
+	Removed       []string  `json:"removed"`
+	ReplacementID string    `json:"replacement_id,omitempty"`
+	Published     bool      `json:"published"`
+}
+
+func (rm *remediator) replace(ctx context.Context, g []*exifscan.ImageResult) error {
+	orig := g[0].Event
+	if orig == nil {
+		return errors.New("source note not available")
+	}
+	var entries []auditEntry
+	blobs := map[string]*media.Blob{}
+	for _, r := range g {
+		fresh := rm.scanner.ScanImage(ctx, exifscan.Image{EventID: r.EventID, URL: r.URL, Event: r.Event})
+		if fresh.Err != nil {
+			return fresh.Err
+		}
+		clean, err := exifscan.Strip(fresh.Data)
+		if err != nil {
+			return fmt.Errorf("%s: %w", r.URL, err)
+		}
+		blob, err := rm.uploader.Upload(ctx, clean, http.DetectContentType(clean))
+		if err != nil {
+			return err
+		}
+		blobs[r.URL] = blob
+		fmt.Printf("    🧼 %s → \033[36m%s\033[0m\n", r.URL, blob.URL)
+		entries = append(entries, auditEntry{
+			Time:      time.Now().UTC(),
+			EventID:   r.EventID,
+			OldURL:    r.URL,
+			OldSHA256: fresh.SHA256,
+			NewURL:    blob.URL,
+			NewSHA256: blob.SHA256,
+			Removed:   r.Categories(),
+		})
+	}
+
+	note := replacementNote(orig, blobs)
+	if rm.publish {
+		if err := rm.pub.Publish(ctx, note); err != nil {
+			return fmt.Errorf("publishing replacement: %w", err)
+		}
+		fmt.Printf("    📣 Replacement published: \033[4m%s\033[0m\n", report.EventURL(note.ID, nil, note.PubKey))
+	} else {
+		fmt.Printf("    📝 Replacement note:\n%s\n", indent(note.Content, "       "))
+	}
+	for i := range entries {
+		entries[i].Published = rm.publish
+		if rm.publish {
+			entries[i].ReplacementID = note.ID
+		}
+	}
+	return appendAudit(rm.log, entries)
+}
+
+// replacementNote copies orig with every re-uploaded URL swapped for its
+// clean copy and imeta tags describing the new files.
+func replacementNote(orig *nostr.Event, blobs map[string]*media.Blob) *nostr.Event {
+	content := orig.Content
+	var tags nostr.Tags
+	for _, tag := range orig.Tags {
+		if tag[0] == "imeta" {
+			continue
+		}
+		tags = append(tags, tag)
+	}
+	for old, blob := range blobs {
+		content = strings.ReplaceAll(content, old, blob.URL)
+		imeta := nostr.Tag{"imeta", "url " + blob.URL, "x " + blob.SHA256}
+		if blob.Type != "" {
+			imeta = append(imeta, "m "+blob.Type)
+		}
+		tags = append(tags, imeta)
+	}
+	return &nostr.Event{Kind: orig.Kind, Tags: tags, Content: content}
+}
+
+func appendAudit(path string, entries []auditEntry) error {
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0o600)
+	if err != nil {
+		return err
+	}
+	enc := json.NewEncoder(f)
+	for _, e := range entries {
+		if err := enc.Encode(e); err != nil {
+			f.Close()
+			return err
+		}
+	}
+	return f.Close()
+}
+
+func indent(s, prefix string) string {
+	return prefix + strings.ReplaceAll(s, "\n", "\n"+prefix)
+}
+
+// parseSelection turns "1,3-5" or "all" into zero-based indexes below n.
+func parseSelection(s string, n int) ([]int, error) {
+	s = strings.TrimSpace(s)
+	if s == "" {
+		return nil, nil
+	}
+	if strings.EqualFold(s, "all") {
+		out := make([]int, n)
+		for i := range out {
+			out[i] = i
+		}
+		return out, nil
+	}
+	seen := map[int]bool{}
+	var out []int
+	for _, part := range strings.Split(s, ",") {
+		lo, hi, isRange := strings.Cut(strings.TrimSpace(part), "-")
+		a, err := strconv.Atoi(strings.TrimSpace(lo))
+		if err != nil {
+			return nil, fmt.Errorf("invalid selection %q", part)
+		}
+		b := a
+		if isRange {
+			if b, err = strconv.Atoi(strings.TrimSpace(hi)); err != nil {
+				return nil, fmt.Errorf("invalid selection %q", part)
+			}
+		}
+		if a < 1 || b > n || a > b {
+			return nil, fmt.Errorf("selection %q out of range 1-%d", part, n)
+		}
+		for i := a; i <= b; i++ {
+			if !seen[i-1] {
+				seen[i-1] = true
+				out = append(out, i-1)
+			}
+		}
+	}
+	return out, nil
+}
+
+func groupSeverity(g []*exifscan.ImageResult) string {
+	best := ""
+	for _, r := range g {
+		if sev := r.Severity(); exifscan.SeverityRank(sev) > exifscan.SeverityRank(best) {
+			best = sev
+		}
+	}
+	return best
+}
+
+func dedup(ss []string) []string {
+	seen := map[string]bool{}
+	var out []string
+	for _, s := range ss {
+		if !seen[s] {
+			seen[s] = true
+			out = append(out, s)
+		}
+	}
+	return out
+}
@@ -0,0 +1,101 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"slices"
+	"sync"
+)
+
+// console is the output of the scan, see renderer.
+var console renderer
+
+// renderer serializes console output. While started, a goroutine is the
+// only writer: hook messages from the workers and the lines of each
+// result reach it over a channel as whole blocks, so they never
+// interleave. Ordered, it prints the results in the order of the image
+// links, holding back those finished early until the ones before them
+// are printed. Stopped, blocks are written right away.
+type renderer struct {
+	mu     sync.Mutex
+	blocks chan block
+	done   chan struct{}
+}
+
+// block is text to print; seq numbers the results to order and is -1 for
+// messages printed as they come.
+type block struct {
+	seq  int
+	text string
+}
+
+func (c *renderer) start(ordered bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.blocks != nil {
+		return
+	}
+	c.blocks, c.done = make(chan block, 64), make(chan struct{})
+	go c.run(c.blocks, c.done, ordered)
+}
+
+// stop prints the results still held back and waits for the output to be
+// written; exit stops it too.
+func (c *renderer) stop() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.blocks == nil {
+		return
+	}
+	close(c.blocks)
+	<-c.done
+	c.blocks = nil
+}
+
+// printf prints a message between results.
+func (c *renderer) printf(format string, args ...any) {
+	c.send(block{seq: -1, text: fmt.Sprintf(format, args...)})
+}
+
+// result prints the lines of the result of the image link seq.
+func (c *renderer) result(seq int, text string) {
+	c.send(block{seq: seq, text: text})
+}
+
+func (c *renderer) send(b block) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.blocks == nil {
+		io.WriteString(os.Stdout, b.text)
+		return
+	}
+	c.blocks <- b
+}
+
+func (c *renderer) run(blocks <-chan block, done chan<- struct{}, ordered bool) {
+	defer close(done)
+	next := 0
+	held := map[int]string{}
+	for b := range blocks {
+		if !ordered || b.seq < 0 {
+			io.WriteString(os.Stdout, b.text)
+			continue
+		}
+		held[b.seq] = b.text
+		for text, ok := held[next]; ok; text, ok = held[next] {
+			io.WriteString(os.Stdout, text)
+			delete(held, next)
+			next++
+		}
+	}
+	// Stopped early, by an aborted scan: what came is printed in order.
+	seqs := make([]int, 0, len(held))
+	for seq := range held {
+		seqs = append(seqs, seq)
+	}
+	slices.Sort(seqs)
+	for _, seq := range seqs {
+		io.WriteString(os.Stdout, held[seq])
+	}
+}
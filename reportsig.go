@@ -0,0 +1,115 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/nbd-wtf/go-nostr"
+	"github.com/nbd-wtf/go-nostr/nip19"
+
+	"nostr-exif-scan/internal/report"
+	"nostr-exif-scan/pkg/exifscan"
+)
+
+// unsignedFlags are left out of a signed report's parameters: secrets, and
+// where the output went.
+var unsignedFlags = map[string]bool{
+	"sign-with": true, "config": true, "report": true, "sign-report": true,
+	"encrypt-to": true, "publish-report-blossom": true, "email": true,
+}
+
+// scanParams describes the scan of pubkey for a report signature: the
+// relays asked, the time range and every flag given on the command line.
+func scanParams(pubkey string, relays []string, since, until time.Time) nostr.Tags {
+	tags := nostr.Tags{{"p", pubkey}, {"client", "nostr-exif-scan"}}
+	for _, r := range relays {
+		tags = append(tags, nostr.Tag{"relay", r})
+	}
+	if !since.IsZero() {
+		tags = append(tags, nostr.Tag{"since", strconv.FormatInt(since.Unix(), 10)})
+	}
+	if !until.IsZero() {
+		tags = append(tags, nostr.Tag{"until", strconv.FormatInt(until.Unix(), 10)})
+	}
+	flag.Visit(func(f *flag.Flag) {
+		if !unsignedFlags[f.Name] {
+			tags = append(tags, nostr.Tag{"param", f.Name, f.Value.String()})
+		}
+	})
+	return tags
+}
+
+// writeReport renders the report, signed with kr and encrypted with c
+// when they aren't nil, and returns the path and bytes it wrote.
+func writeReport(ctx context.Context, path string, d report.Data, kr nostr.Keyer, params nostr.Tags, c *reportCipher) (string, []byte, error) {
+	var buf bytes.Buffer
+	if err := report.Write(&buf, path, d); err != nil {
+		return "", nil, err
+	}
+	body := buf.Bytes()
+	var err error
+	if kr != nil {
+		if body, err = report.Sign(ctx, body, kr, d.GeneratedAt, params); err != nil {
+			return "", nil, err
+		}
+	}
+	if c != nil {
+		if body, err = c.encrypt(ctx, path, body); err != nil {
+			return "", nil, err
+		}
+		path = c.path(path)
+	}
+	return path, body, os.WriteFile(path, body, 0o644)
+}
+
+// verifyReport checks the signature of a report file and, when auditor
+// is set, that it signed it.
+func verifyReport(path, auditor string) int {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		fmt.Println("\033[31m❌ Cannot read report:\033[0m", err)
+		return 1
+	}
+	evt, err := report.Verify(data)
+	if err != nil {
+		fmt.Println("\033[31m❌ Invalid signature:\033[0m", err)
+		return 1
+	}
+	signer, _ := nip19.EncodePublicKey(evt.PubKey)
+	if auditor != "" && auditor != signer && auditor != evt.PubKey {
+		fmt.Printf("\033[31m❌ Signed by %s, not by %s\033[0m\n", signer, auditor)
+		return 1
+	}
+	fmt.Printf("✅ \033[32mValid signature\033[0m by \033[36m%s\033[0m, made %s\n", signer, exifscan.InDisplayZone(evt.CreatedAt.Time()).Format(time.RFC3339))
+	for _, t := range evt.Tags {
+		if len(t) < 2 {
+			continue
+		}
+		switch t[0] {
+		case "p":
+			npub, _ := nip19.EncodePublicKey(t[1])
+			fmt.Printf("   Scanned: %s\n", npub)
+		case "relay":
+			fmt.Printf("   Relay: %s\n", t[1])
+		case "since":
+			fmt.Printf("   Since: %s\n", unixDate(t[1]))
+		case "until":
+			fmt.Printf("   Until: %s\n", unixDate(t[1]))
+		case "param":
+			if len(t) > 2 {
+				fmt.Printf("   --%s %s\n", t[1], t[2])
+			}
+		}
+	}
+	return 0
+}
+
+func unixDate(s string) string {
+	ts, _ := strconv.ParseInt(s, 10, 64)
+	return exifscan.InDisplayZone(time.Unix(ts, 0)).Format(time.RFC3339)
+}
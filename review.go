@@ -0,0 +1,173 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"os"
+	"os/exec"
+	"runtime"
+	"sort"
+	"strings"
+
+	"github.com/nbd-wtf/go-nostr"
+
+	"nostr-exif-scan/internal/media"
+	"nostr-exif-scan/internal/publish"
+	"nostr-exif-scan/internal/report"
+	"nostr-exif-scan/pkg/exifscan"
+)
+
+// reviewer walks the user through the leaking posts of a scan one by one.
+// Ignored posts go to the baseline at once; deletions and clean re-uploads
+// are queued and carried out together at the end.
+type reviewer struct {
+	base     *baseline
+	relays   []string
+	signWith string
+	signer   signerConfig
+	blossom  string
+	nip96    string
+	auditLog string
+}
+
+func (rv *reviewer) run(ctx context.Context, findings *exifscan.Findings) error {
+	var groups [][]*exifscan.ImageResult
+	for _, g := range publish.ByEvent(findings.Images) {
+		if !rv.base.ignores(g[0].EventID) {
+			groups = append(groups, g)
+		}
+	}
+	if len(groups) == 0 {
+		fmt.Println("✅ Nothing left to review.")
+		return nil
+	}
+	sort.SliceStable(groups, func(i, k int) bool {
+		return exifscan.SeverityRank(groupSeverity(groups[i])) > exifscan.SeverityRank(groupSeverity(groups[k]))
+	})
+
+	in := bufio.NewReader(os.Stdin)
+	var deletions, reuploads [][]*exifscan.ImageResult
+review:
+	for i, g := range groups {
+		url := report.ResultURL(g[0])
+		fmt.Printf("\n\033[1m[%d/%d]\033[0m [%s] \033[4m%s\033[0m\n", i+1, len(groups), groupSeverity(g), url)
+		for _, r := range g {
+			fmt.Printf("    🖼️  %s: %s\n", r.URL, strings.Join(r.Categories(), ", "))
+			if r.GPS != nil {
+				fmt.Printf("    🌍 GPS: %s (accurate to %s) %s\n", r.GPS, r.GPS.Accuracy(), r.GPS.MapsURL())
+			}
+			if r.GeoTag != nil {
+				fmt.Printf("    📍 Note %s\n", r.GeoTag)
+			}
+		}
+		for {
+			fmt.Print("(o)pen, (i)gnore, (d)elete, (r)e-upload clean, (n)ext, (q)uit: ")
+			line, err := in.ReadString('\n')
+			if err != nil && line == "" {
+				break review
+			}
+			switch strings.ToLower(strings.TrimSpace(line)) {
+			case "o":
+				if err := openBrowser(url); err != nil {
+					fmt.Println("    ❌ Cannot open the browser:", err)
+				}
+				continue
+			case "i":
+				if err := rv.base.ignore(g[0].EventID, "accepted: "+strings.Join(dedup(categories(g)), ", ")); err != nil {
+					return fmt.Errorf("updating baseline: %w", err)
+				}
+				fmt.Printf("    🙈 Ignored in \033[36m%s\033[0m\n", rv.base.path)
+			case "d":
+				deletions = append(deletions, g)
+				fmt.Println("    🗑️  Queued for deletion")
+			case "r":
+				reuploads = append(reuploads, g)
+				fmt.Println("    🧼 Queued for clean re-upload")
+			case "", "n":
+			case "q":
+				break review
+			default:
+				continue
+			}
+			break
+		}
+	}
+	if len(deletions) == 0 && len(reuploads) == 0 {
+		return nil
+	}
+	return rv.apply(ctx, findings.Pubkey, deletions, reuploads, in)
+}
+
+// apply re-uploads the queued posts' images, publishes their replacement
+// notes and then requests deletion of every queued original.
+func (rv *reviewer) apply(ctx context.Context, pubkey string, deletions, reuploads [][]*exifscan.ImageResult, in *bufio.Reader) error {
+	pool := nostr.NewSimplePool(ctx)
+	kr, err := loadSigner(ctx, pool, rv.signWith, rv.signer)
+	if err != nil {
+		return fmt.Errorf("cannot load signer: %w", err)
+	}
+	if pk, err := kr.GetPublicKey(ctx); err != nil || pk != pubkey {
+		return fmt.Errorf("only the scanned account can delete or replace its posts; sign with its key")
+	}
+	relays := publish.OutboxRelays(ctx, pool, rv.relays, pubkey)
+	fmt.Printf("\nRe-upload %d and delete %d posts on %d relays? [y/N] ", len(reuploads), len(deletions)+len(reuploads), len(relays))
+	if answer, _ := in.ReadString('\n'); !strings.EqualFold(strings.TrimSpace(answer), "y") {
+		fmt.Println("Nothing changed.")
+		return nil
+	}
+
+	pub := publish.New(kr, pool, relays)
+	var ids []string
+	for _, g := range deletions {
+		ids = append(ids, g[0].EventID)
+	}
+	if len(reuploads) > 0 {
+		uploader, server, err := media.Pick(ctx, pool, relays, kr, rv.blossom, rv.nip96)
+		if err != nil {
+			return err
+		}
+		fmt.Printf("☁️  Re-uploading to \033[36m%s\033[0m\n", server)
+		rm := &remediator{
+			scanner:  exifscan.New(exifscan.Options{Relays: relays, Threads: 1, KeepData: true, SpoolBytes: -1}),
+			uploader: uploader,
+			pub:      pub,
+			publish:  true,
+			log:      rv.auditLog,
+		}
+		for _, g := range reuploads {
+			// Keep the original when its replacement could not be made.
+			if err := rm.replace(ctx, g); err != nil {
+				fmt.Printf("    ❌ Re-uploading \033[31m%s\033[0m failed: %v\n", report.ResultURL(g[0]), err)
+				continue
+			}
+			ids = append(ids, g[0].EventID)
+		}
+	}
+	if len(ids) == 0 {
+		return nil
+	}
+	if err := pub.Publish(ctx, publish.Deletion(ids, deletionReason)); err != nil {
+		return fmt.Errorf("publishing the deletion request: %w", err)
+	}
+	fmt.Printf("🗑️  Deletion requested for \033[36m%d\033[0m posts.\n", len(ids))
+	return nil
+}
+
+func categories(g []*exifscan.ImageResult) []string {
+	var cats []string
+	for _, r := range g {
+		cats = append(cats, r.Categories()...)
+	}
+	return cats
+}
+
+func openBrowser(url string) error {
+	switch runtime.GOOS {
+	case "darwin":
+		return exec.Command("open", url).Start()
+	case "windows":
+		return exec.Command("rundll32", "url.dll,FileProtocolHandler", url).Start()
+	}
+	return exec.Command("xdg-open", url).Start()
+}
@@ -0,0 +1,33 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// sampleSize turns --sample (a percentage or a fraction) or --sample-n
+// into the number of the total images to scan, or 0 for all of them.
+func sampleSize(share string, n, total int) (int, error) {
+	if share != "" && n > 0 {
+		return 0, fmt.Errorf("pass --sample or --sample-n, not both")
+	}
+	if n < 0 {
+		return 0, fmt.Errorf("--sample-n must be positive")
+	}
+	if share == "" {
+		return min(n, total), nil
+	}
+	s, pct := strings.CutSuffix(share, "%")
+	f, err := strconv.ParseFloat(s, 64)
+	if err != nil {
+		return 0, fmt.Errorf("--sample %q is neither a percentage nor a fraction", share)
+	}
+	if pct {
+		f /= 100
+	}
+	if f <= 0 || f > 1 {
+		return 0, fmt.Errorf("--sample %q must be above 0 and at most 100%%", share)
+	}
+	return max(1, int(f*float64(total)+0.5)), nil
+}
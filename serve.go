@@ -0,0 +1,120 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"net/http"
+	"os"
+	"os/signal"
+
+	"nostr-exif-scan/internal/health"
+	"nostr-exif-scan/internal/metrics"
+	"nostr-exif-scan/internal/queue"
+	"nostr-exif-scan/internal/server"
+	"nostr-exif-scan/internal/store"
+	"nostr-exif-scan/internal/telemetry"
+	"nostr-exif-scan/pkg/exifscan"
+)
+
+func healthFlag(fs *flag.FlagSet) *string {
+	return fs.String("health-listen", "", "Serve /healthz, /readyz and /status on this address (e.g. :8081)")
+}
+
+func runServe(args []string) int {
+	fs := newFlagSet("serve")
+	listen := fs.String("listen", ":8080", "Address to listen on")
+	maxJobs := fs.Int("max-jobs", 4, "Number of scan workers, i.e. scans running concurrently; more are queued by priority")
+	threads := threadsFlag(fs, "Number of parallel image workers per scan")
+	rps := rpsFlag(fs)
+	limit := fs.Int("limit", 10000, "Maximum number of events to fetch per scan")
+	profile := fs.Bool("profile", true, "Also check the scanned profile's text for phone numbers, emails, street addresses and real names")
+	dbDir := fs.String("db", "", "Results database directory; scans are kept in memory only when empty")
+	otlpEndpoint := fs.String("otlp-endpoint", "", "OTLP/HTTP collector URL for traces (default $OTEL_EXPORTER_OTLP_ENDPOINT)")
+	queueURL := queueFlag(fs)
+	configPath := configFlag(fs)
+	pprofListen := pprofFlag(fs)
+	linkTmpl := linkTemplateFlag(fs)
+	tz := tzFlag(fs)
+	coordFmt := coordFormatFlag(fs)
+	parseFlags(fs, args)
+	if !setLinkTemplate(*linkTmpl) {
+		return 1
+	}
+	if !setTimezone(*tz) || !setCoordFormat(*coordFmt) {
+		return 1
+	}
+	if *maxJobs < 1 {
+		fmt.Println("\033[31m❌ --max-jobs must be at least 1\033[0m")
+		return 1
+	}
+	appCfg, err := loadConfig(*configPath)
+	if err != nil {
+		fmt.Println("\033[31m❌ Cannot load config:\033[0m", err)
+		return 1
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+	servePprof(*pprofListen)
+
+	shutdownTracing, err := telemetry.Setup(ctx, *otlpEndpoint)
+	if err != nil {
+		fmt.Println("\033[31m❌ Cannot set up tracing:\033[0m", err)
+		return 1
+	}
+	defer shutdownTracing(context.Background())
+
+	relays := loadRelays("relays.txt")
+	cfg := server.Config{
+		Scan: exifscan.Options{
+			Relays:    relays,
+			Limit:     *limit,
+			Threads:   *threads,
+			RateLimit: exifscan.NewRateLimit(*rps),
+			Profile:   *profile,
+		},
+		MaxJobs: *maxJobs,
+		Metrics: metrics.New(),
+		Health:  health.New("serve", relays),
+		Tenants: appCfg.Serve.Tenants,
+	}
+	if *dbDir != "" {
+		db, err := store.Open(*dbDir)
+		if err != nil {
+			fmt.Println("\033[31m❌ Cannot open results database:\033[0m", err)
+			return 1
+		}
+		cfg.DB = db
+	}
+	if *queueURL != "" {
+		backend, err := queue.Open(*queueURL)
+		if err != nil {
+			fmt.Println("\033[31m❌ Cannot connect to the queue:\033[0m", err)
+			return 1
+		}
+		defer backend.Close()
+		cfg.Queue = backend
+		fmt.Println("🛠️  Image scans are handed to the workers on the queue (run: nostr-exif-scan worker --queue ...)")
+	}
+	srv, err := server.New(ctx, cfg)
+	if err != nil {
+		fmt.Println("\033[31m❌ Cannot start server:\033[0m", err)
+		return 1
+	}
+	httpSrv := &http.Server{Addr: *listen, Handler: srv}
+	go func() {
+		<-ctx.Done()
+		httpSrv.Shutdown(context.Background())
+	}()
+
+	fmt.Printf("🌐 Listening on \033[36m%s\033[0m (dashboard at /, API spec at /openapi.yaml)\n", *listen)
+	if n := len(cfg.Tenants); n > 0 {
+		fmt.Printf("🔑 %d API keys configured; requests without one are refused\n", n)
+	}
+	if err := httpSrv.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+		fmt.Println("\033[31m❌ Server failed:\033[0m", err)
+		return 1
+	}
+	return 0
+}
@@ -0,0 +1,128 @@
+package main
+
+import (
+	"context"
+	"encoding/hex"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/nbd-wtf/go-nostr"
+	"github.com/nbd-wtf/go-nostr/keyer"
+	"github.com/nbd-wtf/go-nostr/nip46"
+)
+
+const (
+	signerEnv             = "NOSTR_SECRET_KEY"
+	defaultClientKeyFile  = "bunker-client.key"
+	defaultBunkerTimeout  = 2 * time.Minute
+	bunkerConnectTimeout  = time.Minute
+	nsecOnCommandLineHint = "⚠️  A secret key on the command line ends up in shell history; prefer a bunker:// URL, $" + signerEnv + " or the signer config section"
+)
+
+// signerConfig is the signer section of the config file, so the key (or
+// bunker URL) doesn't have to be passed on the command line at all.
+type signerConfig struct {
+	// URI is an nsec, hex key or bunker:// URL.
+	URI string `json:"uri"`
+	// ClientKeyFile keeps the key we use to talk to the bunker, so it only
+	// has to be approved once (default bunker-client.key).
+	ClientKeyFile string `json:"client_key_file,omitempty"`
+	// Timeout bounds each remote signing request (default 2m).
+	Timeout string `json:"timeout,omitempty"`
+}
+
+func signerFlag(fs *flag.FlagSet) *string {
+	return fs.String("sign-with", "", "nsec, hex key or bunker:// URL used to sign published events (default $"+signerEnv+", then the signer config section)")
+}
+
+// loadSigner returns the Keyer every publishing feature signs with, taken
+// from --sign-with, the environment or the config, in that order. Bunker
+// connections go through pool.
+func loadSigner(ctx context.Context, pool *nostr.SimplePool, input string, cfg signerConfig) (nostr.Keyer, error) {
+	if input != "" && !nip46.IsValidBunkerURL(input) {
+		fmt.Println(nsecOnCommandLineHint)
+	}
+	if input == "" {
+		input = os.Getenv(signerEnv)
+	}
+	if input == "" {
+		input = cfg.URI
+	}
+	input = strings.TrimSpace(input)
+	if input == "" {
+		return nil, errors.New("no signer: pass --sign-with, set " + signerEnv + " or fill in the signer config section")
+	}
+
+	opts := &keyer.SignerOptions{
+		BunkerSignTimeout: defaultBunkerTimeout,
+		BunkerAuthHandler: func(url string) {
+			fmt.Printf("🔐 The bunker asks for approval: \033[4m%s\033[0m\n", url)
+		},
+	}
+	if cfg.Timeout != "" {
+		d, err := time.ParseDuration(cfg.Timeout)
+		if err != nil {
+			return nil, fmt.Errorf("signer timeout: %w", err)
+		}
+		opts.BunkerSignTimeout = d
+	}
+	if nip46.IsValidBunkerURL(input) {
+		path := cfg.ClientKeyFile
+		if path == "" {
+			path = defaultClientKeyFile
+		}
+		sk, err := bunkerClientKey(path)
+		if err != nil {
+			return nil, err
+		}
+		opts.BunkerClientSecretKey = sk
+		fmt.Println("🔌 Connecting to the remote signer...")
+	}
+
+	// The bunker keeps listening for responses on ctx, so the connection
+	// attempt is bounded separately instead of with a derived context.
+	type result struct {
+		kr  nostr.Keyer
+		err error
+	}
+	done := make(chan result, 1)
+	go func() {
+		kr, err := keyer.New(ctx, pool, input, opts)
+		done <- result{kr, err}
+	}()
+	select {
+	case res := <-done:
+		if res.err != nil && strings.HasPrefix(res.err.Error(), "unsupported input") {
+			// keyer echoes the input, which may be a mistyped secret.
+			return nil, errors.New("unsupported key: want nsec, hex or bunker:// URL")
+		}
+		return res.kr, res.err
+	case <-time.After(bunkerConnectTimeout):
+		return nil, errors.New("the remote signer did not answer")
+	}
+}
+
+// bunkerClientKey loads the bunker client key from path, creating it on
+// first use.
+func bunkerClientKey(path string) (string, error) {
+	data, err := os.ReadFile(path)
+	if err == nil {
+		sk := strings.TrimSpace(string(data))
+		if _, err := hex.DecodeString(sk); err != nil || len(sk) != 64 {
+			return "", fmt.Errorf("%s: not a hex secret key", path)
+		}
+		return sk, nil
+	}
+	if !errors.Is(err, os.ErrNotExist) {
+		return "", err
+	}
+	sk := nostr.GeneratePrivateKey()
+	if err := os.WriteFile(path, []byte(sk+"\n"), 0o600); err != nil {
+		return "", err
+	}
+	return sk, nil
+}
@@ -0,0 +1,367 @@
+package main
+
+import (
+	"archive/tar"
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"strings"
+	"time"
+
+	"nostr-exif-scan/internal/store"
+)
+
+const (
+	stateFormat  = "nostr-exif-scan-state"
+	stateVersion = 1
+)
+
+// stateManifest is the first entry of a state bundle.
+type stateManifest struct {
+	Format  string    `json:"format"`
+	Version int       `json:"version"`
+	Created time.Time `json:"created"`
+	Host    string    `json:"host,omitempty"`
+	Records int       `json:"records"`
+	Files   []string  `json:"files"`
+}
+
+// Bundle entry names. They don't depend on the local paths, so a bundle
+// can be imported where the files are configured differently.
+const (
+	stateManifestName = "manifest.json"
+	stateDB           = "db/"
+	stateBaseline     = "baseline.txt"
+	stateAuditLog     = "remediation-log.jsonl"
+	stateDMState      = "dm-state.json"
+)
+
+// stateLineFiles are appended to, never rewritten, so importing merges
+// them line by line instead of replacing them.
+var stateLineFiles = map[string]bool{stateBaseline: true, stateAuditLog: true}
+
+// statePaths are the local files a bundle is made from or restored to.
+type statePaths struct {
+	db, baseline, auditLog, config *string
+}
+
+func statePathFlags(fs *flag.FlagSet) statePaths {
+	return statePaths{
+		db:       fs.String("db", "daemon-db", "Results database directory of the daemon"),
+		baseline: baselineFlag(fs),
+		auditLog: fs.String("audit-log", "remediation-log.jsonl", "Remediation audit log"),
+		config:   configFlag(fs),
+	}
+}
+
+// files maps the bundle's file entries to local paths; the DM state file
+// is wherever the dm config section puts it.
+func (p statePaths) files() (map[string]string, error) {
+	cfg, err := loadConfig(*p.config)
+	if err != nil {
+		return nil, err
+	}
+	dmState := cfg.DM.StateFile
+	if dmState == "" {
+		dmState = "dm-state.json"
+	}
+	return map[string]string{
+		stateBaseline: *p.baseline,
+		stateAuditLog: *p.auditLog,
+		stateDMState:  dmState,
+	}, nil
+}
+
+// runState bundles the results database, the baseline, the remediation
+// log and the DM state into one file, or restores such a bundle, so an
+// audit can move between machines.
+func runState(args []string) int {
+	if len(args) > 0 {
+		switch args[0] {
+		case "export":
+			return runStateExport(args[1:])
+		case "import":
+			return runStateImport(args[1:])
+		}
+	}
+	fmt.Println("\033[31m❌ Please use state export [file] or state import <file>\033[0m")
+	return 1
+}
+
+func runStateExport(args []string) int {
+	fs := newFlagSet("state export")
+	paths := statePathFlags(fs)
+	parseFlags(fs, args)
+
+	out := "exifscan-state.tar.gz"
+	if fs.NArg() > 0 {
+		out = fs.Arg(0)
+	}
+	files, err := paths.files()
+	if err != nil {
+		fmt.Println("\033[31m❌ Cannot load config:\033[0m", err)
+		return 1
+	}
+	m, err := exportState(out, *paths.db, files)
+	if err != nil {
+		os.Remove(out)
+		fmt.Println("\033[31m❌ Exporting state failed:\033[0m", err)
+		return 1
+	}
+	fmt.Printf("📦 Exported \033[36m%d\033[0m records and \033[36m%d\033[0m files to \033[36m%s\033[0m\n", m.Records, len(m.Files), out)
+	return 0
+}
+
+// exportState writes a gzipped tar of the manifest, every record of the
+// database in dbDir and the files that exist.
+func exportState(out, dbDir string, files map[string]string) (*stateManifest, error) {
+	host, _ := os.Hostname()
+	m := &stateManifest{Format: stateFormat, Version: stateVersion, Created: time.Now().UTC(), Host: host, Files: []string{}}
+	contents := map[string][]byte{}
+	for _, name := range []string{stateBaseline, stateAuditLog, stateDMState} {
+		data, err := os.ReadFile(files[name])
+		if errors.Is(err, fs.ErrNotExist) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		contents[name] = data
+		m.Files = append(m.Files, name)
+	}
+	// Don't create the database just to export it empty.
+	var db *store.Store
+	var ids []string
+	if _, err := os.Stat(dbDir); err == nil {
+		if db, err = store.Open(dbDir); err != nil {
+			return nil, err
+		}
+		if ids, err = db.IDs(); err != nil {
+			return nil, err
+		}
+		m.Records = len(ids)
+	} else if !errors.Is(err, fs.ErrNotExist) {
+		return nil, err
+	}
+
+	f, err := os.Create(out)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	gz := gzip.NewWriter(f)
+	tw := tar.NewWriter(gz)
+	add := func(name string, data []byte) error {
+		hdr := &tar.Header{Name: name, Mode: 0o600, Size: int64(len(data)), ModTime: m.Created, Typeflag: tar.TypeReg}
+		if err := tw.WriteHeader(hdr); err != nil {
+			return err
+		}
+		_, err := tw.Write(data)
+		return err
+	}
+	manifest, err := json.MarshalIndent(m, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	if err := add(stateManifestName, manifest); err != nil {
+		return nil, err
+	}
+	for _, id := range ids {
+		var rec json.RawMessage
+		if err := db.Get(id, &rec); err != nil {
+			return nil, fmt.Errorf("record %s: %w", id, err)
+		}
+		if err := add(stateDB+id+".json", rec); err != nil {
+			return nil, err
+		}
+	}
+	for _, name := range m.Files {
+		if err := add(name, contents[name]); err != nil {
+			return nil, err
+		}
+	}
+	if err := tw.Close(); err != nil {
+		return nil, err
+	}
+	if err := gz.Close(); err != nil {
+		return nil, err
+	}
+	return m, f.Close()
+}
+
+func runStateImport(args []string) int {
+	fs := newFlagSet("state import")
+	paths := statePathFlags(fs)
+	force := fs.Bool("force", false, "Replace local records and DM state that differ from the bundle's")
+	parseFlags(fs, args)
+
+	if fs.NArg() != 1 {
+		fmt.Println("\033[31m❌ Please provide the state file to import\033[0m")
+		return 1
+	}
+	files, err := paths.files()
+	if err != nil {
+		fmt.Println("\033[31m❌ Cannot load config:\033[0m", err)
+		return 1
+	}
+	entries, m, err := readState(fs.Arg(0))
+	if err != nil {
+		fmt.Println("\033[31m❌ Cannot read state file:\033[0m", err)
+		return 1
+	}
+	fmt.Printf("📦 State of \033[36m%s\033[0m from %s: %d records, %d files\n", m.Host, m.Created.Local().Format(time.DateTime), m.Records, len(m.Files))
+
+	// Check for conflicts first so a refused import changes nothing.
+	if !*force {
+		var conflicts []string
+		for name, data := range entries {
+			local := *paths.db + "/" + strings.TrimPrefix(name, stateDB)
+			if !strings.HasPrefix(name, stateDB) {
+				if stateLineFiles[name] {
+					continue
+				}
+				local = files[name]
+			}
+			if cur, err := os.ReadFile(local); err == nil && !bytes.Equal(cur, data) {
+				conflicts = append(conflicts, local)
+			}
+		}
+		if len(conflicts) > 0 {
+			fmt.Printf("\033[31m❌ %d local files differ from the bundle, use --force to replace them:\033[0m\n", len(conflicts))
+			for _, c := range conflicts {
+				fmt.Println("   " + c)
+			}
+			return 1
+		}
+	}
+
+	db, err := store.Open(*paths.db)
+	if err != nil {
+		fmt.Println("\033[31m❌ Cannot open results database:\033[0m", err)
+		return 1
+	}
+	records, merged := 0, 0
+	for name, data := range entries {
+		if id, ok := strings.CutPrefix(name, stateDB); ok {
+			err = db.Put(strings.TrimSuffix(id, ".json"), json.RawMessage(data))
+			records++
+		} else if stateLineFiles[name] {
+			var n int
+			n, err = mergeLines(files[name], data)
+			merged += n
+		} else {
+			err = os.WriteFile(files[name], data, 0o600)
+		}
+		if err != nil {
+			fmt.Println("\033[31m❌ Importing state failed:\033[0m", name, err)
+			return 1
+		}
+	}
+	fmt.Printf("✅ Imported \033[36m%d\033[0m records and %d new baseline and audit log lines\n", records, merged)
+	return 0
+}
+
+// readState reads and checks a bundle. Entry names are validated, so a
+// crafted bundle can't write outside the configured paths.
+func readState(path string) (map[string][]byte, *stateManifest, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer f.Close()
+	gz, err := gzip.NewReader(f)
+	if err != nil {
+		return nil, nil, err
+	}
+	tr := tar.NewReader(gz)
+	var m *stateManifest
+	entries := map[string][]byte{}
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, nil, err
+		}
+		if hdr.Typeflag != tar.TypeReg {
+			continue
+		}
+		data, err := io.ReadAll(tr)
+		if err != nil {
+			return nil, nil, err
+		}
+		switch name := hdr.Name; {
+		case name == stateManifestName:
+			m = &stateManifest{}
+			if err := json.Unmarshal(data, m); err != nil {
+				return nil, nil, fmt.Errorf("%s: %w", name, err)
+			}
+			if m.Format != stateFormat {
+				return nil, nil, errors.New("not a nostr-exif-scan state file")
+			}
+			if m.Version > stateVersion {
+				return nil, nil, fmt.Errorf("state format version %d is newer than this build supports (%d)", m.Version, stateVersion)
+			}
+		case strings.HasPrefix(name, stateDB):
+			id := strings.TrimPrefix(name, stateDB)
+			if !strings.HasSuffix(id, ".json") || strings.ContainsAny(id, `/\`) || strings.HasPrefix(id, ".") {
+				return nil, nil, fmt.Errorf("invalid entry %q", name)
+			}
+			entries[name] = data
+		case name == stateBaseline || name == stateAuditLog || name == stateDMState:
+			entries[name] = data
+		default:
+			return nil, nil, fmt.Errorf("unknown entry %q", name)
+		}
+	}
+	if m == nil {
+		return nil, nil, errors.New("missing " + stateManifestName)
+	}
+	return entries, m, nil
+}
+
+// mergeLines appends the lines of data that path doesn't have yet and
+// returns how many it added.
+func mergeLines(path string, data []byte) (int, error) {
+	have := map[string]bool{}
+	cur, err := os.ReadFile(path)
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return 0, err
+	}
+	for _, line := range strings.Split(string(cur), "\n") {
+		have[line] = true
+	}
+	var add []string
+	sc := bufio.NewScanner(bytes.NewReader(data))
+	sc.Buffer(nil, 1<<20)
+	for sc.Scan() {
+		if line := sc.Text(); line != "" && !have[line] {
+			have[line] = true
+			add = append(add, line)
+		}
+	}
+	if err := sc.Err(); err != nil || len(add) == 0 {
+		return 0, err
+	}
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0o644)
+	if err != nil {
+		return 0, err
+	}
+	prefix := ""
+	if len(cur) > 0 && cur[len(cur)-1] != '\n' {
+		prefix = "\n"
+	}
+	if _, err := f.WriteString(prefix + strings.Join(add, "\n") + "\n"); err != nil {
+		f.Close()
+		return 0, err
+	}
+	return len(add), f.Close()
+}
@@ -0,0 +1,44 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"strconv"
+
+	"nostr-exif-scan/pkg/exifscan"
+)
+
+// threadsValue is a worker count between 1 and exifscan.MaxThreads, or
+// "auto" for exifscan.AutoThreads.
+type threadsValue int
+
+func (v *threadsValue) String() string {
+	if *v == exifscan.AutoThreads {
+		return "auto"
+	}
+	return strconv.Itoa(int(*v))
+}
+
+func (v *threadsValue) Set(s string) error {
+	if s == "auto" {
+		*v = exifscan.AutoThreads
+		return nil
+	}
+	n, err := strconv.Atoi(s)
+	if err != nil || n < 1 || n > exifscan.MaxThreads {
+		return fmt.Errorf("must be between 1 and %d, or auto", exifscan.MaxThreads)
+	}
+	*v = threadsValue(n)
+	return nil
+}
+
+// threadsFlag defaults to 8 workers; usage should describe what they do.
+func threadsFlag(fs *flag.FlagSet, usage string) *int {
+	v := threadsValue(8)
+	fs.Var(&v, "threads", fmt.Sprintf("%s (max %d), or auto to adapt to latency, errors and per-host rate limits", usage, exifscan.MaxThreads))
+	return (*int)(&v)
+}
+
+func rpsFlag(fs *flag.FlagSet) *float64 {
+	return fs.Float64("rps", 0, "Cap image requests per second across all workers, on top of the per-host limits; 0 disables the cap")
+}
@@ -0,0 +1,46 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"time"
+
+	"nostr-exif-scan/pkg/exifscan"
+)
+
+func sinceFlagFor(fs *flag.FlagSet) *string {
+	return fs.String("since", "", "Only fetch events after this time: RFC3339, a date, or an age like 90d, 6mo or 1y")
+}
+
+func untilFlagFor(fs *flag.FlagSet) *string {
+	return fs.String("until", "", "Only fetch events before this time: RFC3339, a date, now, or an age like 30d")
+}
+
+// timeRange parses --since and --until, rejecting values it can't read
+// and ranges ending before they start.
+func timeRange(since, until string) (s, u time.Time, err error) {
+	now := time.Now()
+	if s, err = exifscan.ParseTime(since, now); err != nil {
+		return s, u, fmt.Errorf("--since: %w", err)
+	}
+	if u, err = exifscan.ParseTime(until, now); err != nil {
+		return s, u, fmt.Errorf("--until: %w", err)
+	}
+	if !s.IsZero() && !u.IsZero() && u.Before(s) {
+		return s, u, fmt.Errorf("--until %s is before --since %s", until, since)
+	}
+	return s, u, nil
+}
+
+func tzFlag(fs *flag.FlagSet) *string {
+	return fs.String("tz", "UTC", "Time zone to show post dates and EXIF times in: UTC, local or an IANA name like Europe/Paris")
+}
+
+// setTimezone applies --tz, reporting an unknown zone.
+func setTimezone(name string) bool {
+	if err := exifscan.SetTimezone(name); err != nil {
+		fmt.Println("\033[31m❌ Invalid --tz:\033[0m", err)
+		return false
+	}
+	return true
+}
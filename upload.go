@@ -0,0 +1,121 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/nbd-wtf/go-nostr"
+
+	"nostr-exif-scan/internal/media"
+	"nostr-exif-scan/internal/publish"
+	"nostr-exif-scan/internal/report"
+	"nostr-exif-scan/pkg/exifscan"
+)
+
+func mediaFlags(fs *flag.FlagSet) (blossom, nip96 *string) {
+	blossom = fs.String("blossom", "", "Blossom server to upload to (default: your kind 10063 list, then your kind 10096 list)")
+	nip96 = fs.String("nip96", "", "NIP-96 server to upload to instead of a Blossom server")
+	return blossom, nip96
+}
+
+// uploadReport puts a written report on --blossom, or else on the first
+// server of the operator's BUD-03 list, and returns its URL.
+func uploadReport(ctx context.Context, relays []string, kr nostr.Keyer, server, path string, data []byte) (string, error) {
+	pool := nostr.NewSimplePool(ctx)
+	if server == "" {
+		pubkey, err := kr.GetPublicKey(ctx)
+		if err != nil {
+			return "", err
+		}
+		servers := media.BlossomServers(ctx, pool, publish.OutboxRelays(ctx, pool, relays, pubkey), pubkey)
+		if len(servers) == 0 {
+			return "", errors.New("no Blossom server: publish a kind 10063 server list or pass --blossom")
+		}
+		server = servers[0]
+	}
+	contentType := report.ContentType(path)
+	if ext := filepath.Ext(path); ext == ".age" || ext == ".nip44" {
+		contentType = "application/octet-stream"
+	}
+	up := &media.Blossom{Server: server, Signer: kr, Client: &http.Client{Timeout: time.Minute}}
+	blob, err := up.Upload(ctx, data, contentType)
+	if err != nil {
+		return "", err
+	}
+	return blob.URL, nil
+}
+
+// runUpload uploads a local image to the user's media server, refusing
+// to upload leaking images unless --strip cleans them first.
+func runUpload(args []string) int {
+	fs := newFlagSet("upload")
+	strip := fs.Bool("strip", false, "Remove all metadata before uploading")
+	blossomServer, nip96Server := mediaFlags(fs)
+	signWith := signerFlag(fs)
+	configPath := configFlag(fs)
+	fs.Usage = func() {
+		fmt.Fprintf(os.Stderr, "Usage of %s upload:\n", os.Args[0])
+		fmt.Fprintf(os.Stderr, "  %s upload [--strip] [--blossom URL | --nip96 URL] image.jpg\n\n", os.Args[0])
+		fs.PrintDefaults()
+	}
+	parseFlags(fs, args)
+	if fs.NArg() != 1 {
+		fs.Usage()
+		return 1
+	}
+	cfg, err := loadConfig(*configPath)
+	if err != nil {
+		fmt.Println("\033[31m❌ Cannot load config:\033[0m", err)
+		return 1
+	}
+
+	path := fs.Arg(0)
+	data, err := os.ReadFile(path)
+	if err != nil {
+		fmt.Println("\033[31m❌ Cannot read image:\033[0m", err)
+		return 1
+	}
+	if *strip {
+		if data, err = exifscan.Strip(data); err != nil {
+			fmt.Println("\033[31m❌ Cannot strip metadata:\033[0m", err)
+			return 1
+		}
+		fmt.Println("🧼 Metadata removed")
+	} else if r := exifscan.ScanBytes(data); r.Sensitive() {
+		fmt.Printf("🚨 \033[31mLEAKS: %s\033[0m: %s\n", strings.Join(r.Categories(), ", "), path)
+		fmt.Println("Not uploading; add --strip to remove the metadata first.")
+		return 1
+	}
+
+	ctx := context.Background()
+	pool := nostr.NewSimplePool(ctx)
+	kr, err := loadSigner(ctx, pool, *signWith, cfg.Signer)
+	if err != nil {
+		fmt.Println("\033[31m❌ Cannot load signer:\033[0m", err)
+		return 1
+	}
+	relays := loadRelays("relays.txt")
+	if pubkey, err := kr.GetPublicKey(ctx); err == nil {
+		relays = publish.OutboxRelays(ctx, pool, relays, pubkey)
+	}
+	uploader, server, err := media.Pick(ctx, pool, relays, kr, *blossomServer, *nip96Server)
+	if err != nil {
+		fmt.Println("\033[31m❌", err, "\033[0m")
+		return 1
+	}
+	blob, err := uploader.Upload(ctx, data, http.DetectContentType(data))
+	if err != nil {
+		fmt.Println("\033[31m❌ Upload failed:\033[0m", err)
+		return 1
+	}
+	fmt.Printf("☁️  Uploaded to \033[36m%s\033[0m\n", server)
+	fmt.Println(blob.URL)
+	return 0
+}
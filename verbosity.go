@@ -0,0 +1,78 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"strconv"
+	"time"
+
+	"nostr-exif-scan/pkg/exifscan"
+)
+
+// verbosity is how much printResult and the relay trace print.
+type verbosity int
+
+const (
+	// verboseTags (-v) prints the sensitive tag values.
+	verboseTags verbosity = 1 + iota
+	// verboseExif (-vv) also dumps every EXIF field and HTTP details.
+	verboseExif
+	// verboseRelays (-vvv) also traces the relay protocol.
+	verboseRelays
+)
+
+// levelFlag sets a verbosity: -v raises it by one each time it is given
+// (or takes a number, -v=2), -vv and -vvv set their level.
+type levelFlag struct {
+	v     *verbosity
+	level verbosity
+}
+
+func (f levelFlag) String() string {
+	if f.v == nil {
+		return "0"
+	}
+	return strconv.Itoa(int(*f.v))
+}
+
+func (f levelFlag) Set(s string) error {
+	if n, err := strconv.Atoi(s); err == nil && f.level == verboseTags {
+		*f.v = verbosity(n)
+		return nil
+	}
+	on, err := strconv.ParseBool(s)
+	switch {
+	case err != nil:
+		return errors.New("want true, false or a level")
+	case !on:
+		*f.v = 0
+	case f.level == verboseTags:
+		*f.v++
+	default:
+		*f.v = max(*f.v, f.level)
+	}
+	return nil
+}
+
+func (levelFlag) IsBoolFlag() bool { return true }
+
+func verbosityFlag(fs *flag.FlagSet) *verbosity {
+	v := new(verbosity)
+	fs.Var(levelFlag{v, verboseTags}, "v", "Verbose output: show the sensitive EXIF values")
+	fs.Var(levelFlag{v, verboseExif}, "vv", "More verbose: also dump every EXIF field and HTTP response details")
+	fs.Var(levelFlag{v, verboseRelays}, "vvv", "Most verbose: also trace the relay protocol")
+	return v
+}
+
+// traceRelays prints the relay protocol at -vvv.
+func traceRelays(h *exifscan.Hooks, v verbosity) {
+	if v < verboseRelays {
+		return
+	}
+	h.OnRelayTrace = func(relay, msg string) {
+		if relay == "" {
+			relay = "relays"
+		}
+		console.printf("    📡 %s \033[90m%s %s\033[0m\n", time.Now().Format("15:04:05.000"), relay, msg)
+	}
+}
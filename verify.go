@@ -0,0 +1,295 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"os/signal"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/nbd-wtf/go-nostr"
+	"github.com/nbd-wtf/go-nostr/nip19"
+
+	"nostr-exif-scan/internal/report"
+	"nostr-exif-scan/internal/store"
+	"nostr-exif-scan/pkg/exifscan"
+)
+
+// storedRun is what verify needs of a daemon run or an API job record.
+type storedRun struct {
+	Started    time.Time          `json:"started"`
+	FinishedAt time.Time          `json:"finished_at"`
+	Findings   *exifscan.Findings `json:"findings"`
+}
+
+func (r storedRun) at() time.Time {
+	if r.Started.IsZero() {
+		return r.FinishedAt
+	}
+	return r.Started
+}
+
+// runVerify re-checks the images a stored run flagged and reports which
+// leaks were deleted, replaced or cleaned since.
+func runVerify(args []string) int {
+	fs := newFlagSet("verify")
+	fromRun := fs.String("from-run", "", "ID of the daemon run or API job whose flagged images to re-check; lists the stored runs when empty")
+	dbDir := fs.String("db", "daemon-db", "Results database directory of the daemon or the API server")
+	auditLog := fs.String("audit-log", "remediation-log.jsonl", "Remediation audit log telling which posts were replaced")
+	threads := threadsFlag(fs, "Number of parallel workers")
+	reportPath := fs.String("report", "", "Write an HTML (.html) or Markdown (.md) progress report to this file")
+	linkTmpl := linkTemplateFlag(fs)
+	tz := tzFlag(fs)
+	coordFmt := coordFormatFlag(fs)
+	signedReport := fs.String("signed-report", "", "Check the signature of a report written with --sign-report instead, and print who signed it, when and with what parameters")
+	auditor := fs.String("auditor", "", "With --signed-report, npub or hex key the report must be signed by")
+	parseFlags(fs, args)
+	if !setLinkTemplate(*linkTmpl) {
+		return 1
+	}
+	if !setTimezone(*tz) || !setCoordFormat(*coordFmt) {
+		return 1
+	}
+	if *signedReport != "" {
+		return verifyReport(*signedReport, *auditor)
+	}
+
+	db, err := store.Open(*dbDir)
+	if err != nil {
+		fmt.Println("\033[31m❌ Cannot open the results database:\033[0m", err)
+		return 1
+	}
+	if *fromRun == "" {
+		return listRuns(db)
+	}
+	var run storedRun
+	if err := db.Get(*fromRun, &run); err != nil {
+		fmt.Println("\033[31m❌ Cannot load run:\033[0m", err)
+		return 1
+	}
+	if run.Findings == nil {
+		fmt.Printf("\033[31m❌ %s holds no scan results\033[0m\n", *fromRun)
+		return 1
+	}
+	flagged := run.Findings.Flagged()
+	npub, _ := nip19.EncodePublicKey(run.Findings.Pubkey)
+	if len(flagged) == 0 {
+		fmt.Printf("✅ Run \033[36m%s\033[0m flagged nothing to verify\n", *fromRun)
+		return 0
+	}
+	replaced, err := loadReplacements(*auditLog)
+	if err != nil {
+		fmt.Println("\033[31m❌ Cannot read the audit log:\033[0m", err)
+		return 1
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+
+	relays := loadRelays("relays.txt")
+	fmt.Printf("🔁 Re-checking \033[36m%d\033[0m images flagged by run \033[36m%s\033[0m on %d relays...\n", len(flagged), *fromRun, len(relays))
+	present, deleted, err := postStatus(ctx, relays, run.Findings.Pubkey, flagged)
+	if err != nil {
+		fmt.Println("\033[31m❌ Checking posts failed:\033[0m", err)
+		return 1
+	}
+
+	// Images of posts still up are downloaded again, and so are the clean
+	// copies of posts remediate replaced.
+	var images []exifscan.Image
+	for _, r := range flagged {
+		if r.EventID == "" || present[r.EventID] {
+			images = append(images, exifscan.Image{EventID: r.EventID, URL: r.URL, Source: r.Source})
+		} else if e, ok := replaced[r.EventID+"\x00"+r.URL]; ok && e.NewURL != "" {
+			images = append(images, exifscan.Image{EventID: e.ReplacementID, URL: e.NewURL})
+		}
+	}
+	scanner := exifscan.New(exifscan.Options{Relays: relays, Threads: *threads})
+	rescanned := map[string]*exifscan.ImageResult{}
+	for r := range scanner.ScanImages(ctx, images) {
+		rescanned[r.URL] = r
+	}
+	if ctx.Err() != nil {
+		return 1
+	}
+
+	v := report.Verification{RunID: *fromRun, Npub: npub, RunAt: run.at()}
+	for _, r := range flagged {
+		it := report.Verified{EventID: r.EventID, URL: r.URL}
+		if r.EventID != "" {
+			it.Link = report.EventURL(r.EventID, r.Relays, run.Findings.Pubkey)
+		}
+		e, wasReplaced := replaced[r.EventID+"\x00"+r.URL]
+		switch {
+		case r.EventID != "" && !present[r.EventID] && wasReplaced && e.NewURL != "":
+			it.Status, it.Detail = rescanStatus(rescanned[e.NewURL], report.VerifyReplaced)
+			switch it.Status {
+			case report.VerifyReplaced:
+				it.Detail = "clean copy " + e.NewURL
+			case report.VerifyLeaking:
+				it.Detail = "the replacement " + e.NewURL + " leaks " + it.Detail
+			}
+		case r.EventID != "" && !present[r.EventID]:
+			it.Status, it.Detail = report.VerifyDeleted, "not on any relay"
+			if deleted[r.EventID] {
+				it.Detail = "deletion request published"
+			}
+		default:
+			it.Status, it.Detail = rescanStatus(rescanned[r.URL], report.VerifyCleaned)
+			if it.Status == report.VerifyCleaned && deleted[r.EventID] {
+				it.Detail += "; a deletion request was published but relays still serve the post"
+			}
+		}
+		v.Items = append(v.Items, it)
+	}
+
+	for _, it := range v.Items {
+		icon := "🚨"
+		if it.Fixed() {
+			icon = "✅"
+		}
+		fmt.Printf("%s %-8s \033[36m%s\033[0m", icon, it.Status, it.URL)
+		if it.Detail != "" {
+			fmt.Printf(" (%s)", it.Detail)
+		}
+		fmt.Println()
+	}
+	fmt.Printf("📊 \033[36m%d\033[0m of %d flagged images fixed (%d%%)\n", v.Fixed(), len(v.Items), v.Percent())
+	for _, c := range v.ByStatus() {
+		fmt.Printf("      %-24s %d\n", c.Key, c.Count)
+	}
+
+	if *reportPath != "" {
+		f, err := os.Create(*reportPath)
+		if err == nil {
+			err = report.WriteVerification(f, *reportPath, v)
+			if cerr := f.Close(); err == nil {
+				err = cerr
+			}
+		}
+		if err != nil {
+			fmt.Println("\033[31m❌ Writing report failed:\033[0m", err)
+			return 1
+		}
+		fmt.Printf("📝 Report written to \033[36m%s\033[0m\n", *reportPath)
+	}
+	return 0
+}
+
+// rescanStatus tells how a re-downloaded image stands; clean is the
+// status of an image that no longer leaks.
+func rescanStatus(r *exifscan.ImageResult, clean string) (status, detail string) {
+	switch {
+	case r == nil:
+		return report.VerifyUnknown, "not checked"
+	case r.Dead != "":
+		return report.VerifyGone, r.Error
+	case r.Err != nil:
+		return report.VerifyUnknown, r.Error
+	case r.Sensitive():
+		return report.VerifyLeaking, strings.Join(r.Categories(), ", ")
+	}
+	return clean, "no sensitive metadata left"
+}
+
+// postStatus asks the relays which of the flagged posts they still serve
+// and which ones pubkey requested to delete (NIP-09).
+func postStatus(ctx context.Context, relays []string, pubkey string, flagged []*exifscan.ImageResult) (present, deleted map[string]bool, err error) {
+	var ids []string
+	seen := map[string]bool{}
+	for _, r := range flagged {
+		if r.EventID != "" && !seen[r.EventID] {
+			seen[r.EventID] = true
+			ids = append(ids, r.EventID)
+		}
+	}
+	present, deleted = map[string]bool{}, map[string]bool{}
+	if len(ids) == 0 {
+		return present, deleted, nil
+	}
+	ctx, cancel := context.WithTimeout(ctx, 30*time.Second)
+	defer cancel()
+	pool := nostr.NewSimplePool(ctx)
+	var up []string
+	for _, url := range relays {
+		if _, err := pool.EnsureRelay(url); err == nil {
+			up = append(up, url)
+		}
+	}
+	// Every post would look deleted without a relay to ask.
+	if len(up) == 0 {
+		return nil, nil, errors.New("no relay reachable")
+	}
+	for ie := range pool.FetchMany(ctx, up, nostr.Filter{IDs: ids}) {
+		present[ie.ID] = true
+	}
+	filter := nostr.Filter{Kinds: []int{nostr.KindDeletion}, Authors: []string{pubkey}, Tags: nostr.TagMap{"e": ids}}
+	for ie := range pool.FetchMany(ctx, up, filter) {
+		for _, tag := range ie.Tags {
+			if len(tag) >= 2 && tag[0] == "e" {
+				deleted[tag[1]] = true
+			}
+		}
+	}
+	return present, deleted, nil
+}
+
+// loadReplacements reads the remediation audit log, keyed by original
+// post and image URL. A missing log means nothing was replaced.
+func loadReplacements(path string) (map[string]auditEntry, error) {
+	out := map[string]auditEntry{}
+	f, err := os.Open(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return out, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	sc := bufio.NewScanner(f)
+	sc.Buffer(nil, 1<<20)
+	for sc.Scan() {
+		var e auditEntry
+		if err := json.Unmarshal(sc.Bytes(), &e); err != nil {
+			continue
+		}
+		out[e.EventID+"\x00"+e.OldURL] = e
+	}
+	return out, sc.Err()
+}
+
+// listRuns prints the stored runs verify can start from, newest first.
+func listRuns(db *store.Store) int {
+	ids, err := db.IDs()
+	if err != nil {
+		fmt.Println("\033[31m❌ Cannot list runs:\033[0m", err)
+		return 1
+	}
+	type entry struct {
+		id  string
+		run storedRun
+	}
+	var runs []entry
+	for _, id := range ids {
+		var run storedRun
+		if db.Get(id, &run) != nil || run.Findings == nil {
+			continue
+		}
+		runs = append(runs, entry{id, run})
+	}
+	if len(runs) == 0 {
+		fmt.Printf("ℹ️  No stored runs in \033[36m%s\033[0m\n", db.Dir())
+		return 1
+	}
+	sort.Slice(runs, func(i, j int) bool { return runs[i].run.at().After(runs[j].run.at()) })
+	fmt.Println("Pass one of these to --from-run:")
+	for _, e := range runs {
+		fmt.Printf("  %s  %s  %d flagged\n", e.id, e.run.at().Format(time.RFC3339), len(e.run.Findings.Flagged()))
+	}
+	return 0
+}
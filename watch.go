@@ -0,0 +1,235 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"os"
+	"os/signal"
+	"sort"
+	"strings"
+
+	"github.com/nbd-wtf/go-nostr"
+	"github.com/nbd-wtf/go-nostr/nip19"
+
+	"nostr-exif-scan/internal/dm"
+	"nostr-exif-scan/internal/health"
+	"nostr-exif-scan/internal/metrics"
+	"nostr-exif-scan/internal/notify"
+	"nostr-exif-scan/internal/report"
+	"nostr-exif-scan/pkg/exifscan"
+)
+
+func runWatch(args []string) int {
+	fs := newFlagSet("watch")
+	npubs := fs.String("npub", "", "Comma separated npubs to watch")
+	hashtags := fs.String("tag", "", "Comma separated hashtags to watch, from any author (e.g. foodpics)")
+	threads := threadsFlag(fs, "Number of parallel workers")
+	rps := rpsFlag(fs)
+	metricsListen := fs.String("metrics-listen", "", "Serve Prometheus metrics on this address (e.g. :9090)")
+	healthListen := healthFlag(fs)
+	pprofListen := pprofFlag(fs)
+	verbose := verbosityFlag(fs)
+	dumpAllTags := fs.Bool("dump-all-tags", false, "List every decoded EXIF tag of every image with metadata, not just the sensitive ones")
+	baselinePath := baselineFlag(fs)
+	filters := filterFlags(fs)
+	includeCW := fs.Bool("include-sensitive-content", false, "Also download the images of notes with a NIP-36 content warning, which are skipped by default")
+	dmFlag := fs.Bool("dm", false, "DM authors of leaking posts (NIP-17)")
+	signWith := signerFlag(fs)
+	configPath := configFlag(fs)
+	linkTmpl := linkTemplateFlag(fs)
+	tz := tzFlag(fs)
+	coordFmt := coordFormatFlag(fs)
+	correlateDB := correlateFlag(fs)
+	parseFlags(fs, args)
+	if !setLinkTemplate(*linkTmpl) {
+		return 1
+	}
+	if !setTimezone(*tz) || !setCoordFormat(*coordFmt) {
+		return 1
+	}
+
+	if *npubs == "" && *hashtags == "" {
+		fmt.Println("\033[31m❌ Please provide --npub or --tag\033[0m")
+		return 1
+	}
+	var authors, topics []string
+	for _, t := range strings.Split(*hashtags, ",") {
+		// t tags are lowercase by convention (NIP-24).
+		if t = strings.ToLower(strings.TrimPrefix(strings.TrimSpace(t), "#")); t != "" {
+			topics = append(topics, t)
+		}
+	}
+	for _, npub := range strings.Split(*npubs, ",") {
+		if strings.TrimSpace(npub) == "" {
+			continue
+		}
+		pubkey, err := exifscan.DecodePubkey(strings.TrimSpace(npub))
+		if err != nil {
+			fmt.Println("\033[31m❌ Invalid npub:\033[0m", npub, err)
+			return 1
+		}
+		authors = append(authors, pubkey)
+	}
+	cfg, err := loadConfig(*configPath)
+	if err != nil {
+		fmt.Println("\033[31m❌ Cannot load config:\033[0m", err)
+		return 1
+	}
+	base, err := loadBaseline(*baselinePath)
+	if err != nil {
+		fmt.Println("\033[31m❌ Cannot load baseline:\033[0m", err)
+		return 1
+	}
+	filter, err := filters.parse()
+	if err != nil {
+		fmt.Println("\033[31m❌", err, "\033[0m")
+		return 1
+	}
+	notifiers := notify.FromConfig(cfg.Notify)
+	photos, err := openPhotoIndex(*correlateDB)
+	if err != nil {
+		fmt.Println("\033[31m❌ Cannot open results database:\033[0m", err)
+		return 1
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+	servePprof(*pprofListen)
+
+	opts := exifscan.Options{
+		Relays:              loadRelays("relays.txt"),
+		Threads:             *threads,
+		RateLimit:           exifscan.NewRateLimit(*rps),
+		KeepData:            *verbose >= verboseExif,
+		AllTags:             *dumpAllTags,
+		SkipContentWarnings: !*includeCW,
+		PerceptualHash:      photos != nil,
+	}
+	traceRelays(&opts.Hooks, *verbose)
+	var sender *dm.Sender
+	if *dmFlag {
+		pool := nostr.NewSimplePool(ctx)
+		kr, err := loadSigner(ctx, pool, *signWith, cfg.Signer)
+		if err != nil {
+			fmt.Println("\033[31m❌ Cannot load signer:\033[0m", err)
+			return 1
+		}
+		if sender, err = dm.New(cfg.DM, kr, pool, opts.Relays); err != nil {
+			fmt.Println("\033[31m❌ Cannot set up DMs:\033[0m", err)
+			return 1
+		}
+	}
+	// Metrics and health share one server when given the same address.
+	muxes := map[string]*http.ServeMux{}
+	muxFor := func(addr string) *http.ServeMux {
+		if muxes[addr] == nil {
+			muxes[addr] = http.NewServeMux()
+		}
+		return muxes[addr]
+	}
+	if *metricsListen != "" {
+		m := metrics.New()
+		opts.Hooks = m.Hooks(opts.Hooks)
+		muxFor(*metricsListen).Handle("GET /metrics", m.Handler())
+	}
+	if *healthListen != "" {
+		mon := health.New("watch", opts.Relays)
+		opts.Hooks = mon.Hooks(opts.Hooks)
+		mon.SetDetail("authors", len(authors))
+		mon.SetDetail("hashtags", len(topics))
+		mon.Register(muxFor(*healthListen))
+		mon.SetReady(true)
+	}
+	for addr, mux := range muxes {
+		go func() {
+			if err := http.ListenAndServe(addr, mux); err != nil {
+				fmt.Println("\033[31m❌ HTTP server failed:\033[0m", err)
+			}
+		}()
+	}
+	scanner := exifscan.New(opts)
+
+	watchFilter := nostr.Filter{Authors: authors}
+	if len(topics) > 0 {
+		watchFilter.Tags = nostr.TagMap{"t": topics}
+		fmt.Printf("👀 Watching \033[36m#%s\033[0m", strings.Join(topics, "\033[0m, \033[36m#"))
+		if len(authors) > 0 {
+			fmt.Printf(" from \033[36m%d\033[0m authors", len(authors))
+		}
+		fmt.Printf(" on \033[36m%d\033[0m relays (%d notifiers)\n", len(opts.Relays), len(notifiers))
+	} else {
+		fmt.Printf("👀 Watching \033[36m%d\033[0m authors on \033[36m%d\033[0m relays (%d notifiers)\n", len(authors), len(opts.Relays), len(notifiers))
+	}
+	// alerted keeps a note linking the same image twice, e.g. through a
+	// mirror, from alerting twice.
+	alerted := map[string]bool{}
+	// leaks counts the distinct leaks per author, for the summary a
+	// hashtag watch prints on exit.
+	leaks := map[string]int{}
+	if len(topics) > 0 {
+		defer func() { printLeaksByAuthor(leaks) }()
+	}
+	for r := range scanner.Watch(ctx, watchFilter) {
+		base.apply(r)
+		filter.apply(r)
+		printResult(os.Stdout, r, *verbose)
+		r.Discard()
+		if photos != nil && r.Event != nil {
+			links, err := photos.add(r.Event.PubKey, []*exifscan.ImageResult{r})
+			if err != nil {
+				fmt.Println("    ⚠️  Cannot record the photo hash:", err)
+			}
+			printLinkedPhotos(links)
+		}
+		if !r.Sensitive() || alerted[r.Fingerprint] {
+			continue
+		}
+		alerted[r.Fingerprint] = true
+		if r.Event != nil {
+			leaks[r.Event.PubKey]++
+			if len(topics) > 0 {
+				npub, _ := nip19.EncodePublicKey(r.Event.PubKey)
+				fmt.Printf("    👤 Author: \033[36m%s\033[0m (%d leaks so far)\n", npub, leaks[r.Event.PubKey])
+			}
+		}
+		if sender != nil && r.Event != nil {
+			sendDM(ctx, sender, r.Event.PubKey, []*exifscan.ImageResult{r})
+		}
+		if len(notifiers) == 0 {
+			continue
+		}
+		alert := notify.Alert{EventURL: report.ResultURL(r), Image: r}
+		if r.Event != nil {
+			alert.Author, _ = nip19.EncodePublicKey(r.Event.PubKey)
+		}
+		if err := notify.All(ctx, notifiers, alert); err != nil {
+			fmt.Println("    ❌ Notification failed:", err)
+		}
+	}
+	return 0
+}
+
+// printLeaksByAuthor lists the authors a hashtag watch caught leaking,
+// the most leaks first.
+func printLeaksByAuthor(leaks map[string]int) {
+	if len(leaks) == 0 {
+		fmt.Println("\n✅ No leaks found while watching")
+		return
+	}
+	authors := make([]string, 0, len(leaks))
+	for pk := range leaks {
+		authors = append(authors, pk)
+	}
+	sort.Slice(authors, func(i, j int) bool {
+		if leaks[authors[i]] != leaks[authors[j]] {
+			return leaks[authors[i]] > leaks[authors[j]]
+		}
+		return authors[i] < authors[j]
+	})
+	fmt.Printf("\n📋 Leaks by author (%d authors):\n", len(authors))
+	for _, pk := range authors {
+		npub, _ := nip19.EncodePublicKey(pk)
+		fmt.Printf("  %s  %d\n", npub, leaks[pk])
+	}
+}
@@ -0,0 +1,99 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"net/http"
+	"os"
+	"os/signal"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"nostr-exif-scan/internal/health"
+	"nostr-exif-scan/internal/queue"
+	"nostr-exif-scan/pkg/exifscan"
+)
+
+func queueFlag(fs *flag.FlagSet) *string {
+	return fs.String("queue", "", "Shared task queue for image scans: redis://host:6379/0 or nats://host:4222")
+}
+
+func runWorker(args []string) int {
+	fs := newFlagSet("worker")
+	queueURL := queueFlag(fs)
+	threads := fs.Int("threads", 8, "Number of parallel image downloads (max 32)")
+	rps := rpsFlag(fs)
+	verbose := verbosityFlag(fs)
+	healthListen := healthFlag(fs)
+	pprofListen := pprofFlag(fs)
+	parseFlags(fs, args)
+
+	if *queueURL == "" {
+		fmt.Println("\033[31m❌ Please provide --queue\033[0m")
+		return 1
+	}
+	if *threads < 1 || *threads > exifscan.MaxThreads {
+		fmt.Printf("\033[31m❌ --threads must be between 1 and %d\033[0m\n", exifscan.MaxThreads)
+		return 1
+	}
+	backend, err := queue.Open(*queueURL)
+	if err != nil {
+		fmt.Println("\033[31m❌ Cannot connect to the queue:\033[0m", err)
+		return 1
+	}
+	defer backend.Close()
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+	servePprof(*pprofListen)
+
+	var busy atomic.Int64
+	opts := exifscan.Options{Threads: *threads, RateLimit: exifscan.NewRateLimit(*rps), KeepData: *verbose >= verboseExif}
+	if *healthListen != "" {
+		mon := health.New("worker", nil)
+		opts.Hooks = mon.Hooks(opts.Hooks)
+		mon.SetJobs(func() (int, int) { return int(busy.Load()), 0 })
+		mux := http.NewServeMux()
+		mon.Register(mux)
+		mon.SetReady(true)
+		go func() {
+			if err := http.ListenAndServe(*healthListen, mux); err != nil {
+				fmt.Println("\033[31m❌ HTTP server failed:\033[0m", err)
+			}
+		}()
+	}
+	scanner := exifscan.New(opts)
+
+	fmt.Printf("🛠️  Taking image scans from the queue with \033[36m%d\033[0m threads\n", *threads)
+	var wg sync.WaitGroup
+	for range *threads {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for {
+				task, err := backend.Pop(ctx)
+				if ctx.Err() != nil || errors.Is(err, queue.ErrClosed) {
+					return
+				}
+				if err != nil {
+					fmt.Println("\033[31m❌ Queue error:\033[0m", err)
+					time.Sleep(time.Second)
+					continue
+				}
+				busy.Add(1)
+				r := scanner.ScanImage(ctx, task.Image())
+				busy.Add(-1)
+				if err := backend.Reply(ctx, task.Job, r); err != nil {
+					fmt.Println("\033[31m❌ Cannot send result:\033[0m", err)
+				}
+				printResult(os.Stdout, r, *verbose)
+				r.Discard()
+			}
+		}()
+	}
+	wg.Wait()
+	return 0
+}